@@ -0,0 +1,97 @@
+// Package gateway configures the REST gateway in front of the racing service.
+package gateway
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// marshaler renders responses with every field, including those with their
+// zero value, named as they are in the protos (e.g. advertised_start_time),
+// and with enums as their names, so clients see the same fields in every
+// response. Requests are accepted with either proto or camelCase names.
+var marshaler = &runtime.HTTPBodyMarshaler{
+	Marshaler: &runtime.JSONPb{
+		MarshalOptions: protojson.MarshalOptions{
+			UseProtoNames:   true,
+			EmitUnpopulated: true,
+			UseEnumNumbers:  false,
+		},
+		UnmarshalOptions: protojson.UnmarshalOptions{
+			DiscardUnknown: true,
+		},
+	},
+}
+
+// varyHeaders are the request headers responses differ by, so HTTP caches
+// in front of the gateway keep a response per time zone, jurisdiction, brand
+// and user, rather than serving one brand's races, or one user's watchlist,
+// to another.
+var varyHeaders = []string{"Accept-Timezone", "X-Jurisdiction", "X-Brand", "X-User-Id"}
+
+// NewServeMux creates a gateway mux that renders JSON as documented, and
+// forwards the headers the racing service reads, with the given options.
+func NewServeMux(opts ...runtime.ServeMuxOption) *runtime.ServeMux {
+	return runtime.NewServeMux(append([]runtime.ServeMuxOption{
+		runtime.WithMarshalerOption(runtime.MIMEWildcard, marshaler),
+		runtime.WithIncomingHeaderMatcher(headerMatcher),
+		runtime.WithMetadata(timeZoneParam),
+		runtime.WithForwardResponseOption(vary),
+	}, opts...)...)
+}
+
+// vary marks responses as differing by the varyHeaders.
+func vary(_ context.Context, w http.ResponseWriter, _ proto.Message) error {
+	for _, header := range varyHeaders {
+		w.Header().Add("Vary", header)
+	}
+
+	return nil
+}
+
+// headerMatcher forwards the Idempotency-Key, X-Fake-Now, Accept-Timezone,
+// X-Jurisdiction, X-Brand and X-User-Id headers to the racing service, as
+// well as the headers forwarded by default. The user is only taken from
+// X-User-Id, which the authenticating proxy in front of the gateway sets, so
+// callers can't name one in a Grpc-Metadata- header it passes on.
+func headerMatcher(key string) (string, bool) {
+	switch {
+	case strings.EqualFold(key, "X-User-Id"):
+		return "x-user-id", true
+	case strings.EqualFold(key, runtime.MetadataHeaderPrefix+"X-User-Id"):
+		return "", false
+	case strings.EqualFold(key, "Idempotency-Key"):
+		return "idempotency-key", true
+	case strings.EqualFold(key, "X-Fake-Now"):
+		return "x-fake-now", true
+	case strings.EqualFold(key, "Accept-Timezone"):
+		return "accept-timezone", true
+	case strings.EqualFold(key, "X-Jurisdiction"):
+		return "x-jurisdiction", true
+	case strings.EqualFold(key, "X-Brand"):
+		return "x-brand", true
+	}
+
+	return runtime.DefaultHeaderMatcher(key)
+}
+
+// timeZoneParam forwards the tz query parameter to the racing service as
+// though it were sent as the Accept-Timezone header, which takes precedence
+// over it when both are sent.
+func timeZoneParam(_ context.Context, r *http.Request) metadata.MD {
+	if r.Header.Get("Accept-Timezone") != "" {
+		return nil
+	}
+
+	if tz := r.URL.Query().Get("tz"); tz != "" {
+		return metadata.Pairs("accept-timezone", tz)
+	}
+
+	return nil
+}
@@ -0,0 +1,155 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"git.neds.sh/matty/entain/api/proto/racing"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+func TestMarshalerEmitsZeroValuesWithProtoNames(t *testing.T) {
+	body, err := marshaler.Marshal(&racing.Race{Id: 1, Name: "Flemington"})
+	if err != nil {
+		t.Fatalf("Marshal() error = %s", err)
+	}
+
+	var got map[string]interface{}
+	if err := json.Unmarshal(body, &got); err != nil {
+		t.Fatalf("unmarshalling %s: %s", body, err)
+	}
+
+	want := map[string]interface{}{
+		"id":                    "1",
+		"name":                  "Flemington",
+		"visible":               false,
+		"seconds_to_start":      "0",
+		"status":                "UNSPECIFIED",
+		"advertised_start_time": nil,
+	}
+
+	for field, value := range want {
+		if got[field] != value {
+			t.Errorf("%s = %#v, want %#v", field, got[field], value)
+		}
+	}
+
+	fields := (&racing.Race{}).ProtoReflect().Descriptor().Fields()
+
+	if len(got) != fields.Len() {
+		t.Errorf("got %d fields, want all %d", len(got), fields.Len())
+	}
+
+	for field := range got {
+		if fields.ByName(protoreflect.Name(field)) == nil {
+			t.Errorf("field %s isn't named as in the proto", field)
+		}
+	}
+}
+
+func TestMarshalerRendersEmptyListsAsArrays(t *testing.T) {
+	for _, resp := range []proto.Message{
+		&racing.ListRacesResponse{},
+		&racing.ListArchivedRacesResponse{},
+		&racing.ListMarketsResponse{},
+		&racing.ListSelectionsResponse{},
+		&racing.ListDeliveriesResponse{},
+		&racing.ListStatusHistoryResponse{},
+		&racing.SearchRacesResponse{},
+	} {
+		name := resp.ProtoReflect().Descriptor().Name()
+
+		body, err := marshaler.Marshal(resp)
+		if err != nil {
+			t.Fatalf("Marshal(%s) error = %s", name, err)
+		}
+
+		var got map[string]interface{}
+		if err := json.Unmarshal(body, &got); err != nil {
+			t.Fatalf("unmarshalling %s: %s", body, err)
+		}
+
+		fields := resp.ProtoReflect().Descriptor().Fields()
+
+		for i := 0; i < fields.Len(); i++ {
+			if !fields.Get(i).IsList() {
+				continue
+			}
+
+			field := string(fields.Get(i).Name())
+			if list, ok := got[field].([]interface{}); !ok || len(list) != 0 {
+				t.Errorf("%s.%s = %#v, want []", name, field, got[field])
+			}
+		}
+	}
+}
+
+func TestMarshalerAcceptsEitherName(t *testing.T) {
+	for _, body := range []string{
+		`{"filter": {"meeting_ids": ["1"]}, "page_size": 2}`,
+		`{"filter": {"meetingIds": ["1"]}, "pageSize": 2}`,
+	} {
+		var got racing.ListRacesRequest
+		if err := marshaler.Unmarshal([]byte(body), &got); err != nil {
+			t.Fatalf("Unmarshal(%s) error = %s", body, err)
+		}
+
+		if !reflect.DeepEqual(got.GetFilter().GetMeetingIds(), []int64{1}) || got.PageSize != 2 {
+			t.Errorf("Unmarshal(%s) = %v", body, &got)
+		}
+	}
+}
+
+func TestTimeZoneParam(t *testing.T) {
+	tests := []struct {
+		url    string
+		header string
+		want   metadata.MD
+	}{
+		{"/v1/list-races", "", nil},
+		{"/v1/list-races?tz=Australia/Perth", "", metadata.Pairs("accept-timezone", "Australia/Perth")},
+		{"/v1/list-races?tz=Australia/Perth", "Asia/Hong_Kong", nil},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodPost, tt.url, nil)
+		if tt.header != "" {
+			r.Header.Set("Accept-Timezone", tt.header)
+		}
+
+		if got := timeZoneParam(context.Background(), r); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("timeZoneParam(%s, %q) = %v, want %v", tt.url, tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestHeaderMatcher(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+		ok     bool
+	}{
+		{"Idempotency-Key", "idempotency-key", true},
+		{"X-Fake-Now", "x-fake-now", true},
+		{"Accept-Timezone", "accept-timezone", true},
+		{"X-Jurisdiction", "x-jurisdiction", true},
+		{"X-Brand", "x-brand", true},
+		{"X-User-Id", "x-user-id", true},
+		{"Grpc-Metadata-X-User-Id", "", false},
+		{"grpc-metadata-x-user-id", "", false},
+		{"idempotency-key", "idempotency-key", true},
+		{"X-Unknown", "", false},
+	}
+
+	for _, tt := range tests {
+		if got, ok := headerMatcher(tt.header); got != tt.want || ok != tt.ok {
+			t.Errorf("headerMatcher(%q) = %q, %t, want %q, %t", tt.header, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -6,9 +6,13 @@ import (
 	"log"
 	"net/http"
 
+	"git.neds.sh/matty/entain/api/gateway"
 	"git.neds.sh/matty/entain/api/proto/racing"
-	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
 	"google.golang.org/grpc"
+
+	// Registers the error details the racing service sends, so the gateway
+	// can render them in error responses.
+	_ "google.golang.org/genproto/googleapis/rpc/errdetails"
 )
 
 var (
@@ -29,7 +33,7 @@ func run() error {
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
-	mux := runtime.NewServeMux()
+	mux := gateway.NewServeMux()
 	if err := racing.RegisterRacingHandlerFromEndpoint(
 		ctx,
 		mux,
@@ -0,0 +1,42 @@
+package proto
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+// update rewrites the golden OpenAPI spec with the generated one, for when the
+// API is meant to change:
+//
+//	go test ./proto -update
+var update = flag.Bool("update", false, "rewrite the golden OpenAPI spec with the generated one")
+
+// TestOpenAPIGolden fails when the OpenAPI spec generated from the protos
+// changes, so changes to the REST API, and breaking ones in particular, show
+// up in review as a diff to the golden spec.
+func TestOpenAPIGolden(t *testing.T) {
+	generated, err := ioutil.ReadFile(filepath.Join("racing", "racing.swagger.json"))
+	if err != nil {
+		t.Fatalf("reading generated spec (run go generate ./...): %s", err)
+	}
+
+	path := filepath.Join("testdata", "racing.swagger.golden.json")
+
+	if *update {
+		if err := ioutil.WriteFile(path, generated, 0644); err != nil {
+			t.Fatalf("updating golden spec: %s", err)
+		}
+	}
+
+	golden, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading golden spec (run with -update to create it): %s", err)
+	}
+
+	if !bytes.Equal(generated, golden) {
+		t.Errorf("generated OpenAPI spec differs from %s: check the API change is backwards compatible, then run go test ./proto -update", path)
+	}
+}
@@ -0,0 +1,17 @@
+module git.neds.sh/matty/entain/integration
+
+go 1.16
+
+require (
+	git.neds.sh/matty/entain/api v0.0.0
+	git.neds.sh/matty/entain/racing v0.0.0
+	github.com/grpc-ecosystem/grpc-gateway/v2 v2.3.0
+	github.com/mattn/go-sqlite3 v1.14.6
+	google.golang.org/grpc v1.36.0
+	google.golang.org/protobuf v1.25.1-0.20201208041424-160c7477e0e8
+)
+
+replace (
+	git.neds.sh/matty/entain/api => ../api
+	git.neds.sh/matty/entain/racing => ../racing
+)
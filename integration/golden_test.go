@@ -0,0 +1,277 @@
+package integration
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"git.neds.sh/matty/entain/racing/proto/racing"
+	"google.golang.org/grpc/metadata"
+)
+
+// update rewrites the golden files with the gateway's responses, for when
+// they are meant to change:
+//
+//	go test ./... -run TestGolden -update
+var update = flag.Bool("update", false, "rewrite golden files with the gateway's responses")
+
+// request is a call made to the gateway.
+type request struct {
+	method string
+	path   string
+	body   string
+}
+
+// TestGolden pins the exact JSON the gateway responds with, so changes to the
+// marshaler or protos that would break clients, e.g. renamed fields, enums
+// rendered as numbers or reformatted timestamps, fail the build.
+func TestGolden(t *testing.T) {
+	tests := []struct {
+		name string
+		// setup are made before the request, e.g. to change the fixtures.
+		setup []request
+		// setupRPC is called before the request too, for changes only made
+		// over gRPC.
+		setupRPC func(ctx context.Context, client racing.RacingClient) error
+		// user is who the setup and request are authenticated as, if anyone.
+		user       string
+		request    request
+		wantStatus int
+	}{
+		{
+			name:       "list_races",
+			request:    request{http.MethodPost, "/v1/list-races", `{}`},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "list_races_filtered",
+			request:    request{http.MethodPost, "/v1/list-races", `{"filter": {"countries": ["au"], "visibility": "HIDDEN_ONLY"}}`},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "list_races_no_matches",
+			request:    request{http.MethodPost, "/v1/list-races", `{"filter": {"meeting_ids": [99]}}`},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "list_archived_races_empty",
+			request:    request{http.MethodPost, "/v1/list-archived-races", `{}`},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "list_archived_races_backwards",
+			request:    request{http.MethodPost, "/v1/list-archived-races", `{"date_range": {"start_date": "2021-02-28", "end_date": "2021-02-01"}}`},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "list_races_paged",
+			request:    request{http.MethodPost, "/v1/list-races", `{"page_size": 2}`},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "list_races_invalid_filter",
+			request:    request{http.MethodPost, "/v1/list-races", `{"filter": {"countries": ["AUS"]}}`},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "get_race_by_external_ref",
+			request:    request{http.MethodGet, "/v1/races/external/fixture/R3", ""},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "get_race_by_external_ref_not_found",
+			request:    request{http.MethodGet, "/v1/races/external/fixture/R9", ""},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name: "list_status_history",
+			setupRPC: func(ctx context.Context, client racing.RacingClient) error {
+				_, err := client.UpdateRaceStatus(metadata.AppendToOutgoingContext(ctx, "x-user-id", "steward"), &racing.UpdateRaceStatusRequest{RaceId: 1, Status: racing.Race_CLOSED})
+				return err
+			},
+			request:    request{http.MethodGet, "/v1/races/1/status-history", ""},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "list_featured_races",
+			setupRPC: func(ctx context.Context, client racing.RacingClient) error {
+				for _, raceID := range []int64{3, 1} {
+					if _, err := client.SetRaceFeatured(ctx, &racing.SetRaceFeaturedRequest{RaceId: raceID, Featured: true}); err != nil {
+						return err
+					}
+				}
+
+				return nil
+			},
+			request:    request{http.MethodPost, "/v1/list-featured-races", `{}`},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "list_watchlist",
+			setup: []request{
+				{http.MethodPost, "/v1/watchlist", `{"race_id": 3}`},
+				{http.MethodPost, "/v1/watchlist", `{"race_id": 1}`},
+			},
+			setupRPC: func(ctx context.Context, client racing.RacingClient) error {
+				_, err := client.WatchRace(metadata.AppendToOutgoingContext(ctx, "x-user-id", "someone"), &racing.WatchRaceRequest{RaceId: 2})
+				return err
+			},
+			user:       "punter",
+			request:    request{http.MethodGet, "/v1/watchlist", ""},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "watch_missing_race",
+			user:       "punter",
+			request:    request{http.MethodPost, "/v1/watchlist", `{"race_id": 99}`},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "list_watchlist_unauthenticated",
+			request:    request{http.MethodGet, "/v1/watchlist", ""},
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name: "list_popular_races",
+			setup: []request{
+				{http.MethodPost, "/v1/races/1/views", `{"views": 2}`},
+				{http.MethodPost, "/v1/races/2/views", `{"views": 9}`},
+				{http.MethodPost, "/v1/races/3/views", `{"views": 4}`},
+			},
+			request:    request{http.MethodPost, "/v1/list-popular-races", `{}`},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "list_races_by_popularity",
+			setup: []request{
+				{http.MethodPost, "/v1/races/1/views", `{"views": 2}`},
+				{http.MethodPost, "/v1/races/2/views", `{"views": 9}`},
+				{http.MethodPost, "/v1/races/3/views", `{"views": 4}`},
+			},
+			request:    request{http.MethodPost, "/v1/list-races", `{"order_by": "POPULARITY", "page_size": 2}`},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "race_stats",
+			request:    request{http.MethodPost, "/v1/race-stats", `{"filter": {"meeting_ids": [1, 2]}}`},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "list_races_by_day",
+			request:    request{http.MethodPost, "/v1/list-races-by-day", `{"date_range": {"start_date": "2021-03-01", "end_date": "2021-03-02"}}`},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "list_races_by_day_backwards",
+			request:    request{http.MethodPost, "/v1/list-races-by-day", `{"date_range": {"start_date": "2021-03-02", "end_date": "2021-03-01"}}`},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "list_meetings",
+			request:    request{http.MethodPost, "/v1/list-meetings", `{"filter": {"visibility": "VISIBLE_ONLY"}}`},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "list_meetings_paged",
+			request:    request{http.MethodPost, "/v1/list-meetings", `{"page_size": 1}`},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "list_related_races",
+			request:    request{http.MethodGet, "/v1/races/2/related", ""},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "list_races_related_to_missing_race",
+			request:    request{http.MethodGet, "/v1/races/99/related", ""},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "view_missing_race",
+			request:    request{http.MethodPost, "/v1/races/99/views", `{"views": 1}`},
+			wantStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			url, client := newGatewayAndClient(t, fixtures)
+
+			for _, req := range tt.setup {
+				if status, body := do(t, url, req, tt.user); status != http.StatusOK {
+					t.Fatalf("setup %s %s status = %d: %s", req.method, req.path, status, body)
+				}
+			}
+
+			if tt.setupRPC != nil {
+				if err := tt.setupRPC(context.Background(), client); err != nil {
+					t.Fatalf("setup error = %s", err)
+				}
+			}
+
+			status, body := do(t, url, tt.request, tt.user)
+			if status != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", status, tt.wantStatus, body)
+			}
+
+			// The gateway's JSON varies its whitespace between runs, so is
+			// compared indented.
+			var got bytes.Buffer
+			if err := json.Indent(&got, body, "", "  "); err != nil {
+				t.Fatalf("response isn't JSON: %s: %s", err, body)
+			}
+			got.WriteByte('\n')
+
+			path := filepath.Join("testdata", "golden", tt.name+".json")
+
+			if *update {
+				if err := ioutil.WriteFile(path, got.Bytes(), 0644); err != nil {
+					t.Fatalf("updating golden file: %s", err)
+				}
+			}
+
+			want, err := ioutil.ReadFile(path)
+			if err != nil {
+				t.Fatalf("reading golden file (run with -update to create it): %s", err)
+			}
+
+			if !bytes.Equal(got.Bytes(), want) {
+				t.Errorf("response differs from %s (run with -update if that's intended)\ngot:\n%s\nwant:\n%s", path, got.Bytes(), want)
+			}
+		})
+	}
+}
+
+// do makes a request to the gateway as the given user, if any, returning its
+// raw response.
+func do(t *testing.T, url string, req request, user string) (int, []byte) {
+	t.Helper()
+
+	httpReq, err := http.NewRequest(req.method, url+req.path, strings.NewReader(req.body))
+	if err != nil {
+		t.Fatalf("creating request: %s", err)
+	}
+
+	if user != "" {
+		httpReq.Header.Set("X-User-Id", user)
+	}
+
+	resp, err := http.DefaultClient.Do(httpReq)
+	if err != nil {
+		t.Fatalf("%s %s: %s", req.method, req.path, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("reading %s %s response: %s", req.method, req.path, err)
+	}
+
+	return resp.StatusCode, body
+}
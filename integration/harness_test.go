@@ -0,0 +1,181 @@
+// Package integration boots the racing service and the REST gateway in
+// process, to test the REST surface end to end.
+package integration
+
+import (
+	// Imported first, so it's initialised before the generated code.
+	_ "git.neds.sh/matty/entain/integration/internal/protoconflict"
+
+	"context"
+	"database/sql"
+	"net"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	apigateway "git.neds.sh/matty/entain/api/gateway"
+	gateway "git.neds.sh/matty/entain/api/proto/racing"
+	"git.neds.sh/matty/entain/racing/db"
+	"git.neds.sh/matty/entain/racing/interceptors"
+	"git.neds.sh/matty/entain/racing/proto/racing"
+	"git.neds.sh/matty/entain/racing/racetest"
+	"git.neds.sh/matty/entain/racing/service"
+	_ "github.com/mattn/go-sqlite3"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// imageBaseURL is the base URL race images are resolved against.
+const imageBaseURL = "https://cdn.example.com/racing"
+
+// now is the time the racing service's clock is fixed at, so responses are
+// the same on every run.
+var now = time.Date(2021, 3, 1, 9, 30, 0, 0, time.UTC)
+
+// fixtures are the races seeded for every test, all starting tomorrow.
+var fixtures = []*racing.Race{
+	racetest.NewRace().
+		Name("Flemington sprint").
+		Conditions("Good 4", "Fine").
+		Image("venues/flemington.jpg").
+		ExternalRef("fixture", "R1").
+		Build(),
+	racetest.NewRace().
+		Number(2).
+		Name("Flemington cup").
+		Hidden().
+		Distance(3200).
+		ExternalRef("fixture", "R2").
+		Build(),
+	racetest.NewRace().
+		Meeting(2).
+		Name("Ellerslie mile").
+		Venue("Pacific/Auckland", "NZ").
+		Distance(1600).
+		ExternalRef("fixture", "R3").
+		Build(),
+}
+
+// newGateway boots the racing service on an in-memory database seeded with
+// the fixtures, and the REST gateway in front of it, returning the gateway's
+// URL. Both are stopped when the test ends.
+func newGateway(t *testing.T) string {
+	t.Helper()
+
+	return newGatewayWithRaces(t, fixtures)
+}
+
+// newGatewayWithRaces is newGateway, seeding the given races instead of the
+// fixtures. Each starts an hour after the one before it, from tomorrow.
+func newGatewayWithRaces(t *testing.T, races []*racing.Race) string {
+	t.Helper()
+
+	url, _ := newGatewayAndClient(t, races)
+
+	return url
+}
+
+// newGatewayAndClient is newGatewayWithRaces, also returning a client of the
+// racing service, for RPCs that are only served over gRPC.
+func newGatewayAndClient(t *testing.T, races []*racing.Race) (string, racing.RacingClient) {
+	t.Helper()
+
+	racingDB, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("opening database: %s", err)
+	}
+
+	// Every connection to :memory: gets its own database, so share one.
+	racingDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { racingDB.Close() })
+
+	racesRepo := db.NewRacesRepo(racingDB, db.WithoutDummyData(), db.WithClock(db.FixedClock(now)))
+	marketsRepo := db.NewMarketsRepo(racingDB, db.WithoutDummyMarkets())
+	subscriptionsRepo := db.NewSubscriptionsRepo(racingDB)
+	notificationsRepo := db.NewNotificationsRepo(racingDB)
+	reconciliationRepo := db.NewReconciliationRepo(racingDB)
+	idempotencyRepo := db.NewIdempotencyRepo(racingDB)
+
+	for _, init := range []func() error{
+		racesRepo.Init,
+		marketsRepo.Init,
+		subscriptionsRepo.Init,
+		notificationsRepo.Init,
+		reconciliationRepo.Init,
+		idempotencyRepo.Init,
+	} {
+		if err := init(); err != nil {
+			t.Fatalf("initialising repositories: %s", err)
+		}
+	}
+
+	tomorrow := now.Add(24 * time.Hour)
+
+	for i, seed := range races {
+		race := proto.Clone(seed).(*racing.Race)
+		race.AdvertisedStartTime = timestamppb.New(tomorrow.Add(time.Duration(i) * time.Hour))
+
+		if _, err := racesRepo.Upsert(race); err != nil {
+			t.Fatalf("seeding race %q: %s", race.Name, err)
+		}
+	}
+
+	listener := bufconn.Listen(1 << 20)
+
+	grpcServer := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(
+			interceptors.Errors(),
+			interceptors.TimeZone(),
+			interceptors.Jurisdiction(),
+			interceptors.Brand(),
+			interceptors.User(),
+			interceptors.Validation(),
+			interceptors.Idempotency(idempotencyRepo, time.Hour),
+		),
+		grpc.ChainStreamInterceptor(
+			interceptors.StreamErrors(),
+			interceptors.StreamValidation(),
+		),
+	)
+
+	racing.RegisterRacingServer(
+		grpcServer,
+		service.NewRacingService(
+			racesRepo,
+			marketsRepo,
+			subscriptionsRepo,
+			notificationsRepo,
+			reconciliationRepo,
+			service.WithImageBaseURL(imageBaseURL),
+			service.WithClock(db.FixedClock(now)),
+		),
+	)
+
+	go grpcServer.Serve(listener)
+	t.Cleanup(grpcServer.Stop)
+
+	conn, err := grpc.DialContext(
+		context.Background(),
+		"bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+			return listener.Dial()
+		}),
+		grpc.WithInsecure(),
+	)
+	if err != nil {
+		t.Fatalf("dialling racing service: %s", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	mux := apigateway.NewServeMux()
+	if err := gateway.RegisterRacingHandler(context.Background(), mux, conn); err != nil {
+		t.Fatalf("registering gateway: %s", err)
+	}
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	return server.URL, racing.NewRacingClient(conn)
+}
@@ -0,0 +1,13 @@
+// Package protoconflict lets the racing and api modules' generated code for
+// racing/racing.proto be linked into one binary. Both register the same proto
+// file and messages, which panics by default, so importing this package first
+// has the second registration ignored instead.
+package protoconflict
+
+import "os"
+
+func init() {
+	// Read when a conflict is found, so it must be set before either
+	// generated package initialises.
+	os.Setenv("GOLANG_PROTOBUF_REGISTRATION_CONFLICT", "ignore")
+}
@@ -0,0 +1,104 @@
+package integration
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"git.neds.sh/matty/entain/racing/proto/racing"
+	"git.neds.sh/matty/entain/racing/racetest"
+)
+
+// orderingRaces are enough races, across enough meetings, for lists of them
+// to span several pages.
+func orderingRaces() []*racing.Race {
+	var races []*racing.Race
+
+	for meeting := int64(1); meeting <= 3; meeting++ {
+		for number := int64(1); number <= 4; number++ {
+			races = append(races, racetest.NewRace().
+				Meeting(meeting).
+				Number(number).
+				Name(fmt.Sprintf("Meeting %d race %d", meeting, number)).
+				ExternalRef("ordering", fmt.Sprintf("M%dR%d", meeting, number)).
+				Build())
+		}
+	}
+
+	return races
+}
+
+// listRaceIDs lists races with the given request body, returning their IDs
+// and the next page token.
+func listRaceIDs(t *testing.T, url string, body map[string]interface{}) ([]string, string) {
+	t.Helper()
+
+	code, resp := call(t, http.MethodPost, url+"/v1/list-races", body)
+	if code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %v", code, http.StatusOK, resp)
+	}
+
+	var ids []string
+	for _, race := range resp["races"].([]interface{}) {
+		ids = append(ids, race.(map[string]interface{})["id"].(string))
+	}
+
+	return ids, resp["next_page_token"].(string)
+}
+
+func TestListRacesOrderIsStable(t *testing.T) {
+	url := newGatewayWithRaces(t, orderingRaces())
+
+	filters := []map[string]interface{}{
+		{},
+		{"meeting_ids": []int{3, 1}},
+		{"visibility": "VISIBLE_ONLY"},
+	}
+
+	for _, filter := range filters {
+		want, _ := listRaceIDs(t, url, map[string]interface{}{"filter": filter})
+
+		for i := 0; i < 5; i++ {
+			if got, _ := listRaceIDs(t, url, map[string]interface{}{"filter": filter}); fmt.Sprint(got) != fmt.Sprint(want) {
+				t.Fatalf("filter %v: listing again = %v, want %v", filter, got, want)
+			}
+		}
+	}
+}
+
+func TestListRacesPagesWithoutGapsOrDuplicates(t *testing.T) {
+	url := newGatewayWithRaces(t, orderingRaces())
+
+	want, _ := listRaceIDs(t, url, map[string]interface{}{})
+	if len(want) != len(orderingRaces()) {
+		t.Fatalf("listed %d races, want %d", len(want), len(orderingRaces()))
+	}
+
+	for pageSize := 1; pageSize <= len(want)+1; pageSize++ {
+		var (
+			got   []string
+			token string
+			pages int
+		)
+
+		for {
+			ids, next := listRaceIDs(t, url, map[string]interface{}{"page_size": pageSize, "page_token": token})
+			got = append(got, ids...)
+			pages++
+
+			if next == "" {
+				break
+			}
+
+			if pages > len(want) {
+				t.Fatalf("page size %d: still paging after %d pages", pageSize, pages)
+			}
+
+			token = next
+		}
+
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("page size %d: paged through %v, want %v", pageSize, got, want)
+		}
+	}
+}
@@ -0,0 +1,461 @@
+package integration
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"git.neds.sh/matty/entain/racing/proto/racing"
+	"git.neds.sh/matty/entain/racing/racetest"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// call makes a request to the gateway, decoding its JSON response.
+func call(t *testing.T, method, url string, body interface{}) (int, map[string]interface{}) {
+	t.Helper()
+
+	var reqBody bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&reqBody).Encode(body); err != nil {
+			t.Fatalf("encoding request: %s", err)
+		}
+	}
+
+	req, err := http.NewRequest(method, url, &reqBody)
+	if err != nil {
+		t.Fatalf("creating request: %s", err)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %s", method, url, err)
+	}
+	defer resp.Body.Close()
+
+	var decoded map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&decoded); err != nil {
+		t.Fatalf("decoding %s %s response: %s", method, url, err)
+	}
+
+	return resp.StatusCode, decoded
+}
+
+// raceNames returns the names of the races in a list races response.
+func raceNames(resp map[string]interface{}) []string {
+	races, _ := resp["races"].([]interface{})
+
+	names := make([]string, 0, len(races))
+	for _, race := range races {
+		names = append(names, race.(map[string]interface{})["name"].(string))
+	}
+
+	return names
+}
+
+func equalNames(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func TestListRaces(t *testing.T) {
+	url := newGateway(t)
+
+	tests := []struct {
+		name      string
+		filter    map[string]interface{}
+		wantNames []string
+	}{
+		{
+			name:      "no filter",
+			filter:    map[string]interface{}{},
+			wantNames: []string{"Flemington sprint", "Flemington cup", "Ellerslie mile"},
+		},
+		{
+			name:      "meeting",
+			filter:    map[string]interface{}{"meeting_ids": []int{1}},
+			wantNames: []string{"Flemington sprint", "Flemington cup"},
+		},
+		{
+			name:      "visible only",
+			filter:    map[string]interface{}{"visibility": "VISIBLE_ONLY"},
+			wantNames: []string{"Flemington sprint", "Ellerslie mile"},
+		},
+		{
+			name:      "country and visibility",
+			filter:    map[string]interface{}{"countries": []string{"au"}, "visibility": "HIDDEN_ONLY"},
+			wantNames: []string{"Flemington cup"},
+		},
+		{
+			name:   "no matches",
+			filter: map[string]interface{}{"meeting_ids": []int{99}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, resp := call(t, http.MethodPost, url+"/v1/list-races", map[string]interface{}{"filter": tt.filter})
+			if code != http.StatusOK {
+				t.Fatalf("status = %d, want %d: %v", code, http.StatusOK, resp)
+			}
+
+			if got := raceNames(resp); !equalNames(got, tt.wantNames) {
+				t.Errorf("races = %v, want %v", got, tt.wantNames)
+			}
+		})
+	}
+}
+
+func TestListRacesResolvesImageURLs(t *testing.T) {
+	url := newGateway(t)
+
+	_, resp := call(t, http.MethodPost, url+"/v1/list-races", map[string]interface{}{
+		"filter": map[string]interface{}{"meeting_ids": []int{1}},
+	})
+
+	race := resp["races"].([]interface{})[0].(map[string]interface{})
+
+	if got, want := race["venue_image_url"], imageBaseURL+"/venues/flemington.jpg"; got != want {
+		t.Errorf("venue_image_url = %v, want %v", got, want)
+	}
+}
+
+func TestListRacesTimeZoneParam(t *testing.T) {
+	url := newGateway(t)
+
+	code, resp := call(t, http.MethodPost, url+"/v1/list-races?tz=Asia/Hong_Kong", map[string]interface{}{
+		"filter": map[string]interface{}{"meeting_ids": []int{1}},
+	})
+	if code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %v", code, http.StatusOK, resp)
+	}
+
+	for _, r := range resp["races"].([]interface{}) {
+		race := r.(map[string]interface{})
+
+		if local := race["local_advertised_start_time"].(string); !strings.HasSuffix(local, "+08:00") {
+			t.Errorf("local_advertised_start_time = %s, want it in Hong Kong time", local)
+		}
+	}
+
+	if code, resp := call(t, http.MethodPost, url+"/v1/list-races?tz=Mars/Olympus_Mons", map[string]interface{}{}); code != http.StatusBadRequest {
+		t.Errorf("invalid tz status = %d, want %d: %v", code, http.StatusBadRequest, resp)
+	}
+}
+
+func TestListRacesRejectsInvalidFilter(t *testing.T) {
+	url := newGateway(t)
+
+	code, resp := call(t, http.MethodPost, url+"/v1/list-races", map[string]interface{}{
+		"filter": map[string]interface{}{"countries": []string{"AUS"}},
+	})
+	if code != http.StatusBadRequest {
+		t.Errorf("status = %d, want %d: %v", code, http.StatusBadRequest, resp)
+	}
+}
+
+func TestGetRaceByExternalRef(t *testing.T) {
+	url := newGateway(t)
+
+	code, resp := call(t, http.MethodGet, url+"/v1/races/external/fixture/R3", nil)
+	if code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %v", code, http.StatusOK, resp)
+	}
+
+	if got := resp["name"]; got != "Ellerslie mile" {
+		t.Errorf("name = %v, want Ellerslie mile", got)
+	}
+
+	if code, resp := call(t, http.MethodGet, url+"/v1/races/external/fixture/R9", nil); code != http.StatusNotFound {
+		t.Errorf("unknown race status = %d, want %d: %v", code, http.StatusNotFound, resp)
+	}
+}
+
+func TestUpdateRaceStatus(t *testing.T) {
+	url, client := newGatewayAndClient(t, fixtures)
+
+	_, race := call(t, http.MethodGet, url+"/v1/races/external/fixture/R1", nil)
+	id := race["id"].(string)
+
+	raceID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		t.Fatalf("race id %q: %s", id, err)
+	}
+
+	// Status changes are recorded against the user making them.
+	steward := metadata.AppendToOutgoingContext(context.Background(), "x-user-id", "steward")
+
+	updated, err := client.UpdateRaceStatus(steward, &racing.UpdateRaceStatusRequest{RaceId: raceID, Status: racing.Race_CLOSED})
+	if err != nil {
+		t.Fatalf("UpdateRaceStatus() error = %s", err)
+	}
+
+	if updated.Status != racing.Race_CLOSED {
+		t.Errorf("race status = %s, want CLOSED", updated.Status)
+	}
+
+	// Closed races can't reopen.
+	if _, err := client.UpdateRaceStatus(steward, &racing.UpdateRaceStatusRequest{RaceId: raceID, Status: racing.Race_OPEN}); status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("reopening error = %v, want %s", err, codes.FailedPrecondition)
+	}
+
+	if _, err := client.UpdateRaceStatus(context.Background(), &racing.UpdateRaceStatusRequest{RaceId: raceID, Status: racing.Race_ABANDONED}); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("unauthenticated error = %v, want %s", err, codes.Unauthenticated)
+	}
+
+	// The gateway doesn't authenticate callers, so can't change statuses.
+	if code, _ := call(t, http.MethodPost, url+"/v1/races/"+id+"/status", map[string]interface{}{"status": "ABANDONED"}); code != http.StatusNotFound {
+		t.Errorf("changing status through the gateway status = %d, want %d", code, http.StatusNotFound)
+	}
+
+	code, resp := call(t, http.MethodGet, url+"/v1/races/"+id+"/status-history", nil)
+	if code != http.StatusOK {
+		t.Fatalf("history status = %d, want %d: %v", code, http.StatusOK, resp)
+	}
+
+	transitions := resp["transitions"].([]interface{})
+	last := transitions[len(transitions)-1].(map[string]interface{})
+
+	if last["from_status"] != "OPEN" || last["to_status"] != "CLOSED" || last["actor"] != "steward" {
+		t.Errorf("last transition = %v, want OPEN to CLOSED by steward", last)
+	}
+}
+
+func TestSearchRacesWithoutIndex(t *testing.T) {
+	url := newGateway(t)
+
+	if code, resp := call(t, http.MethodGet, url+"/v1/races/search?query=flemington", nil); code != http.StatusBadRequest {
+		t.Errorf("status = %d, want %d: %v", code, http.StatusBadRequest, resp)
+	}
+}
+
+func TestGetReconciliationReportBeforeAnyRun(t *testing.T) {
+	url := newGateway(t)
+
+	if code, resp := call(t, http.MethodGet, url+"/v1/reconciliation-report", nil); code != http.StatusNotFound {
+		t.Errorf("status = %d, want %d: %v", code, http.StatusNotFound, resp)
+	}
+}
+
+func TestRestrictedRacesHiddenInJurisdiction(t *testing.T) {
+	url, client := newGatewayAndClient(t, fixtures)
+
+	if _, err := client.SetRaceRestrictions(context.Background(), &racing.SetRaceRestrictionsRequest{RaceId: 1, Jurisdictions: []string{"SA", "WA"}}); err != nil {
+		t.Fatalf("SetRaceRestrictions() error = %s", err)
+	}
+
+	// from makes a request from a jurisdiction, returning its status and
+	// race names.
+	from := func(jurisdiction, method, path, body string) (int, []string) {
+		req, err := http.NewRequest(method, url+path, strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("creating request: %s", err)
+		}
+
+		if jurisdiction != "" {
+			req.Header.Set("X-Jurisdiction", jurisdiction)
+		}
+
+		httpResp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s: %s", method, path, err)
+		}
+		defer httpResp.Body.Close()
+
+		var decoded map[string]interface{}
+		if err := json.NewDecoder(httpResp.Body).Decode(&decoded); err != nil {
+			t.Fatalf("decoding %s %s response: %s", method, path, err)
+		}
+
+		return httpResp.StatusCode, raceNames(decoded)
+	}
+
+	tests := []struct {
+		jurisdiction string
+		want         []string
+	}{
+		{"", []string{"Flemington sprint", "Flemington cup", "Ellerslie mile"}},
+		{"NSW", []string{"Flemington sprint", "Flemington cup", "Ellerslie mile"}},
+		{"SA", []string{"Flemington cup", "Ellerslie mile"}},
+		{"wa", []string{"Flemington cup", "Ellerslie mile"}},
+	}
+
+	for _, tt := range tests {
+		if code, got := from(tt.jurisdiction, http.MethodPost, "/v1/list-races", `{}`); code != http.StatusOK || !equalNames(got, tt.want) {
+			t.Errorf("list races from %q = %d, %v, want %v", tt.jurisdiction, code, got, tt.want)
+		}
+	}
+
+	if code, _ := from("SA", http.MethodGet, "/v1/races/external/fixture/R1", ""); code != http.StatusNotFound {
+		t.Errorf("get restricted race status = %d, want %d", code, http.StatusNotFound)
+	}
+
+	if code, _ := from("NSW", http.MethodGet, "/v1/races/external/fixture/R1", ""); code != http.StatusOK {
+		t.Errorf("get race restricted elsewhere status = %d, want %d", code, http.StatusOK)
+	}
+
+	if code, _ := from("New South Wales", http.MethodPost, "/v1/list-races", `{}`); code != http.StatusBadRequest {
+		t.Errorf("invalid jurisdiction status = %d, want %d", code, http.StatusBadRequest)
+	}
+
+	// The gateway doesn't authenticate callers, so can't restrict races.
+	if code, _ := call(t, http.MethodPost, url+"/v1/races/1/restrictions", map[string]interface{}{"jurisdictions": []string{}}); code != http.StatusNotFound {
+		t.Errorf("restricting through the gateway status = %d, want %d", code, http.StatusNotFound)
+	}
+}
+
+func TestRacesFeaturedOverGRPCOnly(t *testing.T) {
+	url, client := newGatewayAndClient(t, fixtures)
+
+	if _, err := client.SetRaceFeatured(context.Background(), &racing.SetRaceFeaturedRequest{RaceId: 99, Featured: true}); status.Code(err) != codes.NotFound {
+		t.Errorf("SetRaceFeatured(missing race) error = %v, want %s", err, codes.NotFound)
+	}
+
+	// The gateway doesn't authenticate callers, so can't feature races.
+	if code, _ := call(t, http.MethodPost, url+"/v1/races/1/featured", map[string]interface{}{"featured": true}); code != http.StatusNotFound {
+		t.Errorf("featuring through the gateway status = %d, want %d", code, http.StatusNotFound)
+	}
+}
+
+func TestIngestRacesOverGRPCOnly(t *testing.T) {
+	url, client := newGatewayAndClient(t, fixtures)
+
+	stream, err := client.IngestRaces(context.Background())
+	if err != nil {
+		t.Fatalf("IngestRaces() error = %s", err)
+	}
+
+	race := racetest.NewRace().Meeting(3).Name("Randwick plate").ExternalRef("fixture", "R4").StartsAt(now.Add(24 * time.Hour)).Build()
+	if err := stream.Send(race); err != nil {
+		t.Fatalf("Send() error = %s", err)
+	}
+
+	resp, err := stream.CloseAndRecv()
+	if err != nil {
+		t.Fatalf("CloseAndRecv() error = %s", err)
+	}
+
+	if resp.Created != 1 || resp.Failed != 0 {
+		t.Errorf("IngestRaces() created %d and failed %d, want 1 created", resp.Created, resp.Failed)
+	}
+
+	if code, resp := call(t, http.MethodGet, url+"/v1/races/external/fixture/R4", nil); code != http.StatusOK || resp["name"] != "Randwick plate" {
+		t.Errorf("ingested race status = %d, want %d: %v", code, http.StatusOK, resp)
+	}
+
+	// The gateway doesn't authenticate callers, so can't write races.
+	if code, _ := call(t, http.MethodPost, url+"/v1/races/ingest", map[string]interface{}{"name": "Gateway race"}); code != http.StatusNotFound {
+		t.Errorf("ingesting through the gateway status = %d, want %d", code, http.StatusNotFound)
+	}
+}
+
+func TestSubscriptionsOverGRPCOnly(t *testing.T) {
+	url, client := newGatewayAndClient(t, fixtures)
+
+	sub, err := client.CreateSubscription(context.Background(), &racing.CreateSubscriptionRequest{CallbackUrl: "https://example.com/hook"})
+	if err != nil {
+		t.Fatalf("CreateSubscription() error = %s", err)
+	}
+
+	if _, err := client.ListDeliveries(context.Background(), &racing.ListDeliveriesRequest{SubscriptionId: sub.Id}); err != nil {
+		t.Errorf("ListDeliveries() error = %s", err)
+	}
+
+	// The gateway doesn't authenticate callers, so can't have the service
+	// call out to URLs they name, or see and delete others' subscriptions.
+	for _, route := range []struct{ method, path string }{
+		{http.MethodPost, "/v1/subscriptions"},
+		{http.MethodGet, "/v1/subscriptions/" + strconv.FormatInt(sub.Id, 10) + "/deliveries"},
+		{http.MethodDelete, "/v1/subscriptions/" + strconv.FormatInt(sub.Id, 10)},
+	} {
+		if code, _ := call(t, route.method, url+route.path, map[string]interface{}{"callback_url": "http://169.254.169.254/"}); code != http.StatusNotFound {
+			t.Errorf("%s %s status = %d, want %d", route.method, route.path, code, http.StatusNotFound)
+		}
+	}
+}
+
+func TestRacesShownAsForBrand(t *testing.T) {
+	url, client := newGatewayAndClient(t, fixtures)
+
+	for raceID, visibility := range map[int64]racing.SetBrandVisibilityRequest_Visibility{
+		1: racing.SetBrandVisibilityRequest_HIDDEN,
+		2: racing.SetBrandVisibilityRequest_VISIBLE,
+	} {
+		if _, err := client.SetBrandVisibility(context.Background(), &racing.SetBrandVisibilityRequest{RaceId: raceID, Brand: "neds", Visibility: visibility}); err != nil {
+			t.Fatalf("SetBrandVisibility(%d) error = %s", raceID, err)
+		}
+	}
+
+	// through lists the visible races through a brand, returning the
+	// response's status, Vary header and race names.
+	through := func(brand string) (int, []string, []string) {
+		req, err := http.NewRequest(http.MethodPost, url+"/v1/list-races", strings.NewReader(`{"filter": {"visibility": "VISIBLE_ONLY"}}`))
+		if err != nil {
+			t.Fatalf("creating request: %s", err)
+		}
+
+		if brand != "" {
+			req.Header.Set("X-Brand", brand)
+		}
+
+		httpResp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("listing races: %s", err)
+		}
+		defer httpResp.Body.Close()
+
+		var decoded map[string]interface{}
+		if err := json.NewDecoder(httpResp.Body).Decode(&decoded); err != nil {
+			t.Fatalf("decoding list races response: %s", err)
+		}
+
+		return httpResp.StatusCode, httpResp.Header.Values("Vary"), raceNames(decoded)
+	}
+
+	tests := []struct {
+		brand string
+		want  []string
+	}{
+		{"", []string{"Flemington sprint", "Ellerslie mile"}},
+		{"ladbrokes", []string{"Flemington sprint", "Ellerslie mile"}},
+		{"neds", []string{"Flemington cup", "Ellerslie mile"}},
+		{"NEDS", []string{"Flemington cup", "Ellerslie mile"}},
+	}
+
+	for _, tt := range tests {
+		code, vary, got := through(tt.brand)
+		if code != http.StatusOK || !equalNames(got, tt.want) {
+			t.Errorf("list races through %q = %d, %v, want %v", tt.brand, code, got, tt.want)
+		}
+
+		// Caches in front of the gateway mustn't serve one brand's races to
+		// another.
+		if !strings.Contains(strings.Join(vary, ", "), "X-Brand") {
+			t.Errorf("list races through %q Vary = %v, want X-Brand", tt.brand, vary)
+		}
+	}
+
+	if code, _, _ := through("neds.com.au"); code != http.StatusBadRequest {
+		t.Errorf("invalid brand status = %d, want %d", code, http.StatusBadRequest)
+	}
+
+	// The gateway doesn't authenticate callers, so can't show or hide races.
+	if code, _ := call(t, http.MethodPost, url+"/v1/races/1/brands/neds/visibility", map[string]interface{}{"visibility": "VISIBLE"}); code != http.StatusNotFound {
+		t.Errorf("overriding through the gateway status = %d, want %d", code, http.StatusNotFound)
+	}
+}
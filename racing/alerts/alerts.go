@@ -0,0 +1,142 @@
+package alerts
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+// pagerDutyEventsURL is the endpoint of PagerDuty's Events API v2.
+const pagerDutyEventsURL = "https://events.pagerduty.com/v2/enqueue"
+
+// Alert describes a job that has failed repeatedly, or has recovered.
+type Alert struct {
+	// Job is the name of the failing job.
+	Job string `json:"job"`
+	// Failures is how many times in a row the job has failed.
+	Failures int `json:"failures"`
+	// Since is when the job started failing.
+	Since time.Time `json:"since"`
+	// LastError is the error the job last failed with.
+	LastError string `json:"last_error"`
+	// Resolved is set once the job has succeeded again.
+	Resolved bool `json:"resolved"`
+}
+
+// Hook is told about alerts, e.g. to page someone.
+type Hook interface {
+	// Fire sends an alert.
+	Fire(ctx context.Context, alert Alert) error
+}
+
+// WebhookHook POSTs alerts as JSON to a URL.
+type WebhookHook struct {
+	url    string
+	source string
+	client *http.Client
+}
+
+// NewWebhookHook creates a hook POSTing alerts raised by source, e.g. the
+// replica's name, to the given URL.
+func NewWebhookHook(url, source string, client *http.Client) *WebhookHook {
+	return &WebhookHook{url: url, source: source, client: client}
+}
+
+// webhookMessage is the JSON body POSTed by the webhook hook.
+type webhookMessage struct {
+	Source string `json:"source"`
+	Alert
+}
+
+// Fire POSTs the alert, returning an error unless it was accepted.
+func (h *WebhookHook) Fire(ctx context.Context, alert Alert) error {
+	return post(ctx, h.client, h.url, webhookMessage{Source: h.source, Alert: alert})
+}
+
+// PagerDutyHook triggers, and resolves, PagerDuty incidents through the
+// Events API. Alerts for a job are deduplicated into a single incident.
+type PagerDutyHook struct {
+	routingKey string
+	source     string
+	client     *http.Client
+}
+
+// NewPagerDutyHook creates a hook sending alerts raised by source, e.g. the
+// replica's name, to the PagerDuty service with the given integration key.
+func NewPagerDutyHook(routingKey, source string, client *http.Client) *PagerDutyHook {
+	return &PagerDutyHook{routingKey: routingKey, source: source, client: client}
+}
+
+// pagerDutyEvent is an Events API v2 event.
+type pagerDutyEvent struct {
+	RoutingKey  string            `json:"routing_key"`
+	EventAction string            `json:"event_action"`
+	DedupKey    string            `json:"dedup_key"`
+	Payload     *pagerDutyPayload `json:"payload,omitempty"`
+}
+
+// pagerDutyPayload describes a triggered incident.
+type pagerDutyPayload struct {
+	Summary       string `json:"summary"`
+	Source        string `json:"source"`
+	Severity      string `json:"severity"`
+	Component     string `json:"component"`
+	CustomDetails Alert  `json:"custom_details"`
+}
+
+// Fire triggers an incident for the job, or resolves it.
+func (h *PagerDutyHook) Fire(ctx context.Context, alert Alert) error {
+	event := pagerDutyEvent{
+		RoutingKey:  h.routingKey,
+		EventAction: "resolve",
+		DedupKey:    "racing/" + alert.Job,
+	}
+
+	if !alert.Resolved {
+		event.EventAction = "trigger"
+		event.Payload = &pagerDutyPayload{
+			Summary:       fmt.Sprintf("racing job %s has failed %d times in a row: %s", alert.Job, alert.Failures, alert.LastError),
+			Source:        h.source,
+			Severity:      "error",
+			Component:     alert.Job,
+			CustomDetails: alert,
+		}
+	}
+
+	return post(ctx, h.client, pagerDutyEventsURL, event)
+}
+
+// post POSTs a JSON body, returning an error unless it was accepted.
+func post(ctx context.Context, client *http.Client, url string, body interface{}) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// Drain the body, so the connection can be reused.
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected response: %s", resp.Status)
+	}
+
+	return nil
+}
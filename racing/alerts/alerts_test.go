@@ -0,0 +1,205 @@
+package alerts
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// roundTripFunc sends requests by calling itself.
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// recordingClient returns a client answering every request with the given
+// status code, recording the URL and JSON body of each.
+func recordingClient(t *testing.T, code int, urls *[]string, bodies *[]map[string]interface{}) *http.Client {
+	return &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		if got := req.Header.Get("Content-Type"); got != "application/json" {
+			t.Errorf("content type = %q, want application/json", got)
+		}
+
+		var body map[string]interface{}
+		if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+			t.Fatalf("decoding body: %s", err)
+		}
+
+		*urls = append(*urls, req.URL.String())
+		*bodies = append(*bodies, body)
+
+		return &http.Response{
+			StatusCode: code,
+			Status:     http.StatusText(code),
+			Body:       ioutil.NopCloser(strings.NewReader("")),
+		}, nil
+	})}
+}
+
+var testAlert = Alert{
+	Job:       "ingest",
+	Failures:  3,
+	Since:     time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC),
+	LastError: "feed unavailable",
+}
+
+func TestWebhookHookFire(t *testing.T) {
+	resolved := testAlert
+	resolved.Resolved = true
+	resolved.LastError = ""
+
+	tests := []struct {
+		name     string
+		alert    Alert
+		code     int
+		wantBody map[string]interface{}
+		wantErr  bool
+	}{
+		{
+			"failing",
+			testAlert,
+			http.StatusOK,
+			map[string]interface{}{
+				"source": "racing-0", "job": "ingest", "failures": 3.0,
+				"since": "2021-03-01T12:00:00Z", "last_error": "feed unavailable", "resolved": false,
+			},
+			false,
+		},
+		{
+			"resolved",
+			resolved,
+			http.StatusAccepted,
+			map[string]interface{}{
+				"source": "racing-0", "job": "ingest", "failures": 3.0,
+				"since": "2021-03-01T12:00:00Z", "last_error": "", "resolved": true,
+			},
+			false,
+		},
+		{
+			"rejected",
+			testAlert,
+			http.StatusServiceUnavailable,
+			nil,
+			true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				urls   []string
+				bodies []map[string]interface{}
+			)
+
+			hook := NewWebhookHook("https://alerts.example.com/hook", "racing-0", recordingClient(t, tt.code, &urls, &bodies))
+
+			err := hook.Fire(context.Background(), tt.alert)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Fire() error = %v, want error %t", err, tt.wantErr)
+			}
+
+			if len(urls) != 1 || urls[0] != "https://alerts.example.com/hook" {
+				t.Errorf("Fire() posted to %v, want the hook's URL once", urls)
+			}
+
+			if tt.wantBody != nil && !reflect.DeepEqual(bodies[0], tt.wantBody) {
+				t.Errorf("Fire() body = %v, want %v", bodies[0], tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestPagerDutyHookFire(t *testing.T) {
+	resolved := testAlert
+	resolved.Resolved = true
+
+	tests := []struct {
+		name     string
+		alert    Alert
+		code     int
+		wantBody map[string]interface{}
+		wantErr  bool
+	}{
+		{
+			"trigger",
+			testAlert,
+			http.StatusAccepted,
+			map[string]interface{}{
+				"routing_key":  "key",
+				"event_action": "trigger",
+				"dedup_key":    "racing/ingest",
+				"payload": map[string]interface{}{
+					"summary":   "racing job ingest has failed 3 times in a row: feed unavailable",
+					"source":    "racing-0",
+					"severity":  "error",
+					"component": "ingest",
+					"custom_details": map[string]interface{}{
+						"job": "ingest", "failures": 3.0, "since": "2021-03-01T12:00:00Z",
+						"last_error": "feed unavailable", "resolved": false,
+					},
+				},
+			},
+			false,
+		},
+		{
+			"resolve",
+			resolved,
+			http.StatusAccepted,
+			map[string]interface{}{
+				"routing_key":  "key",
+				"event_action": "resolve",
+				"dedup_key":    "racing/ingest",
+			},
+			false,
+		},
+		{
+			"rate limited",
+			testAlert,
+			http.StatusTooManyRequests,
+			nil,
+			true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				urls   []string
+				bodies []map[string]interface{}
+			)
+
+			hook := NewPagerDutyHook("key", "racing-0", recordingClient(t, tt.code, &urls, &bodies))
+
+			err := hook.Fire(context.Background(), tt.alert)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Fire() error = %v, want error %t", err, tt.wantErr)
+			}
+
+			if len(urls) != 1 || urls[0] != pagerDutyEventsURL {
+				t.Errorf("Fire() posted to %v, want the Events API once", urls)
+			}
+
+			if tt.wantBody != nil && !reflect.DeepEqual(bodies[0], tt.wantBody) {
+				t.Errorf("Fire() body = %v, want %v", bodies[0], tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestWebhookHookUnreachable(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+
+	hook := NewWebhookHook(server.URL, "racing-0", server.Client())
+
+	if err := hook.Fire(context.Background(), testAlert); err == nil {
+		t.Error("Fire() error = nil, want an error reaching a closed server")
+	}
+}
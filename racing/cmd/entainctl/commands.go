@@ -0,0 +1,700 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"git.neds.sh/matty/entain/racing/proto/racing"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// command is an action on a resource of the racing service.
+type command struct {
+	summary string
+	// streaming commands run until interrupted, or their stream ends, rather
+	// than timing out.
+	streaming bool
+	run       func(ctx context.Context, client racing.RacingClient, fs *flag.FlagSet, args []string) error
+}
+
+// commands are keyed by their resource and action, e.g. "races list".
+var commands = map[string]command{
+	"races list":               {summary: "List races, or archived races", run: listRaces},
+	"races get":                {summary: "Get a race by its external reference", run: getRace},
+	"races status":             {summary: "Move a race to a new status as the -user-id user", run: updateRaceStatus},
+	"races history":            {summary: "List a race's status transitions", run: listStatusHistory},
+	"races feature":            {summary: "Feature a race on the home screen, or stop featuring it", run: setRaceFeatured},
+	"races featured":           {summary: "List the visible featured races", run: listFeaturedRaces},
+	"races restrict":           {summary: "Replace the jurisdictions a race is restricted in", run: setRaceRestrictions},
+	"races brand-visibility":   {summary: "Show or hide a race for a brand", run: setBrandVisibility},
+	"races view":               {summary: "Count views of a race seen outside the service", run: recordRaceViews},
+	"races popular":            {summary: "List the visible races viewed most recently", run: listPopularRaces},
+	"races stats":              {summary: "Count races by meeting, country, status and day", run: getRaceStats},
+	"races by-day":             {summary: "List races by the day they start on at their venue", run: listRacesByDay},
+	"races related":            {summary: "List upcoming races related to a race", run: listRelatedRaces},
+	"meetings list":            {summary: "List meetings, each with their races", run: listMeetings},
+	"races watch":              {summary: "Stream changes to races", streaming: true, run: watchChanges},
+	"races ingest":             {summary: "Create or update races from a JSON Lines file", streaming: true, run: ingestRaces},
+	"markets list":             {summary: "List markets", run: listMarkets},
+	"markets get":              {summary: "Get a market by its ID", run: getMarket},
+	"selections list":          {summary: "List market selections", run: listSelections},
+	"selections get":           {summary: "Get a market selection by its ID", run: getSelection},
+	"subscriptions create":     {summary: "Subscribe a callback URL to race changes", run: createSubscription},
+	"subscriptions delete":     {summary: "Delete a subscription", run: deleteSubscription},
+	"subscriptions deliveries": {summary: "List the notifications sent to a subscription", run: listDeliveries},
+	"devices register":         {summary: "Register a device for push notifications", run: registerDevice},
+	"devices unregister":       {summary: "Stop sending push notifications to a device", run: unregisterDevice},
+	"watchlist add":            {summary: "Add a race to the -user-id user's watchlist", run: watchRace},
+	"watchlist remove":         {summary: "Remove a race from the -user-id user's watchlist", run: unwatchRace},
+	"watchlist list":           {summary: "List the races on the -user-id user's watchlist", run: listWatchlist},
+	"reconciliation report":    {summary: "Get the report of the last reconciliation against the feed", run: getReconciliationReport},
+}
+
+// visibilities are the accepted values of the -visibility flag.
+var visibilities = map[string]racing.ListRacesRequestFilter_Visibility{
+	"all":     racing.ListRacesRequestFilter_ALL,
+	"visible": racing.ListRacesRequestFilter_VISIBLE_ONLY,
+	"hidden":  racing.ListRacesRequestFilter_HIDDEN_ONLY,
+}
+
+// orders are the accepted values of the -order-by flag.
+var orders = map[string]racing.ListRacesRequest_OrderBy{
+	"id":         racing.ListRacesRequest_ID,
+	"popularity": racing.ListRacesRequest_POPULARITY,
+}
+
+// brandVisibilities are the accepted values of the -visibility flag of
+// races brand-visibility.
+var brandVisibilities = map[string]racing.SetBrandVisibilityRequest_Visibility{
+	"inherit": racing.SetBrandVisibilityRequest_INHERIT,
+	"visible": racing.SetBrandVisibilityRequest_VISIBLE,
+	"hidden":  racing.SetBrandVisibilityRequest_HIDDEN,
+}
+
+func listRaces(ctx context.Context, client racing.RacingClient, fs *flag.FlagSet, args []string) error {
+	var (
+		filter     racing.ListRacesRequestFilter
+		meetingIDs idList
+		countries  stringList
+	)
+
+	fs.Var(&meetingIDs, "meeting-ids", "Comma separated meeting IDs to filter by")
+	fs.Var(&countries, "countries", "Comma separated ISO 3166-1 alpha-2 country codes to filter by")
+	visibility := fs.String("visibility", "all", "Visibility to filter by: all, visible or hidden")
+	fs.Int64Var(&filter.MinDistance, "min-distance", 0, "Minimum distance in metres")
+	fs.Int64Var(&filter.MaxDistance, "max-distance", 0, "Maximum distance in metres")
+	fs.BoolVar(&filter.MissingStartTime, "missing-start-time", false, "List only races without an advertised start time")
+	timeZone := fs.String("time-zone", "", "IANA time zone to format local start times in")
+	locale := fs.String("locale", "", "BCP 47 language tag to return race names in")
+	asOf := fs.String("as-of", "", "RFC 3339 time to list races as they were at (requires the event log)")
+	pageSize := fs.Int("page-size", 0, "Most races to list (0 lists the default of 50, and at most 1000 are listed)")
+	pageToken := fs.String("page-token", "", "next_page_token of the previous page, to list the page after it")
+	archived := fs.Bool("archived", false, "List archived races instead")
+	startDate := fs.String("from", "", "With -archived, the first UTC day to list races for, as YYYY-MM-DD")
+	endDate := fs.String("to", "", "With -archived, the last UTC day to list races for, as YYYY-MM-DD (defaults to -from)")
+	orderBy := fs.String("order-by", "id", "Order to list races in: id, or popularity for the most viewed first")
+	_ = fs.Parse(args)
+
+	var ok bool
+	if filter.Visibility, ok = visibilities[*visibility]; !ok {
+		return fmt.Errorf("invalid visibility %q", *visibility)
+	}
+
+	filter.MeetingIds = meetingIDs
+	filter.Countries = countries
+
+	if *archived {
+		if *asOf != "" {
+			return errors.New("-as-of can't be used with -archived")
+		}
+
+		if *orderBy != "id" {
+			return errors.New("archived races are listed by ID, so -order-by can't be used with -archived")
+		}
+
+		req := &racing.ListArchivedRacesRequest{
+			Filter:    &filter,
+			TimeZone:  *timeZone,
+			Locale:    *locale,
+			PageSize:  int32(*pageSize),
+			PageToken: *pageToken,
+		}
+
+		if *startDate != "" || *endDate != "" {
+			req.DateRange = &racing.DateRange{StartDate: *startDate, EndDate: *endDate}
+		}
+
+		resp, err := client.ListArchivedRaces(ctx, req)
+		if err != nil {
+			return err
+		}
+
+		return printJSON(resp)
+	}
+
+	if *startDate != "" || *endDate != "" {
+		return errors.New("-from and -to can only be used with -archived")
+	}
+
+	req := &racing.ListRacesRequest{
+		Filter:    &filter,
+		TimeZone:  *timeZone,
+		Locale:    *locale,
+		PageSize:  int32(*pageSize),
+		PageToken: *pageToken,
+	}
+
+	if req.OrderBy, ok = orders[*orderBy]; !ok {
+		return fmt.Errorf("invalid order %q", *orderBy)
+	}
+
+	if *asOf != "" {
+		t, err := time.Parse(time.RFC3339, *asOf)
+		if err != nil {
+			return fmt.Errorf("invalid -as-of: %w", err)
+		}
+
+		req.AsOf = timestamppb.New(t)
+	}
+
+	resp, err := client.ListRaces(ctx, req)
+	if err != nil {
+		return err
+	}
+
+	return printJSON(resp)
+}
+
+func getRace(ctx context.Context, client racing.RacingClient, fs *flag.FlagSet, args []string) error {
+	source := fs.String("source", "racing-feed", "External source the race is referenced by")
+	sourceID := fs.String("id", "", "ID of the race in the external source")
+	_ = fs.Parse(args)
+
+	resp, err := client.GetRaceByExternalRef(ctx, &racing.GetRaceByExternalRefRequest{
+		ExternalRef: &racing.ExternalRef{Source: *source, SourceId: *sourceID},
+	})
+	if err != nil {
+		return err
+	}
+
+	return printJSON(resp)
+}
+
+func updateRaceStatus(ctx context.Context, client racing.RacingClient, fs *flag.FlagSet, args []string) error {
+	raceID := fs.Int64("id", 0, "ID of the race")
+	status := fs.String("status", "", "Status to move the race to, e.g. CLOSED")
+	_ = fs.Parse(args)
+
+	value, ok := racing.Race_Status_value[strings.ToUpper(*status)]
+	if !ok {
+		return fmt.Errorf("invalid status %q", *status)
+	}
+
+	resp, err := client.UpdateRaceStatus(ctx, &racing.UpdateRaceStatusRequest{
+		RaceId: *raceID,
+		Status: racing.Race_Status(value),
+	})
+	if err != nil {
+		return err
+	}
+
+	return printJSON(resp)
+}
+
+func listStatusHistory(ctx context.Context, client racing.RacingClient, fs *flag.FlagSet, args []string) error {
+	raceID := fs.Int64("id", 0, "ID of the race")
+	_ = fs.Parse(args)
+
+	resp, err := client.ListStatusHistory(ctx, &racing.ListStatusHistoryRequest{RaceId: *raceID})
+	if err != nil {
+		return err
+	}
+
+	return printJSON(resp)
+}
+
+func setRaceFeatured(ctx context.Context, client racing.RacingClient, fs *flag.FlagSet, args []string) error {
+	raceID := fs.Int64("id", 0, "ID of the race")
+	featured := fs.Bool("featured", true, "Whether to feature the race (-featured=false stops featuring it)")
+	_ = fs.Parse(args)
+
+	resp, err := client.SetRaceFeatured(ctx, &racing.SetRaceFeaturedRequest{RaceId: *raceID, Featured: *featured})
+	if err != nil {
+		return err
+	}
+
+	return printJSON(resp)
+}
+
+func listFeaturedRaces(ctx context.Context, client racing.RacingClient, fs *flag.FlagSet, args []string) error {
+	timeZone := fs.String("time-zone", "", "IANA time zone to format local start times in")
+	locale := fs.String("locale", "", "BCP 47 language tag to return race names in")
+	_ = fs.Parse(args)
+
+	resp, err := client.ListFeaturedRaces(ctx, &racing.ListFeaturedRacesRequest{TimeZone: *timeZone, Locale: *locale})
+	if err != nil {
+		return err
+	}
+
+	return printJSON(resp)
+}
+
+func recordRaceViews(ctx context.Context, client racing.RacingClient, fs *flag.FlagSet, args []string) error {
+	raceID := fs.Int64("id", 0, "ID of the race")
+	views := fs.Int64("views", 1, "How many times the race was viewed")
+	_ = fs.Parse(args)
+
+	resp, err := client.RecordRaceViews(ctx, &racing.RecordRaceViewsRequest{RaceId: *raceID, Views: *views})
+	if err != nil {
+		return err
+	}
+
+	return printJSON(resp)
+}
+
+func listPopularRaces(ctx context.Context, client racing.RacingClient, fs *flag.FlagSet, args []string) error {
+	limit := fs.Int("limit", 0, "Most races to list (0 lists the default of 10)")
+	windowHours := fs.Int("window-hours", 0, "Hours of views, before the current hour, to rank races by (0 ranks by the default of 1)")
+	timeZone := fs.String("time-zone", "", "IANA time zone to format local start times in")
+	locale := fs.String("locale", "", "BCP 47 language tag to return race names in")
+	_ = fs.Parse(args)
+
+	resp, err := client.ListPopularRaces(ctx, &racing.ListPopularRacesRequest{
+		Limit:       int32(*limit),
+		WindowHours: int32(*windowHours),
+		TimeZone:    *timeZone,
+		Locale:      *locale,
+	})
+	if err != nil {
+		return err
+	}
+
+	return printJSON(resp)
+}
+
+func getRaceStats(ctx context.Context, client racing.RacingClient, fs *flag.FlagSet, args []string) error {
+	var (
+		filter     racing.ListRacesRequestFilter
+		meetingIDs idList
+		countries  stringList
+	)
+
+	fs.Var(&meetingIDs, "meeting-ids", "Comma separated meeting IDs to filter by")
+	fs.Var(&countries, "countries", "Comma separated ISO 3166-1 alpha-2 country codes to filter by")
+	visibility := fs.String("visibility", "all", "Visibility to filter by: all, visible or hidden")
+	_ = fs.Parse(args)
+
+	var ok bool
+	if filter.Visibility, ok = visibilities[*visibility]; !ok {
+		return fmt.Errorf("invalid visibility %q", *visibility)
+	}
+
+	filter.MeetingIds = meetingIDs
+	filter.Countries = countries
+
+	resp, err := client.GetRaceStats(ctx, &racing.GetRaceStatsRequest{Filter: &filter})
+	if err != nil {
+		return err
+	}
+
+	return printJSON(resp)
+}
+
+func listRacesByDay(ctx context.Context, client racing.RacingClient, fs *flag.FlagSet, args []string) error {
+	var (
+		filter     racing.ListRacesRequestFilter
+		meetingIDs idList
+		countries  stringList
+	)
+
+	startDate := fs.String("from", time.Now().Format("2006-01-02"), "First day to list races for, as YYYY-MM-DD")
+	endDate := fs.String("to", "", "Last day to list races for, as YYYY-MM-DD (defaults to -from)")
+	fs.Var(&meetingIDs, "meeting-ids", "Comma separated meeting IDs to filter by")
+	fs.Var(&countries, "countries", "Comma separated ISO 3166-1 alpha-2 country codes to filter by")
+	visibility := fs.String("visibility", "all", "Visibility to filter by: all, visible or hidden")
+	locale := fs.String("locale", "", "BCP 47 language tag to return race names in")
+	_ = fs.Parse(args)
+
+	var ok bool
+	if filter.Visibility, ok = visibilities[*visibility]; !ok {
+		return fmt.Errorf("invalid visibility %q", *visibility)
+	}
+
+	filter.MeetingIds = meetingIDs
+	filter.Countries = countries
+
+	resp, err := client.ListRacesByDay(ctx, &racing.ListRacesByDayRequest{
+		DateRange: &racing.DateRange{StartDate: *startDate, EndDate: *endDate},
+		Filter:    &filter,
+		Locale:    *locale,
+	})
+	if err != nil {
+		return err
+	}
+
+	return printJSON(resp)
+}
+
+func listRelatedRaces(ctx context.Context, client racing.RacingClient, fs *flag.FlagSet, args []string) error {
+	raceID := fs.Int64("id", 0, "ID of the race")
+	limit := fs.Int("limit", 0, "Most races to list (0 lists the default of 10)")
+	timeZone := fs.String("time-zone", "", "IANA time zone to format local start times in")
+	locale := fs.String("locale", "", "BCP 47 language tag to return race names in")
+	_ = fs.Parse(args)
+
+	resp, err := client.ListRelatedRaces(ctx, &racing.ListRelatedRacesRequest{
+		RaceId:   *raceID,
+		Limit:    int32(*limit),
+		TimeZone: *timeZone,
+		Locale:   *locale,
+	})
+	if err != nil {
+		return err
+	}
+
+	return printJSON(resp)
+}
+
+func listMeetings(ctx context.Context, client racing.RacingClient, fs *flag.FlagSet, args []string) error {
+	var (
+		filter     racing.ListRacesRequestFilter
+		meetingIDs idList
+		countries  stringList
+	)
+
+	fs.Var(&meetingIDs, "meeting-ids", "Comma separated meeting IDs to filter by")
+	fs.Var(&countries, "countries", "Comma separated ISO 3166-1 alpha-2 country codes to filter by")
+	visibility := fs.String("visibility", "all", "Visibility to filter by: all, visible or hidden")
+	timeZone := fs.String("time-zone", "", "IANA time zone to format local start times in")
+	locale := fs.String("locale", "", "BCP 47 language tag to return race names in")
+	pageSize := fs.Int("page-size", 0, "Most meetings to list (0 lists the default of 50, and at most 1000 are listed)")
+	pageToken := fs.String("page-token", "", "next_page_token of the previous page, to list the page after it")
+	_ = fs.Parse(args)
+
+	var ok bool
+	if filter.Visibility, ok = visibilities[*visibility]; !ok {
+		return fmt.Errorf("invalid visibility %q", *visibility)
+	}
+
+	filter.MeetingIds = meetingIDs
+	filter.Countries = countries
+
+	resp, err := client.ListMeetings(ctx, &racing.ListMeetingsRequest{
+		Filter:    &filter,
+		TimeZone:  *timeZone,
+		Locale:    *locale,
+		PageSize:  int32(*pageSize),
+		PageToken: *pageToken,
+	})
+	if err != nil {
+		return err
+	}
+
+	return printJSON(resp)
+}
+
+func setRaceRestrictions(ctx context.Context, client racing.RacingClient, fs *flag.FlagSet, args []string) error {
+	var jurisdictions stringList
+
+	raceID := fs.Int64("id", 0, "ID of the race")
+	fs.Var(&jurisdictions, "jurisdictions", "Comma separated jurisdictions (e.g. SA,NSW) to restrict the race in, or none to lift every restriction")
+	_ = fs.Parse(args)
+
+	resp, err := client.SetRaceRestrictions(ctx, &racing.SetRaceRestrictionsRequest{RaceId: *raceID, Jurisdictions: jurisdictions})
+	if err != nil {
+		return err
+	}
+
+	return printJSON(resp)
+}
+
+func setBrandVisibility(ctx context.Context, client racing.RacingClient, fs *flag.FlagSet, args []string) error {
+	raceID := fs.Int64("id", 0, "ID of the race")
+	brand := fs.String("brand", "", "Brand (e.g. neds) to show or hide the race for")
+	visibility := fs.String("visibility", "inherit", "Visibility for the brand: visible, hidden, or inherit to show it as it is for every brand")
+	_ = fs.Parse(args)
+
+	req := &racing.SetBrandVisibilityRequest{RaceId: *raceID, Brand: *brand}
+
+	var ok bool
+	if req.Visibility, ok = brandVisibilities[*visibility]; !ok {
+		return fmt.Errorf("invalid visibility %q", *visibility)
+	}
+
+	resp, err := client.SetBrandVisibility(ctx, req)
+	if err != nil {
+		return err
+	}
+
+	return printJSON(resp)
+}
+
+func watchChanges(ctx context.Context, client racing.RacingClient, fs *flag.FlagSet, args []string) error {
+	since := fs.String("since", "", "Token of the change to replay changes after (empty streams new changes only)")
+	_ = fs.Parse(args)
+
+	stream, err := client.WatchChanges(ctx, &racing.WatchChangesRequest{SinceToken: *since})
+	if err != nil {
+		return err
+	}
+
+	for {
+		change, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := printJSON(change); err != nil {
+			return err
+		}
+	}
+}
+
+func ingestRaces(ctx context.Context, client racing.RacingClient, fs *flag.FlagSet, args []string) error {
+	file := fs.String("file", "-", "JSON Lines file of races, each with an external_ref, to ingest (- reads stdin)")
+	_ = fs.Parse(args)
+
+	in := os.Stdin
+	if *file != "-" {
+		f, err := os.Open(*file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		in = f
+	}
+
+	stream, err := client.IngestRaces(ctx)
+	if err != nil {
+		return err
+	}
+
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+
+		var race racing.Race
+		if err := protojson.Unmarshal(scanner.Bytes(), &race); err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+
+		if err := stream.Send(&race); err != nil {
+			// The server has ended the stream, with an error returned by
+			// CloseAndRecv.
+			if errors.Is(err, io.EOF) {
+				break
+			}
+
+			return err
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	resp, err := stream.CloseAndRecv()
+	if err != nil {
+		return err
+	}
+
+	return printJSON(resp)
+}
+
+func listMarkets(ctx context.Context, client racing.RacingClient, fs *flag.FlagSet, args []string) error {
+	var raceIDs idList
+
+	fs.Var(&raceIDs, "race-ids", "Comma separated race IDs to filter by")
+	_ = fs.Parse(args)
+
+	resp, err := client.ListMarkets(ctx, &racing.ListMarketsRequest{
+		Filter: &racing.ListMarketsRequestFilter{RaceIds: raceIDs},
+	})
+	if err != nil {
+		return err
+	}
+
+	return printJSON(resp)
+}
+
+func getMarket(ctx context.Context, client racing.RacingClient, fs *flag.FlagSet, args []string) error {
+	id := fs.Int64("id", 0, "ID of the market")
+	_ = fs.Parse(args)
+
+	resp, err := client.GetMarket(ctx, &racing.GetMarketRequest{Id: *id})
+	if err != nil {
+		return err
+	}
+
+	return printJSON(resp)
+}
+
+func listSelections(ctx context.Context, client racing.RacingClient, fs *flag.FlagSet, args []string) error {
+	var marketIDs idList
+
+	fs.Var(&marketIDs, "market-ids", "Comma separated market IDs to filter by")
+	_ = fs.Parse(args)
+
+	resp, err := client.ListSelections(ctx, &racing.ListSelectionsRequest{
+		Filter: &racing.ListSelectionsRequestFilter{MarketIds: marketIDs},
+	})
+	if err != nil {
+		return err
+	}
+
+	return printJSON(resp)
+}
+
+func getSelection(ctx context.Context, client racing.RacingClient, fs *flag.FlagSet, args []string) error {
+	id := fs.Int64("id", 0, "ID of the selection")
+	_ = fs.Parse(args)
+
+	resp, err := client.GetSelection(ctx, &racing.GetSelectionRequest{Id: *id})
+	if err != nil {
+		return err
+	}
+
+	return printJSON(resp)
+}
+
+func createSubscription(ctx context.Context, client racing.RacingClient, fs *flag.FlagSet, args []string) error {
+	var meetingIDs idList
+
+	callbackURL := fs.String("callback-url", "", "Absolute http(s) URL to POST notifications to")
+	fs.Var(&meetingIDs, "meeting-ids", "Comma separated meeting IDs to be notified of (empty is every meeting)")
+	_ = fs.Parse(args)
+
+	resp, err := client.CreateSubscription(ctx, &racing.CreateSubscriptionRequest{
+		CallbackUrl: *callbackURL,
+		Filter:      &racing.SubscriptionFilter{MeetingIds: meetingIDs},
+	})
+	if err != nil {
+		return err
+	}
+
+	return printJSON(resp)
+}
+
+func deleteSubscription(ctx context.Context, client racing.RacingClient, fs *flag.FlagSet, args []string) error {
+	id := fs.Int64("id", 0, "ID of the subscription")
+	_ = fs.Parse(args)
+
+	resp, err := client.DeleteSubscription(ctx, &racing.DeleteSubscriptionRequest{Id: *id})
+	if err != nil {
+		return err
+	}
+
+	return printJSON(resp)
+}
+
+func listDeliveries(ctx context.Context, client racing.RacingClient, fs *flag.FlagSet, args []string) error {
+	id := fs.Int64("id", 0, "ID of the subscription")
+	_ = fs.Parse(args)
+
+	resp, err := client.ListDeliveries(ctx, &racing.ListDeliveriesRequest{SubscriptionId: *id})
+	if err != nil {
+		return err
+	}
+
+	return printJSON(resp)
+}
+
+func registerDevice(ctx context.Context, client racing.RacingClient, fs *flag.FlagSet, args []string) error {
+	var raceIDs, meetingIDs idList
+
+	channel := fs.String("channel", "", "Push service the token belongs to, e.g. push")
+	token := fs.String("token", "", "Device's address on the channel")
+	fs.Var(&raceIDs, "race-ids", "Comma separated race IDs to follow")
+	fs.Var(&meetingIDs, "meeting-ids", "Comma separated meeting IDs to follow every race of")
+	followWatchlist := fs.Bool("follow-watchlist", false, "Follow the -user-id user's watchlist")
+	_ = fs.Parse(args)
+
+	resp, err := client.RegisterDevice(ctx, &racing.RegisterDeviceRequest{
+		Channel:         *channel,
+		Token:           *token,
+		Filter:          &racing.DeviceFilter{RaceIds: raceIDs, MeetingIds: meetingIDs},
+		FollowWatchlist: *followWatchlist,
+	})
+	if err != nil {
+		return err
+	}
+
+	return printJSON(resp)
+}
+
+func unregisterDevice(ctx context.Context, client racing.RacingClient, fs *flag.FlagSet, args []string) error {
+	channel := fs.String("channel", "", "Push service the token belongs to")
+	token := fs.String("token", "", "Device's address on the channel")
+	_ = fs.Parse(args)
+
+	resp, err := client.UnregisterDevice(ctx, &racing.UnregisterDeviceRequest{Channel: *channel, Token: *token})
+	if err != nil {
+		return err
+	}
+
+	return printJSON(resp)
+}
+
+func watchRace(ctx context.Context, client racing.RacingClient, fs *flag.FlagSet, args []string) error {
+	raceID := fs.Int64("race-id", 0, "ID of the race")
+	_ = fs.Parse(args)
+
+	resp, err := client.WatchRace(ctx, &racing.WatchRaceRequest{RaceId: *raceID})
+	if err != nil {
+		return err
+	}
+
+	return printJSON(resp)
+}
+
+func unwatchRace(ctx context.Context, client racing.RacingClient, fs *flag.FlagSet, args []string) error {
+	raceID := fs.Int64("race-id", 0, "ID of the race")
+	_ = fs.Parse(args)
+
+	resp, err := client.UnwatchRace(ctx, &racing.UnwatchRaceRequest{RaceId: *raceID})
+	if err != nil {
+		return err
+	}
+
+	return printJSON(resp)
+}
+
+func listWatchlist(ctx context.Context, client racing.RacingClient, fs *flag.FlagSet, args []string) error {
+	timeZone := fs.String("time-zone", "", "IANA time zone to format local start times in")
+	locale := fs.String("locale", "", "BCP 47 language tag to return race names in")
+	_ = fs.Parse(args)
+
+	resp, err := client.ListWatchlist(ctx, &racing.ListWatchlistRequest{TimeZone: *timeZone, Locale: *locale})
+	if err != nil {
+		return err
+	}
+
+	return printJSON(resp)
+}
+
+func getReconciliationReport(ctx context.Context, client racing.RacingClient, fs *flag.FlagSet, args []string) error {
+	_ = fs.Parse(args)
+
+	resp, err := client.GetReconciliationReport(ctx, &racing.GetReconciliationReportRequest{})
+	if err != nil {
+		return err
+	}
+
+	return printJSON(resp)
+}
@@ -0,0 +1,152 @@
+// Command entainctl is a command-line client for the racing service, for
+// operating and smoke testing environments.
+//
+// Usage:
+//
+//	entainctl [-grpc-endpoint localhost:9000] [-timeout 10s] [-idempotency-key key] <resource> <action> [flags]
+//
+// Run entainctl without arguments to list its commands, or a command with -h
+// to list its flags. Responses are printed as JSON.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"git.neds.sh/matty/entain/racing/proto/racing"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+var (
+	grpcEndpoint   = flag.String("grpc-endpoint", "localhost:9000", "gRPC endpoint of the racing service")
+	timeout        = flag.Duration("timeout", 10*time.Second, "How long to wait for a response (streaming commands run until interrupted)")
+	idempotencyKey = flag.String("idempotency-key", "", "Key identifying the request, so retrying a command with the same key doesn't repeat it")
+	jurisdiction   = flag.String("jurisdiction", "", "Jurisdiction (e.g. NSW) to make the request from, hiding races restricted in it")
+	brand          = flag.String("brand", "", "Brand (e.g. neds) to make the request through, showing races as they are for it")
+	userID         = flag.String("user-id", "", "User to make the request as, e.g. whose watchlist is used, or who changes a race's status")
+)
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	if err := run(flag.Arg(0)+" "+flag.Arg(1), flag.Args()[2:]); err != nil {
+		fmt.Fprintf(os.Stderr, "entainctl: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(name string, args []string) error {
+	cmd, ok := commands[name]
+	if !ok {
+		return fmt.Errorf("unknown command %q, run entainctl without arguments to list commands", name)
+	}
+
+	conn, err := grpc.Dial(*grpcEndpoint, grpc.WithInsecure())
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	ctx := context.Background()
+
+	if *idempotencyKey != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, "idempotency-key", *idempotencyKey)
+	}
+
+	if *jurisdiction != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, "x-jurisdiction", *jurisdiction)
+	}
+
+	if *brand != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, "x-brand", *brand)
+	}
+
+	if *userID != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, "x-user-id", *userID)
+	}
+
+	if !cmd.streaming {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *timeout)
+		defer cancel()
+	}
+
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+
+	return cmd.run(ctx, racing.NewRacingClient(conn), fs, args)
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: entainctl [flags] <resource> <action> [flags]\n\nFlags:\n")
+	flag.PrintDefaults()
+
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintf(os.Stderr, "\nCommands:\n")
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-24s %s\n", name, commands[name].summary)
+	}
+}
+
+// printJSON writes a response to stdout as JSON.
+func printJSON(m proto.Message) error {
+	out, err := protojson.MarshalOptions{Multiline: true}.Marshal(m)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Println(string(out))
+
+	return err
+}
+
+// idList is a flag holding a comma separated list of IDs.
+type idList []int64
+
+func (l *idList) String() string {
+	return fmt.Sprint([]int64(*l))
+}
+
+func (l *idList) Set(s string) error {
+	for _, field := range strings.Split(s, ",") {
+		var id int64
+		if _, err := fmt.Sscan(field, &id); err != nil {
+			return fmt.Errorf("invalid ID %q", field)
+		}
+
+		*l = append(*l, id)
+	}
+
+	return nil
+}
+
+// stringList is a flag holding a comma separated list of strings.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(s string) error {
+	*l = append(*l, strings.Split(s, ",")...)
+
+	return nil
+}
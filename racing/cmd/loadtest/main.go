@@ -0,0 +1,122 @@
+// Command loadtest drives a load test scenario at the racing service, or the
+// REST gateway in front of it, and checks latencies and errors stay within
+// thresholds, exiting non-zero if they don't. Run it against an environment
+// sized like production, to validate capacity ahead of big events.
+//
+// Usage:
+//
+//	loadtest [-target grpc|gateway] [-scenario ramp-up|spike|sustained] [-rate 500] [-duration 1m] [-p99 250ms] [-max-error-rate 0.01]
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"time"
+
+	"git.neds.sh/matty/entain/racing/loadtest"
+	"git.neds.sh/matty/entain/racing/proto/racing"
+	"google.golang.org/grpc"
+)
+
+var (
+	target       = flag.String("target", "grpc", "What to load: grpc (the racing service) or gateway (the REST gateway)")
+	grpcEndpoint = flag.String("grpc-endpoint", "localhost:9000", "gRPC endpoint of the racing service")
+	gatewayURL   = flag.String("gateway-url", "http://localhost:8000", "Base URL of the REST gateway")
+	scenarioName = flag.String("scenario", "ramp-up", "Load test scenario: ramp-up, spike (a tenth of -rate, jumping to -rate) or sustained")
+	rate         = flag.Float64("rate", 100, "Peak requests per second")
+	duration     = flag.Duration("duration", time.Minute, "How long the scenario runs")
+	maxInFlight  = flag.Int("max-in-flight", 1000, "Most requests waiting on a response at once")
+	requestTTL   = flag.Duration("request-timeout", 5*time.Second, "How long each request may take before it fails")
+	p50          = flag.Duration("p50", 0, "Fail if median latency is over this (0 doesn't check)")
+	p95          = flag.Duration("p95", 0, "Fail if 95th percentile latency is over this (0 doesn't check)")
+	p99          = flag.Duration("p99", 250*time.Millisecond, "Fail if 99th percentile latency is over this (0 doesn't check)")
+	maxErrorRate = flag.Float64("max-error-rate", 0.01, "Fail if more than this proportion of requests fail, from 0 to 1 (0 doesn't check)")
+)
+
+func main() {
+	flag.Parse()
+
+	if err := run(); err != nil {
+		fmt.Fprintf(os.Stderr, "loadtest: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+func run() error {
+	scenario, err := loadtest.NewScenario(*scenarioName, *rate, *duration, loadtest.Thresholds{
+		P50:          *p50,
+		P95:          *p95,
+		P99:          *p99,
+		MaxErrorRate: *maxErrorRate,
+	})
+	if err != nil {
+		return err
+	}
+
+	t, err := newTarget()
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	fmt.Printf("running %s scenario against %s, up to %g requests/s for %s\n", scenario.Name, *target, *rate, scenario.Duration())
+
+	result := loadtest.Run(ctx, scenario, withTimeout(t, *requestTTL), *maxInFlight)
+
+	fmt.Printf("requests: %d over %s (%.1f/s)\n", result.Requests, result.Duration.Round(time.Millisecond), float64(result.Requests)/result.Duration.Seconds())
+	fmt.Printf("errors:   %d (%.2f%%)\n", result.Errors, result.ErrorRate()*100)
+	fmt.Printf("latency:  p50 %s, p95 %s, p99 %s, max %s\n", result.P50, result.P95, result.P99, result.Max)
+
+	if result.FirstError != nil {
+		fmt.Printf("first error: %s\n", result.FirstError)
+	}
+
+	if violations := result.Violations(scenario.Thresholds); len(violations) > 0 {
+		for _, violation := range violations {
+			fmt.Printf("FAIL: %s\n", violation)
+		}
+
+		return fmt.Errorf("%s scenario broke %d thresholds", scenario.Name, len(violations))
+	}
+
+	fmt.Println("PASS")
+
+	return nil
+}
+
+// newTarget creates the target selected by the -target flag.
+func newTarget() (loadtest.Target, error) {
+	switch *target {
+	case "grpc":
+		conn, err := grpc.Dial(*grpcEndpoint, grpc.WithInsecure())
+		if err != nil {
+			return nil, err
+		}
+
+		return loadtest.ListRaces(racing.NewRacingClient(conn), nil), nil
+	case "gateway":
+		client := &http.Client{
+			Transport: &http.Transport{MaxIdleConnsPerHost: *maxInFlight},
+		}
+
+		return loadtest.GatewayListRaces(client, *gatewayURL, []byte(`{}`)), nil
+	default:
+		return nil, fmt.Errorf("unknown target %q, want grpc or gateway", *target)
+	}
+}
+
+// withTimeout fails requests that take longer than the timeout.
+func withTimeout(t loadtest.Target, timeout time.Duration) loadtest.Target {
+	return func(ctx context.Context) error {
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+
+		return t(ctx)
+	}
+}
@@ -0,0 +1,253 @@
+// Command smoketest checks a deployed racing service and REST gateway are
+// up and answering, for verifying deployments. Each check has a tight
+// deadline, and smoketest prints a report of them, exiting non-zero if any
+// failed.
+//
+// Usage:
+//
+//	smoketest [-grpc-endpoint localhost:9000] [-gateway-url http://localhost:8000] [-metrics-url http://localhost:9100] [-timeout 2s]
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"git.neds.sh/matty/entain/racing/proto/racing"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health/grpc_health_v1"
+)
+
+var (
+	grpcEndpoint = flag.String("grpc-endpoint", "localhost:9000", "gRPC endpoint of the racing service")
+	gatewayURL   = flag.String("gateway-url", "http://localhost:8000", "Base URL of the REST gateway")
+	metricsURL   = flag.String("metrics-url", "http://localhost:9100", "Base URL of the racing service's metrics endpoint (empty skips checking it)")
+	timeout      = flag.Duration("timeout", 2*time.Second, "How long each check may take before it fails")
+)
+
+// errSkipped marks a check that couldn't run, e.g. as there was nothing to
+// look up.
+var errSkipped = errors.New("skipped")
+
+// check is a single smoke test.
+type check struct {
+	name string
+	run  func(ctx context.Context) error
+}
+
+// result is the outcome of a check.
+type result struct {
+	name     string
+	err      error
+	duration time.Duration
+}
+
+func main() {
+	flag.Parse()
+
+	conn, err := grpc.Dial(*grpcEndpoint, grpc.WithInsecure())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "smoketest: %s\n", err)
+		os.Exit(1)
+	}
+	defer conn.Close()
+
+	s := &smokeTest{
+		client:     racing.NewRacingClient(conn),
+		health:     grpc_health_v1.NewHealthClient(conn),
+		httpClient: &http.Client{},
+	}
+
+	results := runChecks(s.checks())
+
+	failed := report(os.Stdout, results)
+	if failed > 0 {
+		fmt.Fprintf(os.Stderr, "smoketest: %d of %d checks failed\n", failed, len(results))
+		os.Exit(1)
+	}
+}
+
+// runChecks runs each check in turn, with its own deadline.
+func runChecks(checks []check) []result {
+	results := make([]result, 0, len(checks))
+
+	for _, c := range checks {
+		ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+
+		start := time.Now()
+		err := c.run(ctx)
+		results = append(results, result{name: c.name, err: err, duration: time.Since(start)})
+
+		cancel()
+	}
+
+	return results
+}
+
+// report prints the results of the checks, returning how many failed.
+func report(w io.Writer, results []result) int {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	failed := 0
+
+	for _, r := range results {
+		outcome, detail := "PASS", ""
+
+		switch {
+		case errors.Is(r.err, errSkipped):
+			outcome, detail = "SKIP", r.err.Error()
+		case r.err != nil:
+			outcome, detail = "FAIL", r.err.Error()
+			failed++
+		}
+
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", outcome, r.name, r.duration.Round(time.Millisecond), detail)
+	}
+
+	tw.Flush()
+
+	return failed
+}
+
+// smokeTest holds the clients the checks are made with, and what earlier
+// checks found for later ones to use.
+type smokeTest struct {
+	client     racing.RacingClient
+	health     grpc_health_v1.HealthClient
+	httpClient *http.Client
+
+	// ref is the external reference of a race found by listing races, for
+	// looking it up.
+	ref *racing.ExternalRef
+}
+
+// checks returns the checks to run, in order.
+func (s *smokeTest) checks() []check {
+	checks := []check{
+		{"racing health (grpc)", s.checkHealth},
+		{"list races (grpc)", s.checkListRaces},
+		{"get race (grpc)", s.checkGetRace},
+		{"list races (gateway)", s.checkGatewayListRaces},
+		{"get race (gateway)", s.checkGatewayGetRace},
+	}
+
+	if *metricsURL != "" {
+		checks = append(checks, check{"racing metrics", s.checkMetrics})
+	}
+
+	return checks
+}
+
+// checkHealth checks the racing service reports itself as serving.
+func (s *smokeTest) checkHealth(ctx context.Context) error {
+	resp, err := s.health.Check(ctx, &grpc_health_v1.HealthCheckRequest{})
+	if err != nil {
+		return err
+	}
+
+	if resp.Status != grpc_health_v1.HealthCheckResponse_SERVING {
+		return fmt.Errorf("status is %s", resp.Status)
+	}
+
+	return nil
+}
+
+// checkListRaces lists races, remembering one to look up.
+func (s *smokeTest) checkListRaces(ctx context.Context) error {
+	resp, err := s.client.ListRaces(ctx, &racing.ListRacesRequest{})
+	if err != nil {
+		return err
+	}
+
+	for _, race := range resp.Races {
+		if race.ExternalRef != nil {
+			s.ref = race.ExternalRef
+			break
+		}
+	}
+
+	return nil
+}
+
+// checkGetRace looks up the race found by listing races.
+func (s *smokeTest) checkGetRace(ctx context.Context) error {
+	if s.ref == nil {
+		return fmt.Errorf("%w: no race with an external reference was listed", errSkipped)
+	}
+
+	race, err := s.client.GetRaceByExternalRef(ctx, &racing.GetRaceByExternalRefRequest{ExternalRef: s.ref})
+	if err != nil {
+		return err
+	}
+
+	if race.ExternalRef.GetSourceId() != s.ref.SourceId {
+		return fmt.Errorf("got race %s/%s, want %s/%s", race.ExternalRef.GetSource(), race.ExternalRef.GetSourceId(), s.ref.Source, s.ref.SourceId)
+	}
+
+	return nil
+}
+
+// checkGatewayListRaces lists races through the REST gateway.
+func (s *smokeTest) checkGatewayListRaces(ctx context.Context) error {
+	var resp struct {
+		Races []json.RawMessage `json:"races"`
+	}
+
+	return s.getJSON(ctx, http.MethodPost, *gatewayURL+"/v1/list-races", []byte(`{}`), &resp)
+}
+
+// checkGatewayGetRace looks up the race found by listing races, through the
+// REST gateway.
+func (s *smokeTest) checkGatewayGetRace(ctx context.Context) error {
+	if s.ref == nil {
+		return fmt.Errorf("%w: no race with an external reference was listed", errSkipped)
+	}
+
+	var race struct {
+		Name string `json:"name"`
+	}
+
+	return s.getJSON(ctx, http.MethodGet, *gatewayURL+"/v1/races/external/"+url.PathEscape(s.ref.Source)+"/"+url.PathEscape(s.ref.SourceId), nil, &race)
+}
+
+// checkMetrics checks the racing service is serving its metrics.
+func (s *smokeTest) checkMetrics(ctx context.Context) error {
+	var vars map[string]json.RawMessage
+
+	return s.getJSON(ctx, http.MethodGet, *metricsURL+"/debug/vars", nil, &vars)
+}
+
+// getJSON makes a request, decoding its JSON response, and returning an error
+// unless it succeeded.
+func (s *smokeTest) getJSON(ctx context.Context, method, url string, body []byte, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+
+		return fmt.Errorf("unexpected response: %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+
+	return json.NewDecoder(resp.Body).Decode(out)
+}
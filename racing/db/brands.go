@@ -0,0 +1,111 @@
+package db
+
+import (
+	"context"
+	"strings"
+)
+
+// brandKey keys the brand a request was made through in its context.
+type brandKey struct{}
+
+// ContextWithBrand returns a copy of ctx made through the given brand (e.g.
+// neds), so races are shown or hidden as they are for it.
+func ContextWithBrand(ctx context.Context, brand string) context.Context {
+	return context.WithValue(ctx, brandKey{}, brand)
+}
+
+// BrandFromContext returns the brand ctx was made through, if it says.
+func BrandFromContext(ctx context.Context) (string, bool) {
+	brand, ok := ctx.Value(brandKey{}).(string)
+
+	return brand, ok
+}
+
+func (r *racesRepo) SetBrandVisibility(raceID int64, brand string, visible *bool) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var exists bool
+	if err := tx.QueryRow(getRaceQueries()[raceUnarchived], raceID).Scan(&exists); err != nil {
+		return err
+	}
+
+	if !exists {
+		return ErrNotFound
+	}
+
+	if visible == nil {
+		_, err = tx.Exec(getRaceQueries()[brandVisibilityClear], raceID, brand)
+	} else {
+		_, err = tx.Exec(getRaceQueries()[brandVisibilitySet], raceID, brand, *visible)
+	}
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(getRaceQueries()[raceTouch], raceID); err != nil {
+		return err
+	}
+
+	rows, err := tx.Query(getRaceQueries()[racesList]+" WHERE id = ?", raceID)
+	if err != nil {
+		return err
+	}
+
+	races, err := r.scanRaces(rows)
+	if err != nil {
+		return err
+	}
+
+	if len(races) == 0 {
+		return ErrNotFound
+	}
+
+	// Only the brand's view of the race changed, not its own visibility or
+	// status.
+	if err := r.writeRaceChanged(tx, races[0], races[0].Visible, races[0].Status); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *racesRepo) BrandVisibility(raceIDs []int64, brand string) (map[int64]bool, error) {
+	visibility := make(map[int64]bool)
+
+	if len(raceIDs) == 0 || brand == "" {
+		return visibility, nil
+	}
+
+	query := getRaceQueries()[brandVisibilityList] +
+		" AND race_id IN (" + strings.Repeat("?,", len(raceIDs)-1) + "?)"
+
+	args := []interface{}{brand}
+	for _, raceID := range raceIDs {
+		args = append(args, raceID)
+	}
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			raceID  int64
+			visible bool
+		)
+
+		if err := rows.Scan(&raceID, &visible); err != nil {
+			return nil, err
+		}
+
+		visibility[raceID] = visible
+	}
+
+	return visibility, rows.Err()
+}
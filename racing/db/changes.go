@@ -0,0 +1,100 @@
+package db
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"git.neds.sh/matty/entain/racing/proto/racing"
+	"github.com/golang/protobuf/ptypes"
+)
+
+func (r *racesRepo) Changes(after int64, limit int) ([]*racing.RaceChange, error) {
+	rows, err := r.db.Query(getRaceQueries()[raceChangesList], after, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var (
+		changes []*racing.RaceChange
+		raceIDs []interface{}
+	)
+
+	for rows.Next() {
+		var (
+			change    racing.RaceChange
+			id        int64
+			operation string
+			changedAt time.Time
+		)
+
+		if err := rows.Scan(&id, &change.RaceId, &change.MeetingId, &operation, &changedAt); err != nil {
+			return nil, err
+		}
+
+		ts, err := ptypes.TimestampProto(changedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		change.Token = strconv.FormatInt(id, 10)
+		change.Operation = racing.RaceChange_Operation(racing.RaceChange_Operation_value[operation])
+		change.ChangedAt = ts
+
+		if change.Operation == racing.RaceChange_UPSERTED {
+			raceIDs = append(raceIDs, change.RaceId)
+		}
+
+		changes = append(changes, &change)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(raceIDs) == 0 {
+		return changes, nil
+	}
+
+	byID, err := r.racesByID(raceIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, change := range changes {
+		if change.Operation == racing.RaceChange_UPSERTED {
+			change.Race = byID[change.RaceId]
+		}
+	}
+
+	return changes, nil
+}
+
+// racesByID returns the races with the given IDs that are still in the races
+// table, keyed by their ID.
+func (r *racesRepo) racesByID(raceIDs []interface{}) (map[int64]*racing.Race, error) {
+	byID := make(map[int64]*racing.Race, len(raceIDs))
+
+	if len(raceIDs) == 0 {
+		return byID, nil
+	}
+
+	query := getRaceQueries()[racesList] + " WHERE id IN (" + strings.Repeat("?,", len(raceIDs)-1) + "?)"
+
+	rows, err := r.query(query, raceIDs...)
+	if err != nil {
+		return nil, err
+	}
+
+	races, err := r.scanRaces(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, race := range races {
+		byID[race.Id] = race
+	}
+
+	return byID, nil
+}
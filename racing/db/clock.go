@@ -0,0 +1,49 @@
+package db
+
+import (
+	"context"
+	"time"
+)
+
+// Clock tells the time. Repositories read "now" from a clock, rather than
+// the system time directly, so tests can control it.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+}
+
+// ClockFunc adapts a function to a Clock.
+type ClockFunc func() time.Time
+
+// Now calls the function.
+func (f ClockFunc) Now() time.Time {
+	return f()
+}
+
+// SystemClock is the clock repositories use unless given another, telling the
+// system time.
+var SystemClock Clock = ClockFunc(time.Now)
+
+// FixedClock returns a clock that is stopped at the given time.
+func FixedClock(at time.Time) Clock {
+	return ClockFunc(func() time.Time {
+		return at
+	})
+}
+
+// nowKey keys the time a request pretends it is in its context.
+type nowKey struct{}
+
+// ContextWithNow returns a copy of ctx in which it is the given time, for QA
+// to see how races behave around their start without waiting for it.
+func ContextWithNow(ctx context.Context, now time.Time) context.Context {
+	return context.WithValue(ctx, nowKey{}, now)
+}
+
+// NowFromContext returns the time it is in ctx, if it pretends to be another
+// time.
+func NowFromContext(ctx context.Context) (time.Time, bool) {
+	now, ok := ctx.Value(nowKey{}).(time.Time)
+
+	return now, ok
+}
@@ -0,0 +1,92 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"git.neds.sh/matty/entain/racing/proto/racing"
+	"git.neds.sh/matty/entain/racing/racetest"
+)
+
+func TestRacesRepoCountsDownFromClock(t *testing.T) {
+	now := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	repo, testDB := newTestRacesRepo(t)
+	repo.clock = FixedClock(now)
+
+	race := racetest.NewRace().StartsAt(now.Add(90 * time.Second)).Build()
+	insertRace(t, testDB, race)
+
+	races, err := repo.List(nil)
+	if err != nil {
+		t.Fatalf("List() error = %s", err)
+	}
+
+	if len(races) != 1 {
+		t.Fatalf("List() returned %d races, want 1", len(races))
+	}
+
+	if got := races[0].SecondsToStart; got != 90 {
+		t.Errorf("SecondsToStart = %d, want 90", got)
+	}
+
+	// Moving the clock on counts down from the new time, past the start.
+	repo.clock = FixedClock(now.Add(2 * time.Minute))
+
+	if races, err = repo.List(nil); err != nil {
+		t.Fatalf("List() error = %s", err)
+	}
+
+	if got := races[0].SecondsToStart; got != -30 {
+		t.Errorf("SecondsToStart = %d, want -30", got)
+	}
+}
+
+func TestRacesRepoRecordsStatusAtClock(t *testing.T) {
+	now := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	repo, testDB := newTestRacesRepo(t)
+	repo.clock = FixedClock(now)
+
+	race := racetest.NewRace().Build()
+	insertRace(t, testDB, race)
+
+	if _, err := repo.UpdateStatus(race.Id, racing.Race_CLOSED, "test"); err != nil {
+		t.Fatalf("UpdateStatus() error = %s", err)
+	}
+
+	history, err := repo.StatusHistory(race.Id)
+	if err != nil {
+		t.Fatalf("StatusHistory() error = %s", err)
+	}
+
+	if len(history) != 1 {
+		t.Fatalf("StatusHistory() returned %d transitions, want 1", len(history))
+	}
+
+	if got := history[0].ChangedAt.AsTime(); !got.Equal(now) {
+		t.Errorf("ChangedAt = %s, want %s", got, now)
+	}
+}
+
+func TestSeedStatus(t *testing.T) {
+	now := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		start time.Time
+		want  racing.Race_Status
+	}{
+		{"not started", now.Add(time.Minute), racing.Race_OPEN},
+		{"just started", now.Add(-time.Minute), racing.Race_INTERIM},
+		{"long finished", now.Add(-time.Hour), racing.Race_FINAL},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := seedStatus(tt.start, now); got != tt.want {
+				t.Errorf("seedStatus() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
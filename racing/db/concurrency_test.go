@@ -0,0 +1,227 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"git.neds.sh/matty/entain/racing/proto/racing"
+	"git.neds.sh/matty/entain/racing/racetest"
+)
+
+// These tests share a repository between goroutines, and are most useful run
+// with the race detector:
+//
+//	go test -race ./db -run Concurrent
+
+const (
+	// workers is how many goroutines use the repository at once.
+	workers = 8
+	// iterations is how many times each goroutine uses the repository.
+	iterations = 25
+)
+
+// newTestFileDB opens an empty database in a temporary file, closed when the
+// test ends. Unlike :memory:, a file is shared by every connection in the
+// pool, so queries really do run concurrently.
+func newTestFileDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	testDB, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "racing.db"))
+	if err != nil {
+		t.Fatalf("opening test database: %s", err)
+	}
+
+	t.Cleanup(func() { testDB.Close() })
+
+	return testDB
+}
+
+// hammer runs fn on every worker at once, failing the test with the errors it
+// returns.
+func hammer(t *testing.T, fn func(worker, iteration int) error) {
+	t.Helper()
+
+	var (
+		wg   sync.WaitGroup
+		errs = make(chan error, workers*iterations)
+	)
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+
+		go func(worker int) {
+			defer wg.Done()
+
+			for i := 0; i < iterations; i++ {
+				if err := fn(worker, i); err != nil {
+					errs <- fmt.Errorf("worker %d, iteration %d: %w", worker, i, err)
+				}
+			}
+		}(w)
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+// seedConcurrentRaces initialises a races repository on the database, sharing
+// it between goroutines, with a race at each of a few meetings.
+func seedConcurrentRaces(t *testing.T, testDB *sql.DB) (RacesRepo, []*racing.Race) {
+	t.Helper()
+
+	repo := NewRacesRepo(testDB, WithoutDummyData())
+
+	// Initialising is safe from many goroutines, and only happens once.
+	hammer(t, func(int, int) error {
+		return repo.Init()
+	})
+
+	var races []*racing.Race
+
+	for meetingID := int64(1); meetingID <= 4; meetingID++ {
+		race := racetest.NewRace().
+			Meeting(meetingID).
+			Name(fmt.Sprintf("Meeting %d race", meetingID)).
+			Visible(meetingID%2 == 0).
+			ExternalRef("feed", fmt.Sprintf("R%d", meetingID)).
+			Build()
+		insertRace(t, testDB, race)
+
+		races = append(races, race)
+	}
+
+	return repo, races
+}
+
+func TestRacesRepoConcurrentReads(t *testing.T) {
+	testDB := newTestFileDB(t)
+	repo, races := seedConcurrentRaces(t, testDB)
+
+	hammer(t, func(worker, iteration int) error {
+		race := races[(worker+iteration)%len(races)]
+
+		switch iteration % 4 {
+		case 0:
+			listed, err := repo.List(nil)
+			if err != nil {
+				return err
+			}
+
+			if len(listed) != len(races) {
+				return fmt.Errorf("List() returned %d races, want %d", len(listed), len(races))
+			}
+		case 1:
+			listed, err := repo.List(&racing.ListRacesRequestFilter{MeetingIds: []int64{race.MeetingId}})
+			if err != nil {
+				return err
+			}
+
+			if len(listed) != 1 || listed[0].Id != race.Id {
+				return fmt.Errorf("List() for meeting %d = %v, want race %d", race.MeetingId, raceIDs(listed), race.Id)
+			}
+		case 2:
+			got, err := repo.GetByExternalRef("feed", race.ExternalRef.SourceId)
+			if err != nil {
+				return err
+			}
+
+			if got.Id != race.Id {
+				return fmt.Errorf("GetByExternalRef() = race %d, want %d", got.Id, race.Id)
+			}
+		default:
+			listed, err := repo.ListByIDs([]int64{race.Id})
+			if err != nil {
+				return err
+			}
+
+			if len(listed) != 1 || listed[0].Id != race.Id {
+				return fmt.Errorf("ListByIDs(%d) = %v", race.Id, raceIDs(listed))
+			}
+		}
+
+		return nil
+	})
+}
+
+func TestRacesRepoConcurrentReadsAndWrites(t *testing.T) {
+	testDB := newTestFileDB(t)
+	repo, races := seedConcurrentRaces(t, testDB)
+
+	hammer(t, func(worker, iteration int) error {
+		race := races[(worker+iteration)%len(races)]
+
+		// Half the workers write, moving races back and forth between
+		// visible and hidden, while the others read.
+		if worker%2 == 0 {
+			if _, err := repo.List(nil); err != nil {
+				return err
+			}
+
+			_, err := repo.GetByExternalRef("feed", race.ExternalRef.SourceId)
+
+			return err
+		}
+
+		update := racetest.NewRace().
+			Meeting(race.MeetingId).
+			Name(race.Name).
+			StartsAt(race.AdvertisedStartTime.AsTime()).
+			ExternalRef(race.ExternalRef.Source, race.ExternalRef.SourceId).
+			Visible(iteration%2 == 0).
+			Build()
+
+		_, err := repo.Upsert(update)
+
+		return err
+	})
+
+	// Every write landed on the races seeded, rather than adding races.
+	listed, err := repo.List(nil)
+	if err != nil {
+		t.Fatalf("List() error = %s", err)
+	}
+
+	if len(listed) != len(races) {
+		t.Errorf("List() returned %d races, want %d", len(listed), len(races))
+	}
+}
+
+func TestMarketsRepoConcurrentReads(t *testing.T) {
+	testDB := newTestFileDB(t)
+	_, races := seedConcurrentRaces(t, testDB)
+
+	repo := NewMarketsRepo(testDB)
+
+	hammer(t, func(int, int) error {
+		return repo.Init()
+	})
+
+	hammer(t, func(worker, iteration int) error {
+		race := races[(worker+iteration)%len(races)]
+
+		markets, err := repo.List(&racing.ListMarketsRequestFilter{RaceIds: []int64{race.Id}})
+		if err != nil {
+			return err
+		}
+
+		if len(markets) != len(marketNames) {
+			return fmt.Errorf("List() for race %d returned %d markets, want %d", race.Id, len(markets), len(marketNames))
+		}
+
+		market, err := repo.Get(markets[iteration%len(markets)].Id)
+		if err != nil {
+			return err
+		}
+
+		_, err = repo.ListSelections(&racing.ListSelectionsRequestFilter{MarketIds: []int64{market.Id}})
+
+		return err
+	})
+}
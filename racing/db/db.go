@@ -1,30 +1,753 @@
 package db
 
 import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
 	"time"
 
+	"git.neds.sh/matty/entain/racing/proto/racing"
 	"syreclabs.com/go/faker"
 )
 
+// venue describes where a seeded meeting is held.
+type venue struct {
+	timeZone string
+	country  string
+}
+
+// venues are the venues that seeded meetings are held at, indexed by meeting ID.
+var venues = []venue{
+	{"Australia/Melbourne", "AU"},
+	{"Australia/Sydney", "AU"},
+	{"Australia/Brisbane", "AU"},
+	{"Australia/Adelaide", "AU"},
+	{"Australia/Perth", "AU"},
+	{"Australia/Hobart", "AU"},
+	{"Australia/Darwin", "AU"},
+	{"Pacific/Auckland", "NZ"},
+	{"Asia/Hong_Kong", "HK"},
+	{"Europe/London", "GB"},
+}
+
+var (
+	// raceDistances are common race distances, in metres.
+	raceDistances = []string{"1000", "1100", "1200", "1400", "1600", "2000", "2400", "3200"}
+	// trackConditions are the standard track ratings.
+	trackConditions = []string{"Firm 1", "Good 3", "Good 4", "Soft 5", "Soft 7", "Heavy 8", "Heavy 10", "Synthetic"}
+	// weatherConditions are the weather descriptions reported by stewards.
+	weatherConditions = []string{"Fine", "Overcast", "Showery", "Raining"}
+)
+
+// seedFeedSource is the external source seeded races are referenced by.
+const seedFeedSource = "racing-feed"
+
+// SeedRandom seeds the random source that dummy races and markets are
+// generated from, so every empty database seeded with the same seed gets the
+// same data. Start times are generated around the races repository's clock,
+// so are only identical too with a fixed clock.
+func SeedRandom(seed int64) {
+	faker.Seed(seed)
+}
+
+// racesColumns defines the races table. IDs are never reused, even once the
+// race they were given to is archived or purged, so a race's ID can't come to
+// mean another race.
+const racesColumns = `id INTEGER PRIMARY KEY AUTOINCREMENT, meeting_id INTEGER, name TEXT, number INTEGER, visible INTEGER, advertised_start_time DATETIME, venue_time_zone TEXT, country TEXT, distance INTEGER, track_condition TEXT, weather TEXT, venue_image TEXT, external_source TEXT, external_id TEXT, status TEXT`
+
+func (r *racesRepo) createTables() error {
+	statement, err := r.db.Prepare(`CREATE TABLE IF NOT EXISTS races (` + racesColumns + `)`)
+	if err == nil {
+		_, err = statement.Exec()
+	}
+
+	if err == nil {
+		statement, err = r.db.Prepare(`CREATE TABLE IF NOT EXISTS races_archive (id INTEGER PRIMARY KEY, meeting_id INTEGER, name TEXT, number INTEGER, visible INTEGER, advertised_start_time DATETIME, venue_time_zone TEXT, country TEXT, distance INTEGER, track_condition TEXT, weather TEXT, venue_image TEXT, external_source TEXT, external_id TEXT, status TEXT, archived_at DATETIME)`)
+		if err == nil {
+			_, err = statement.Exec()
+		}
+	}
+
+	// Databases created by older versions have races, and archived races,
+	// without the columns added since.
+	for _, column := range []struct{ name, definition string }{
+		{"venue_time_zone", "TEXT DEFAULT ''"},
+		{"country", "TEXT DEFAULT ''"},
+		{"distance", "INTEGER DEFAULT 0"},
+		{"track_condition", "TEXT DEFAULT ''"},
+		{"weather", "TEXT DEFAULT ''"},
+		{"venue_image", "TEXT DEFAULT ''"},
+		{"external_source", "TEXT"},
+		{"external_id", "TEXT"},
+		// Races were all open until they had a status.
+		{"status", "TEXT DEFAULT 'OPEN'"},
+	} {
+		for _, table := range []string{"races", "races_archive"} {
+			if err == nil {
+				err = addColumn(r.db, table, column.name, column.definition)
+			}
+		}
+	}
+
+	// Rebuilding the table drops its indexes and triggers, so it comes before
+	// they're created.
+	if err == nil {
+		err = rebuildRacesWithoutReuse(r.db)
+	}
+
+	// The index is created apart from the table so that older databases get
+	// it too. Their races have no external references yet to clash.
+	if err == nil {
+		statement, err = r.db.Prepare(`CREATE UNIQUE INDEX IF NOT EXISTS races_external_ref ON races (external_source, external_id)`)
+		if err == nil {
+			_, err = statement.Exec()
+		}
+	}
+
+	// Race numbers are unique per meeting by an index, rather than by the
+	// table, so that older databases get it too, once the races they were
+	// seeded with that share a number are renumbered.
+	if err == nil {
+		err = renumberDuplicateRaces(r.db)
+	}
+
+	if err == nil {
+		statement, err = r.db.Prepare(`CREATE UNIQUE INDEX IF NOT EXISTS races_meeting_number ON races (meeting_id, number)`)
+		if err == nil {
+			_, err = statement.Exec()
+		}
+	}
+
+	if err == nil {
+		statement, err = r.db.Prepare(`CREATE TABLE IF NOT EXISTS race_names (race_id INTEGER, locale TEXT, name TEXT, PRIMARY KEY (race_id, locale))`)
+		if err == nil {
+			_, err = statement.Exec()
+		}
+	}
+
+	if err == nil {
+		statement, err = r.db.Prepare(`CREATE TABLE IF NOT EXISTS featured_races (race_id INTEGER PRIMARY KEY, featured_at DATETIME)`)
+		if err == nil {
+			_, err = statement.Exec()
+		}
+	}
+
+	if err == nil {
+		statement, err = r.db.Prepare(`CREATE TABLE IF NOT EXISTS race_restrictions (race_id INTEGER, jurisdiction TEXT, PRIMARY KEY (race_id, jurisdiction))`)
+		if err == nil {
+			_, err = statement.Exec()
+		}
+	}
+
+	if err == nil {
+		statement, err = r.db.Prepare(`CREATE TABLE IF NOT EXISTS race_brand_visibility (race_id INTEGER, brand TEXT, visible INTEGER, PRIMARY KEY (race_id, brand))`)
+		if err == nil {
+			_, err = statement.Exec()
+		}
+	}
+
+	if err == nil {
+		statement, err = r.db.Prepare(`CREATE TABLE IF NOT EXISTS race_views (race_id INTEGER, hour DATETIME, views INTEGER, PRIMARY KEY (race_id, hour))`)
+		if err == nil {
+			_, err = statement.Exec()
+		}
+	}
+
+	if err == nil {
+		statement, err = r.db.Prepare(`CREATE TABLE IF NOT EXISTS race_status_history (id INTEGER PRIMARY KEY AUTOINCREMENT, race_id INTEGER, from_status TEXT, to_status TEXT, actor TEXT, changed_at DATETIME)`)
+		if err == nil {
+			_, err = statement.Exec()
+		}
+	}
+
+	// Every change to a race is logged by triggers, so writes can't forget to
+	// log their changes.
+	for _, ddl := range []string{
+		`CREATE TABLE IF NOT EXISTS race_changes (id INTEGER PRIMARY KEY AUTOINCREMENT, race_id INTEGER, meeting_id INTEGER, operation TEXT, changed_at DATETIME)`,
+		`CREATE TRIGGER IF NOT EXISTS race_changes_insert AFTER INSERT ON races BEGIN INSERT INTO race_changes (race_id, meeting_id, operation, changed_at) VALUES (NEW.id, NEW.meeting_id, 'UPSERTED', strftime('%Y-%m-%dT%H:%M:%SZ', 'now')); END`,
+		`CREATE TRIGGER IF NOT EXISTS race_changes_update AFTER UPDATE ON races BEGIN INSERT INTO race_changes (race_id, meeting_id, operation, changed_at) VALUES (NEW.id, NEW.meeting_id, 'UPSERTED', strftime('%Y-%m-%dT%H:%M:%SZ', 'now')); END`,
+		`CREATE TRIGGER IF NOT EXISTS race_changes_delete AFTER DELETE ON races BEGIN INSERT INTO race_changes (race_id, meeting_id, operation, changed_at) VALUES (OLD.id, OLD.meeting_id, 'DELETED', strftime('%Y-%m-%dT%H:%M:%SZ', 'now')); END`,
+	} {
+		if err == nil {
+			statement, err = r.db.Prepare(ddl)
+			if err == nil {
+				_, err = statement.Exec()
+			}
+		}
+	}
+
+	if err == nil {
+		statement, err = r.db.Prepare(`CREATE TABLE IF NOT EXISTS race_events (id INTEGER PRIMARY KEY AUTOINCREMENT, race_id INTEGER, operation TEXT, meeting_id INTEGER, name TEXT, number INTEGER, visible INTEGER, advertised_start_time DATETIME, venue_time_zone TEXT, country TEXT, distance INTEGER, track_condition TEXT, weather TEXT, venue_image TEXT, external_source TEXT, external_id TEXT, status TEXT, recorded_at DATETIME)`)
+		if err == nil {
+			_, err = statement.Exec()
+		}
+	}
+
+	if err == nil {
+		statement, err = r.db.Prepare(`CREATE TABLE IF NOT EXISTS outbox (id INTEGER PRIMARY KEY AUTOINCREMENT, type TEXT, key TEXT, payload BLOB, created_at DATETIME)`)
+		if err == nil {
+			_, err = statement.Exec()
+		}
+	}
+
+	return err
+}
+
+// SeedConfig shapes the dummy races a races repository seeds, e.g. to
+// generate a large, realistic dataset for performance testing.
+type SeedConfig struct {
+	// Races is how many races are seeded.
+	Races int
+	// Meetings is how many meetings the races are spread across. Meetings are
+	// held at venues in turn, so there are more than one at a venue once there
+	// are more meetings than venues.
+	Meetings int
+	// StartsBefore and StartsAfter are how long before and after now races
+	// are advertised to start, spread evenly at random across the window.
+	StartsBefore time.Duration
+	StartsAfter  time.Duration
+	// VisibleRatio is the proportion of races that are visible, from 0 to 1.
+	VisibleRatio float64
+}
+
+// DefaultSeedConfig seeds 100 races across 10 meetings over the three days
+// around today, half of them visible.
+var DefaultSeedConfig = SeedConfig{
+	Races:        100,
+	Meetings:     len(venues),
+	StartsBefore: 24 * time.Hour,
+	StartsAfter:  48 * time.Hour,
+	VisibleRatio: 0.5,
+}
+
+// Validate returns an error describing why the config can't seed races.
+func (c SeedConfig) Validate() error {
+	switch {
+	case c.Races < 0:
+		return fmt.Errorf("can't seed %d races", c.Races)
+	case c.Meetings < 1:
+		return fmt.Errorf("can't seed races across %d meetings", c.Meetings)
+	case c.StartsBefore < 0 || c.StartsAfter < 0:
+		return errors.New("races must start within a window around now")
+	case c.VisibleRatio < 0 || c.VisibleRatio > 1:
+		return fmt.Errorf("visible ratio %g must be between 0 and 1", c.VisibleRatio)
+	}
+
+	return nil
+}
+
+// visibleOdds is the resolution that races are made visible at random with.
+const visibleOdds = 10000
+
 func (r *racesRepo) seed() error {
-	statement, err := r.db.Prepare(`CREATE TABLE IF NOT EXISTS races (id INTEGER PRIMARY KEY, meeting_id INTEGER, name TEXT, number INTEGER, visible INTEGER, advertised_start_time DATETIME)`)
+	if err := r.seedConfig.Validate(); err != nil {
+		return err
+	}
+
+	// Seed in a single transaction, so large datasets are written quickly.
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Races archived since they were seeded aren't seeded again, so their IDs
+	// aren't reused by races that would be archived over them.
+	insertRace, err := tx.Prepare(`INSERT OR IGNORE INTO races(id, meeting_id, name, number, visible, advertised_start_time, venue_time_zone, country, distance, track_condition, weather, venue_image, external_source, external_id, status) SELECT ?,?,?,?,?,?,?,?,?,?,?,?,?,?,? WHERE NOT EXISTS (SELECT 1 FROM races_archive WHERE id = ?)`)
+	if err != nil {
+		return err
+	}
+
+	recordStatus, err := tx.Prepare(`INSERT INTO race_status_history(race_id, from_status, to_status, actor, changed_at) VALUES (?,?,?,?,?)`)
+	if err != nil {
+		return err
+	}
+
+	insertName, err := tx.Prepare(`INSERT OR IGNORE INTO race_names(race_id, locale, name) VALUES (?,?,?)`)
+	if err != nil {
+		return err
+	}
+
+	// Races are numbered in order within their meeting, as race numbers must
+	// be unique per meeting.
+	meetingRaces := make(map[int]int)
+
+	now := r.clock.Now()
+	from, until := now.Add(-r.seedConfig.StartsBefore), now.Add(r.seedConfig.StartsAfter)
+
+	for i := 1; i <= r.seedConfig.Races; i++ {
+		meetingID := faker.RandomInt(1, r.seedConfig.Meetings)
+		venue := venues[(meetingID-1)%len(venues)]
+		number := meetingRaces[meetingID] + 1
+		meetingRaces[meetingID] = number
+
+		advertisedStart := faker.Time().Between(from, until)
+		status := seedStatus(advertisedStart, now)
+		visible := faker.RandomInt(0, visibleOdds-1) < int(r.seedConfig.VisibleRatio*visibleOdds)
+
+		res, err := insertRace.Exec(
+			i,
+			meetingID,
+			faker.Team().Name(),
+			number,
+			visible,
+			advertisedStart.Format(time.RFC3339),
+			venue.timeZone,
+			venue.country,
+			faker.RandomChoice(raceDistances),
+			faker.RandomChoice(trackConditions),
+			faker.RandomChoice(weatherConditions),
+			fmt.Sprintf("venues/%d.jpg", meetingID),
+			seedFeedSource,
+			fmt.Sprintf("R%07d", 1000000+i),
+			status.String(),
+			i,
+		)
+		if err != nil {
+			return err
+		}
+
+		// Only record the status of races that weren't already seeded.
+		inserted, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if inserted > 0 {
+			if _, err := recordStatus.Exec(i, racing.Race_UNSPECIFIED.String(), status.String(), "seed", now.UTC().Format(time.RFC3339)); err != nil {
+				return err
+			}
+		}
+
+		// Hong Kong races are also known by their number in Chinese.
+		if venue.country == "HK" {
+			if _, err := insertName.Exec(i, "zh-HK", fmt.Sprintf("第%d場", number)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return tx.Commit()
+}
+
+// seedStatus returns a plausible status, as of now, for a race advertised to
+// start at the given time.
+func seedStatus(advertisedStart, now time.Time) racing.Race_Status {
+	switch sinceStart := now.Sub(advertisedStart); {
+	case sinceStart < 0:
+		return racing.Race_OPEN
+	case sinceStart < 30*time.Minute:
+		return racing.Race_INTERIM
+	default:
+		return racing.Race_FINAL
+	}
+}
+
+// marketNames are the markets seeded for every race.
+var marketNames = []string{"Win", "Place"}
+
+func (r *marketsRepo) createTables() error {
+	statement, err := r.db.Prepare(`CREATE TABLE IF NOT EXISTS markets (id INTEGER PRIMARY KEY, race_id INTEGER, name TEXT, status TEXT)`)
 	if err == nil {
 		_, err = statement.Exec()
 	}
 
-	for i := 1; i <= 100; i++ {
-		statement, err = r.db.Prepare(`INSERT OR IGNORE INTO races(id, meeting_id, name, number, visible, advertised_start_time) VALUES (?,?,?,?,?,?)`)
+	if err == nil {
+		statement, err = r.db.Prepare(`CREATE TABLE IF NOT EXISTS selections (id INTEGER PRIMARY KEY, market_id INTEGER, name TEXT, price REAL, status TEXT)`)
 		if err == nil {
-			_, err = statement.Exec(
-				i,
-				faker.Number().Between(1, 10),
-				faker.Team().Name(),
-				faker.Number().Between(1, 12),
-				faker.Number().Between(0, 1),
-				faker.Time().Between(time.Now().AddDate(0, 0, -1), time.Now().AddDate(0, 0, 2)).Format(time.RFC3339),
-			)
+			_, err = statement.Exec()
 		}
 	}
 
 	return err
 }
+
+// seed seeds markets for every race, so they follow however many races were
+// seeded.
+func (r *marketsRepo) seed() error {
+	raceIDs, err := r.seedRaceIDs()
+	if err != nil {
+		return err
+	}
+
+	// Seed in a single transaction, so large datasets are written quickly.
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	insertMarket, err := tx.Prepare(`INSERT OR IGNORE INTO markets(id, race_id, name, status) VALUES (?,?,?,?)`)
+	if err != nil {
+		return err
+	}
+
+	insertSelection, err := tx.Prepare(`INSERT OR IGNORE INTO selections(id, market_id, name, price, status) VALUES (?,?,?,?,?)`)
+	if err != nil {
+		return err
+	}
+
+	for _, raceID := range raceIDs {
+		runners := make([]string, faker.RandomInt(6, 12))
+		for i := range runners {
+			runners[i] = faker.Name().FirstName() + " " + faker.Name().LastName()
+		}
+
+		for m, name := range marketNames {
+			marketID := (raceID-1)*int64(len(marketNames)) + int64(m) + 1
+
+			if _, err := insertMarket.Exec(marketID, raceID, name, racing.Market_OPEN.String()); err != nil {
+				return err
+			}
+
+			for s := range runners {
+				status := racing.Selection_ACTIVE
+				if faker.RandomInt(1, 10) == 1 {
+					status = racing.Selection_SCRATCHED
+				}
+
+				// Selection IDs are derived from their market, so re-seeding is a no-op.
+				if _, err := insertSelection.Exec(
+					marketID*100+int64(s)+1,
+					marketID,
+					runners[s],
+					float64(faker.RandomInt(101, 5000))/100,
+					status.String(),
+				); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return tx.Commit()
+}
+
+// seedRaceIDs returns the IDs of the races to seed markets for, in order.
+func (r *marketsRepo) seedRaceIDs() ([]int64, error) {
+	rows, err := r.db.Query(`SELECT id FROM races ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var raceIDs []int64
+
+	for rows.Next() {
+		var raceID int64
+		if err := rows.Scan(&raceID); err != nil {
+			return nil, err
+		}
+
+		raceIDs = append(raceIDs, raceID)
+	}
+
+	return raceIDs, rows.Err()
+}
+
+func (r *subscriptionsRepo) createTables() error {
+	statement, err := r.db.Prepare(`CREATE TABLE IF NOT EXISTS subscriptions (id INTEGER PRIMARY KEY, callback_url TEXT, meeting_ids TEXT, secret TEXT, last_change_id INTEGER, created_at DATETIME)`)
+	if err == nil {
+		_, err = statement.Exec()
+	}
+
+	if err == nil {
+		statement, err = r.db.Prepare(`CREATE TABLE IF NOT EXISTS deliveries (id INTEGER PRIMARY KEY, subscription_id INTEGER, change_id INTEGER, race_id INTEGER, payload BLOB, status TEXT, attempts INTEGER, next_attempt_at DATETIME, last_attempt_at DATETIME, last_response_code INTEGER, last_error TEXT)`)
+		if err == nil {
+			_, err = statement.Exec()
+		}
+	}
+
+	return err
+}
+
+// devicesColumns are the columns of the devices table, as created.
+const devicesColumns = `(id INTEGER PRIMARY KEY, channel TEXT, token TEXT, user_id TEXT, jurisdiction TEXT, brand TEXT, created_at DATETIME, UNIQUE (channel, token))`
+
+func (r *notificationsRepo) createTables() error {
+	statement, err := r.db.Prepare(`CREATE TABLE IF NOT EXISTS devices ` + devicesColumns)
+	if err == nil {
+		_, err = statement.Exec()
+	}
+
+	// Databases created before devices could follow a user's watchlist, or
+	// were registered for an audience, have no columns for them.
+	for _, column := range []string{"user_id", "jurisdiction", "brand"} {
+		if err == nil {
+			err = addColumn(r.db, "devices", column, "TEXT")
+		}
+	}
+
+	if err == nil {
+		statement, err = r.db.Prepare(`CREATE TABLE IF NOT EXISTS device_races (race_id INTEGER, device_id INTEGER, PRIMARY KEY (race_id, device_id))`)
+		if err == nil {
+			_, err = statement.Exec()
+		}
+	}
+
+	if err == nil {
+		statement, err = r.db.Prepare(`CREATE TABLE IF NOT EXISTS device_meetings (meeting_id INTEGER, device_id INTEGER, PRIMARY KEY (meeting_id, device_id))`)
+		if err == nil {
+			_, err = statement.Exec()
+		}
+	}
+
+	if err == nil {
+		err = moveDeviceFollows(r.db)
+	}
+
+	if err == nil {
+		statement, err = r.db.Prepare(`CREATE TABLE IF NOT EXISTS notification_cursors (name TEXT PRIMARY KEY, position INTEGER)`)
+		if err == nil {
+			_, err = statement.Exec()
+		}
+	}
+
+	if err == nil {
+		statement, err = r.db.Prepare(`CREATE TABLE IF NOT EXISTS watchlists (user_id TEXT, race_id INTEGER, watched_at DATETIME, PRIMARY KEY (user_id, race_id))`)
+		if err == nil {
+			_, err = statement.Exec()
+		}
+	}
+
+	return err
+}
+
+// addColumn adds a column to a table created before the column was, so
+// databases created by older versions keep working. Tables that already have
+// the column are left alone.
+func addColumn(db *sql.DB, table, column, definition string) error {
+	var found int
+
+	err := db.QueryRow(`SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?`, table, column).Scan(&found)
+	if err != nil || found != 0 {
+		return err
+	}
+
+	// Names can't be bound as parameters, but only come from callers here.
+	_, err = db.Exec(fmt.Sprintf(`ALTER TABLE %s ADD COLUMN %s %s`, table, column, definition))
+
+	return err
+}
+
+// moveDeviceFollows moves the races and meetings followed by devices
+// registered before they were kept a row each, from the comma separated IDs
+// they were kept as, rebuilding the devices table without them.
+func moveDeviceFollows(db *sql.DB) error {
+	var found int
+
+	err := db.QueryRow(`SELECT COUNT(*) FROM pragma_table_info('devices') WHERE name = 'race_ids'`).Scan(&found)
+	if err != nil || found == 0 {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`SELECT id, COALESCE(race_ids, ''), COALESCE(meeting_ids, '') FROM devices`)
+	if err != nil {
+		return err
+	}
+
+	type followed struct {
+		raceIDs, meetingIDs []int64
+	}
+
+	follows := make(map[int64]followed)
+
+	for rows.Next() {
+		var (
+			deviceID            int64
+			raceIDs, meetingIDs string
+		)
+
+		if err := rows.Scan(&deviceID, &raceIDs, &meetingIDs); err != nil {
+			rows.Close()
+			return err
+		}
+
+		var f followed
+
+		if f.raceIDs, err = parseIDs(raceIDs); err == nil {
+			f.meetingIDs, err = parseIDs(meetingIDs)
+		}
+		if err != nil {
+			rows.Close()
+			return err
+		}
+
+		follows[deviceID] = f
+	}
+
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for deviceID, f := range follows {
+		if err := setDeviceFollows(tx, deviceID, f.raceIDs, f.meetingIDs); err != nil {
+			return err
+		}
+	}
+
+	for _, statement := range []string{
+		`CREATE TABLE devices_rebuilt ` + devicesColumns,
+		`INSERT INTO devices_rebuilt (id, channel, token, user_id, jurisdiction, brand, created_at)
+			SELECT id, channel, token, user_id, jurisdiction, brand, created_at FROM devices`,
+		`DROP TABLE devices`,
+		`ALTER TABLE devices_rebuilt RENAME TO devices`,
+	} {
+		if _, err := tx.Exec(statement); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// rebuildRacesWithoutReuse rebuilds a races table created before race IDs
+// were never reused, from which archived races' IDs were given to new races.
+// Races created after the rebuild are given IDs after every race's, archived
+// or not.
+func rebuildRacesWithoutReuse(db *sql.DB) error {
+	var ddl string
+
+	err := db.QueryRow(`SELECT sql FROM sqlite_master WHERE type = 'table' AND name = 'races'`).Scan(&ddl)
+	if err != nil || strings.Contains(strings.ToUpper(ddl), "AUTOINCREMENT") {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`SELECT name FROM pragma_table_info('races')`)
+	if err != nil {
+		return err
+	}
+
+	var columns []string
+	for rows.Next() {
+		var column string
+		if err := rows.Scan(&column); err != nil {
+			rows.Close()
+			return err
+		}
+
+		columns = append(columns, column)
+	}
+
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	// Names can't be bound as parameters, but only come from the table here.
+	copied := strings.Join(columns, ", ")
+
+	for _, statement := range []string{
+		`CREATE TABLE races_rebuilt (` + racesColumns + `)`,
+		fmt.Sprintf(`INSERT INTO races_rebuilt (%s) SELECT %s FROM races`, copied, copied),
+		`DROP TABLE races`,
+		`ALTER TABLE races_rebuilt RENAME TO races`,
+		`DELETE FROM sqlite_sequence WHERE name = 'races'`,
+		`INSERT INTO sqlite_sequence (name, seq) SELECT 'races', COALESCE(MAX(id), 0) FROM (SELECT id FROM races UNION ALL SELECT id FROM races_archive)`,
+	} {
+		if _, err := tx.Exec(statement); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// renumberDuplicateRaces moves every race that shares its number with an
+// earlier race of the same meeting to the end of the meeting, so race numbers
+// can be made unique. Databases that already have them unique are left alone.
+func renumberDuplicateRaces(db *sql.DB) error {
+	var indexed int
+
+	err := db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'index' AND name = 'races_meeting_number'`).Scan(&indexed)
+	if err != nil || indexed != 0 {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`SELECT id, meeting_id, number FROM races r WHERE EXISTS (SELECT 1 FROM races o WHERE o.meeting_id = r.meeting_id AND o.number = r.number AND o.id < r.id) ORDER BY id`)
+	if err != nil {
+		return err
+	}
+
+	var duplicates [][3]int64
+	for rows.Next() {
+		var duplicate [3]int64
+		if err := rows.Scan(&duplicate[0], &duplicate[1], &duplicate[2]); err != nil {
+			rows.Close()
+			return err
+		}
+
+		duplicates = append(duplicates, duplicate)
+	}
+
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, duplicate := range duplicates {
+		id, meetingID, number := duplicate[0], duplicate[1], duplicate[2]
+
+		var renumbered int64
+		if err := tx.QueryRow(`SELECT MAX(number) + 1 FROM races WHERE meeting_id = ?`, meetingID).Scan(&renumbered); err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec(`UPDATE races SET number = ? WHERE id = ?`, renumbered, id); err != nil {
+			return err
+		}
+
+		log.Printf("renumbered race %d from race %d to race %d of meeting %d, which already had a race %d", id, number, renumbered, meetingID, number)
+	}
+
+	return tx.Commit()
+}
+
+func (r *reconciliationRepo) createTables() error {
+	statement, err := r.db.Prepare(`CREATE TABLE IF NOT EXISTS reconciliation_reports (source TEXT PRIMARY KEY, reconciled_at DATETIME, report BLOB)`)
+	if err == nil {
+		_, err = statement.Exec()
+	}
+
+	return err
+}
+
+func (r *idempotencyRepo) createTables() error {
+	statement, err := r.db.Prepare(`CREATE TABLE IF NOT EXISTS idempotency_keys (key TEXT, method TEXT, fingerprint BLOB, response BLOB, expires_at DATETIME, PRIMARY KEY (key, method))`)
+	if err == nil {
+		_, err = statement.Exec()
+	}
+
+	return err
+}
+
+func (r *leasesRepo) createTables() error {
+	statement, err := r.db.Prepare(`CREATE TABLE IF NOT EXISTS leases (name TEXT PRIMARY KEY, holder TEXT, expires_at DATETIME)`)
+	if err == nil {
+		_, err = statement.Exec()
+	}
+
+	return err
+}
@@ -0,0 +1,385 @@
+package db
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+	"time"
+
+	"git.neds.sh/matty/entain/racing/proto/racing"
+	"git.neds.sh/matty/entain/racing/racetest"
+	"google.golang.org/protobuf/proto"
+)
+
+// newTestDB opens an empty in-memory database, closed when the test ends.
+func newTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	testDB, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("opening test database: %s", err)
+	}
+
+	// Every connection to :memory: gets its own database, so share one.
+	testDB.SetMaxOpenConns(1)
+
+	t.Cleanup(func() { testDB.Close() })
+
+	return testDB
+}
+
+// newTestRacesRepo creates a races repository on an empty in-memory
+// database, without dummy races.
+func newTestRacesRepo(t *testing.T) (*racesRepo, *sql.DB) {
+	t.Helper()
+
+	testDB := newTestDB(t)
+
+	repo := NewRacesRepo(testDB, WithoutDummyData()).(*racesRepo)
+	if err := repo.Init(); err != nil {
+		t.Fatalf("initialising races repo: %s", err)
+	}
+
+	return repo, testDB
+}
+
+// insertRace writes a race fixture directly, setting its ID.
+func insertRace(t *testing.T, testDB *sql.DB, race *racing.Race) {
+	t.Helper()
+
+	var source, sourceID interface{}
+	if race.ExternalRef != nil {
+		source, sourceID = race.ExternalRef.Source, race.ExternalRef.SourceId
+	}
+
+	res, err := testDB.Exec(getRaceQueries()[racesInsert], append(raceFields(race), source, sourceID)...)
+	if err != nil {
+		t.Fatalf("inserting race %q: %s", race.Name, err)
+	}
+
+	if race.Id, err = res.LastInsertId(); err != nil {
+		t.Fatalf("inserting race %q: %s", race.Name, err)
+	}
+}
+
+// raceIDs returns the IDs of races, in order.
+func raceIDs(races []*racing.Race) []int64 {
+	ids := make([]int64, 0, len(races))
+	for _, race := range races {
+		ids = append(ids, race.Id)
+	}
+
+	return ids
+}
+
+// equalIDs reports whether two lists of IDs are the same, in the same order.
+func equalIDs(a, b []int64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func TestReseedKeepsArchivedRaces(t *testing.T) {
+	testDB := newTestDB(t)
+
+	now := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+	config := SeedConfig{Races: 10, Meetings: 2, StartsBefore: 24 * time.Hour, StartsAfter: 24 * time.Hour, VisibleRatio: 1}
+
+	// seed seeds the database as the service does on starting.
+	seed := func() RacesRepo {
+		repo := NewRacesRepo(testDB, WithClock(FixedClock(now)), WithSeedConfig(config))
+		if err := repo.Init(); err != nil {
+			t.Fatalf("Init() error = %s", err)
+		}
+
+		return repo
+	}
+
+	repo := seed()
+
+	if _, err := repo.Archive(now); err != nil {
+		t.Fatalf("Archive() error = %s", err)
+	}
+
+	before, err := repo.ListArchived(nil, Audience{}, time.Time{}, time.Time{}, 0, config.Races)
+	if err != nil {
+		t.Fatalf("ListArchived() error = %s", err)
+	}
+
+	if len(before) == 0 {
+		t.Fatal("ListArchived() = no races, want the races seeded before now")
+	}
+
+	// Seeding again, and archiving the races seeded, leaves the races
+	// archived before as they were.
+	repo = seed()
+
+	races, err := repo.List(nil)
+	if err != nil {
+		t.Fatalf("List() error = %s", err)
+	}
+
+	archivedIDs := make(map[int64]bool)
+	for _, race := range before {
+		archivedIDs[race.Id] = true
+	}
+
+	for _, race := range races {
+		if archivedIDs[race.Id] {
+			t.Errorf("reseeded race %d, which was archived", race.Id)
+		}
+	}
+
+	if _, err := repo.Archive(now.Add(24 * time.Hour)); err != nil {
+		t.Fatalf("Archive() again error = %s", err)
+	}
+
+	after, err := repo.ListArchived(nil, Audience{}, time.Time{}, time.Time{}, 0, config.Races)
+	if err != nil {
+		t.Fatalf("ListArchived() again error = %s", err)
+	}
+
+	byID := make(map[int64]*racing.Race)
+	for _, race := range after {
+		byID[race.Id] = race
+	}
+
+	for _, race := range before {
+		if !proto.Equal(byID[race.Id], race) {
+			t.Errorf("archived race %d = %v, want it kept as %v", race.Id, byID[race.Id], race)
+		}
+	}
+}
+
+func TestSeedRandomIsDeterministic(t *testing.T) {
+	now := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	// seedDummyData seeds an empty database, returning its races and
+	// selections.
+	seedDummyData := func() ([]*racing.Race, []*racing.Selection) {
+		testDB := newTestDB(t)
+
+		SeedRandom(42)
+
+		races := NewRacesRepo(testDB, WithClock(FixedClock(now)))
+		if err := races.Init(); err != nil {
+			t.Fatalf("initialising races repo: %s", err)
+		}
+
+		markets := NewMarketsRepo(testDB)
+		if err := markets.Init(); err != nil {
+			t.Fatalf("initialising markets repo: %s", err)
+		}
+
+		listedRaces, err := races.List(nil)
+		if err != nil {
+			t.Fatalf("List() error = %s", err)
+		}
+
+		selections, err := markets.ListSelections(nil)
+		if err != nil {
+			t.Fatalf("ListSelections() error = %s", err)
+		}
+
+		return listedRaces, selections
+	}
+
+	firstRaces, firstSelections := seedDummyData()
+	secondRaces, secondSelections := seedDummyData()
+
+	if len(firstRaces) == 0 || len(firstRaces) != len(secondRaces) {
+		t.Fatalf("seeded %d and %d races, want the same number", len(firstRaces), len(secondRaces))
+	}
+
+	for i := range firstRaces {
+		if !proto.Equal(firstRaces[i], secondRaces[i]) {
+			t.Errorf("seeded race %d = %v, then %v", i, firstRaces[i], secondRaces[i])
+		}
+	}
+
+	if len(firstSelections) != len(secondSelections) {
+		t.Fatalf("seeded %d and %d selections, want the same number", len(firstSelections), len(secondSelections))
+	}
+
+	for i := range firstSelections {
+		if !proto.Equal(firstSelections[i], secondSelections[i]) {
+			t.Errorf("seeded selection %d = %v, then %v", i, firstSelections[i], secondSelections[i])
+		}
+	}
+}
+
+func TestRacesRepoInitMigratesRaces(t *testing.T) {
+	testDB := newTestDB(t)
+
+	// Races, and archived races, as they were before races had details.
+	for _, statement := range []string{
+		`CREATE TABLE races (id INTEGER PRIMARY KEY, meeting_id INTEGER, name TEXT, number INTEGER, visible INTEGER, advertised_start_time DATETIME)`,
+		`CREATE TABLE races_archive (id INTEGER PRIMARY KEY, meeting_id INTEGER, name TEXT, number INTEGER, visible INTEGER, advertised_start_time DATETIME, archived_at DATETIME)`,
+		`INSERT INTO races (id, meeting_id, name, number, visible, advertised_start_time) VALUES (1, 5, 'Old race', 1, 1, '2021-03-02T00:00:00Z')`,
+	} {
+		if _, err := testDB.Exec(statement); err != nil {
+			t.Fatalf("creating old races tables: %s", err)
+		}
+	}
+
+	if err := NewRacesRepo(testDB, WithoutDummyData()).Init(); err != nil {
+		t.Fatalf("Init() error = %s", err)
+	}
+
+	// Initialising again finds the columns already added.
+	if err := NewRacesRepo(testDB, WithoutDummyData()).Init(); err != nil {
+		t.Fatalf("Init() again error = %s", err)
+	}
+
+	for _, table := range []string{"races", "races_archive"} {
+		for _, column := range []string{"venue_time_zone", "country", "distance", "track_condition", "weather", "venue_image", "external_source", "external_id", "status"} {
+			var exists bool
+			if err := testDB.QueryRow(`SELECT COUNT(*) > 0 FROM pragma_table_info(?) WHERE name = ?`, table, column).Scan(&exists); err != nil {
+				t.Fatalf("checking %s.%s: %s", table, column, err)
+			}
+
+			if !exists {
+				t.Errorf("%s has no %s column", table, column)
+			}
+		}
+	}
+
+	for i, number := range []int{2, 3} {
+		_, err := testDB.Exec(`INSERT INTO races (meeting_id, name, number, visible, venue_time_zone, country, distance, track_condition, weather, venue_image, status, external_source, external_id) VALUES (5, 'Fed race', ?, 1, '', '', 0, '', '', '', 'OPEN', 'feed', 'R1')`, number)
+		if wantErr := i > 0; (err != nil) != wantErr {
+			t.Errorf("inserting race %d referenced as feed/R1: error = %v, want error %t", number, err, wantErr)
+		}
+	}
+
+	races, err := NewRacesRepo(testDB, WithoutDummyData()).List(&racing.ListRacesRequestFilter{MeetingIds: []int64{5}})
+	if err != nil {
+		t.Fatalf("List() error = %s", err)
+	}
+
+	if len(races) == 0 || races[0].Name != "Old race" || races[0].Status != racing.Race_OPEN {
+		t.Errorf("List() = %v, want the old race first, open", races)
+	}
+}
+
+func TestRacesRepoInitRenumbersDuplicateRaces(t *testing.T) {
+	testDB := newTestDB(t)
+
+	// Races as they were seeded before race numbers were unique per meeting.
+	for _, statement := range []string{
+		`CREATE TABLE races (id INTEGER PRIMARY KEY, meeting_id INTEGER, name TEXT, number INTEGER, visible INTEGER, advertised_start_time DATETIME)`,
+		`INSERT INTO races (id, meeting_id, name, number, visible) VALUES (1, 5, 'First', 1, 1), (2, 5, 'Second', 1, 1), (3, 5, 'Third', 2, 1), (4, 5, 'Fourth', 1, 1), (5, 6, 'Other meeting', 1, 1)`,
+	} {
+		if _, err := testDB.Exec(statement); err != nil {
+			t.Fatalf("creating old races table: %s", err)
+		}
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := NewRacesRepo(testDB, WithoutDummyData()).Init(); err != nil {
+			t.Fatalf("Init() #%d error = %s", i+1, err)
+		}
+	}
+
+	rows, err := testDB.Query(`SELECT id, number FROM races ORDER BY id`)
+	if err != nil {
+		t.Fatalf("listing race numbers: %s", err)
+	}
+	defer rows.Close()
+
+	numbers := make(map[int64]int64)
+	for rows.Next() {
+		var id, number int64
+		if err := rows.Scan(&id, &number); err != nil {
+			t.Fatalf("listing race numbers: %s", err)
+		}
+
+		numbers[id] = number
+	}
+
+	if want := map[int64]int64{1: 1, 2: 3, 3: 2, 4: 4, 5: 1}; !reflect.DeepEqual(numbers, want) {
+		t.Errorf("race numbers = %v, want %v", numbers, want)
+	}
+
+	if _, err := testDB.Exec(`INSERT INTO races (meeting_id, name, number, visible) VALUES (6, 'Duplicate', 1, 1)`); err == nil {
+		t.Error("inserting a second race 1 of meeting 6 succeeded, want it rejected")
+	}
+}
+
+func TestRacesRepoInitStopsReusingRaceIDs(t *testing.T) {
+	testDB := newTestDB(t)
+
+	// Races, and archived races, as they were when the newest race's ID was
+	// given to the next race once the race was archived.
+	for _, statement := range []string{
+		`CREATE TABLE races (id INTEGER PRIMARY KEY, meeting_id INTEGER, name TEXT, number INTEGER, visible INTEGER, advertised_start_time DATETIME)`,
+		`CREATE TABLE races_archive (id INTEGER PRIMARY KEY, meeting_id INTEGER, name TEXT, number INTEGER, visible INTEGER, advertised_start_time DATETIME, archived_at DATETIME)`,
+		`INSERT INTO races (id, meeting_id, name, number, visible, advertised_start_time) VALUES (1, 5, 'Old race', 1, 1, '2021-03-02T00:00:00Z')`,
+		`INSERT INTO races_archive (id, meeting_id, name, number, visible, advertised_start_time, archived_at) VALUES (7, 5, 'Archived race', 2, 1, '2021-02-01T00:00:00Z', '2021-02-02T00:00:00Z')`,
+	} {
+		if _, err := testDB.Exec(statement); err != nil {
+			t.Fatalf("creating old races tables: %s", err)
+		}
+	}
+
+	repo := NewRacesRepo(testDB, WithoutDummyData())
+	for i := 0; i < 2; i++ {
+		if err := repo.Init(); err != nil {
+			t.Fatalf("Init() #%d error = %s", i+1, err)
+		}
+	}
+
+	race := racetest.NewRace().Meeting(5).Number(3).ExternalRef("feed", "R3").Build()
+	if _, err := repo.Upsert(race); err != nil {
+		t.Fatalf("Upsert() error = %s", err)
+	}
+
+	if race.Id != 8 {
+		t.Errorf("Upsert() gave the race ID %d, want 8, after the archived race's", race.Id)
+	}
+
+	races, err := repo.List(&racing.ListRacesRequestFilter{MeetingIds: []int64{5}})
+	if err != nil {
+		t.Fatalf("List() error = %s", err)
+	}
+
+	if len(races) != 2 || races[0].Id != 1 || races[0].Name != "Old race" {
+		t.Errorf("List() = %v, want the old race kept alongside the new one", races)
+	}
+}
+
+func TestRacesRepoUpsertAfterArchiveGetsNewID(t *testing.T) {
+	repo, _ := newTestRacesRepo(t)
+
+	now := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	first := racetest.NewRace().Number(1).StartsAt(now.Add(time.Hour)).ExternalRef("feed", "R1").Build()
+	last := racetest.NewRace().Number(2).StartsAt(now.Add(-time.Hour)).ExternalRef("feed", "R2").Build()
+
+	for _, race := range []*racing.Race{first, last} {
+		if _, err := repo.Upsert(race); err != nil {
+			t.Fatalf("Upsert() error = %s", err)
+		}
+	}
+
+	// Archiving the newest race leaves no race with its ID to go by.
+	if _, err := repo.Archive(now); err != nil {
+		t.Fatalf("Archive() error = %s", err)
+	}
+
+	next := racetest.NewRace().Number(3).StartsAt(now.Add(2*time.Hour)).ExternalRef("feed", "R3").Build()
+	if _, err := repo.Upsert(next); err != nil {
+		t.Fatalf("Upsert() error = %s", err)
+	}
+
+	if next.Id <= last.Id {
+		t.Errorf("Upsert() after archiving race %d gave the race ID %d, want a new one", last.Id, next.Id)
+	}
+}
@@ -0,0 +1,50 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// ErrNotFound is returned when a requested record doesn't exist.
+var ErrNotFound = errors.New("not found")
+
+// ErrAlreadyExists is returned when a write would duplicate a record that must
+// be unique, such as a race number within a meeting.
+var ErrAlreadyExists = errors.New("already exists")
+
+// ErrInvalidTransition is returned when a write would move a race to a status
+// it can't move to from its current status.
+var ErrInvalidTransition = errors.New("invalid status transition")
+
+// ErrMissingExternalRef is returned when upserting a race without an external
+// reference to dedupe it by.
+var ErrMissingExternalRef = errors.New("race has no external reference")
+
+// ErrEventLogDisabled is returned when reading from the event log, of a
+// repository that doesn't keep one.
+var ErrEventLogDisabled = errors.New("event log is disabled")
+
+// ErrKeyReused is returned when an idempotency key is reused for a different
+// request to the one it was first used for.
+var ErrKeyReused = errors.New("idempotency key was used for a different request")
+
+// ErrKeyInUse is returned when an idempotency key is used while the request
+// it was first used for is still being handled.
+var ErrKeyInUse = errors.New("idempotency key is in use by a request in progress")
+
+// IsUnavailable reports whether an error means the database was too busy or
+// had gone away, so the operation failing might succeed if retried.
+func IsUnavailable(err error) bool {
+	if errors.Is(err, sql.ErrConnDone) {
+		return true
+	}
+
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.Code == sqlite3.ErrBusy || sqliteErr.Code == sqlite3.ErrLocked
+	}
+
+	return false
+}
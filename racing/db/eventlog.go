@@ -0,0 +1,117 @@
+package db
+
+import (
+	"time"
+
+	"git.neds.sh/matty/entain/racing/proto/racing"
+)
+
+// eventLogTriggers append every write to races to the event log, as a
+// snapshot of the race after it, or before it for deletes.
+var eventLogTriggers = map[string]string{
+	"race_events_insert": `CREATE TRIGGER IF NOT EXISTS race_events_insert AFTER INSERT ON races BEGIN INSERT INTO race_events (race_id, operation, meeting_id, name, number, visible, advertised_start_time, venue_time_zone, country, distance, track_condition, weather, venue_image, external_source, external_id, status, recorded_at) VALUES (NEW.id, 'UPSERTED', NEW.meeting_id, NEW.name, NEW.number, NEW.visible, NEW.advertised_start_time, NEW.venue_time_zone, NEW.country, NEW.distance, NEW.track_condition, NEW.weather, NEW.venue_image, NEW.external_source, NEW.external_id, NEW.status, strftime('%Y-%m-%dT%H:%M:%SZ', 'now')); END`,
+	"race_events_update": `CREATE TRIGGER IF NOT EXISTS race_events_update AFTER UPDATE ON races BEGIN INSERT INTO race_events (race_id, operation, meeting_id, name, number, visible, advertised_start_time, venue_time_zone, country, distance, track_condition, weather, venue_image, external_source, external_id, status, recorded_at) VALUES (NEW.id, 'UPSERTED', NEW.meeting_id, NEW.name, NEW.number, NEW.visible, NEW.advertised_start_time, NEW.venue_time_zone, NEW.country, NEW.distance, NEW.track_condition, NEW.weather, NEW.venue_image, NEW.external_source, NEW.external_id, NEW.status, strftime('%Y-%m-%dT%H:%M:%SZ', 'now')); END`,
+	"race_events_delete": `CREATE TRIGGER IF NOT EXISTS race_events_delete AFTER DELETE ON races BEGIN INSERT INTO race_events (race_id, operation, meeting_id, name, number, visible, advertised_start_time, venue_time_zone, country, distance, track_condition, weather, venue_image, external_source, external_id, status, recorded_at) VALUES (OLD.id, 'DELETED', OLD.meeting_id, OLD.name, OLD.number, OLD.visible, OLD.advertised_start_time, OLD.venue_time_zone, OLD.country, OLD.distance, OLD.track_condition, OLD.weather, OLD.venue_image, OLD.external_source, OLD.external_id, OLD.status, strftime('%Y-%m-%dT%H:%M:%SZ', 'now')); END`,
+}
+
+// initEventLog starts or stops recording writes to races in the event log.
+//
+// Writes made while the log is stopped aren't recorded as they're made. The
+// log's triggers are missing while it's stopped, as they are before it's
+// first started, so whenever it's started without them it's first brought up
+// to date with a snapshot of every race, and the deletion of every race it
+// has that's gone.
+func (r *racesRepo) initEventLog() error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if r.eventLog {
+		var recording int
+		if err := tx.QueryRow(getRaceEventQueries()[raceEventsRecording]).Scan(&recording); err != nil {
+			return err
+		}
+
+		if recording < len(eventLogTriggers) {
+			for _, query := range []string{raceEventsSnapshotDeletes, raceEventsSnapshot} {
+				if _, err := tx.Exec(getRaceEventQueries()[query]); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	for name, ddl := range eventLogTriggers {
+		if !r.eventLog {
+			ddl = "DROP TRIGGER IF EXISTS " + name
+		}
+
+		if _, err := tx.Exec(ddl); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (r *racesRepo) ListAt(filter *racing.ListRacesRequestFilter, at time.Time) ([]*racing.Race, error) {
+	if !r.eventLog {
+		return nil, ErrEventLogDisabled
+	}
+
+	query, args := r.applyFilter(getRaceEventQueries()[raceEventsListAt], filter)
+	query += orderByID
+
+	rows, err := r.query(query, append([]interface{}{at.UTC().Format(time.RFC3339)}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	races, err := r.scanRaces(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	// Count down to the start as it was at the time, too.
+	for _, race := range races {
+		if race.AdvertisedStartTime == nil {
+			continue
+		}
+
+		race.SecondsToStart = int64(race.AdvertisedStartTime.AsTime().Sub(at).Seconds())
+	}
+
+	return races, nil
+}
+
+func (r *racesRepo) Replay() (int64, error) {
+	if !r.eventLog {
+		return 0, ErrEventLogDisabled
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var replayed int64
+
+	for _, query := range []string{raceEventsReplayDelete, raceEventsReplayUpsert} {
+		res, err := tx.Exec(getRaceEventQueries()[query])
+		if err != nil {
+			return 0, translateError(err)
+		}
+
+		written, err := res.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+
+		replayed += written
+	}
+
+	return replayed, tx.Commit()
+}
@@ -0,0 +1,127 @@
+package db
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"git.neds.sh/matty/entain/racing/proto/racing"
+	"git.neds.sh/matty/entain/racing/racetest"
+)
+
+// raceNamesOf returns the names of races, in order.
+func raceNamesOf(races []*racing.Race) []string {
+	var names []string
+	for _, race := range races {
+		names = append(names, race.Name)
+	}
+
+	return names
+}
+
+func TestRacesRepoEventLogRestarted(t *testing.T) {
+	testDB := newTestDB(t)
+
+	// initRepo initialises a repository on the database, with the event log
+	// enabled or not.
+	initRepo := func(eventLog bool) RacesRepo {
+		options := []RacesRepoOption{WithoutDummyData()}
+		if eventLog {
+			options = append(options, WithEventLog())
+		}
+
+		repo := NewRacesRepo(testDB, options...)
+		if err := repo.Init(); err != nil {
+			t.Fatalf("Init() error = %s", err)
+		}
+
+		return repo
+	}
+
+	upsert := func(repo RacesRepo, race *racing.Race) {
+		if _, err := repo.Upsert(race); err != nil {
+			t.Fatalf("Upsert(%q) error = %s", race.Name, err)
+		}
+	}
+
+	repo := initRepo(true)
+
+	kept := racetest.NewRace().Number(1).Name("Kept").ExternalRef("feed", "R1").Build()
+	renamed := racetest.NewRace().Number(2).Name("Renamed").ExternalRef("feed", "R2").Build()
+	deleted := racetest.NewRace().Number(3).Name("Deleted").ExternalRef("feed", "R3").Build()
+
+	for _, race := range []*racing.Race{kept, renamed, deleted} {
+		upsert(repo, race)
+	}
+
+	// Writes made while the log is stopped aren't recorded as they're made.
+	repo = initRepo(false)
+
+	renamed.Name = "Renamed while stopped"
+	upsert(repo, renamed)
+	upsert(repo, racetest.NewRace().Number(4).Name("Created while stopped").ExternalRef("feed", "R4").Build())
+
+	if _, err := testDB.Exec(`DELETE FROM races WHERE id = ?`, deleted.Id); err != nil {
+		t.Fatalf("deleting race: %s", err)
+	}
+
+	// Starting the log again brings it up to date with them.
+	repo = initRepo(true)
+
+	want := []string{"Kept", "Renamed while stopped", "Created while stopped"}
+
+	races, err := repo.ListAt(nil, time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatalf("ListAt() error = %s", err)
+	}
+
+	if got := raceNamesOf(races); !reflect.DeepEqual(got, want) {
+		t.Errorf("ListAt() = %v, want %v", got, want)
+	}
+
+	if replayed, err := repo.Replay(); replayed != 0 || err != nil {
+		t.Errorf("Replay() = %d, %v, want nothing drifted", replayed, err)
+	}
+}
+
+func TestRacesRepoReplayKeepsRacesNotLogged(t *testing.T) {
+	testDB := newTestDB(t)
+
+	repo := NewRacesRepo(testDB, WithoutDummyData(), WithEventLog())
+	if err := repo.Init(); err != nil {
+		t.Fatalf("Init() error = %s", err)
+	}
+
+	logged := racetest.NewRace().Number(1).Name("Logged").ExternalRef("feed", "R1").Build()
+	unlogged := racetest.NewRace().Number(2).Name("Not logged").ExternalRef("feed", "R2").Build()
+	deleted := racetest.NewRace().Number(3).Name("Deleted").ExternalRef("feed", "R3").Build()
+
+	for _, race := range []*racing.Race{logged, unlogged, deleted} {
+		if _, err := repo.Upsert(race); err != nil {
+			t.Fatalf("Upsert(%q) error = %s", race.Name, err)
+		}
+	}
+
+	// The log has no record of one race, and the other's deletion was
+	// recorded but never made.
+	if _, err := testDB.Exec(`DELETE FROM race_events WHERE race_id = ?`, unlogged.Id); err != nil {
+		t.Fatalf("deleting race's events: %s", err)
+	}
+
+	if _, err := testDB.Exec(`UPDATE race_events SET operation = 'DELETED' WHERE id = (SELECT MAX(id) FROM race_events WHERE race_id = ?)`, deleted.Id); err != nil {
+		t.Fatalf("recording race's deletion: %s", err)
+	}
+
+	if replayed, err := repo.Replay(); replayed != 1 || err != nil {
+		t.Fatalf("Replay() = %d, %v, want 1 race drifted", replayed, err)
+	}
+
+	races, err := repo.List(nil)
+	if err != nil {
+		t.Fatalf("List() error = %s", err)
+	}
+
+	if got, want := raceNamesOf(races), []string{"Logged", "Not logged"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("List() after Replay() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,40 @@
+package db
+
+import (
+	"database/sql"
+	"log"
+	"strings"
+)
+
+// explainQueryPlan logs SQLite's query plan for the given query, so it's easy
+// to verify whether a query makes use of the table indexes.
+func explainQueryPlan(db *sql.DB, query string, args ...interface{}) error {
+	rows, err := db.Query("EXPLAIN QUERY PLAN "+query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var plan []string
+
+	for rows.Next() {
+		var (
+			id, parent, notUsed int
+			detail              string
+		)
+
+		if err := rows.Scan(&id, &parent, &notUsed, &detail); err != nil {
+			return err
+		}
+
+		plan = append(plan, detail)
+	}
+
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	log.Printf("query plan for %q:\n  %s\n", strings.Join(strings.Fields(query), " "), strings.Join(plan, "\n  "))
+
+	return nil
+}
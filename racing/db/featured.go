@@ -0,0 +1,44 @@
+package db
+
+import (
+	"time"
+
+	"git.neds.sh/matty/entain/racing/proto/racing"
+)
+
+func (r *racesRepo) SetFeatured(raceID int64, featured bool) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var exists bool
+	if err := tx.QueryRow(getRaceQueries()[raceUnarchived], raceID).Scan(&exists); err != nil {
+		return err
+	}
+
+	if !exists {
+		return ErrNotFound
+	}
+
+	if featured {
+		_, err = tx.Exec(getRaceQueries()[raceFeature], raceID, r.clock.Now().UTC().Format(time.RFC3339))
+	} else {
+		_, err = tx.Exec(getRaceQueries()[raceUnfeature], raceID)
+	}
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *racesRepo) ListFeatured() ([]*racing.Race, error) {
+	rows, err := r.query(getRaceQueries()[featuredRacesList])
+	if err != nil {
+		return nil, err
+	}
+
+	return r.scanRaces(rows)
+}
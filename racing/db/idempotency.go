@@ -0,0 +1,134 @@
+package db
+
+import (
+	"bytes"
+	"database/sql"
+	"sync"
+	"time"
+)
+
+// IdempotencyRepo provides repository access to idempotency keys, and the
+// responses to the requests made with them, so retried requests aren't
+// handled twice.
+type IdempotencyRepo interface {
+	// Init will initialise our idempotency repository.
+	Init() error
+
+	// Reserve will claim a key for a request to a method until the given
+	// time, identifying the request by its fingerprint. It returns the
+	// response recorded for the key if the request has already been handled,
+	// or nil if the key was claimed. It returns ErrKeyReused if the key was
+	// used for a different request, or ErrKeyInUse if the request is still
+	// being handled. A request whose fingerprint isn't known until it has
+	// been handled, e.g. a client stream, reserves without one, matching any.
+	Reserve(key, method string, fingerprint []byte, until time.Time) ([]byte, error)
+
+	// Extend will keep a claimed key until the given time, while its request
+	// is still being handled.
+	Extend(key, method string, until time.Time) error
+
+	// Complete will record the response to the request a key was claimed
+	// for, keeping it until the given time. A request reserved without a
+	// fingerprint is given one.
+	Complete(key, method string, fingerprint, response []byte, until time.Time) error
+
+	// Release will give up a claimed key whose request failed, so it can be
+	// retried.
+	Release(key, method string) error
+
+	// Purge will delete keys that expired before the given time, returning
+	// the number deleted.
+	Purge(before time.Time) (int64, error)
+}
+
+type idempotencyRepo struct {
+	db   *sql.DB
+	init sync.Once
+}
+
+// NewIdempotencyRepo creates a new idempotency repository.
+func NewIdempotencyRepo(db *sql.DB) IdempotencyRepo {
+	return &idempotencyRepo{db: db}
+}
+
+// Init prepares the idempotency repository tables.
+func (r *idempotencyRepo) Init() error {
+	var err error
+
+	r.init.Do(func() {
+		err = r.createTables()
+	})
+
+	return err
+}
+
+func (r *idempotencyRepo) Reserve(key, method string, fingerprint []byte, until time.Time) ([]byte, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(
+		getIdempotencyQueries()[keysReserve],
+		key,
+		method,
+		fingerprint,
+		until.UTC().Format(time.RFC3339),
+		time.Now().UTC().Format(time.RFC3339),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	reserved, err := res.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+
+	if reserved > 0 {
+		return nil, tx.Commit()
+	}
+
+	var storedFingerprint, response []byte
+
+	if err := tx.QueryRow(getIdempotencyQueries()[keysFind], key, method).Scan(&storedFingerprint, &response); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case storedFingerprint != nil && fingerprint != nil && !bytes.Equal(storedFingerprint, fingerprint):
+		return nil, ErrKeyReused
+	case response == nil:
+		return nil, ErrKeyInUse
+	default:
+		return response, nil
+	}
+}
+
+func (r *idempotencyRepo) Extend(key, method string, until time.Time) error {
+	_, err := r.db.Exec(getIdempotencyQueries()[keysExtend], until.UTC().Format(time.RFC3339), key, method)
+
+	return err
+}
+
+func (r *idempotencyRepo) Complete(key, method string, fingerprint, response []byte, until time.Time) error {
+	_, err := r.db.Exec(getIdempotencyQueries()[keysComplete], response, fingerprint, until.UTC().Format(time.RFC3339), key, method)
+
+	return err
+}
+
+func (r *idempotencyRepo) Release(key, method string) error {
+	_, err := r.db.Exec(getIdempotencyQueries()[keysRelease], key, method)
+
+	return err
+}
+
+func (r *idempotencyRepo) Purge(before time.Time) (int64, error) {
+	res, err := r.db.Exec(getIdempotencyQueries()[keysPurge], before.UTC().Format(time.RFC3339))
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
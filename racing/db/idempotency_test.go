@@ -0,0 +1,103 @@
+package db
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func newTestIdempotencyRepo(t *testing.T) IdempotencyRepo {
+	t.Helper()
+
+	repo := NewIdempotencyRepo(newTestDB(t))
+	if err := repo.Init(); err != nil {
+		t.Fatalf("initialising idempotency repo: %s", err)
+	}
+
+	return repo
+}
+
+func TestIdempotencyRepoLease(t *testing.T) {
+	repo := newTestIdempotencyRepo(t)
+
+	now := time.Now()
+	fingerprint := []byte("request")
+
+	// A claim whose lease has run out, e.g. as the service stopped while
+	// handling the request, can be claimed again by a retry.
+	if _, err := repo.Reserve("key", "method", fingerprint, now.Add(-time.Minute)); err != nil {
+		t.Fatalf("Reserve() error = %s", err)
+	}
+
+	if resp, err := repo.Reserve("key", "method", fingerprint, now.Add(time.Minute)); resp != nil || err != nil {
+		t.Fatalf("Reserve() after the lease = %q, %v, want the key claimed", resp, err)
+	}
+
+	if _, err := repo.Reserve("key", "method", fingerprint, now.Add(time.Minute)); !errors.Is(err, ErrKeyInUse) {
+		t.Errorf("Reserve() during the lease error = %v, want %v", err, ErrKeyInUse)
+	}
+
+	// Extending a claim that has run out keeps it from being claimed again.
+	if _, err := repo.Reserve("extended", "method", fingerprint, now.Add(-time.Minute)); err != nil {
+		t.Fatalf("Reserve() error = %s", err)
+	}
+
+	if err := repo.Extend("extended", "method", now.Add(time.Minute)); err != nil {
+		t.Fatalf("Extend() error = %s", err)
+	}
+
+	if _, err := repo.Reserve("extended", "method", fingerprint, now.Add(time.Minute)); !errors.Is(err, ErrKeyInUse) {
+		t.Errorf("Reserve() after extending error = %v, want %v", err, ErrKeyInUse)
+	}
+
+	// The response is kept until the time it's completed with, not the
+	// lease's.
+	if err := repo.Complete("extended", "method", nil, []byte("response"), now.Add(time.Hour)); err != nil {
+		t.Fatalf("Complete() error = %s", err)
+	}
+
+	if err := repo.Extend("extended", "method", now.Add(-time.Minute)); err != nil {
+		t.Fatalf("Extend() error = %s", err)
+	}
+
+	if resp, err := repo.Reserve("extended", "method", fingerprint, now.Add(time.Minute)); string(resp) != "response" || err != nil {
+		t.Errorf("Reserve() after completing = %q, %v, want the response", resp, err)
+	}
+}
+
+func TestIdempotencyRepoFingerprintedOnCompleting(t *testing.T) {
+	repo := newTestIdempotencyRepo(t)
+
+	until := time.Now().Add(time.Hour)
+
+	if _, err := repo.Reserve("key", "method", nil, until); err != nil {
+		t.Fatalf("Reserve() error = %s", err)
+	}
+
+	if err := repo.Complete("key", "method", []byte("request"), []byte("response"), until); err != nil {
+		t.Fatalf("Complete() error = %s", err)
+	}
+
+	tests := []struct {
+		name        string
+		fingerprint []byte
+		wantErr     error
+	}{
+		{name: "not yet known", fingerprint: nil},
+		{name: "same request", fingerprint: []byte("request")},
+		{name: "different request", fingerprint: []byte("other request"), wantErr: ErrKeyReused},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := repo.Reserve("key", "method", tt.fingerprint, until)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Reserve() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr == nil && string(resp) != "response" {
+				t.Errorf("Reserve() = %q, want the response", resp)
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package db
+
+import (
+	"database/sql"
+	"sync"
+	"time"
+)
+
+// LeasesRepo provides repository access to leases, which let one of many
+// replicas hold a named role for a time.
+type LeasesRepo interface {
+	// Init will initialise our leases repository.
+	Init() error
+
+	// Acquire will take or renew the named lease for the holder until the
+	// given time, returning whether the holder now has it.
+	Acquire(name, holder string, until time.Time) (bool, error)
+}
+
+type leasesRepo struct {
+	db   *sql.DB
+	init sync.Once
+}
+
+// NewLeasesRepo creates a new leases repository.
+func NewLeasesRepo(db *sql.DB) LeasesRepo {
+	return &leasesRepo{db: db}
+}
+
+// Init prepares the leases repository tables.
+func (r *leasesRepo) Init() error {
+	var err error
+
+	r.init.Do(func() {
+		err = r.createTables()
+	})
+
+	return err
+}
+
+func (r *leasesRepo) Acquire(name, holder string, until time.Time) (bool, error) {
+	res, err := r.db.Exec(
+		getLeaseQueries()[leasesAcquire],
+		name,
+		holder,
+		until.UTC().Format(time.RFC3339),
+		time.Now().UTC().Format(time.RFC3339),
+	)
+	if err != nil {
+		return false, err
+	}
+
+	acquired, err := res.RowsAffected()
+
+	return acquired > 0, err
+}
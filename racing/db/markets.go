@@ -0,0 +1,195 @@
+package db
+
+import (
+	"database/sql"
+	"strings"
+	"sync"
+
+	"git.neds.sh/matty/entain/racing/proto/racing"
+)
+
+// MarketsRepo provides repository access to markets and their selections.
+type MarketsRepo interface {
+	// Init will initialise our markets repository.
+	Init() error
+
+	// List will return a list of markets.
+	List(filter *racing.ListMarketsRequestFilter) ([]*racing.Market, error)
+
+	// Get will return a single market, or ErrNotFound if it doesn't exist.
+	Get(id int64) (*racing.Market, error)
+
+	// ListSelections will return a list of market selections.
+	ListSelections(filter *racing.ListSelectionsRequestFilter) ([]*racing.Selection, error)
+
+	// GetSelection will return a single market selection, or ErrNotFound if it
+	// doesn't exist.
+	GetSelection(id int64) (*racing.Selection, error)
+}
+
+type marketsRepo struct {
+	db        *sql.DB
+	init      sync.Once
+	dummyData bool
+}
+
+// MarketsRepoOption configures optional behaviour of a markets repository.
+type MarketsRepoOption func(*marketsRepo)
+
+// WithoutDummyMarkets stops the repository seeding dummy markets, for when
+// races are sourced from a real feed.
+func WithoutDummyMarkets() MarketsRepoOption {
+	return func(r *marketsRepo) {
+		r.dummyData = false
+	}
+}
+
+// NewMarketsRepo creates a new markets repository.
+func NewMarketsRepo(db *sql.DB, opts ...MarketsRepoOption) MarketsRepo {
+	r := &marketsRepo{db: db, dummyData: true}
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	return r
+}
+
+// Init prepares the markets repository dummy data.
+func (r *marketsRepo) Init() error {
+	var err error
+
+	r.init.Do(func() {
+		err = r.createTables()
+
+		// For test/example purposes, we seed the DB with some dummy markets.
+		if err == nil && r.dummyData {
+			err = r.seed()
+		}
+	})
+
+	return err
+}
+
+func (r *marketsRepo) List(filter *racing.ListMarketsRequestFilter) ([]*racing.Market, error) {
+	query := getMarketQueries()[marketsList]
+
+	var args []interface{}
+
+	if len(filter.GetRaceIds()) > 0 {
+		query += " WHERE race_id IN (" + strings.Repeat("?,", len(filter.RaceIds)-1) + "?)"
+
+		for _, raceID := range filter.RaceIds {
+			args = append(args, raceID)
+		}
+	}
+
+	rows, err := r.db.Query(query+orderByID, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.scanMarkets(rows)
+}
+
+func (r *marketsRepo) Get(id int64) (*racing.Market, error) {
+	rows, err := r.db.Query(getMarketQueries()[marketsList]+" WHERE id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+
+	markets, err := r.scanMarkets(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(markets) == 0 {
+		return nil, ErrNotFound
+	}
+
+	return markets[0], nil
+}
+
+func (r *marketsRepo) ListSelections(filter *racing.ListSelectionsRequestFilter) ([]*racing.Selection, error) {
+	query := getMarketQueries()[selectionsList]
+
+	var args []interface{}
+
+	if len(filter.GetMarketIds()) > 0 {
+		query += " WHERE market_id IN (" + strings.Repeat("?,", len(filter.MarketIds)-1) + "?)"
+
+		for _, marketID := range filter.MarketIds {
+			args = append(args, marketID)
+		}
+	}
+
+	rows, err := r.db.Query(query+orderByID, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.scanSelections(rows)
+}
+
+func (r *marketsRepo) GetSelection(id int64) (*racing.Selection, error) {
+	rows, err := r.db.Query(getMarketQueries()[selectionsList]+" WHERE id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+
+	selections, err := r.scanSelections(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(selections) == 0 {
+		return nil, ErrNotFound
+	}
+
+	return selections[0], nil
+}
+
+func (r *marketsRepo) scanMarkets(rows *sql.Rows) ([]*racing.Market, error) {
+	defer rows.Close()
+
+	var markets []*racing.Market
+
+	for rows.Next() {
+		var (
+			market racing.Market
+			status string
+		)
+
+		if err := rows.Scan(&market.Id, &market.RaceId, &market.Name, &status); err != nil {
+			return nil, err
+		}
+
+		market.Status = racing.Market_Status(racing.Market_Status_value[status])
+
+		markets = append(markets, &market)
+	}
+
+	return markets, rows.Err()
+}
+
+func (r *marketsRepo) scanSelections(rows *sql.Rows) ([]*racing.Selection, error) {
+	defer rows.Close()
+
+	var selections []*racing.Selection
+
+	for rows.Next() {
+		var (
+			selection racing.Selection
+			status    string
+		)
+
+		if err := rows.Scan(&selection.Id, &selection.MarketId, &selection.Name, &selection.Price, &status); err != nil {
+			return nil, err
+		}
+
+		selection.Status = racing.Selection_Status(racing.Selection_Status_value[status])
+
+		selections = append(selections, &selection)
+	}
+
+	return selections, rows.Err()
+}
@@ -0,0 +1,136 @@
+package db
+
+import (
+	"database/sql"
+	"testing"
+
+	"git.neds.sh/matty/entain/racing/proto/racing"
+)
+
+// newTestMarketsRepo creates a markets repository on an in-memory database,
+// with two markets on race 1 and one on race 2, each with a selection.
+func newTestMarketsRepo(t *testing.T) (*marketsRepo, *sql.DB) {
+	t.Helper()
+
+	testDB := newTestDB(t)
+
+	repo := NewMarketsRepo(testDB, WithoutDummyMarkets()).(*marketsRepo)
+	if err := repo.Init(); err != nil {
+		t.Fatalf("initialising markets repo: %s", err)
+	}
+
+	fixtures := []string{
+		`INSERT INTO markets (id, race_id, name, status) VALUES (1, 1, 'Win', 'OPEN'), (2, 1, 'Place', 'SUSPENDED'), (3, 2, 'Win', 'CLOSED')`,
+		`INSERT INTO selections (id, market_id, name, price, status) VALUES (1, 1, 'Runner 1', 2.5, 'ACTIVE'), (2, 2, 'Runner 1', 1.4, 'SCRATCHED'), (3, 3, 'Runner 2', 7, 'ACTIVE')`,
+	}
+	for _, fixture := range fixtures {
+		if _, err := testDB.Exec(fixture); err != nil {
+			t.Fatalf("inserting fixtures: %s", err)
+		}
+	}
+
+	return repo, testDB
+}
+
+func TestMarketsRepoList(t *testing.T) {
+	repo, _ := newTestMarketsRepo(t)
+
+	tests := []struct {
+		name    string
+		filter  *racing.ListMarketsRequestFilter
+		wantIDs []int64
+	}{
+		{name: "nil filter", wantIDs: []int64{1, 2, 3}},
+		{name: "empty filter", filter: &racing.ListMarketsRequestFilter{}, wantIDs: []int64{1, 2, 3}},
+		{name: "race", filter: &racing.ListMarketsRequestFilter{RaceIds: []int64{1}}, wantIDs: []int64{1, 2}},
+		{name: "races", filter: &racing.ListMarketsRequestFilter{RaceIds: []int64{1, 2}}, wantIDs: []int64{1, 2, 3}},
+		{name: "no matches", filter: &racing.ListMarketsRequestFilter{RaceIds: []int64{99}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			markets, err := repo.List(tt.filter)
+			if err != nil {
+				t.Fatalf("List() error = %s", err)
+			}
+
+			got := make([]int64, 0, len(markets))
+			for _, market := range markets {
+				got = append(got, market.Id)
+			}
+
+			if !equalIDs(got, tt.wantIDs) {
+				t.Errorf("List() ids = %v, want %v", got, tt.wantIDs)
+			}
+		})
+	}
+}
+
+func TestMarketsRepoGet(t *testing.T) {
+	repo, _ := newTestMarketsRepo(t)
+
+	market, err := repo.Get(2)
+	if err != nil {
+		t.Fatalf("Get() error = %s", err)
+	}
+
+	if market.RaceId != 1 || market.Name != "Place" || market.Status != racing.Market_SUSPENDED {
+		t.Errorf("Get() = %v, want race 1's suspended Place market", market)
+	}
+
+	if _, err := repo.Get(99); err != ErrNotFound {
+		t.Errorf("Get(99) error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestMarketsRepoListSelections(t *testing.T) {
+	repo, _ := newTestMarketsRepo(t)
+
+	selections, err := repo.ListSelections(&racing.ListSelectionsRequestFilter{MarketIds: []int64{1, 3}})
+	if err != nil {
+		t.Fatalf("ListSelections() error = %s", err)
+	}
+
+	got := make([]int64, 0, len(selections))
+	for _, selection := range selections {
+		got = append(got, selection.Id)
+	}
+
+	if want := []int64{1, 3}; !equalIDs(got, want) {
+		t.Errorf("ListSelections() ids = %v, want %v", got, want)
+	}
+}
+
+func TestMarketsRepoGetSelection(t *testing.T) {
+	repo, _ := newTestMarketsRepo(t)
+
+	selection, err := repo.GetSelection(2)
+	if err != nil {
+		t.Fatalf("GetSelection() error = %s", err)
+	}
+
+	if selection.MarketId != 2 || selection.Price != 1.4 || selection.Status != racing.Selection_SCRATCHED {
+		t.Errorf("GetSelection() = %v, want market 2's scratched selection", selection)
+	}
+
+	if _, err := repo.GetSelection(99); err != ErrNotFound {
+		t.Errorf("GetSelection(99) error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestMarketsRepoScanError(t *testing.T) {
+	repo, testDB := newTestMarketsRepo(t)
+
+	// Names are scanned into strings, which can't hold NULL.
+	if _, err := testDB.Exec(`UPDATE markets SET name = NULL WHERE id = 1`); err != nil {
+		t.Fatalf("corrupting market: %s", err)
+	}
+
+	if _, err := repo.List(nil); err == nil {
+		t.Error("List() error = nil, want a scan error")
+	}
+
+	if _, err := repo.Get(1); err == nil {
+		t.Error("Get() error = nil, want a scan error")
+	}
+}
@@ -0,0 +1,992 @@
+package db
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"git.neds.sh/matty/entain/racing/proto/racing"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// memoryRacesRepo is a races repository kept in memory, for teams depending
+// on the racing service to unit test against without SQLite.
+type memoryRacesRepo struct {
+	mu    sync.Mutex
+	clock Clock
+
+	nextID   int64
+	races    map[int64]*racing.Race
+	archived map[int64]*racing.Race
+	featured map[int64]time.Time
+	// restrictions are the jurisdictions each race is restricted in.
+	restrictions map[int64][]string
+	// brandVisibility is whether each race is visible to each brand it's
+	// overridden for.
+	brandVisibility map[int64]map[string]bool
+	// views are how many times each race was viewed, by the hour.
+	views map[int64]map[time.Time]int64
+
+	statusChanges []memoryStatusChange
+	changes       []*racing.RaceChange
+}
+
+// memoryStatusChange is a race's move between statuses, in the order made.
+type memoryStatusChange struct {
+	raceID     int64
+	transition *racing.StatusTransition
+}
+
+// NewInMemoryRacesRepo creates a races repository that keeps races in memory,
+// behaving like one backed by SQLite, for tests. It starts empty, and takes
+// the same options as NewRacesRepo, though only WithClock has any effect: it
+// doesn't seed dummy races, keep an outbox or event log, or have localised
+// names.
+func NewInMemoryRacesRepo(opts ...RacesRepoOption) RacesRepo {
+	config := &racesRepo{clock: SystemClock}
+	for _, opt := range opts {
+		opt(config)
+	}
+
+	return &memoryRacesRepo{
+		clock:    config.clock,
+		races:    make(map[int64]*racing.Race),
+		archived: make(map[int64]*racing.Race),
+		featured: make(map[int64]time.Time),
+
+		restrictions:    make(map[int64][]string),
+		brandVisibility: make(map[int64]map[string]bool),
+		views:           make(map[int64]map[time.Time]int64),
+	}
+}
+
+func (m *memoryRacesRepo) Init() error {
+	return nil
+}
+
+func (m *memoryRacesRepo) List(filter *racing.ListRacesRequestFilter) ([]*racing.Race, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.list(m.races, filter, Audience{}), nil
+}
+
+func (m *memoryRacesRepo) ListPage(filter *racing.ListRacesRequestFilter, audience Audience, after int64, limit int) ([]*racing.Race, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var page []*racing.Race
+
+	for _, race := range m.list(m.races, filter, audience) {
+		if len(page) == limit {
+			break
+		}
+
+		if race.Id > after {
+			page = append(page, race)
+		}
+	}
+
+	return page, nil
+}
+
+func (m *memoryRacesRepo) ListMeetingPage(filter *racing.ListRacesRequestFilter, audience Audience, after int64, limit, raceLimit int) ([]*racing.Race, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	byMeeting := make(map[int64][]*racing.Race)
+	var meetingIDs []int64
+
+	for _, race := range m.list(m.races, filter, audience) {
+		if race.MeetingId <= after {
+			continue
+		}
+
+		if _, ok := byMeeting[race.MeetingId]; !ok {
+			meetingIDs = append(meetingIDs, race.MeetingId)
+		}
+
+		byMeeting[race.MeetingId] = append(byMeeting[race.MeetingId], race)
+	}
+
+	sort.Slice(meetingIDs, func(i, j int) bool {
+		return meetingIDs[i] < meetingIDs[j]
+	})
+
+	if len(meetingIDs) > limit {
+		meetingIDs = meetingIDs[:limit]
+	}
+
+	var races []*racing.Race
+
+	for _, meetingID := range meetingIDs {
+		meetingRaces := byMeeting[meetingID]
+
+		// Races are listed by ID, so those with the same number stay in ID
+		// order.
+		sort.SliceStable(meetingRaces, func(i, j int) bool {
+			return meetingRaces[i].Number < meetingRaces[j].Number
+		})
+
+		if len(meetingRaces) > raceLimit {
+			meetingRaces = meetingRaces[:raceLimit]
+		}
+
+		races = append(races, meetingRaces...)
+	}
+
+	return races, nil
+}
+
+func (m *memoryRacesRepo) ListBetween(filter *racing.ListRacesRequestFilter, audience Audience, from, to time.Time) ([]*racing.Race, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var races []*racing.Race
+
+	for _, race := range m.list(m.races, filter, audience) {
+		if race.AdvertisedStartTime == nil {
+			continue
+		}
+
+		start := race.AdvertisedStartTime.AsTime()
+
+		if !start.Before(from) && start.Before(to) {
+			races = append(races, race)
+		}
+	}
+
+	// Races are listed by ID, so those starting together stay in ID order.
+	sort.SliceStable(races, func(i, j int) bool {
+		return races[i].AdvertisedStartTime.AsTime().Before(races[j].AdvertisedStartTime.AsTime())
+	})
+
+	return races, nil
+}
+
+func (m *memoryRacesRepo) ListAt(*racing.ListRacesRequestFilter, time.Time) ([]*racing.Race, error) {
+	return nil, ErrEventLogDisabled
+}
+
+func (m *memoryRacesRepo) ListArchived(filter *racing.ListRacesRequestFilter, audience Audience, from, to time.Time, after int64, limit int) ([]*racing.Race, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var page []*racing.Race
+
+	for _, race := range m.list(m.archived, filter, audience) {
+		if len(page) == limit {
+			break
+		}
+
+		if race.Id <= after {
+			continue
+		}
+
+		// Races without a start time are outside every bounded range.
+		if !from.IsZero() || !to.IsZero() {
+			if race.AdvertisedStartTime == nil {
+				continue
+			}
+
+			start := race.AdvertisedStartTime.AsTime()
+
+			if (!from.IsZero() && start.Before(from)) || (!to.IsZero() && !start.Before(to)) {
+				continue
+			}
+		}
+
+		page = append(page, race)
+	}
+
+	return page, nil
+}
+
+func (m *memoryRacesRepo) Archive(before time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var archived int64
+
+	for _, race := range m.startingBefore(m.races, before) {
+		m.archived[race.Id] = race
+		m.delete(race)
+
+		archived++
+	}
+
+	return archived, nil
+}
+
+func (m *memoryRacesRepo) Purge(before time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var purged int64
+
+	for _, race := range m.startingBefore(m.races, before) {
+		if race.Status == racing.Race_OPEN {
+			continue
+		}
+
+		m.delete(race)
+
+		purged++
+	}
+
+	for _, race := range m.startingBefore(m.archived, before) {
+		if race.Status == racing.Race_OPEN {
+			continue
+		}
+
+		delete(m.archived, race.Id)
+
+		purged++
+	}
+
+	for raceID := range m.restrictions {
+		if !m.exists(raceID) {
+			delete(m.restrictions, raceID)
+		}
+	}
+
+	for raceID := range m.brandVisibility {
+		if !m.exists(raceID) {
+			delete(m.brandVisibility, raceID)
+		}
+	}
+
+	for raceID, hours := range m.views {
+		if _, ok := m.races[raceID]; !ok {
+			delete(m.views, raceID)
+
+			continue
+		}
+
+		for hour := range hours {
+			if hour.Before(before) {
+				delete(hours, hour)
+			}
+		}
+	}
+
+	// Keep the status history of races still around.
+	var statusChanges []memoryStatusChange
+
+	for _, change := range m.statusChanges {
+		if m.exists(change.raceID) {
+			statusChanges = append(statusChanges, change)
+		}
+	}
+
+	m.statusChanges = statusChanges
+
+	// The change log only needs to cover as long as races are kept for.
+	var changes []*racing.RaceChange
+
+	for _, change := range m.changes {
+		if !change.ChangedAt.AsTime().Before(before) {
+			changes = append(changes, change)
+		}
+	}
+
+	m.changes = changes
+
+	return purged, nil
+}
+
+func (m *memoryRacesRepo) LocalisedNames([]int64, string) (map[int64]string, error) {
+	return make(map[int64]string), nil
+}
+
+func (m *memoryRacesRepo) SetFeatured(raceID int64, featured bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.races[raceID]; !ok {
+		return ErrNotFound
+	}
+
+	if !featured {
+		delete(m.featured, raceID)
+	} else if _, ok := m.featured[raceID]; !ok {
+		m.featured[raceID] = m.clock.Now().Truncate(time.Second)
+	}
+
+	return nil
+}
+
+func (m *memoryRacesRepo) ListFeatured() ([]*racing.Race, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var featured []*racing.Race
+
+	now := m.clock.Now()
+
+	// Sorted by ID first, so races featured in the same second stay in ID
+	// order.
+	for _, race := range m.sorted(m.races) {
+		if _, ok := m.featured[race.Id]; ok && race.Visible {
+			featured = append(featured, m.output(race, now))
+		}
+	}
+
+	sort.SliceStable(featured, func(i, j int) bool {
+		return m.featured[featured[i].Id].Before(m.featured[featured[j].Id])
+	})
+
+	return featured, nil
+}
+
+func (m *memoryRacesRepo) SetRestrictions(raceID int64, jurisdictions []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.races[raceID]; !ok {
+		return ErrNotFound
+	}
+
+	m.restrictions[raceID] = append([]string(nil), jurisdictions...)
+
+	return nil
+}
+
+func (m *memoryRacesRepo) Restricted(raceIDs []int64, jurisdiction string) (map[int64]bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	restricted := make(map[int64]bool)
+
+	for _, raceID := range raceIDs {
+		if m.restricted(raceID, jurisdiction) {
+			restricted[raceID] = true
+		}
+	}
+
+	return restricted, nil
+}
+
+// restricted returns whether a race is restricted in the given jurisdiction.
+func (m *memoryRacesRepo) restricted(raceID int64, jurisdiction string) bool {
+	for _, restrictedIn := range m.restrictions[raceID] {
+		if jurisdiction != "" && restrictedIn == jurisdiction {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (m *memoryRacesRepo) SetBrandVisibility(raceID int64, brand string, visible *bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	race, ok := m.races[raceID]
+	if !ok {
+		return ErrNotFound
+	}
+
+	m.recordChange(race, racing.RaceChange_UPSERTED)
+
+	if visible == nil {
+		delete(m.brandVisibility[raceID], brand)
+
+		return nil
+	}
+
+	if m.brandVisibility[raceID] == nil {
+		m.brandVisibility[raceID] = make(map[string]bool)
+	}
+
+	m.brandVisibility[raceID][brand] = *visible
+
+	return nil
+}
+
+func (m *memoryRacesRepo) BrandVisibility(raceIDs []int64, brand string) (map[int64]bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	visibility := make(map[int64]bool)
+
+	for _, raceID := range raceIDs {
+		if visible, ok := m.brandVisibility[raceID][brand]; ok {
+			visibility[raceID] = visible
+		}
+	}
+
+	return visibility, nil
+}
+
+func (m *memoryRacesRepo) RecordViews(raceID int64, views int64, at time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.races[raceID]; !ok {
+		return ErrNotFound
+	}
+
+	if m.views[raceID] == nil {
+		m.views[raceID] = make(map[time.Time]int64)
+	}
+
+	m.views[raceID][viewHour(at)] += views
+
+	return nil
+}
+
+func (m *memoryRacesRepo) ListPopular(filter *racing.ListRacesRequestFilter, audience Audience, since time.Time, offset int64, limit int) ([]*racing.PopularRace, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var popular []*racing.PopularRace
+
+	for _, race := range m.list(m.races, filter, audience) {
+		var views int64
+
+		for hour, count := range m.views[race.Id] {
+			if !hour.Before(viewHour(since)) {
+				views += count
+			}
+		}
+
+		popular = append(popular, &racing.PopularRace{Race: race, Views: views})
+	}
+
+	// Races are listed by ID, so those viewed as often stay in ID order.
+	sort.SliceStable(popular, func(i, j int) bool {
+		return popular[i].Views > popular[j].Views
+	})
+
+	if offset >= int64(len(popular)) {
+		return nil, nil
+	}
+
+	popular = popular[offset:]
+
+	if len(popular) > limit {
+		popular = popular[:limit]
+	}
+
+	return popular, nil
+}
+
+func (m *memoryRacesRepo) Stats(filter *racing.ListRacesRequestFilter) (*racing.RaceStats, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var (
+		stats     racing.RaceStats
+		byMeeting = make(map[int64]int64)
+		byCountry = make(map[string]int64)
+		byStatus  = make(map[string]int64)
+		byDay     = make(map[string]int64)
+	)
+
+	for _, race := range m.list(m.races, filter, Audience{}) {
+		stats.Total++
+
+		byMeeting[race.MeetingId]++
+		byCountry[race.Country]++
+		byStatus[race.Status.String()]++
+
+		var day string
+		if race.AdvertisedStartTime != nil {
+			day = race.AdvertisedStartTime.AsTime().UTC().Format("2006-01-02")
+		}
+
+		byDay[day]++
+	}
+
+	meetingIDs := make([]int64, 0, len(byMeeting))
+	for meetingID := range byMeeting {
+		meetingIDs = append(meetingIDs, meetingID)
+	}
+
+	sort.Slice(meetingIDs, func(i, j int) bool { return meetingIDs[i] < meetingIDs[j] })
+
+	for _, meetingID := range meetingIDs {
+		stats.ByMeeting = append(stats.ByMeeting, &racing.StatsCount{
+			Key:   strconv.FormatInt(meetingID, 10),
+			Count: byMeeting[meetingID],
+		})
+	}
+
+	stats.ByCountry = sortedCounts(byCountry)
+	stats.ByStatus = sortedCounts(byStatus)
+	stats.ByDay = sortedCounts(byDay)
+
+	return &stats, nil
+}
+
+// sortedCounts returns counts by their key, in key order.
+func sortedCounts(counts map[string]int64) []*racing.StatsCount {
+	keys := make([]string, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	var sorted []*racing.StatsCount
+	for _, key := range keys {
+		sorted = append(sorted, &racing.StatsCount{Key: key, Count: counts[key]})
+	}
+
+	return sorted
+}
+
+func (m *memoryRacesRepo) GetByExternalRef(source, sourceID string) (*racing.Race, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	race := m.findByRef(source, sourceID)
+	if race == nil {
+		return nil, ErrNotFound
+	}
+
+	return m.output(race, m.clock.Now()), nil
+}
+
+func (m *memoryRacesRepo) Upsert(race *racing.Race) (UpsertResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	result, err := m.upsert(race)
+	if err != nil {
+		return UpsertSkipped, err
+	}
+
+	racesUpserted.Add(result.String(), 1)
+
+	return result, nil
+}
+
+func (m *memoryRacesRepo) UpsertBatch(races []*racing.Race) ([]UpsertOutcome, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	outcomes := make([]UpsertOutcome, len(races))
+
+	for i, race := range races {
+		outcomes[i].Result, outcomes[i].Err = m.upsert(race)
+
+		if outcomes[i].Err == nil {
+			racesUpserted.Add(outcomes[i].Result.String(), 1)
+		}
+	}
+
+	return outcomes, nil
+}
+
+func (m *memoryRacesRepo) UpdateStatus(raceID int64, status racing.Race_Status, actor string) (*racing.Race, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	race, ok := m.races[raceID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	if status != race.Status {
+		if !canTransition(race.Status, status) {
+			return nil, invalidTransition(race.Status, status)
+		}
+
+		m.recordStatus(raceID, race.Status, status, actor)
+
+		race.Status = status
+		m.recordChange(race, racing.RaceChange_UPSERTED)
+	}
+
+	return m.output(race, m.clock.Now()), nil
+}
+
+func (m *memoryRacesRepo) StatusHistory(raceID int64) ([]*racing.StatusTransition, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.exists(raceID) {
+		return nil, ErrNotFound
+	}
+
+	var transitions []*racing.StatusTransition
+
+	for _, change := range m.statusChanges {
+		if change.raceID == raceID {
+			transitions = append(transitions, proto.Clone(change.transition).(*racing.StatusTransition))
+		}
+	}
+
+	return transitions, nil
+}
+
+func (m *memoryRacesRepo) StatusChanges(after int64, limit int) ([]*StatusChange, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var (
+		changes []*StatusChange
+		now     = m.clock.Now()
+	)
+
+	// Status changes are numbered from 1, in the order made.
+	for i := int(after); i < len(m.statusChanges) && len(changes) < limit; i++ {
+		if i < 0 {
+			continue
+		}
+
+		change := &StatusChange{
+			ID:         int64(i + 1),
+			Transition: proto.Clone(m.statusChanges[i].transition).(*racing.StatusTransition),
+		}
+
+		if race, ok := m.races[m.statusChanges[i].raceID]; ok {
+			change.Race = m.output(race, now)
+		}
+
+		changes = append(changes, change)
+	}
+
+	return changes, nil
+}
+
+func (m *memoryRacesRepo) ListStarting(after, before time.Time) ([]*racing.Race, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var races []*racing.Race
+
+	now := m.clock.Now()
+
+	for _, race := range m.sorted(m.races) {
+		if race.AdvertisedStartTime == nil {
+			continue
+		}
+
+		start := race.AdvertisedStartTime.AsTime()
+
+		if race.Status == racing.Race_OPEN && start.After(after) && !start.After(before) {
+			races = append(races, m.output(race, now))
+		}
+	}
+
+	return races, nil
+}
+
+func (m *memoryRacesRepo) ListByIDs(raceIDs []int64) ([]*racing.Race, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	races := make([]*racing.Race, 0, len(raceIDs))
+
+	now := m.clock.Now()
+
+	for _, raceID := range raceIDs {
+		if race, ok := m.races[raceID]; ok {
+			races = append(races, m.output(race, now))
+		}
+	}
+
+	return races, nil
+}
+
+func (m *memoryRacesRepo) Replay() (int64, error) {
+	return 0, ErrEventLogDisabled
+}
+
+func (m *memoryRacesRepo) Changes(after int64, limit int) ([]*racing.RaceChange, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var changes []*racing.RaceChange
+
+	now := m.clock.Now()
+
+	for _, change := range m.changes {
+		if len(changes) == limit {
+			break
+		}
+
+		if id, _ := changeID(change); id <= after {
+			continue
+		}
+
+		change = proto.Clone(change).(*racing.RaceChange)
+
+		if race, ok := m.races[change.RaceId]; ok && change.Operation == racing.RaceChange_UPSERTED {
+			change.Race = m.output(race, now)
+		}
+
+		changes = append(changes, change)
+	}
+
+	return changes, nil
+}
+
+// upsert creates or updates a race, deduplicated by its external reference.
+func (m *memoryRacesRepo) upsert(race *racing.Race) (UpsertResult, error) {
+	ref := race.GetExternalRef()
+	if ref.GetSource() == "" || ref.GetSourceId() == "" {
+		return UpsertSkipped, ErrMissingExternalRef
+	}
+
+	existing := m.findByRef(ref.Source, ref.SourceId)
+
+	if existing == nil {
+		// New races are open unless the source says otherwise.
+		if race.Status == racing.Race_UNSPECIFIED {
+			race.Status = racing.Race_OPEN
+		}
+
+		if m.numberTaken(race, 0) {
+			return UpsertSkipped, ErrAlreadyExists
+		}
+
+		m.nextID++
+		race.Id = m.nextID
+
+		m.races[race.Id] = m.input(race)
+		m.recordStatus(race.Id, racing.Race_UNSPECIFIED, race.Status, ref.Source)
+		m.recordChange(race, racing.RaceChange_UPSERTED)
+
+		return UpsertCreated, nil
+	}
+
+	// Sources that don't track status leave it as it is.
+	if race.Status == racing.Race_UNSPECIFIED {
+		race.Status = existing.Status
+	}
+
+	if race.Status != existing.Status && !canTransition(existing.Status, race.Status) {
+		return UpsertSkipped, invalidTransition(existing.Status, race.Status)
+	}
+
+	if m.numberTaken(race, existing.Id) {
+		return UpsertSkipped, ErrAlreadyExists
+	}
+
+	race.Id = existing.Id
+
+	updated := m.input(race)
+	if proto.Equal(updated, existing) {
+		return UpsertSkipped, nil
+	}
+
+	if race.Status != existing.Status {
+		m.recordStatus(race.Id, existing.Status, race.Status, ref.Source)
+	}
+
+	m.races[race.Id] = updated
+	m.recordChange(race, racing.RaceChange_UPSERTED)
+
+	return UpsertUpdated, nil
+}
+
+// input copies a race to store, without the fields worked out as it's read,
+// and with its start time to the second, as SQLite stores it.
+func (m *memoryRacesRepo) input(race *racing.Race) *racing.Race {
+	stored := proto.Clone(race).(*racing.Race)
+	stored.SecondsToStart = 0
+	stored.LocalAdvertisedStartTime = ""
+	if race.AdvertisedStartTime != nil {
+		stored.AdvertisedStartTime = timestamppb.New(race.AdvertisedStartTime.AsTime().Truncate(time.Second))
+	}
+
+	return stored
+}
+
+// output copies a stored race to return, counting down to it from now.
+func (m *memoryRacesRepo) output(race *racing.Race, now time.Time) *racing.Race {
+	out := proto.Clone(race).(*racing.Race)
+	if race.AdvertisedStartTime != nil {
+		out.SecondsToStart = int64(race.AdvertisedStartTime.AsTime().Sub(now).Seconds())
+	}
+
+	return out
+}
+
+// list returns the races matching the filter, as listed for the audience, in
+// the order they were created.
+func (m *memoryRacesRepo) list(races map[int64]*racing.Race, filter *racing.ListRacesRequestFilter, audience Audience) []*racing.Race {
+	var listed []*racing.Race
+
+	now := m.clock.Now()
+
+	for _, race := range m.sorted(races) {
+		if m.restricted(race.Id, audience.Jurisdiction) {
+			continue
+		}
+
+		race = m.output(race, now)
+
+		if visible, ok := m.brandVisibility[race.Id][audience.Brand]; ok && audience.Brand != "" {
+			race.Visible = visible
+		}
+
+		if matchesFilter(race, filter) {
+			listed = append(listed, race)
+		}
+	}
+
+	return listed
+}
+
+// sorted returns races in the order they were created, as SQLite lists them.
+func (m *memoryRacesRepo) sorted(races map[int64]*racing.Race) []*racing.Race {
+	sorted := make([]*racing.Race, 0, len(races))
+	for _, race := range races {
+		sorted = append(sorted, race)
+	}
+
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Id < sorted[j].Id
+	})
+
+	return sorted
+}
+
+// startingBefore returns the races advertised to start before the given time.
+func (m *memoryRacesRepo) startingBefore(races map[int64]*racing.Race, before time.Time) []*racing.Race {
+	var starting []*racing.Race
+
+	for _, race := range m.sorted(races) {
+		if race.AdvertisedStartTime != nil && race.AdvertisedStartTime.AsTime().Before(before) {
+			starting = append(starting, race)
+		}
+	}
+
+	return starting
+}
+
+// findByRef returns the race an external source knows by the given ID, or
+// nil if there isn't one.
+func (m *memoryRacesRepo) findByRef(source, sourceID string) *racing.Race {
+	for _, race := range m.races {
+		if race.ExternalRef.GetSource() == source && race.ExternalRef.GetSourceId() == sourceID {
+			return race
+		}
+	}
+
+	return nil
+}
+
+// numberTaken reports whether another race than the given one has the race's
+// number in its meeting.
+func (m *memoryRacesRepo) numberTaken(race *racing.Race, raceID int64) bool {
+	for _, other := range m.races {
+		if other.Id != raceID && other.MeetingId == race.MeetingId && other.Number == race.Number {
+			return true
+		}
+	}
+
+	return false
+}
+
+// exists reports whether a race exists, archived or not.
+func (m *memoryRacesRepo) exists(raceID int64) bool {
+	_, live := m.races[raceID]
+	_, archived := m.archived[raceID]
+
+	return live || archived
+}
+
+// delete removes a race, recording it in the change log.
+func (m *memoryRacesRepo) delete(race *racing.Race) {
+	delete(m.races, race.Id)
+	delete(m.featured, race.Id)
+	m.recordChange(race, racing.RaceChange_DELETED)
+}
+
+// recordStatus records a race's move between statuses in its status history.
+func (m *memoryRacesRepo) recordStatus(raceID int64, from, to racing.Race_Status, actor string) {
+	m.statusChanges = append(m.statusChanges, memoryStatusChange{
+		raceID: raceID,
+		transition: &racing.StatusTransition{
+			FromStatus: from,
+			ToStatus:   to,
+			Actor:      actor,
+			ChangedAt:  timestamppb.New(m.clock.Now().Truncate(time.Second)),
+		},
+	})
+}
+
+// recordChange records a change to a race in the change log.
+func (m *memoryRacesRepo) recordChange(race *racing.Race, operation racing.RaceChange_Operation) {
+	var id int64
+	if len(m.changes) > 0 {
+		id, _ = changeID(m.changes[len(m.changes)-1])
+	}
+
+	m.changes = append(m.changes, &racing.RaceChange{
+		Token:     strconv.FormatInt(id+1, 10),
+		RaceId:    race.Id,
+		MeetingId: race.MeetingId,
+		Operation: operation,
+		ChangedAt: timestamppb.New(m.clock.Now().Truncate(time.Second)),
+	})
+}
+
+// changeID returns the ID of a change, from its token.
+func changeID(change *racing.RaceChange) (int64, error) {
+	return strconv.ParseInt(change.Token, 10, 64)
+}
+
+// matchesFilter reports whether a race should be listed by the filter, as
+// applyFilter would list it.
+func matchesFilter(race *racing.Race, filter *racing.ListRacesRequestFilter) bool {
+	if filter == nil {
+		return true
+	}
+
+	if len(filter.MeetingIds) > 0 && !containsInt64(filter.MeetingIds, race.MeetingId) {
+		return false
+	}
+
+	if len(filter.Countries) > 0 {
+		found := false
+		for _, country := range filter.Countries {
+			found = found || strings.ToUpper(country) == race.Country
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	if filter.MinDistance > 0 && race.Distance < filter.MinDistance {
+		return false
+	}
+
+	if filter.MaxDistance > 0 && race.Distance > filter.MaxDistance {
+		return false
+	}
+
+	if filter.MissingStartTime && race.AdvertisedStartTime != nil {
+		return false
+	}
+
+	switch filter.Visibility {
+	case racing.ListRacesRequestFilter_VISIBLE_ONLY:
+		return race.Visible
+	case racing.ListRacesRequestFilter_HIDDEN_ONLY:
+		return !race.Visible
+	}
+
+	return true
+}
+
+// containsInt64 reports whether values contains value.
+func containsInt64(values []int64, value int64) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
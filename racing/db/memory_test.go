@@ -0,0 +1,409 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"git.neds.sh/matty/entain/racing/proto/racing"
+	"git.neds.sh/matty/entain/racing/racetest"
+	"google.golang.org/protobuf/proto"
+	"pgregory.net/rapid"
+)
+
+// The in-memory repository is checked against the SQLite one, doing the same
+// to both, so it keeps behaving like the real thing.
+
+// memoryTestNow is when the repositories compared pretend it is.
+var memoryTestNow = time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+
+// newComparedRacesRepos creates an empty SQLite races repository, and an
+// in-memory one, at the same fixed time.
+func newComparedRacesRepos(t *testing.T) (sqlite, memory RacesRepo) {
+	t.Helper()
+
+	sqlite = NewRacesRepo(newTestDB(t), WithoutDummyData(), WithClock(FixedClock(memoryTestNow)))
+	if err := sqlite.Init(); err != nil {
+		t.Fatalf("initialising races repo: %s", err)
+	}
+
+	return sqlite, NewInMemoryRacesRepo(WithClock(FixedClock(memoryTestNow)))
+}
+
+// sameRaces reports whether two lists of races are equal.
+func sameRaces(a, b []*racing.Race) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if !proto.Equal(a[i], b[i]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func TestInMemoryRacesRepoListsLikeSQLite(t *testing.T) {
+	rapid.Check(t, func(t *rapid.T) {
+		testDB, err := sql.Open("sqlite3", ":memory:")
+		if err != nil {
+			t.Fatalf("opening test database: %s", err)
+		}
+		defer testDB.Close()
+
+		testDB.SetMaxOpenConns(1)
+
+		sqlite := NewRacesRepo(testDB, WithoutDummyData(), WithClock(FixedClock(memoryTestNow)))
+		if err := sqlite.Init(); err != nil {
+			t.Fatalf("initialising races repo: %s", err)
+		}
+
+		memory := NewInMemoryRacesRepo(WithClock(FixedClock(memoryTestNow)))
+
+		n := rapid.IntRange(0, 12).Draw(t, "races").(int)
+
+		for i := 0; i < n; i++ {
+			race := racetest.NewRace().
+				Meeting(rapid.Int64Range(1, 4).Draw(t, "meeting_id").(int64)).
+				Number(rapid.Int64Range(1, 3).Draw(t, "number").(int64)).
+				Country(rapid.SampledFrom([]string{"AU", "NZ", "GB"}).Draw(t, "country").(string)).
+				Distance(rapid.Int64Range(800, 3200).Draw(t, "distance").(int64)).
+				Visible(rapid.Bool().Draw(t, "visible").(bool)).
+				StartsAt(memoryTestNow.Add(time.Duration(rapid.IntRange(-120, 120).Draw(t, "starts_in").(int))*time.Minute)).
+				ExternalRef("feed", fmt.Sprintf("R%d", rapid.IntRange(0, 8).Draw(t, "source_id").(int))).
+				Build()
+
+			if rapid.IntRange(0, 9).Draw(t, "unscheduled").(int) == 0 {
+				race.AdvertisedStartTime = nil
+			}
+
+			wantResult, wantErr := sqlite.Upsert(proto.Clone(race).(*racing.Race))
+			gotResult, gotErr := memory.Upsert(proto.Clone(race).(*racing.Race))
+
+			if gotResult != wantResult || fmt.Sprint(gotErr) != fmt.Sprint(wantErr) {
+				t.Fatalf("Upsert() = %s, %v, want %s, %v", gotResult, gotErr, wantResult, wantErr)
+			}
+		}
+
+		filter := drawFilter(t)
+
+		want, err := sqlite.List(filter)
+		if err != nil {
+			t.Fatalf("SQLite List() error = %s", err)
+		}
+
+		got, err := memory.List(filter)
+		if err != nil {
+			t.Fatalf("List() error = %s", err)
+		}
+
+		if !sameRaces(got, want) {
+			t.Fatalf("List() = %v, want %v", got, want)
+		}
+
+		after := rapid.Int64Range(0, 4).Draw(t, "after_meeting_id").(int64)
+		limit := rapid.IntRange(1, 4).Draw(t, "meetings").(int)
+		raceLimit := rapid.IntRange(1, 3).Draw(t, "meeting_races").(int)
+
+		want, err = sqlite.ListMeetingPage(filter, Audience{}, after, limit, raceLimit)
+		if err != nil {
+			t.Fatalf("SQLite ListMeetingPage() error = %s", err)
+		}
+
+		got, err = memory.ListMeetingPage(filter, Audience{}, after, limit, raceLimit)
+		if err != nil {
+			t.Fatalf("ListMeetingPage() error = %s", err)
+		}
+
+		if !sameRaces(got, want) {
+			t.Fatalf("ListMeetingPage() = %v, want %v", got, want)
+		}
+
+		wantStats, err := sqlite.Stats(filter)
+		if err != nil {
+			t.Fatalf("SQLite Stats() error = %s", err)
+		}
+
+		gotStats, err := memory.Stats(filter)
+		if err != nil {
+			t.Fatalf("Stats() error = %s", err)
+		}
+
+		if !proto.Equal(gotStats, wantStats) {
+			t.Fatalf("Stats() = %v, want %v", gotStats, wantStats)
+		}
+	})
+}
+
+func TestInMemoryRacesRepoLifecycleLikeSQLite(t *testing.T) {
+	sqlite, memory := newComparedRacesRepos(t)
+
+	races := []*racing.Race{
+		racetest.NewRace().Meeting(1).Number(1).StartsAt(memoryTestNow.Add(-48*time.Hour)).ExternalRef("feed", "old").Build(),
+		racetest.NewRace().Meeting(1).Number(2).StartsAt(memoryTestNow.Add(time.Hour)).ExternalRef("feed", "soon").Build(),
+		racetest.NewRace().Meeting(2).Number(1).StartsAt(memoryTestNow.Add(2*time.Hour)).ExternalRef("feed", "later").Build(),
+	}
+
+	for _, repo := range []RacesRepo{sqlite, memory} {
+		batch := make([]*racing.Race, len(races))
+		for i, race := range races {
+			batch[i] = proto.Clone(race).(*racing.Race)
+		}
+
+		if _, err := repo.UpsertBatch(batch); err != nil {
+			t.Fatalf("UpsertBatch() error = %s", err)
+		}
+	}
+
+	steps := []struct {
+		name string
+		do   func(repo RacesRepo) (interface{}, error)
+	}{
+		{"close race", func(repo RacesRepo) (interface{}, error) {
+			return repo.UpdateStatus(2, racing.Race_CLOSED, "steward")
+		}},
+		{"reopen race", func(repo RacesRepo) (interface{}, error) {
+			return repo.UpdateStatus(2, racing.Race_OPEN, "steward")
+		}},
+		{"update missing race", func(repo RacesRepo) (interface{}, error) {
+			return repo.UpdateStatus(99, racing.Race_CLOSED, "steward")
+		}},
+		{"upsert clashing number", func(repo RacesRepo) (interface{}, error) {
+			return repo.Upsert(racetest.NewRace().Meeting(1).Number(2).ExternalRef("feed", "clash").Build())
+		}},
+		{"upsert unchanged", func(repo RacesRepo) (interface{}, error) {
+			return repo.Upsert(proto.Clone(races[2]).(*racing.Race))
+		}},
+		{"list starting", func(repo RacesRepo) (interface{}, error) {
+			return repo.ListStarting(memoryTestNow, memoryTestNow.Add(2*time.Hour))
+		}},
+		{"list between", func(repo RacesRepo) (interface{}, error) {
+			return repo.ListBetween(&racing.ListRacesRequestFilter{MeetingIds: []int64{1}}, Audience{}, memoryTestNow.Add(-72*time.Hour), memoryTestNow.Add(2*time.Hour))
+		}},
+		{"list by ids", func(repo RacesRepo) (interface{}, error) {
+			return repo.ListByIDs([]int64{3, 99, 1})
+		}},
+		{"get by ref", func(repo RacesRepo) (interface{}, error) {
+			return repo.GetByExternalRef("feed", "later")
+		}},
+		{"feature race", func(repo RacesRepo) (interface{}, error) {
+			return nil, repo.SetFeatured(3, true)
+		}},
+		{"feature old race", func(repo RacesRepo) (interface{}, error) {
+			return nil, repo.SetFeatured(1, true)
+		}},
+		{"feature missing race", func(repo RacesRepo) (interface{}, error) {
+			return nil, repo.SetFeatured(99, true)
+		}},
+		{"list featured", func(repo RacesRepo) (interface{}, error) {
+			return repo.ListFeatured()
+		}},
+		{"restrict races", func(repo RacesRepo) (interface{}, error) {
+			if err := repo.SetRestrictions(1, []string{"SA", "NSW"}); err != nil {
+				return nil, err
+			}
+
+			return nil, repo.SetRestrictions(3, []string{"SA"})
+		}},
+		{"lift restrictions", func(repo RacesRepo) (interface{}, error) {
+			return nil, repo.SetRestrictions(3, nil)
+		}},
+		{"restrict missing race", func(repo RacesRepo) (interface{}, error) {
+			return nil, repo.SetRestrictions(99, []string{"SA"})
+		}},
+		{"restricted", func(repo RacesRepo) (interface{}, error) {
+			return repo.Restricted([]int64{1, 2, 3, 99}, "SA")
+		}},
+		{"restricted elsewhere", func(repo RacesRepo) (interface{}, error) {
+			return repo.Restricted([]int64{1, 2, 3}, "VIC")
+		}},
+		{"list page in jurisdiction", func(repo RacesRepo) (interface{}, error) {
+			return repo.ListPage(nil, Audience{Jurisdiction: "SA"}, 0, 10)
+		}},
+		{"list meeting page in jurisdiction", func(repo RacesRepo) (interface{}, error) {
+			return repo.ListMeetingPage(nil, Audience{Jurisdiction: "SA"}, 0, 10, 1)
+		}},
+		{"list between in jurisdiction", func(repo RacesRepo) (interface{}, error) {
+			return repo.ListBetween(nil, Audience{Jurisdiction: "NSW"}, memoryTestNow.Add(-72*time.Hour), memoryTestNow.Add(2*time.Hour))
+		}},
+		{"override brand visibility", func(repo RacesRepo) (interface{}, error) {
+			if err := repo.SetBrandVisibility(1, "neds", proto.Bool(false)); err != nil {
+				return nil, err
+			}
+
+			if err := repo.SetBrandVisibility(2, "neds", proto.Bool(true)); err != nil {
+				return nil, err
+			}
+
+			return nil, repo.SetBrandVisibility(3, "ladbrokes", proto.Bool(false))
+		}},
+		{"inherit brand visibility", func(repo RacesRepo) (interface{}, error) {
+			return nil, repo.SetBrandVisibility(2, "neds", nil)
+		}},
+		{"override missing race's brand visibility", func(repo RacesRepo) (interface{}, error) {
+			return nil, repo.SetBrandVisibility(99, "neds", proto.Bool(true))
+		}},
+		{"brand visibility", func(repo RacesRepo) (interface{}, error) {
+			return repo.BrandVisibility([]int64{1, 2, 3, 99}, "neds")
+		}},
+		{"list page for brand", func(repo RacesRepo) (interface{}, error) {
+			return repo.ListPage(&racing.ListRacesRequestFilter{Visibility: racing.ListRacesRequestFilter_HIDDEN_ONLY}, Audience{Jurisdiction: "VIC", Brand: "neds"}, 0, 10)
+		}},
+		{"record views", func(repo RacesRepo) (interface{}, error) {
+			for _, views := range []struct {
+				raceID int64
+				count  int64
+				at     time.Time
+			}{
+				{1, 3, memoryTestNow.Add(-30 * time.Hour)},
+				{2, 5, memoryTestNow.Add(-30 * time.Hour)},
+				{2, 2, memoryTestNow.Add(-90 * time.Minute)},
+				{2, 1, memoryTestNow},
+				{3, 4, memoryTestNow.Add(10 * time.Minute)},
+			} {
+				if err := repo.RecordViews(views.raceID, views.count, views.at); err != nil {
+					return nil, err
+				}
+			}
+
+			return nil, nil
+		}},
+		{"record views of missing race", func(repo RacesRepo) (interface{}, error) {
+			return nil, repo.RecordViews(99, 1, memoryTestNow)
+		}},
+		{"list popular", func(repo RacesRepo) (interface{}, error) {
+			return repo.ListPopular(nil, Audience{}, memoryTestNow.Add(-time.Hour), 0, 10)
+		}},
+		{"list popular page", func(repo RacesRepo) (interface{}, error) {
+			return repo.ListPopular(nil, Audience{}, memoryTestNow.Add(-48*time.Hour), 1, 2)
+		}},
+		{"list popular in jurisdiction", func(repo RacesRepo) (interface{}, error) {
+			return repo.ListPopular(&racing.ListRacesRequestFilter{Visibility: racing.ListRacesRequestFilter_VISIBLE_ONLY}, Audience{Jurisdiction: "VIC", Brand: "neds"}, memoryTestNow.Add(-48*time.Hour), 0, 10)
+		}},
+		{"close old race", func(repo RacesRepo) (interface{}, error) {
+			return repo.UpdateStatus(1, racing.Race_CLOSED, "steward")
+		}},
+		{"archive", func(repo RacesRepo) (interface{}, error) {
+			return repo.Archive(memoryTestNow.Add(-24 * time.Hour))
+		}},
+		{"list featured after archive", func(repo RacesRepo) (interface{}, error) {
+			return repo.ListFeatured()
+		}},
+		{"feature archived race", func(repo RacesRepo) (interface{}, error) {
+			return nil, repo.SetFeatured(1, true)
+		}},
+		{"restricted after archive", func(repo RacesRepo) (interface{}, error) {
+			return repo.Restricted([]int64{1}, "NSW")
+		}},
+		{"restrict archived race", func(repo RacesRepo) (interface{}, error) {
+			return nil, repo.SetRestrictions(1, nil)
+		}},
+		{"record views of archived race", func(repo RacesRepo) (interface{}, error) {
+			return nil, repo.RecordViews(1, 1, memoryTestNow)
+		}},
+		{"list", func(repo RacesRepo) (interface{}, error) {
+			return repo.List(nil)
+		}},
+		{"stats", func(repo RacesRepo) (interface{}, error) {
+			return repo.Stats(nil)
+		}},
+		{"filtered stats", func(repo RacesRepo) (interface{}, error) {
+			return repo.Stats(&racing.ListRacesRequestFilter{MeetingIds: []int64{2}})
+		}},
+		{"list page", func(repo RacesRepo) (interface{}, error) {
+			return repo.ListPage(nil, Audience{}, 2, 1)
+		}},
+		{"list archived", func(repo RacesRepo) (interface{}, error) {
+			return repo.ListArchived(nil, Audience{}, time.Time{}, time.Time{}, 0, 10)
+		}},
+		{"list archived in jurisdiction", func(repo RacesRepo) (interface{}, error) {
+			return repo.ListArchived(nil, Audience{Jurisdiction: "NSW"}, time.Time{}, time.Time{}, 0, 10)
+		}},
+		{"list archived between", func(repo RacesRepo) (interface{}, error) {
+			return repo.ListArchived(nil, Audience{}, memoryTestNow.Add(-72*time.Hour), memoryTestNow, 0, 10)
+		}},
+		{"status history", func(repo RacesRepo) (interface{}, error) {
+			return repo.StatusHistory(2)
+		}},
+		{"archived status history", func(repo RacesRepo) (interface{}, error) {
+			return repo.StatusHistory(1)
+		}},
+		{"status changes", func(repo RacesRepo) (interface{}, error) {
+			changes, err := repo.StatusChanges(1, 3)
+
+			var described []string
+			for _, change := range changes {
+				described = append(described, fmt.Sprintf("%d %s %v", change.ID, change.Transition, change.Race))
+			}
+
+			return described, err
+		}},
+		{"changes", func(repo RacesRepo) (interface{}, error) {
+			changes, err := repo.Changes(2, 10)
+
+			// SQLite records changes at the system time.
+			for _, change := range changes {
+				change.ChangedAt = nil
+			}
+
+			return changes, err
+		}},
+		{"purge", func(repo RacesRepo) (interface{}, error) {
+			return repo.Purge(memoryTestNow.Add(-24 * time.Hour))
+		}},
+		{"purged status history", func(repo RacesRepo) (interface{}, error) {
+			return repo.StatusHistory(1)
+		}},
+		{"purged restrictions", func(repo RacesRepo) (interface{}, error) {
+			return repo.Restricted([]int64{1}, "NSW")
+		}},
+		{"purged brand visibility", func(repo RacesRepo) (interface{}, error) {
+			return repo.BrandVisibility([]int64{1}, "neds")
+		}},
+		{"purged views", func(repo RacesRepo) (interface{}, error) {
+			return repo.ListPopular(nil, Audience{}, memoryTestNow.Add(-48*time.Hour), 0, 10)
+		}},
+	}
+
+	for _, step := range steps {
+		want, wantErr := step.do(sqlite)
+		got, gotErr := step.do(memory)
+
+		if fmt.Sprint(gotErr) != fmt.Sprint(wantErr) {
+			t.Errorf("%s: error = %v, want %v", step.name, gotErr, wantErr)
+		}
+
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("%s: got %v, want %v", step.name, got, want)
+		}
+	}
+}
+
+func TestUpsertWithoutExternalRef(t *testing.T) {
+	sqlite, memory := newComparedRacesRepos(t)
+
+	for name, repo := range map[string]RacesRepo{"sqlite": sqlite, "memory": memory} {
+		race := racetest.NewRace().Build()
+		race.ExternalRef = nil
+
+		if _, err := repo.Upsert(race); !errors.Is(err, ErrMissingExternalRef) {
+			t.Errorf("%s: Upsert() error = %v, want %s", name, err, ErrMissingExternalRef)
+		}
+	}
+}
+
+func TestInMemoryRacesRepoWithoutEventLog(t *testing.T) {
+	repo := NewInMemoryRacesRepo()
+
+	if _, err := repo.ListAt(nil, memoryTestNow); !errors.Is(err, ErrEventLogDisabled) {
+		t.Errorf("ListAt() error = %v, want %s", err, ErrEventLogDisabled)
+	}
+
+	if _, err := repo.Replay(); !errors.Is(err, ErrEventLogDisabled) {
+		t.Errorf("Replay() error = %v, want %s", err, ErrEventLogDisabled)
+	}
+}
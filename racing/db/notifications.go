@@ -0,0 +1,300 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"sync"
+	"time"
+
+	"git.neds.sh/matty/entain/racing/proto/racing"
+	"github.com/golang/protobuf/ptypes"
+)
+
+// NotificationsRepo provides repository access to the devices registered for
+// push notifications, and how far notifications have got.
+type NotificationsRepo interface {
+	// Init will initialise our notifications repository.
+	Init() error
+
+	// RegisterDevice will register a device for the audience it's sent races
+	// as they're listed for, setting its ID and when it was first registered.
+	// A device already registered on the channel has what it follows, and who
+	// for, replaced.
+	RegisterDevice(device *racing.Device, audience Audience) error
+
+	// UnregisterDevice will delete a device, or return ErrNotFound if it isn't
+	// registered.
+	UnregisterDevice(channel, token string) error
+
+	// Following will return the devices following a race, directly, by its
+	// meeting or by their user's watchlist, that it's listed for as it is
+	// now. Brand visibility and restrictions are read from the races
+	// repository's tables, so it must be initialised on the same database.
+	Following(race *racing.Race) ([]*racing.Device, error)
+
+	// Cursor will return how far the named notification has got, starting it
+	// at initial if it hasn't run before.
+	Cursor(name string, initial int64) (int64, error)
+
+	// SetCursor will record how far the named notification has got.
+	SetCursor(name string, position int64) error
+
+	// Watch will add a race to a user's watchlist as watched at the given
+	// time, returning when the user first watched it.
+	Watch(userID string, raceID int64, at time.Time) (*racing.WatchedRace, error)
+
+	// Unwatch will remove a race from a user's watchlist, or return
+	// ErrNotFound if it isn't on it.
+	Unwatch(userID string, raceID int64) error
+
+	// Watchlist will return the races on a user's watchlist, in the order
+	// they were watched, without the races themselves.
+	Watchlist(userID string) ([]*racing.WatchedRace, error)
+}
+
+type notificationsRepo struct {
+	db   *sql.DB
+	init sync.Once
+}
+
+// NewNotificationsRepo creates a new notifications repository.
+func NewNotificationsRepo(db *sql.DB) NotificationsRepo {
+	return &notificationsRepo{db: db}
+}
+
+// Init prepares the notifications repository tables.
+func (r *notificationsRepo) Init() error {
+	var err error
+
+	r.init.Do(func() {
+		err = r.createTables()
+	})
+
+	return err
+}
+
+func (r *notificationsRepo) RegisterDevice(device *racing.Device, audience Audience) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(
+		getNotificationQueries()[devicesRegister],
+		device.Channel,
+		device.Token,
+		device.GetFilter().GetUserId(),
+		audience.Jurisdiction,
+		audience.Brand,
+		time.Now().UTC().Format(time.RFC3339),
+	)
+	if err != nil {
+		return err
+	}
+
+	var createdAt time.Time
+
+	if err := tx.QueryRow(getNotificationQueries()[devicesFind], device.Channel, device.Token).Scan(&device.Id, &createdAt); err != nil {
+		return err
+	}
+
+	if device.CreatedAt, err = ptypes.TimestampProto(createdAt); err != nil {
+		return err
+	}
+
+	if err := setDeviceFollows(tx, device.Id, device.GetFilter().GetRaceIds(), device.GetFilter().GetMeetingIds()); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// setDeviceFollows replaces the races and meetings a device follows. They're
+// kept a row each, so races are matched by their IDs exactly.
+func setDeviceFollows(tx *sql.Tx, deviceID int64, raceIDs, meetingIDs []int64) error {
+	for _, query := range []string{deviceRacesClear, deviceMeetingsClear} {
+		if _, err := tx.Exec(getNotificationQueries()[query], deviceID); err != nil {
+			return err
+		}
+	}
+
+	for _, raceID := range raceIDs {
+		if _, err := tx.Exec(getNotificationQueries()[deviceRacesAdd], raceID, deviceID); err != nil {
+			return err
+		}
+	}
+
+	for _, meetingID := range meetingIDs {
+		if _, err := tx.Exec(getNotificationQueries()[deviceMeetingsAdd], meetingID, deviceID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *notificationsRepo) UnregisterDevice(channel, token string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var (
+		deviceID  int64
+		createdAt time.Time
+	)
+
+	err = tx.QueryRow(getNotificationQueries()[devicesFind], channel, token).Scan(&deviceID, &createdAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := setDeviceFollows(tx, deviceID, nil, nil); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(getNotificationQueries()[devicesUnregister], channel, token); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *notificationsRepo) Following(race *racing.Race) ([]*racing.Device, error) {
+	rows, err := r.db.Query(getNotificationQueries()[devicesFollowing], race.Id, race.MeetingId, race.Visible)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var devices []*racing.Device
+
+	for rows.Next() {
+		var (
+			device              racing.Device
+			raceIDs, meetingIDs string
+			userID              sql.NullString
+			createdAt           time.Time
+			filter              racing.DeviceFilter
+		)
+
+		if err := rows.Scan(&device.Id, &device.Channel, &device.Token, &raceIDs, &meetingIDs, &userID, &createdAt); err != nil {
+			return nil, err
+		}
+
+		if filter.RaceIds, err = parseIDs(raceIDs); err != nil {
+			return nil, err
+		}
+
+		if filter.MeetingIds, err = parseIDs(meetingIDs); err != nil {
+			return nil, err
+		}
+
+		filter.UserId = userID.String
+		device.Filter = &filter
+
+		if device.CreatedAt, err = ptypes.TimestampProto(createdAt); err != nil {
+			return nil, err
+		}
+
+		devices = append(devices, &device)
+	}
+
+	return devices, rows.Err()
+}
+
+func (r *notificationsRepo) Cursor(name string, initial int64) (int64, error) {
+	if _, err := r.db.Exec(getNotificationQueries()[cursorsInit], name, initial); err != nil {
+		return 0, err
+	}
+
+	var position int64
+
+	err := r.db.QueryRow(getNotificationQueries()[cursorsGet], name).Scan(&position)
+
+	return position, err
+}
+
+func (r *notificationsRepo) SetCursor(name string, position int64) error {
+	_, err := r.db.Exec(getNotificationQueries()[cursorsSet], position, name)
+
+	return err
+}
+
+func (r *notificationsRepo) Watch(userID string, raceID int64, at time.Time) (*racing.WatchedRace, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(getNotificationQueries()[watchlistsAdd], userID, raceID, at.UTC().Format(time.RFC3339)); err != nil {
+		return nil, err
+	}
+
+	var watchedAt time.Time
+
+	if err := tx.QueryRow(getNotificationQueries()[watchlistsFind], userID, raceID).Scan(&watchedAt); err != nil {
+		return nil, err
+	}
+
+	watched := &racing.WatchedRace{RaceId: raceID}
+
+	if watched.WatchedAt, err = ptypes.TimestampProto(watchedAt); err != nil {
+		return nil, err
+	}
+
+	return watched, tx.Commit()
+}
+
+func (r *notificationsRepo) Unwatch(userID string, raceID int64) error {
+	res, err := r.db.Exec(getNotificationQueries()[watchlistsRemove], userID, raceID)
+	if err != nil {
+		return err
+	}
+
+	deleted, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if deleted == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (r *notificationsRepo) Watchlist(userID string) ([]*racing.WatchedRace, error) {
+	rows, err := r.db.Query(getNotificationQueries()[watchlistsList], userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var watchlist []*racing.WatchedRace
+
+	for rows.Next() {
+		var (
+			watched   racing.WatchedRace
+			watchedAt time.Time
+		)
+
+		if err := rows.Scan(&watched.RaceId, &watchedAt); err != nil {
+			return nil, err
+		}
+
+		if watched.WatchedAt, err = ptypes.TimestampProto(watchedAt); err != nil {
+			return nil, err
+		}
+
+		watchlist = append(watchlist, &watched)
+	}
+
+	return watchlist, rows.Err()
+}
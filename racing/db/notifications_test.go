@@ -0,0 +1,287 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"git.neds.sh/matty/entain/racing/proto/racing"
+	"git.neds.sh/matty/entain/racing/racetest"
+	"google.golang.org/protobuf/proto"
+)
+
+// notificationsTestNow is when races are watched from.
+var notificationsTestNow = time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+
+// newTestNotificationsRepo creates a notifications repository on an empty
+// in-memory database.
+func newTestNotificationsRepo(t *testing.T) NotificationsRepo {
+	t.Helper()
+
+	return initTestNotificationsRepo(t, newTestDB(t))
+}
+
+// initTestNotificationsRepo initialises a notifications repository on the
+// given database, with the races repository it reads races' visibility from.
+func initTestNotificationsRepo(t *testing.T, testDB *sql.DB) NotificationsRepo {
+	t.Helper()
+
+	if err := NewRacesRepo(testDB, WithoutDummyData()).Init(); err != nil {
+		t.Fatalf("initialising races repo: %s", err)
+	}
+
+	repo := NewNotificationsRepo(testDB)
+	if err := repo.Init(); err != nil {
+		t.Fatalf("initialising notifications repo: %s", err)
+	}
+
+	return repo
+}
+
+func TestNotificationsRepoInitMigratesDevices(t *testing.T) {
+	testDB := newTestDB(t)
+
+	// Devices as they were before they could follow a user's watchlist.
+	for _, statement := range []string{
+		`CREATE TABLE devices (id INTEGER PRIMARY KEY, channel TEXT, token TEXT, race_ids TEXT, meeting_ids TEXT, created_at DATETIME, UNIQUE (channel, token))`,
+		`INSERT INTO devices (channel, token, race_ids, meeting_ids, created_at) VALUES ('push', 'old', '1', '', '2021-03-01T00:00:00Z')`,
+		`INSERT INTO devices (channel, token, race_ids, meeting_ids, created_at) VALUES ('push', 'older', '10,2', '15', '2021-03-01T00:00:00Z')`,
+	} {
+		if _, err := testDB.Exec(statement); err != nil {
+			t.Fatalf("creating old devices table: %s", err)
+		}
+	}
+
+	repo := initTestNotificationsRepo(t, testDB)
+
+	// Initialising again finds the columns already added, and what devices
+	// follow already moved.
+	if err := NewNotificationsRepo(testDB).Init(); err != nil {
+		t.Fatalf("Init() again error = %s", err)
+	}
+
+	var columns int
+	if err := testDB.QueryRow(`SELECT COUNT(*) FROM pragma_table_info('devices') WHERE name IN ('race_ids', 'meeting_ids')`).Scan(&columns); err != nil || columns != 0 {
+		t.Errorf("devices has %d columns of followed IDs, %v, want none", columns, err)
+	}
+
+	if err := repo.RegisterDevice(&racing.Device{Channel: "push", Token: "new", Filter: &racing.DeviceFilter{UserId: "punter"}}, Audience{}); err != nil {
+		t.Fatalf("RegisterDevice() error = %s", err)
+	}
+
+	if _, err := repo.Watch("punter", 1, notificationsTestNow); err != nil {
+		t.Fatalf("Watch() error = %s", err)
+	}
+
+	tests := []struct {
+		race       *racing.Race
+		wantTokens []string
+	}{
+		{&racing.Race{Id: 1, MeetingId: 5, Visible: true}, []string{"old", "new"}},
+		{&racing.Race{Id: 2, MeetingId: 5, Visible: true}, []string{"older"}},
+		{&racing.Race{Id: 3, MeetingId: 15, Visible: true}, []string{"older"}},
+		{&racing.Race{Id: 0, MeetingId: 1, Visible: true}, nil},
+	}
+
+	for _, tt := range tests {
+		following, err := repo.Following(tt.race)
+		if err != nil {
+			t.Fatalf("Following(%d) error = %s", tt.race.Id, err)
+		}
+
+		var tokens []string
+		for _, device := range following {
+			tokens = append(tokens, device.Token)
+		}
+
+		if fmt.Sprint(tokens) != fmt.Sprint(tt.wantTokens) {
+			t.Errorf("Following(%d) = devices %v, want %v", tt.race.Id, tokens, tt.wantTokens)
+		}
+	}
+
+	following, err := repo.Following(&racing.Race{Id: 2, MeetingId: 5, Visible: true})
+	if err != nil || len(following) != 1 {
+		t.Fatalf("Following(2) = %v, %v, want the older device", following, err)
+	}
+
+	if filter := following[0].Filter; fmt.Sprint(filter.RaceIds, filter.MeetingIds) != "[10 2] [15]" {
+		t.Errorf("Following(2) device follows races %v, meetings %v, want races [10 2], meetings [15]", filter.RaceIds, filter.MeetingIds)
+	}
+}
+
+func TestNotificationsRepoWatchlist(t *testing.T) {
+	repo := newTestNotificationsRepo(t)
+
+	watches := []struct {
+		raceID int64
+		at     time.Duration
+		// wantAt is when the race should say it was first watched.
+		wantAt time.Duration
+	}{
+		{3, 0, 0},
+		{1, time.Minute, time.Minute},
+		{3, 2 * time.Minute, 0},
+	}
+
+	for _, w := range watches {
+		watched, err := repo.Watch("punter", w.raceID, notificationsTestNow.Add(w.at))
+		if err != nil {
+			t.Fatalf("Watch(%d) error = %s", w.raceID, err)
+		}
+
+		if want := notificationsTestNow.Add(w.wantAt); watched.RaceId != w.raceID || !watched.WatchedAt.AsTime().Equal(want) {
+			t.Errorf("Watch(%d) = %v, want race %d watched at %s", w.raceID, watched, w.raceID, want)
+		}
+	}
+
+	if _, err := repo.Watch("someone else", 2, notificationsTestNow); err != nil {
+		t.Fatalf("Watch() error = %s", err)
+	}
+
+	watchlist, err := repo.Watchlist("punter")
+	if err != nil {
+		t.Fatalf("Watchlist() error = %s", err)
+	}
+
+	if len(watchlist) != 2 || watchlist[0].RaceId != 3 || watchlist[1].RaceId != 1 {
+		t.Errorf("Watchlist() = %v, want races 3 then 1", watchlist)
+	}
+
+	if err := repo.Unwatch("punter", 3); err != nil {
+		t.Fatalf("Unwatch() error = %s", err)
+	}
+
+	if err := repo.Unwatch("punter", 3); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Unwatch() unwatched race error = %v, want %s", err, ErrNotFound)
+	}
+
+	if watchlist, err = repo.Watchlist("punter"); err != nil || len(watchlist) != 1 || watchlist[0].RaceId != 1 {
+		t.Errorf("Watchlist() after unwatching = %v, %v, want race 1", watchlist, err)
+	}
+}
+
+func TestNotificationsRepoFollowingWatchlist(t *testing.T) {
+	repo := newTestNotificationsRepo(t)
+
+	devices := []*racing.Device{
+		{Channel: "push", Token: "race", Filter: &racing.DeviceFilter{RaceIds: []int64{10}}},
+		{Channel: "push", Token: "punter", Filter: &racing.DeviceFilter{UserId: "punter"}},
+		{Channel: "push", Token: "someone-else", Filter: &racing.DeviceFilter{UserId: "someone else"}},
+	}
+	for _, device := range devices {
+		if err := repo.RegisterDevice(device, Audience{}); err != nil {
+			t.Fatalf("RegisterDevice() error = %s", err)
+		}
+	}
+
+	if _, err := repo.Watch("punter", 1, notificationsTestNow); err != nil {
+		t.Fatalf("Watch() error = %s", err)
+	}
+
+	tests := []struct {
+		race       *racing.Race
+		wantTokens []string
+	}{
+		{&racing.Race{Id: 1, MeetingId: 5, Visible: true}, []string{"punter"}},
+		{&racing.Race{Id: 10, MeetingId: 5, Visible: true}, []string{"race"}},
+		{&racing.Race{Id: 2, MeetingId: 5, Visible: true}, nil},
+	}
+
+	for _, tt := range tests {
+		following, err := repo.Following(tt.race)
+		if err != nil {
+			t.Fatalf("Following(%d) error = %s", tt.race.Id, err)
+		}
+
+		var tokens []string
+		for _, device := range following {
+			tokens = append(tokens, device.Token)
+
+			if device.Token == "punter" && device.Filter.UserId != "punter" {
+				t.Errorf("Following(%d) device user = %q, want %q", tt.race.Id, device.Filter.UserId, "punter")
+			}
+		}
+
+		if fmt.Sprint(tokens) != fmt.Sprint(tt.wantTokens) {
+			t.Errorf("Following(%d) = devices %v, want %v", tt.race.Id, tokens, tt.wantTokens)
+		}
+	}
+}
+
+func TestNotificationsRepoFollowingAsListed(t *testing.T) {
+	testDB := newTestDB(t)
+	repo := initTestNotificationsRepo(t, testDB)
+	racesRepo := NewRacesRepo(testDB, WithoutDummyData())
+
+	hidden := racetest.NewRace().Meeting(5).Number(1).Hidden().ExternalRef("feed", "hidden").Build()
+	restricted := racetest.NewRace().Meeting(5).Number(2).ExternalRef("feed", "restricted").Build()
+
+	for _, race := range []*racing.Race{hidden, restricted} {
+		if _, err := racesRepo.Upsert(race); err != nil {
+			t.Fatalf("Upsert() error = %s", err)
+		}
+	}
+
+	if err := racesRepo.SetBrandVisibility(hidden.Id, "neds", proto.Bool(true)); err != nil {
+		t.Fatalf("SetBrandVisibility() error = %s", err)
+	}
+
+	if err := racesRepo.SetBrandVisibility(restricted.Id, "ladbrokes", proto.Bool(false)); err != nil {
+		t.Fatalf("SetBrandVisibility() error = %s", err)
+	}
+
+	if err := racesRepo.SetRestrictions(restricted.Id, []string{"SA"}); err != nil {
+		t.Fatalf("SetRestrictions() error = %s", err)
+	}
+
+	audiences := map[string]Audience{
+		"anyone":    {},
+		"neds":      {Brand: "neds"},
+		"ladbrokes": {Brand: "ladbrokes"},
+		"sa":        {Jurisdiction: "SA"},
+	}
+	for _, token := range []string{"anyone", "neds", "ladbrokes", "sa"} {
+		device := &racing.Device{Channel: "push", Token: token, Filter: &racing.DeviceFilter{MeetingIds: []int64{5}}}
+		if err := repo.RegisterDevice(device, audiences[token]); err != nil {
+			t.Fatalf("RegisterDevice(%s) error = %s", token, err)
+		}
+	}
+
+	tests := []struct {
+		race       *racing.Race
+		wantTokens []string
+	}{
+		// Hidden races are only sent to devices of brands showing them.
+		{hidden, []string{"neds"}},
+		// Races aren't sent to devices of brands hiding them, or from where
+		// they're restricted.
+		{restricted, []string{"anyone", "neds"}},
+	}
+
+	for _, tt := range tests {
+		following, err := repo.Following(tt.race)
+		if err != nil {
+			t.Fatalf("Following(%s) error = %s", tt.race.ExternalRef.SourceId, err)
+		}
+
+		var tokens []string
+		for _, device := range following {
+			tokens = append(tokens, device.Token)
+		}
+
+		if fmt.Sprint(tokens) != fmt.Sprint(tt.wantTokens) {
+			t.Errorf("Following(%s) = devices %v, want %v", tt.race.ExternalRef.SourceId, tokens, tt.wantTokens)
+		}
+	}
+
+	if err := repo.UnregisterDevice("push", "neds"); err != nil {
+		t.Fatalf("UnregisterDevice() error = %s", err)
+	}
+
+	var follows int
+	if err := testDB.QueryRow(`SELECT COUNT(*) FROM device_meetings WHERE device_id NOT IN (SELECT id FROM devices)`).Scan(&follows); err != nil || follows != 0 {
+		t.Errorf("%d meetings followed by unregistered devices, %v, want none", follows, err)
+	}
+}
@@ -0,0 +1,103 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+// OutboxEvent is an event waiting in the outbox to be published.
+type OutboxEvent struct {
+	// ID orders events by when they were written.
+	ID int64
+	// Key is what the event should be keyed by when published.
+	Key string
+	// Message is the event itself.
+	Message proto.Message
+}
+
+// OutboxRepo provides repository access to the outbox, which holds events
+// written in the same transaction as the changes they describe, until they
+// are published.
+type OutboxRepo interface {
+	// Pending will return up to limit events still to be published, oldest
+	// first.
+	Pending(limit int) ([]*OutboxEvent, error)
+
+	// Delete will remove an event from the outbox, once it's been published.
+	Delete(id int64) error
+}
+
+type outboxRepo struct {
+	db *sql.DB
+}
+
+// NewOutboxRepo creates a new outbox repository. The outbox table is created
+// by the races repository, which writes to it.
+func NewOutboxRepo(db *sql.DB) OutboxRepo {
+	return &outboxRepo{db: db}
+}
+
+func (r *outboxRepo) Pending(limit int) ([]*OutboxEvent, error) {
+	rows, err := r.db.Query(getOutboxQueries()[outboxPending], limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []*OutboxEvent
+
+	for rows.Next() {
+		var (
+			event   OutboxEvent
+			msgType string
+			payload []byte
+		)
+
+		if err := rows.Scan(&event.ID, &msgType, &event.Key, &payload); err != nil {
+			return nil, err
+		}
+
+		mt, err := protoregistry.GlobalTypes.FindMessageByName(protoreflect.FullName(msgType))
+		if err != nil {
+			return nil, err
+		}
+
+		event.Message = mt.New().Interface()
+		if err := proto.Unmarshal(payload, event.Message); err != nil {
+			return nil, err
+		}
+
+		events = append(events, &event)
+	}
+
+	return events, rows.Err()
+}
+
+func (r *outboxRepo) Delete(id int64) error {
+	_, err := r.db.Exec(getOutboxQueries()[outboxDelete], id)
+
+	return err
+}
+
+// writeOutboxEvent writes an event into the outbox, as part of the transaction
+// making the change it describes.
+func writeOutboxEvent(tx *sql.Tx, key string, msg proto.Message) error {
+	payload, err := proto.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(
+		getOutboxQueries()[outboxInsert],
+		string(msg.ProtoReflect().Descriptor().FullName()),
+		key,
+		payload,
+		time.Now().UTC().Format(time.RFC3339),
+	)
+
+	return err
+}
@@ -1,7 +1,58 @@
 package db
 
+// orderByID orders the rows of list queries, that filters are appended to, by
+// ID, so they're listed in the same order every time.
+const orderByID = " ORDER BY id"
+
 const (
-	racesList = "list"
+	racesList            = "list"
+	racesArchive         = "archive"
+	racesPurge           = "purge"
+	archivedRacesList    = "list_archived"
+	racesListForBrand    = "list_for_brand"
+	archivedListForBrand = "list_archived_for_brand"
+	archivedRacesPurge   = "purge_archived"
+	closedRacesPurge     = "purge_closed"
+	raceNamesList        = "list_names"
+	raceNamesPurge       = "purge_names"
+	racesFindByRef       = "find_by_ref"
+	racesInsert          = "insert"
+	racesUpdate          = "update"
+	raceChangesList      = "list_changes"
+	raceChangesPurge     = "purge_changes"
+	raceStatusFind       = "find_status"
+	raceStatusUpdate     = "update_status"
+	raceStatusRecord     = "record_status"
+	raceStatusPurge      = "purge_status"
+	raceStatusHistory    = "status_history"
+	raceExists           = "exists"
+	raceStatusChanges    = "status_changes"
+	racesStarting        = "starting"
+	raceSavepoint        = "savepoint"
+	raceRollbackTo       = "rollback_to_savepoint"
+	raceRelease          = "release_savepoint"
+	raceUnarchived       = "unarchived"
+	raceFeature          = "feature"
+	raceUnfeature        = "unfeature"
+	featuredRacesList    = "list_featured"
+	featuredRacesPurge   = "purge_featured"
+	raceUnrestrict       = "unrestrict"
+	raceRestrict         = "restrict"
+	restrictedRaceIDs    = "list_restricted"
+	racesUnrestricted    = "unrestricted"
+	restrictionsPurge    = "purge_restrictions"
+	brandVisibilityClear = "clear_brand_visibility"
+	brandVisibilitySet   = "set_brand_visibility"
+	brandVisibilityList  = "list_brand_visibility"
+	brandVisibilityPurge = "purge_brand_visibility"
+	raceTouch            = "touch"
+	raceViewsRecord      = "record_views"
+	raceViewsList        = "list_views"
+	raceViewsPurge       = "purge_views"
+	raceStatsByMeeting   = "stats_by_meeting"
+	raceStatsByCountry   = "stats_by_country"
+	raceStatsByStatus    = "stats_by_status"
+	raceStatsByDay       = "stats_by_day"
 )
 
 func getRaceQueries() map[string]string {
@@ -13,8 +64,1050 @@ func getRaceQueries() map[string]string {
 				name, 
 				number, 
 				visible, 
-				advertised_start_time 
+				advertised_start_time, 
+				venue_time_zone, 
+				country, 
+				distance, 
+				track_condition, 
+				weather, 
+				venue_image, 
+				external_source, 
+				external_id, 
+				status 
+			FROM races
+		`,
+		// Replace rather than ignore, as the seeded dummy data re-uses ids once
+		// archived races have been removed from the races table.
+		racesArchive: `
+			INSERT OR REPLACE INTO races_archive (
+				id,
+				meeting_id,
+				name,
+				number,
+				visible,
+				advertised_start_time,
+				venue_time_zone,
+				country,
+				distance,
+				track_condition,
+				weather,
+				venue_image,
+				external_source,
+				external_id,
+				status,
+				archived_at
+			)
+			SELECT
+				id,
+				meeting_id,
+				name,
+				number,
+				visible,
+				advertised_start_time,
+				venue_time_zone,
+				country,
+				distance,
+				track_condition,
+				weather,
+				venue_image,
+				external_source,
+				external_id,
+				status,
+				CURRENT_TIMESTAMP
+			FROM races
+			WHERE datetime(advertised_start_time) < datetime(?)
+		`,
+		racesPurge: `
+			DELETE FROM races
+			WHERE datetime(advertised_start_time) < datetime(?)
+		`,
+		archivedRacesList: `
+			SELECT 
+				id, 
+				meeting_id, 
+				name, 
+				number, 
+				visible, 
+				advertised_start_time, 
+				venue_time_zone, 
+				country, 
+				distance, 
+				track_condition, 
+				weather, 
+				venue_image, 
+				external_source, 
+				external_id, 
+				status 
+			FROM races_archive
+		`,
+		// Races as they're listed for a brand, with their visibility overridden
+		// for it, for filters to be applied to as they are to racesList.
+		racesListForBrand: `
+			SELECT
+				id,
+				meeting_id,
+				name,
+				number,
+				visible,
+				advertised_start_time,
+				venue_time_zone,
+				country,
+				distance,
+				track_condition,
+				weather,
+				venue_image,
+				external_source,
+				external_id,
+				status
+			FROM (
+				SELECT
+					r.id,
+					r.meeting_id,
+					r.name,
+					r.number,
+					COALESCE(b.visible, r.visible) AS visible,
+					r.advertised_start_time,
+					r.venue_time_zone,
+					r.country,
+					r.distance,
+					r.track_condition,
+					r.weather,
+					r.venue_image,
+					r.external_source,
+					r.external_id,
+					r.status
+				FROM races r
+				LEFT JOIN race_brand_visibility b ON b.race_id = r.id AND b.brand = ?
+			)
+		`,
+		archivedListForBrand: `
+			SELECT
+				id,
+				meeting_id,
+				name,
+				number,
+				visible,
+				advertised_start_time,
+				venue_time_zone,
+				country,
+				distance,
+				track_condition,
+				weather,
+				venue_image,
+				external_source,
+				external_id,
+				status
+			FROM (
+				SELECT
+					r.id,
+					r.meeting_id,
+					r.name,
+					r.number,
+					COALESCE(b.visible, r.visible) AS visible,
+					r.advertised_start_time,
+					r.venue_time_zone,
+					r.country,
+					r.distance,
+					r.track_condition,
+					r.weather,
+					r.venue_image,
+					r.external_source,
+					r.external_id,
+					r.status
+				FROM races_archive r
+				LEFT JOIN race_brand_visibility b ON b.race_id = r.id AND b.brand = ?
+			)
+		`,
+		// Races still open when they're purged are overdue rather than done
+		// with, e.g. delayed or awaiting results, so are kept.
+		archivedRacesPurge: `
+			DELETE FROM races_archive
+			WHERE datetime(advertised_start_time) < datetime(?) AND status IS NOT 'OPEN'
+		`,
+		closedRacesPurge: `
+			DELETE FROM races
+			WHERE datetime(advertised_start_time) < datetime(?) AND status IS NOT 'OPEN'
+		`,
+		racesFindByRef: `
+			SELECT id, visible, status
+			FROM races
+			WHERE external_source = ? AND external_id = ?
+		`,
+		racesInsert: `
+			INSERT INTO races (
+				meeting_id,
+				name,
+				number,
+				visible,
+				advertised_start_time,
+				venue_time_zone,
+				country,
+				distance,
+				track_condition,
+				weather,
+				venue_image,
+				status,
+				external_source,
+				external_id
+			) VALUES (?,?,?,?,?,?,?,?,?,?,?,?,?,?)
+		`,
+		// Only touch the row when something has changed, so the number of rows
+		// affected tells us whether the race was updated.
+		racesUpdate: `
+			UPDATE races SET
+				meeting_id = ?1,
+				name = ?2,
+				number = ?3,
+				visible = ?4,
+				advertised_start_time = ?5,
+				venue_time_zone = ?6,
+				country = ?7,
+				distance = ?8,
+				track_condition = ?9,
+				weather = ?10,
+				venue_image = ?11,
+				status = ?12
+			WHERE id = ?13 AND NOT (
+				meeting_id IS ?1 AND
+				name IS ?2 AND
+				number IS ?3 AND
+				visible IS ?4 AND
+				advertised_start_time IS ?5 AND
+				venue_time_zone IS ?6 AND
+				country IS ?7 AND
+				distance IS ?8 AND
+				track_condition IS ?9 AND
+				weather IS ?10 AND
+				venue_image IS ?11 AND
+				status IS ?12
+			)
+		`,
+		raceNamesList: `
+			SELECT
+				race_id,
+				locale,
+				name
+			FROM race_names
+		`,
+		raceNamesPurge: `
+			DELETE FROM race_names
+			WHERE race_id NOT IN (SELECT id FROM races UNION SELECT id FROM races_archive)
+		`,
+		raceChangesList: `
+			SELECT
+				id,
+				race_id,
+				meeting_id,
+				operation,
+				changed_at
+			FROM race_changes
+			WHERE id > ?
+			ORDER BY id
+			LIMIT ?
+		`,
+		raceStatusFind: `
+			SELECT visible, status
+			FROM races
+			WHERE id = ?
+		`,
+		raceStatusUpdate: `
+			UPDATE races
+			SET status = ?
+			WHERE id = ?
+		`,
+		raceStatusRecord: `
+			INSERT INTO race_status_history (race_id, from_status, to_status, actor, changed_at)
+			VALUES (?, ?, ?, ?, ?)
+		`,
+		raceStatusHistory: `
+			SELECT
+				from_status,
+				to_status,
+				actor,
+				changed_at
+			FROM race_status_history
+			WHERE race_id = ?
+			ORDER BY id
+		`,
+		raceStatusChanges: `
+			SELECT
+				id,
+				race_id,
+				from_status,
+				to_status,
+				actor,
+				changed_at
+			FROM race_status_history
+			WHERE id > ?
+			ORDER BY id
+			LIMIT ?
+		`,
+		racesStarting: `
+			SELECT 
+				id, 
+				meeting_id, 
+				name, 
+				number, 
+				visible, 
+				advertised_start_time, 
+				venue_time_zone, 
+				country, 
+				distance, 
+				track_condition, 
+				weather, 
+				venue_image, 
+				external_source, 
+				external_id, 
+				status 
+			FROM races
+			WHERE status = 'OPEN'
+			AND datetime(advertised_start_time) > datetime(?)
+			AND datetime(advertised_start_time) <= datetime(?)
+			ORDER BY id
+		`,
+		// Savepoints let a batch of upserts roll back a race that fails to
+		// write without losing the rest of the transaction.
+		raceSavepoint:  `SAVEPOINT upsert_race`,
+		raceRollbackTo: `ROLLBACK TO SAVEPOINT upsert_race`,
+		raceRelease:    `RELEASE SAVEPOINT upsert_race`,
+		raceExists: `
+			SELECT EXISTS (
+				SELECT 1 FROM races WHERE id = ?1
+				UNION ALL
+				SELECT 1 FROM races_archive WHERE id = ?1
+			)
+		`,
+		raceUnarchived: `
+			SELECT EXISTS (SELECT 1 FROM races WHERE id = ?)
+		`,
+		// Featuring a race again keeps its place in the carousel.
+		raceFeature: `
+			INSERT OR IGNORE INTO featured_races (race_id, featured_at)
+			VALUES (?, ?)
+		`,
+		raceUnfeature: `
+			DELETE FROM featured_races
+			WHERE race_id = ?
+		`,
+		featuredRacesList: `
+			SELECT
+				r.id,
+				r.meeting_id,
+				r.name,
+				r.number,
+				r.visible,
+				r.advertised_start_time,
+				r.venue_time_zone,
+				r.country,
+				r.distance,
+				r.track_condition,
+				r.weather,
+				r.venue_image,
+				r.external_source,
+				r.external_id,
+				r.status
+			FROM races r
+			JOIN featured_races f ON f.race_id = r.id
+			WHERE r.visible = 1
+			ORDER BY f.featured_at, r.id
+		`,
+		// Archived races stop being featured, so races seeded later with
+		// their IDs don't take their place.
+		featuredRacesPurge: `
+			DELETE FROM featured_races
+			WHERE race_id NOT IN (SELECT id FROM races)
+		`,
+		raceUnrestrict: `
+			DELETE FROM race_restrictions
+			WHERE race_id = ?
+		`,
+		raceRestrict: `
+			INSERT OR IGNORE INTO race_restrictions (race_id, jurisdiction)
+			VALUES (?, ?)
+		`,
+		restrictedRaceIDs: `
+			SELECT race_id
+			FROM race_restrictions
+			WHERE jurisdiction = ?
+		`,
+		// A condition list queries are filtered by, rather than a query of its
+		// own. The race's ID is left unqualified, as race_restrictions has no
+		// id column, so it's that of whichever table is listed.
+		racesUnrestricted: `
+			NOT EXISTS (
+				SELECT 1
+				FROM race_restrictions
+				WHERE race_restrictions.race_id = id AND race_restrictions.jurisdiction = ?
+			)
+		`,
+		// Archived races keep their restrictions, as they can still be listed.
+		restrictionsPurge: `
+			DELETE FROM race_restrictions
+			WHERE race_id NOT IN (SELECT id FROM races UNION SELECT id FROM races_archive)
+		`,
+		brandVisibilityClear: `
+			DELETE FROM race_brand_visibility
+			WHERE race_id = ? AND brand = ?
+		`,
+		brandVisibilitySet: `
+			INSERT INTO race_brand_visibility (race_id, brand, visible)
+			VALUES (?, ?, ?)
+			ON CONFLICT (race_id, brand) DO UPDATE SET visible = excluded.visible
+		`,
+		// Updating a race without changing it fires its triggers, so changes
+		// kept apart from the race are logged like changes to it.
+		raceTouch: `
+			UPDATE races
+			SET visible = visible
+			WHERE id = ?
+		`,
+		brandVisibilityList: `
+			SELECT race_id, visible
+			FROM race_brand_visibility
+			WHERE brand = ?
+		`,
+		// Archived races keep their overrides, as they can still be listed.
+		brandVisibilityPurge: `
+			DELETE FROM race_brand_visibility
+			WHERE race_id NOT IN (SELECT id FROM races UNION SELECT id FROM races_archive)
+		`,
+		// Views are only counted for races that haven't been archived.
+		raceViewsRecord: `
+			INSERT INTO race_views (race_id, hour, views)
+			SELECT id, ?2, ?3 FROM races WHERE id = ?1
+			ON CONFLICT (race_id, hour) DO UPDATE SET views = views + excluded.views
+		`,
+		// Views counted since an hour, by race, for races listed to be ranked
+		// by as they're read.
+		raceViewsList: `
+			SELECT race_id, SUM(views) AS views
+			FROM race_views
+			WHERE datetime(hour) >= datetime(?)
+			GROUP BY race_id
+		`,
+		raceViewsPurge: `
+			DELETE FROM race_views
+			WHERE datetime(hour) < datetime(?) OR race_id NOT IN (SELECT id FROM races)
+		`,
+		// Stats are grouped by their first column, once filtered.
+		raceStatsByMeeting: `
+			SELECT meeting_id, COUNT(*)
+			FROM races
+		`,
+		raceStatsByCountry: `
+			SELECT country, COUNT(*)
+			FROM races
+		`,
+		raceStatsByStatus: `
+			SELECT COALESCE(status, 'UNSPECIFIED'), COUNT(*)
+			FROM races
+		`,
+		raceStatsByDay: `
+			SELECT COALESCE(date(advertised_start_time), ''), COUNT(*)
+			FROM races
+		`,
+		raceStatusPurge: `
+			DELETE FROM race_status_history
+			WHERE race_id NOT IN (SELECT id FROM races UNION SELECT id FROM races_archive)
+		`,
+		raceChangesPurge: `
+			DELETE FROM race_changes
+			WHERE datetime(changed_at) < datetime(?)
+		`,
+	}
+}
+
+const (
+	marketsList    = "list"
+	selectionsList = "list_selections"
+)
+
+func getMarketQueries() map[string]string {
+	return map[string]string{
+		marketsList: `
+			SELECT
+				id,
+				race_id,
+				name,
+				status
+			FROM markets
+		`,
+		selectionsList: `
+			SELECT
+				id,
+				market_id,
+				name,
+				price,
+				status
+			FROM selections
+		`,
+	}
+}
+
+const (
+	outboxInsert  = "insert"
+	outboxPending = "pending"
+	outboxDelete  = "delete"
+)
+
+func getOutboxQueries() map[string]string {
+	return map[string]string{
+		outboxInsert: `
+			INSERT INTO outbox (type, key, payload, created_at)
+			VALUES (?, ?, ?, ?)
+		`,
+		outboxPending: `
+			SELECT
+				id,
+				type,
+				key,
+				payload
+			FROM outbox
+			ORDER BY id
+			LIMIT ?
+		`,
+		outboxDelete: `
+			DELETE FROM outbox
+			WHERE id = ?
+		`,
+	}
+}
+
+const (
+	subscriptionsInsert     = "insert"
+	subscriptionsList       = "list"
+	subscriptionsDelete     = "delete"
+	subscriptionsSetCursor  = "set_cursor"
+	deliveriesInsert        = "insert_delivery"
+	deliveriesList          = "list_deliveries"
+	deliveriesDue           = "due_deliveries"
+	deliveriesRecordAttempt = "record_attempt"
+	deliveriesPurge         = "purge_deliveries"
+)
+
+func getSubscriptionQueries() map[string]string {
+	return map[string]string{
+		// New subscriptions are only notified of changes made after they're
+		// created.
+		subscriptionsInsert: `
+			INSERT INTO subscriptions (callback_url, meeting_ids, secret, last_change_id, created_at)
+			VALUES (?, ?, ?, (SELECT COALESCE(MAX(id), 0) FROM race_changes), ?)
+		`,
+		subscriptionsList: `
+			SELECT
+				id,
+				callback_url,
+				meeting_ids,
+				last_change_id,
+				created_at
+			FROM subscriptions
+		`,
+		subscriptionsDelete: `
+			DELETE FROM subscriptions
+			WHERE id = ?
+		`,
+		subscriptionsSetCursor: `
+			UPDATE subscriptions
+			SET last_change_id = ?
+			WHERE id = ?
+		`,
+		deliveriesInsert: `
+			INSERT INTO deliveries (subscription_id, change_id, race_id, payload, status, attempts, next_attempt_at)
+			VALUES (?, ?, ?, ?, ?, 0, ?)
+		`,
+		deliveriesList: `
+			SELECT
+				id,
+				subscription_id,
+				change_id,
+				race_id,
+				status,
+				attempts,
+				last_attempt_at,
+				last_response_code,
+				last_error
+			FROM deliveries
+			WHERE subscription_id = ?
+			ORDER BY id
+		`,
+		deliveriesDue: `
+			SELECT
+				d.id,
+				d.subscription_id,
+				d.attempts,
+				d.payload,
+				s.callback_url,
+				s.secret
+			FROM deliveries d
+			JOIN subscriptions s ON s.id = d.subscription_id
+			WHERE d.status = 'PENDING' AND datetime(d.next_attempt_at) <= datetime(?)
+			ORDER BY d.id
+			LIMIT ?
+		`,
+		deliveriesRecordAttempt: `
+			UPDATE deliveries
+			SET status = ?, attempts = ?, next_attempt_at = ?, last_attempt_at = ?, last_response_code = ?, last_error = ?
+			WHERE id = ?
+		`,
+		deliveriesPurge: `
+			DELETE FROM deliveries
+			WHERE subscription_id = ?
+		`,
+	}
+}
+
+const (
+	devicesRegister     = "register"
+	devicesFind         = "find"
+	devicesUnregister   = "unregister"
+	devicesFollowing    = "following"
+	deviceRacesClear    = "clear_device_races"
+	deviceRacesAdd      = "add_device_race"
+	deviceMeetingsClear = "clear_device_meetings"
+	deviceMeetingsAdd   = "add_device_meeting"
+	cursorsInit         = "init_cursor"
+	cursorsGet          = "get_cursor"
+	cursorsSet          = "set_cursor"
+	watchlistsAdd       = "watch"
+	watchlistsFind      = "find_watched"
+	watchlistsRemove    = "unwatch"
+	watchlistsList      = "watchlist"
+)
+
+func getNotificationQueries() map[string]string {
+	return map[string]string{
+		// Re-registering a device replaces what it follows, and who for.
+		devicesRegister: `
+			INSERT INTO devices (channel, token, user_id, jurisdiction, brand, created_at)
+			VALUES (?, ?, ?, ?, ?, ?)
+			ON CONFLICT (channel, token) DO UPDATE
+			SET user_id = excluded.user_id, jurisdiction = excluded.jurisdiction, brand = excluded.brand
+		`,
+		devicesFind: `
+			SELECT id, created_at
+			FROM devices
+			WHERE channel = ? AND token = ?
+		`,
+		devicesUnregister: `
+			DELETE FROM devices
+			WHERE channel = ? AND token = ?
+		`,
+		deviceRacesClear: `
+			DELETE FROM device_races
+			WHERE device_id = ?
+		`,
+		deviceRacesAdd: `
+			INSERT OR IGNORE INTO device_races (race_id, device_id)
+			VALUES (?, ?)
+		`,
+		deviceMeetingsClear: `
+			DELETE FROM device_meetings
+			WHERE device_id = ?
+		`,
+		deviceMeetingsAdd: `
+			INSERT OR IGNORE INTO device_meetings (meeting_id, device_id)
+			VALUES (?, ?)
+		`,
+		// Devices are only sent races as they're listed for the audience they
+		// were registered for: shown or hidden for its brand, and not
+		// restricted in its jurisdiction. What they follow is listed in the
+		// order it was registered.
+		devicesFollowing: `
+			SELECT
+				d.id,
+				d.channel,
+				d.token,
+				COALESCE((
+					SELECT group_concat(race_id)
+					FROM (SELECT race_id FROM device_races WHERE device_id = d.id ORDER BY rowid)
+				), ''),
+				COALESCE((
+					SELECT group_concat(meeting_id)
+					FROM (SELECT meeting_id FROM device_meetings WHERE device_id = d.id ORDER BY rowid)
+				), ''),
+				d.user_id,
+				d.created_at
+			FROM devices d
+			LEFT JOIN race_brand_visibility b ON b.race_id = ?1 AND b.brand = d.brand
+			WHERE (
+				EXISTS (
+					SELECT 1
+					FROM device_races dr
+					WHERE dr.race_id = ?1 AND dr.device_id = d.id
+				)
+				OR EXISTS (
+					SELECT 1
+					FROM device_meetings dm
+					WHERE dm.meeting_id = ?2 AND dm.device_id = d.id
+				)
+				OR EXISTS (
+					SELECT 1
+					FROM watchlists w
+					WHERE w.user_id = d.user_id AND w.race_id = ?1
+				)
+			)
+			AND COALESCE(b.visible, ?3)
+			AND NOT EXISTS (
+				SELECT 1
+				FROM race_restrictions rr
+				WHERE rr.race_id = ?1 AND rr.jurisdiction = d.jurisdiction
+			)
+			ORDER BY d.id
+		`,
+		// Watching a race already watched keeps when it was first watched.
+		watchlistsAdd: `
+			INSERT OR IGNORE INTO watchlists (user_id, race_id, watched_at)
+			VALUES (?, ?, ?)
+		`,
+		watchlistsFind: `
+			SELECT watched_at
+			FROM watchlists
+			WHERE user_id = ? AND race_id = ?
+		`,
+		watchlistsRemove: `
+			DELETE FROM watchlists
+			WHERE user_id = ? AND race_id = ?
+		`,
+		watchlistsList: `
+			SELECT race_id, watched_at
+			FROM watchlists
+			WHERE user_id = ?
+			ORDER BY watched_at, race_id
+		`,
+		cursorsInit: `
+			INSERT OR IGNORE INTO notification_cursors (name, position)
+			VALUES (?, ?)
+		`,
+		cursorsGet: `
+			SELECT position
+			FROM notification_cursors
+			WHERE name = ?
+		`,
+		cursorsSet: `
+			UPDATE notification_cursors
+			SET position = ?
+			WHERE name = ?
+		`,
+	}
+}
+
+const (
+	leasesAcquire = "acquire"
+)
+
+func getLeaseQueries() map[string]string {
+	return map[string]string{
+		// Only takes the lease if it's free, expired, or already held by the
+		// holder, in which case it's renewed.
+		leasesAcquire: `
+			INSERT INTO leases (name, holder, expires_at)
+			VALUES (?1, ?2, ?3)
+			ON CONFLICT (name) DO UPDATE
+			SET holder = excluded.holder, expires_at = excluded.expires_at
+			WHERE leases.holder = excluded.holder OR datetime(leases.expires_at) < datetime(?4)
+		`,
+	}
+}
+
+const (
+	reportsSave   = "save"
+	reportsLatest = "latest"
+)
+
+func getReconciliationQueries() map[string]string {
+	return map[string]string{
+		// Reports are stored whole, as only the latest is ever read.
+		reportsSave: `
+			INSERT INTO reconciliation_reports (source, reconciled_at, report)
+			VALUES (?, ?, ?)
+			ON CONFLICT (source) DO UPDATE
+			SET reconciled_at = excluded.reconciled_at, report = excluded.report
+		`,
+		reportsLatest: `
+			SELECT report
+			FROM reconciliation_reports
+			ORDER BY datetime(reconciled_at) DESC, source
+			LIMIT 1
+		`,
+	}
+}
+
+const (
+	keysReserve  = "reserve"
+	keysFind     = "find"
+	keysExtend   = "extend"
+	keysComplete = "complete"
+	keysRelease  = "release"
+	keysPurge    = "purge"
+)
+
+func getIdempotencyQueries() map[string]string {
+	return map[string]string{
+		// Only claims the key if it's unused, or has expired.
+		keysReserve: `
+			INSERT INTO idempotency_keys (key, method, fingerprint, response, expires_at)
+			VALUES (?1, ?2, ?3, NULL, ?4)
+			ON CONFLICT (key, method) DO UPDATE
+			SET fingerprint = excluded.fingerprint, response = NULL, expires_at = excluded.expires_at
+			WHERE datetime(idempotency_keys.expires_at) < datetime(?5)
+		`,
+		keysFind: `
+			SELECT fingerprint, response
+			FROM idempotency_keys
+			WHERE key = ? AND method = ?
+		`,
+		keysExtend: `
+			UPDATE idempotency_keys
+			SET expires_at = ?
+			WHERE key = ? AND method = ? AND response IS NULL
+		`,
+		keysComplete: `
+			UPDATE idempotency_keys
+			SET response = ?, fingerprint = COALESCE(?, fingerprint), expires_at = ?
+			WHERE key = ? AND method = ?
+		`,
+		keysRelease: `
+			DELETE FROM idempotency_keys
+			WHERE key = ? AND method = ? AND response IS NULL
+		`,
+		keysPurge: `
+			DELETE FROM idempotency_keys
+			WHERE datetime(expires_at) < datetime(?)
+		`,
+	}
+}
+
+const (
+	raceEventsRecording       = "recording"
+	raceEventsSnapshot        = "snapshot"
+	raceEventsSnapshotDeletes = "snapshot_deletes"
+	raceEventsListAt          = "list_at"
+	raceEventsReplayDelete    = "replay_delete"
+	raceEventsReplayUpsert    = "replay_upsert"
+)
+
+func getRaceEventQueries() map[string]string {
+	return map[string]string{
+		raceEventsRecording: `
+			SELECT COUNT(*)
+			FROM sqlite_master
+			WHERE type = 'trigger' AND name IN ('race_events_insert', 'race_events_update', 'race_events_delete')
+		`,
+		raceEventsSnapshot: `
+			INSERT INTO race_events (
+				race_id,
+				operation,
+				meeting_id,
+				name,
+				number,
+				visible,
+				advertised_start_time,
+				venue_time_zone,
+				country,
+				distance,
+				track_condition,
+				weather,
+				venue_image,
+				external_source,
+				external_id,
+				status,
+				recorded_at
+			)
+			SELECT
+				id,
+				'UPSERTED',
+				meeting_id,
+				name,
+				number,
+				visible,
+				advertised_start_time,
+				venue_time_zone,
+				country,
+				distance,
+				track_condition,
+				weather,
+				venue_image,
+				external_source,
+				external_id,
+				status,
+				strftime('%Y-%m-%dT%H:%M:%SZ', 'now')
 			FROM races
 		`,
+		// Races last recorded as upserted that no longer exist were deleted
+		// while the log was stopped.
+		raceEventsSnapshotDeletes: `
+			INSERT INTO race_events (
+				race_id,
+				operation,
+				meeting_id,
+				name,
+				number,
+				visible,
+				advertised_start_time,
+				venue_time_zone,
+				country,
+				distance,
+				track_condition,
+				weather,
+				venue_image,
+				external_source,
+				external_id,
+				status,
+				recorded_at
+			)
+			SELECT
+				e.race_id,
+				'DELETED',
+				e.meeting_id,
+				e.name,
+				e.number,
+				e.visible,
+				e.advertised_start_time,
+				e.venue_time_zone,
+				e.country,
+				e.distance,
+				e.track_condition,
+				e.weather,
+				e.venue_image,
+				e.external_source,
+				e.external_id,
+				e.status,
+				strftime('%Y-%m-%dT%H:%M:%SZ', 'now')
+			FROM race_events e
+			WHERE e.id IN (SELECT MAX(id) FROM race_events GROUP BY race_id)
+			AND e.operation = 'UPSERTED'
+			AND e.race_id NOT IN (SELECT id FROM races)
+		`,
+		// Races are projected from the last event recorded for each by the
+		// given time, and filtered like the races table.
+		raceEventsListAt: `
+			SELECT
+				id,
+				meeting_id,
+				name,
+				number,
+				visible,
+				advertised_start_time,
+				venue_time_zone,
+				country,
+				distance,
+				track_condition,
+				weather,
+				venue_image,
+				external_source,
+				external_id,
+				status
+			FROM (
+				SELECT
+					e.race_id AS id,
+					e.meeting_id,
+					e.name,
+					e.number,
+					e.visible,
+					e.advertised_start_time,
+					e.venue_time_zone,
+					e.country,
+					e.distance,
+					e.track_condition,
+					e.weather,
+					e.venue_image,
+					e.external_source,
+					e.external_id,
+					e.status
+				FROM race_events e
+				WHERE e.id IN (
+					SELECT MAX(id)
+					FROM race_events
+					WHERE datetime(recorded_at) <= datetime(?)
+					GROUP BY race_id
+				)
+				AND e.operation = 'UPSERTED'
+			)
+		`,
+		// Only races the log last recorded as deleted are deleted, so races
+		// it has no record of are left alone.
+		raceEventsReplayDelete: `
+			DELETE FROM races
+			WHERE id IN (
+				SELECT e.race_id
+				FROM race_events e
+				WHERE e.id IN (SELECT MAX(id) FROM race_events GROUP BY race_id)
+				AND e.operation = 'DELETED'
+			)
+		`,
+		// Only races that differ from their last event are written, so
+		// replaying a consistent log changes nothing.
+		raceEventsReplayUpsert: `
+			INSERT INTO races (
+				id,
+				meeting_id,
+				name,
+				number,
+				visible,
+				advertised_start_time,
+				venue_time_zone,
+				country,
+				distance,
+				track_condition,
+				weather,
+				venue_image,
+				external_source,
+				external_id,
+				status
+			)
+			SELECT
+				e.race_id,
+				e.meeting_id,
+				e.name,
+				e.number,
+				e.visible,
+				e.advertised_start_time,
+				e.venue_time_zone,
+				e.country,
+				e.distance,
+				e.track_condition,
+				e.weather,
+				e.venue_image,
+				e.external_source,
+				e.external_id,
+				e.status
+			FROM race_events e
+			WHERE e.id IN (SELECT MAX(id) FROM race_events GROUP BY race_id)
+			AND e.operation = 'UPSERTED'
+			AND NOT EXISTS (
+				SELECT 1
+				FROM races r
+				WHERE r.id = e.race_id
+					AND r.meeting_id IS e.meeting_id
+					AND r.name IS e.name
+					AND r.number IS e.number
+					AND r.visible IS e.visible
+					AND r.advertised_start_time IS e.advertised_start_time
+					AND r.venue_time_zone IS e.venue_time_zone
+					AND r.country IS e.country
+					AND r.distance IS e.distance
+					AND r.track_condition IS e.track_condition
+					AND r.weather IS e.weather
+					AND r.venue_image IS e.venue_image
+					AND r.external_source IS e.external_source
+					AND r.external_id IS e.external_id
+					AND r.status IS e.status
+			)
+			ON CONFLICT (id) DO UPDATE SET
+				meeting_id = excluded.meeting_id,
+				name = excluded.name,
+				number = excluded.number,
+				visible = excluded.visible,
+				advertised_start_time = excluded.advertised_start_time,
+				venue_time_zone = excluded.venue_time_zone,
+				country = excluded.country,
+				distance = excluded.distance,
+				track_condition = excluded.track_condition,
+				weather = excluded.weather,
+				venue_image = excluded.venue_image,
+				external_source = excluded.external_source,
+				external_id = excluded.external_id,
+				status = excluded.status
+		`,
 	}
 }
@@ -18,16 +18,229 @@ type RacesRepo interface {
 
 	// List will return a list of races.
 	List(filter *racing.ListRacesRequestFilter) ([]*racing.Race, error)
+
+	// ListPage will return up to limit races matching the filter, as listed
+	// for the audience, in ID order, starting after the race with the given
+	// ID.
+	ListPage(filter *racing.ListRacesRequestFilter, audience Audience, after int64, limit int) ([]*racing.Race, error)
+
+	// ListMeetingPage will return the races matching the filter, as listed
+	// for the audience, of up to limit meetings in meeting ID order, starting
+	// after the meeting with the given ID. Only the first raceLimit of each
+	// meeting's races, in number order, are returned.
+	ListMeetingPage(filter *racing.ListRacesRequestFilter, audience Audience, after int64, limit, raceLimit int) ([]*racing.Race, error)
+
+	// ListAt will return a list of races as they were at the given time,
+	// projected from the event log. It returns ErrEventLogDisabled if the
+	// repository doesn't keep one.
+	ListAt(filter *racing.ListRacesRequestFilter, at time.Time) ([]*racing.Race, error)
+
+	// ListArchived will return up to limit archived races matching the
+	// filter, as listed for the audience, advertised to start at or after one
+	// time and before another, in ID order, starting after the race with the
+	// given ID. A zero time leaves its end of the range open.
+	ListArchived(filter *racing.ListRacesRequestFilter, audience Audience, from, to time.Time, after int64, limit int) ([]*racing.Race, error)
+
+	// Archive will move races advertised to start before the given time into
+	// the archive, returning the number of races moved.
+	Archive(before time.Time) (int64, error)
+
+	// Purge will permanently delete races, archived or not, advertised to
+	// start before the given time that are no longer open, returning the
+	// number of races deleted. Views counted before the time are deleted too.
+	Purge(before time.Time) (int64, error)
+
+	// LocalisedNames will return the names of the given races in the given
+	// locale, keyed by race ID. Races without a name in the locale (or its
+	// base language) are omitted.
+	LocalisedNames(raceIDs []int64, locale string) (map[int64]string, error)
+
+	// GetByExternalRef will return the race an external source knows by the
+	// given ID, or ErrNotFound if there isn't one.
+	GetByExternalRef(source, sourceID string) (*racing.Race, error)
+
+	// Upsert will create or update a race, deduplicated by its external
+	// reference, setting its ID and returning what was done with it.
+	Upsert(race *racing.Race) (UpsertResult, error)
+
+	// UpsertBatch will upsert races in a single transaction, returning what
+	// was done with each, in order. A race that fails to write is rolled back
+	// on its own, with its error in its outcome, so it can't hold up the
+	// rest.
+	UpsertBatch(races []*racing.Race) ([]UpsertOutcome, error)
+
+	// UpdateStatus will move a race to the given status, recording the actor
+	// making the change in its status history, and return the updated race.
+	// It returns ErrNotFound if the race doesn't exist, or
+	// ErrInvalidTransition if it can't move to the status.
+	UpdateStatus(raceID int64, status racing.Race_Status, actor string) (*racing.Race, error)
+
+	// StatusHistory will return a race's status transitions, archived or not,
+	// oldest first, or ErrNotFound if the race doesn't exist.
+	StatusHistory(raceID int64) ([]*racing.StatusTransition, error)
+
+	// StatusChanges will return up to limit status changes made after the
+	// change with the given ID, oldest first, from every race's status
+	// history.
+	StatusChanges(after int64, limit int) ([]*StatusChange, error)
+
+	// ListStarting will return the open races advertised to start after one
+	// time, up to and including another. Hidden races are returned too, as
+	// brands can show them.
+	ListStarting(after, before time.Time) ([]*racing.Race, error)
+
+	// ListBetween will return the races matching the filter, as listed for
+	// the audience, advertised to start at or after one time and before
+	// another, in the order they start, then by ID.
+	ListBetween(filter *racing.ListRacesRequestFilter, audience Audience, from, to time.Time) ([]*racing.Race, error)
+
+	// ListByIDs will return the races with the given IDs, in the order given.
+	// IDs of races that no longer exist are skipped.
+	ListByIDs(raceIDs []int64) ([]*racing.Race, error)
+
+	// SetFeatured will feature a race, or stop featuring it. It returns
+	// ErrNotFound if the race doesn't exist, or has been archived.
+	SetFeatured(raceID int64, featured bool) error
+
+	// ListFeatured will return the visible featured races, in the order they
+	// were featured. Races stop being featured once they're archived.
+	ListFeatured() ([]*racing.Race, error)
+
+	// SetRestrictions will replace the jurisdictions a race is restricted in,
+	// that it mustn't be shown to callers from. It returns ErrNotFound if the
+	// race doesn't exist, or has been archived.
+	SetRestrictions(raceID int64, jurisdictions []string) error
+
+	// Restricted will return which of the given races, archived or not, are
+	// restricted in the given jurisdiction.
+	Restricted(raceIDs []int64, jurisdiction string) (map[int64]bool, error)
+
+	// SetBrandVisibility will override whether a race is visible to callers
+	// from a brand, or remove the override when visible is nil. It returns
+	// ErrNotFound if the race doesn't exist, or has been archived.
+	SetBrandVisibility(raceID int64, brand string, visible *bool) error
+
+	// BrandVisibility will return whether each of the given races, archived
+	// or not, is visible to callers from the given brand, for those whose
+	// visibility is overridden for it.
+	BrandVisibility(raceIDs []int64, brand string) (map[int64]bool, error)
+
+	// RecordViews will count views of a race in the hour they were seen at.
+	// It returns ErrNotFound if the race doesn't exist, or has been
+	// archived.
+	RecordViews(raceID int64, views int64, at time.Time) error
+
+	// ListPopular will return up to limit races matching the filter, as
+	// listed for the audience, ranked by how many times they were viewed in
+	// the hours since the given time, counting the hour it's in. The most
+	// viewed come first, then races by ID, skipping the first offset races.
+	// Races that weren't viewed are ranked last, with no views.
+	ListPopular(filter *racing.ListRacesRequestFilter, audience Audience, since time.Time, offset int64, limit int) ([]*racing.PopularRace, error)
+
+	// Stats will count the races matching the filter, grouped by meeting,
+	// country, status and the UTC day they start on, each in key order.
+	Stats(filter *racing.ListRacesRequestFilter) (*racing.RaceStats, error)
+
+	// Replay will rebuild races from the event log, returning the number of
+	// races that had drifted from it. It returns ErrEventLogDisabled if the
+	// repository doesn't keep one.
+	Replay() (int64, error)
+
+	// Changes will return up to limit changes made to races after the change
+	// with the given ID, oldest first, with upserted races as they are now.
+	Changes(after int64, limit int) ([]*racing.RaceChange, error)
+}
+
+// StatusChange is a move of a race between statuses, from its status history.
+type StatusChange struct {
+	// ID is the position of the change in the status history.
+	ID         int64
+	Transition *racing.StatusTransition
+	// Race is the race as it is now, or nil if it has since been archived or
+	// purged.
+	Race *racing.Race
+}
+
+// Audience is who races are listed for, as races restricted in a
+// jurisdiction aren't listed for callers from it, and races can be shown or
+// hidden for callers from a brand. The zero Audience is listed every race, as
+// it is for every brand.
+type Audience struct {
+	// Jurisdiction is where callers are from (e.g. NSW), if they say.
+	Jurisdiction string
+	// Brand is the brand callers are from (e.g. neds), if they say.
+	Brand string
 }
 
 type racesRepo struct {
-	db   *sql.DB
-	init sync.Once
+	db         *sql.DB
+	init       sync.Once
+	explain    bool
+	dummyData  bool
+	outbox     bool
+	eventLog   bool
+	clock      Clock
+	seedConfig SeedConfig
+}
+
+// RacesRepoOption configures optional behaviour of a races repository.
+type RacesRepoOption func(*racesRepo)
+
+// WithQueryPlanLogging logs the query plan of every list query the repository runs.
+func WithQueryPlanLogging() RacesRepoOption {
+	return func(r *racesRepo) {
+		r.explain = true
+	}
+}
+
+// WithoutDummyData stops the repository seeding dummy races, for when races
+// are sourced from a real feed.
+func WithoutDummyData() RacesRepoOption {
+	return func(r *racesRepo) {
+		r.dummyData = false
+	}
+}
+
+// WithOutbox writes a RaceChanged event to the outbox, in the same
+// transaction, whenever a write changes a race's visibility or status.
+func WithOutbox() RacesRepoOption {
+	return func(r *racesRepo) {
+		r.outbox = true
+	}
+}
+
+// WithEventLog records every write to races in an append-only event log, that
+// races can be projected from as they were at any time, or replayed from.
+func WithEventLog() RacesRepoOption {
+	return func(r *racesRepo) {
+		r.eventLog = true
+	}
+}
+
+// WithClock reads the current time from the given clock, rather than the
+// system clock, e.g. to count down to races from a fixed point in time.
+func WithClock(clock Clock) RacesRepoOption {
+	return func(r *racesRepo) {
+		r.clock = clock
+	}
+}
+
+// WithSeedConfig shapes the dummy races the repository seeds, rather than
+// seeding DefaultSeedConfig.
+func WithSeedConfig(config SeedConfig) RacesRepoOption {
+	return func(r *racesRepo) {
+		r.seedConfig = config
+	}
 }
 
 // NewRacesRepo creates a new races repository.
-func NewRacesRepo(db *sql.DB) RacesRepo {
-	return &racesRepo{db: db}
+func NewRacesRepo(db *sql.DB, opts ...RacesRepoOption) RacesRepo {
+	r := &racesRepo{db: db, dummyData: true, clock: SystemClock, seedConfig: DefaultSeedConfig}
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	return r
 }
 
 // Init prepares the race repository dummy data.
@@ -35,8 +248,16 @@ func (r *racesRepo) Init() error {
 	var err error
 
 	r.init.Do(func() {
+		err = r.createTables()
+
+		if err == nil {
+			err = r.initEventLog()
+		}
+
 		// For test/example purposes, we seed the DB with some dummy races.
-		err = r.seed()
+		if err == nil && r.dummyData {
+			err = r.seed()
+		}
 	})
 
 	return err
@@ -52,8 +273,27 @@ func (r *racesRepo) List(filter *racing.ListRacesRequestFilter) ([]*racing.Race,
 	query = getRaceQueries()[racesList]
 
 	query, args = r.applyFilter(query, filter)
+	query += orderByID
 
-	rows, err := r.db.Query(query, args...)
+	rows, err := r.query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.scanRaces(rows)
+}
+
+func (r *racesRepo) ListPage(filter *racing.ListRacesRequestFilter, audience Audience, after int64, limit int) ([]*racing.Race, error) {
+	query, clauses, args := r.listFor(racesList, filter, audience)
+
+	// Pages are read in ID order, each starting after the last race of the
+	// page before.
+	clauses = append(clauses, "id > ?")
+	args = append(args, after, limit)
+
+	query += " WHERE " + strings.Join(clauses, " AND ") + orderByID + " LIMIT ?"
+
+	rows, err := r.query(query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -61,14 +301,306 @@ func (r *racesRepo) List(filter *racing.ListRacesRequestFilter) ([]*racing.Race,
 	return r.scanRaces(rows)
 }
 
+func (r *racesRepo) ListMeetingPage(filter *racing.ListRacesRequestFilter, audience Audience, after int64, limit, raceLimit int) ([]*racing.Race, error) {
+	listed, clauses, args := r.listFor(racesList, filter, audience)
+
+	if len(clauses) != 0 {
+		listed += " WHERE " + strings.Join(clauses, " AND ")
+	}
+
+	// Pages are read in meeting ID order, each starting after the last
+	// meeting of the page before, with each meeting's races numbered so only
+	// the first are read.
+	query := "WITH listed AS (" + listed + ")" +
+		" SELECT * FROM (" +
+		" SELECT listed.*, ROW_NUMBER() OVER (PARTITION BY meeting_id ORDER BY number, id) AS race_number" +
+		" FROM listed" +
+		" WHERE meeting_id IN (SELECT DISTINCT meeting_id FROM listed WHERE meeting_id > ? ORDER BY meeting_id LIMIT ?)" +
+		") WHERE race_number <= ? ORDER BY meeting_id, number, id"
+
+	args = append(args, after, limit, raceLimit)
+
+	rows, err := r.query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var races []*racing.Race
+
+	// Use a single point in time, so countdowns are consistent across the list.
+	now := r.clock.Now()
+
+	for rows.Next() {
+		var raceNumber int
+
+		race, err := r.scanRace(rows, now, &raceNumber)
+		if err != nil {
+			return nil, err
+		}
+
+		races = append(races, race)
+	}
+
+	return races, rows.Err()
+}
+
+func (r *racesRepo) ListBetween(filter *racing.ListRacesRequestFilter, audience Audience, from, to time.Time) ([]*racing.Race, error) {
+	query, clauses, args := r.listFor(racesList, filter, audience)
+
+	clauses = append(clauses, "datetime(advertised_start_time) >= datetime(?)", "datetime(advertised_start_time) < datetime(?)")
+	args = append(args, from.UTC().Format(time.RFC3339), to.UTC().Format(time.RFC3339))
+
+	query += " WHERE " + strings.Join(clauses, " AND ") + " ORDER BY datetime(advertised_start_time), id"
+
+	rows, err := r.query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.scanRaces(rows)
+}
+
+func (r *racesRepo) ListArchived(filter *racing.ListRacesRequestFilter, audience Audience, from, to time.Time, after int64, limit int) ([]*racing.Race, error) {
+	query, clauses, args := r.listFor(archivedRacesList, filter, audience)
+
+	if !from.IsZero() {
+		clauses = append(clauses, "datetime(advertised_start_time) >= datetime(?)")
+		args = append(args, from.UTC().Format(time.RFC3339))
+	}
+
+	if !to.IsZero() {
+		clauses = append(clauses, "datetime(advertised_start_time) < datetime(?)")
+		args = append(args, to.UTC().Format(time.RFC3339))
+	}
+
+	// Pages are read in ID order, as ListPage reads them.
+	clauses = append(clauses, "id > ?")
+	args = append(args, after, limit)
+
+	query += " WHERE " + strings.Join(clauses, " AND ") + orderByID + " LIMIT ?"
+
+	rows, err := r.query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.scanRaces(rows)
+}
+
+func (r *racesRepo) GetByExternalRef(source, sourceID string) (*racing.Race, error) {
+	query := getRaceQueries()[racesList] + " WHERE external_source = ? AND external_id = ?"
+
+	rows, err := r.query(query, source, sourceID)
+	if err != nil {
+		return nil, err
+	}
+
+	races, err := r.scanRaces(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(races) == 0 {
+		return nil, ErrNotFound
+	}
+
+	return races[0], nil
+}
+
+func (r *racesRepo) Archive(before time.Time) (int64, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	cutoff := before.Format(time.RFC3339)
+
+	if _, err := tx.Exec(getRaceQueries()[racesArchive], cutoff); err != nil {
+		return 0, err
+	}
+
+	res, err := tx.Exec(getRaceQueries()[racesPurge], cutoff)
+	if err != nil {
+		return 0, err
+	}
+
+	archived, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := tx.Exec(getRaceQueries()[featuredRacesPurge]); err != nil {
+		return 0, err
+	}
+
+	return archived, tx.Commit()
+}
+
+func (r *racesRepo) Purge(before time.Time) (int64, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	cutoff := before.Format(time.RFC3339)
+
+	var purged int64
+
+	for _, query := range []string{closedRacesPurge, archivedRacesPurge} {
+		res, err := tx.Exec(getRaceQueries()[query], cutoff)
+		if err != nil {
+			return 0, err
+		}
+
+		deleted, err := res.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+
+		purged += deleted
+	}
+
+	if _, err := tx.Exec(getRaceQueries()[raceNamesPurge]); err != nil {
+		return 0, err
+	}
+
+	if _, err := tx.Exec(getRaceQueries()[featuredRacesPurge]); err != nil {
+		return 0, err
+	}
+
+	if _, err := tx.Exec(getRaceQueries()[restrictionsPurge]); err != nil {
+		return 0, err
+	}
+
+	if _, err := tx.Exec(getRaceQueries()[brandVisibilityPurge]); err != nil {
+		return 0, err
+	}
+
+	if _, err := tx.Exec(getRaceQueries()[raceViewsPurge], cutoff); err != nil {
+		return 0, err
+	}
+
+	if _, err := tx.Exec(getRaceQueries()[raceStatusPurge]); err != nil {
+		return 0, err
+	}
+
+	// The change log only needs to cover as long as races are kept for.
+	if _, err := tx.Exec(getRaceQueries()[raceChangesPurge], cutoff); err != nil {
+		return 0, err
+	}
+
+	return purged, tx.Commit()
+}
+
+func (r *racesRepo) ListStarting(after, before time.Time) ([]*racing.Race, error) {
+	rows, err := r.query(getRaceQueries()[racesStarting], after.UTC().Format(time.RFC3339), before.UTC().Format(time.RFC3339))
+	if err != nil {
+		return nil, err
+	}
+
+	return r.scanRaces(rows)
+}
+
+func (r *racesRepo) ListByIDs(raceIDs []int64) ([]*racing.Race, error) {
+	args := make([]interface{}, len(raceIDs))
+	for i, raceID := range raceIDs {
+		args[i] = raceID
+	}
+
+	byID, err := r.racesByID(args)
+	if err != nil {
+		return nil, err
+	}
+
+	races := make([]*racing.Race, 0, len(raceIDs))
+
+	for _, raceID := range raceIDs {
+		if race, ok := byID[raceID]; ok {
+			races = append(races, race)
+		}
+	}
+
+	return races, nil
+}
+
+func (r *racesRepo) LocalisedNames(raceIDs []int64, locale string) (map[int64]string, error) {
+	names := make(map[int64]string)
+
+	if len(raceIDs) == 0 || locale == "" {
+		return names, nil
+	}
+
+	// Fall back to the base language, so "en-AU" can use an "en" name.
+	locale = strings.ToLower(strings.ReplaceAll(locale, "_", "-"))
+	language := strings.SplitN(locale, "-", 2)[0]
+
+	query := getRaceQueries()[raceNamesList] +
+		" WHERE lower(locale) IN (?,?) AND race_id IN (" + strings.Repeat("?,", len(raceIDs)-1) + "?)"
+
+	args := []interface{}{locale, language}
+	for _, raceID := range raceIDs {
+		args = append(args, raceID)
+	}
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			raceID           int64
+			nameLocale, name string
+		)
+
+		if err := rows.Scan(&raceID, &nameLocale, &name); err != nil {
+			return nil, err
+		}
+
+		// An exact locale match takes precedence over the base language.
+		if _, ok := names[raceID]; !ok || strings.ToLower(nameLocale) == locale {
+			names[raceID] = name
+		}
+	}
+
+	return names, rows.Err()
+}
+
+// query runs the given query, logging its plan first when enabled.
+func (r *racesRepo) query(query string, args ...interface{}) (*sql.Rows, error) {
+	if r.explain {
+		if err := explainQueryPlan(r.db, query, args...); err != nil {
+			return nil, err
+		}
+	}
+
+	return r.db.Query(query, args...)
+}
+
 func (r *racesRepo) applyFilter(query string, filter *racing.ListRacesRequestFilter) (string, []interface{}) {
+	clauses, args := r.whereClauses(filter)
+
+	if len(clauses) != 0 {
+		query += " WHERE " + strings.Join(clauses, " AND ")
+	}
+
+	return query, args
+}
+
+// whereClauses returns the conditions races must meet to match the filter,
+// and their arguments.
+func (r *racesRepo) whereClauses(filter *racing.ListRacesRequestFilter) ([]string, []interface{}) {
 	var (
 		clauses []string
 		args    []interface{}
 	)
 
 	if filter == nil {
-		return query, args
+		return clauses, args
 	}
 
 	if len(filter.MeetingIds) > 0 {
@@ -79,11 +611,67 @@ func (r *racesRepo) applyFilter(query string, filter *racing.ListRacesRequestFil
 		}
 	}
 
-	if len(clauses) != 0 {
-		query += " WHERE " + strings.Join(clauses, " AND ")
+	if len(filter.Countries) > 0 {
+		clauses = append(clauses, "country IN ("+strings.Repeat("?,", len(filter.Countries)-1)+"?)")
+
+		for _, country := range filter.Countries {
+			args = append(args, strings.ToUpper(country))
+		}
 	}
 
-	return query, args
+	if filter.MinDistance > 0 {
+		clauses = append(clauses, "distance >= ?")
+		args = append(args, filter.MinDistance)
+	}
+
+	if filter.MaxDistance > 0 {
+		clauses = append(clauses, "distance <= ?")
+		args = append(args, filter.MaxDistance)
+	}
+
+	if filter.MissingStartTime {
+		clauses = append(clauses, "advertised_start_time IS NULL")
+	}
+
+	switch filter.Visibility {
+	case racing.ListRacesRequestFilter_VISIBLE_ONLY:
+		clauses = append(clauses, "visible = 1")
+	case racing.ListRacesRequestFilter_HIDDEN_ONLY:
+		clauses = append(clauses, "visible = 0")
+	}
+
+	return clauses, args
+}
+
+// listedForBrand are the list queries of races as they're listed for a brand,
+// keyed by the list query of races as they are for every brand.
+var listedForBrand = map[string]string{
+	racesList:         racesListForBrand,
+	archivedRacesList: archivedListForBrand,
+}
+
+// listFor returns the named list query (racesList or archivedRacesList) as
+// races are listed for the audience, the conditions races must meet to match
+// the filter and be listed for it, and the arguments of both, in order.
+func (r *racesRepo) listFor(list string, filter *racing.ListRacesRequestFilter, audience Audience) (string, []string, []interface{}) {
+	var args []interface{}
+
+	// Races are shown or hidden for the brand, and checked for restrictions,
+	// as they're read rather than afterwards, so lists of any size can be.
+	if audience.Brand != "" {
+		list = listedForBrand[list]
+		args = append(args, audience.Brand)
+	}
+
+	clauses, filterArgs := r.whereClauses(filter)
+	args = append(args, filterArgs...)
+
+	if audience.Jurisdiction != "" {
+		clauses = append(clauses, getRaceQueries()[racesUnrestricted])
+		args = append(args, audience.Jurisdiction)
+	}
+
+	return getRaceQueries()[list], clauses, args
 }
 
 func (m *racesRepo) scanRaces(
@@ -91,11 +679,12 @@ func (m *racesRepo) scanRaces(
 ) ([]*racing.Race, error) {
 	var races []*racing.Race
 
-	for rows.Next() {
-		var race racing.Race
-		var advertisedStart time.Time
+	// Use a single point in time, so countdowns are consistent across the list.
+	now := m.clock.Now()
 
-		if err := rows.Scan(&race.Id, &race.MeetingId, &race.Name, &race.Number, &race.Visible, &advertisedStart); err != nil {
+	for rows.Next() {
+		race, err := m.scanRace(rows, now)
+		if err != nil {
 			if err == sql.ErrNoRows {
 				return nil, nil
 			}
@@ -103,15 +692,44 @@ func (m *racesRepo) scanRaces(
 			return nil, err
 		}
 
-		ts, err := ptypes.TimestampProto(advertisedStart)
+		races = append(races, race)
+	}
+
+	return races, nil
+}
+
+// scanRace scans the race in the row rows is at, counting down to it from
+// now, into a race and any columns selected after the race's into extra.
+func (m *racesRepo) scanRace(rows *sql.Rows, now time.Time, extra ...interface{}) (*racing.Race, error) {
+	var race racing.Race
+	var advertisedStart sql.NullTime
+	var externalSource, externalID, status sql.NullString
+
+	dest := []interface{}{&race.Id, &race.MeetingId, &race.Name, &race.Number, &race.Visible, &advertisedStart, &race.VenueTimeZone, &race.Country, &race.Distance, &race.TrackCondition, &race.Weather, &race.VenueImageUrl, &externalSource, &externalID, &status}
+
+	if err := rows.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
+
+	// Races without a start time, imported before one was required,
+	// are listed without one rather than failing the whole list.
+	if advertisedStart.Valid && !advertisedStart.Time.IsZero() {
+		ts, err := ptypes.TimestampProto(advertisedStart.Time)
 		if err != nil {
 			return nil, err
 		}
 
 		race.AdvertisedStartTime = ts
+		race.SecondsToStart = int64(advertisedStart.Time.Sub(now).Seconds())
+	}
 
-		races = append(races, &race)
+	if externalSource.Valid && externalID.Valid {
+		race.ExternalRef = &racing.ExternalRef{
+			Source:   externalSource.String,
+			SourceId: externalID.String,
+		}
 	}
+	race.Status = racing.Race_Status(racing.Race_Status_value[status.String])
 
-	return races, nil
+	return &race, nil
 }
@@ -0,0 +1,1197 @@
+package db
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"git.neds.sh/matty/entain/racing/proto/racing"
+	"git.neds.sh/matty/entain/racing/racetest"
+	"google.golang.org/protobuf/proto"
+	"pgregory.net/rapid"
+)
+
+func TestApplyFilter(t *testing.T) {
+	const base = "SELECT id FROM races"
+
+	tests := []struct {
+		name      string
+		filter    *racing.ListRacesRequestFilter
+		wantQuery string
+		wantArgs  []interface{}
+	}{
+		{
+			name:      "nil filter",
+			wantQuery: base,
+		},
+		{
+			name:      "empty filter",
+			filter:    &racing.ListRacesRequestFilter{},
+			wantQuery: base,
+		},
+		{
+			name:      "meeting ids",
+			filter:    &racing.ListRacesRequestFilter{MeetingIds: []int64{1, 2}},
+			wantQuery: base + " WHERE meeting_id IN (?,?)",
+			wantArgs:  []interface{}{int64(1), int64(2)},
+		},
+		{
+			name:      "countries are upper cased",
+			filter:    &racing.ListRacesRequestFilter{Countries: []string{"au"}},
+			wantQuery: base + " WHERE country IN (?)",
+			wantArgs:  []interface{}{"AU"},
+		},
+		{
+			name:      "distance range",
+			filter:    &racing.ListRacesRequestFilter{MinDistance: 1000, MaxDistance: 2000},
+			wantQuery: base + " WHERE distance >= ? AND distance <= ?",
+			wantArgs:  []interface{}{int64(1000), int64(2000)},
+		},
+		{
+			name:      "missing start time",
+			filter:    &racing.ListRacesRequestFilter{MissingStartTime: true},
+			wantQuery: base + " WHERE advertised_start_time IS NULL",
+		},
+		{
+			name:      "visible only",
+			filter:    &racing.ListRacesRequestFilter{Visibility: racing.ListRacesRequestFilter_VISIBLE_ONLY},
+			wantQuery: base + " WHERE visible = 1",
+		},
+		{
+			name:      "hidden only",
+			filter:    &racing.ListRacesRequestFilter{Visibility: racing.ListRacesRequestFilter_HIDDEN_ONLY},
+			wantQuery: base + " WHERE visible = 0",
+		},
+		{
+			name: "every filter",
+			filter: &racing.ListRacesRequestFilter{
+				MeetingIds:  []int64{3},
+				Countries:   []string{"NZ"},
+				MinDistance: 800,
+				Visibility:  racing.ListRacesRequestFilter_VISIBLE_ONLY,
+			},
+			wantQuery: base + " WHERE meeting_id IN (?) AND country IN (?) AND distance >= ? AND visible = 1",
+			wantArgs:  []interface{}{int64(3), "NZ", int64(800)},
+		},
+	}
+
+	r := &racesRepo{}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, args := r.applyFilter(base, tt.filter)
+
+			if query != tt.wantQuery {
+				t.Errorf("query = %q, want %q", query, tt.wantQuery)
+			}
+
+			if !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Errorf("args = %v, want %v", args, tt.wantArgs)
+			}
+		})
+	}
+}
+
+// filterClauses match each clause applyFilter can generate, in the order it
+// generates them.
+var filterClauses = []*regexp.Regexp{
+	regexp.MustCompile(`^meeting_id IN \(\?(,\?)*\)$`),
+	regexp.MustCompile(`^country IN \(\?(,\?)*\)$`),
+	regexp.MustCompile(`^distance >= \?$`),
+	regexp.MustCompile(`^distance <= \?$`),
+	regexp.MustCompile(`^advertised_start_time IS NULL$`),
+	regexp.MustCompile(`^visible = [01]$`),
+}
+
+// drawFilter draws a races filter, including values that should be ignored,
+// such as non-positive distances, and countries that aren't country codes.
+func drawFilter(t *rapid.T) *racing.ListRacesRequestFilter {
+	if rapid.IntRange(0, 9).Draw(t, "nil").(int) == 0 {
+		return nil
+	}
+
+	filter := &racing.ListRacesRequestFilter{
+		MinDistance:      rapid.Int64Range(-100, 3200).Draw(t, "min_distance").(int64),
+		MaxDistance:      rapid.Int64Range(-100, 3200).Draw(t, "max_distance").(int64),
+		Visibility:       racing.ListRacesRequestFilter_Visibility(rapid.Int32Range(0, 3).Draw(t, "visibility").(int32)),
+		MissingStartTime: rapid.Bool().Draw(t, "missing_start_time").(bool),
+	}
+
+	for _, id := range rapid.SliceOfN(rapid.Int64Range(-1, 6), 0, 6).Draw(t, "meeting_ids").([]int64) {
+		filter.MeetingIds = append(filter.MeetingIds, id)
+	}
+
+	countries := rapid.SampledFrom([]string{"AU", "au", "NZ", "GB", "hk", "", "AU' OR 1=1 --"})
+	for _, country := range rapid.SliceOfN(countries, 0, 4).Draw(t, "countries").([]string) {
+		filter.Countries = append(filter.Countries, country)
+	}
+
+	return filter
+}
+
+func TestApplyFilterProperties(t *testing.T) {
+	const base = "SELECT id FROM races"
+
+	r := &racesRepo{}
+
+	rapid.Check(t, func(t *rapid.T) {
+		filter := drawFilter(t)
+
+		query, args := r.applyFilter(base, filter)
+
+		if placeholders := strings.Count(query, "?"); placeholders != len(args) {
+			t.Fatalf("query %q has %d placeholders for %d args", query, placeholders, len(args))
+		}
+
+		if !strings.HasPrefix(query, base) {
+			t.Fatalf("query %q doesn't start with %q", query, base)
+		}
+
+		where := strings.TrimPrefix(query, base)
+		if where == "" {
+			return
+		}
+
+		if !strings.HasPrefix(where, " WHERE ") {
+			t.Fatalf("query %q adds %q, want a WHERE clause", query, where)
+		}
+
+		// Every clause is one applyFilter generates, AND-joined, in order and
+		// at most once.
+		next := 0
+
+		for _, clause := range strings.Split(strings.TrimPrefix(where, " WHERE "), " AND ") {
+			for next < len(filterClauses) && !filterClauses[next].MatchString(clause) {
+				next++
+			}
+
+			if next == len(filterClauses) {
+				t.Fatalf("query %q has unexpected or out of order clause %q", query, clause)
+			}
+
+			next++
+		}
+	})
+}
+
+func TestApplyFilterQueriesSchema(t *testing.T) {
+	testDB := newTestDB(t)
+
+	repo := NewRacesRepo(testDB, WithoutDummyData(), WithEventLog()).(*racesRepo)
+	if err := repo.Init(); err != nil {
+		t.Fatalf("initialising races repo: %s", err)
+	}
+
+	var races []*racing.Race
+
+	for i, country := range []string{"AU", "NZ", "GB", "HK"} {
+		for number := int64(1); number <= 4; number++ {
+			race := racetest.NewRace().
+				Meeting(int64(i + 1)).
+				Number(number).
+				Country(country).
+				Distance(800 * number).
+				Visible(number%2 == 0).
+				Build()
+			insertRace(t, testDB, race)
+
+			races = append(races, race)
+		}
+	}
+
+	rapid.Check(t, func(t *rapid.T) {
+		filter := drawFilter(t)
+
+		// Every query filtered is valid against the schema.
+		for _, base := range []string{
+			getRaceQueries()[racesList],
+			getRaceQueries()[archivedRacesList],
+			getRaceQueries()[racesListForBrand],
+			getRaceQueries()[archivedListForBrand],
+			getRaceEventQueries()[raceEventsListAt],
+		} {
+			query, _ := repo.applyFilter(base, filter)
+
+			statement, err := testDB.Prepare(query)
+			if err != nil {
+				t.Fatalf("preparing %q: %s", query, err)
+			}
+
+			statement.Close()
+		}
+
+		// And lists exactly the races matching the filter.
+		listed, err := repo.List(filter)
+		if err != nil {
+			t.Fatalf("List(%v) error = %s", filter, err)
+		}
+
+		var want []int64
+
+		for _, race := range races {
+			if matchesFilter(race, filter) {
+				want = append(want, race.Id)
+			}
+		}
+
+		if got := raceIDs(listed); !equalIDs(got, want) {
+			t.Fatalf("List(%v) = %v, want %v", filter, got, want)
+		}
+	})
+}
+
+// updateSnapshots rewrites snapshot files with what's generated now, for
+// when queries are meant to change:
+//
+//	go test ./db -run Snapshot -update
+var updateSnapshots = flag.Bool("update", false, "rewrite snapshot files with what's generated now")
+
+// TestApplyFilterSnapshot records the exact SQL and args of the list query for
+// every combination of filters, so a refactor of how queries are built can
+// show it generates the same queries.
+func TestApplyFilterSnapshot(t *testing.T) {
+	var (
+		meetingIDs  = [][]int64{nil, {1}, {1, 2}}
+		countries   = [][]string{nil, {"au"}, {"AU", "nz"}}
+		minDistance = []int64{0, 1000}
+		maxDistance = []int64{0, 2000}
+		visibility  = []racing.ListRacesRequestFilter_Visibility{
+			racing.ListRacesRequestFilter_ALL,
+			racing.ListRacesRequestFilter_VISIBLE_ONLY,
+			racing.ListRacesRequestFilter_HIDDEN_ONLY,
+		}
+	)
+
+	r := &racesRepo{}
+
+	var snapshot bytes.Buffer
+
+	record := func(filter *racing.ListRacesRequestFilter) {
+		query, args := r.applyFilter(getRaceQueries()[racesList], filter)
+		query += orderByID
+
+		// Describe filters by hand, as the protobuf text formats vary their
+		// whitespace between builds.
+		description := "nil"
+		if filter != nil {
+			description = fmt.Sprintf("meeting_ids=%v countries=%q min_distance=%d max_distance=%d visibility=%s", filter.MeetingIds, filter.Countries, filter.MinDistance, filter.MaxDistance, filter.Visibility)
+		}
+
+		fmt.Fprintf(&snapshot, "filter: %s\nquery:  %s\nargs:   %#v\n\n", description, strings.Join(strings.Fields(query), " "), args)
+	}
+
+	record(nil)
+
+	for _, m := range meetingIDs {
+		for _, c := range countries {
+			for _, min := range minDistance {
+				for _, max := range maxDistance {
+					for _, v := range visibility {
+						record(&racing.ListRacesRequestFilter{
+							MeetingIds:  m,
+							Countries:   c,
+							MinDistance: min,
+							MaxDistance: max,
+							Visibility:  v,
+						})
+					}
+				}
+			}
+		}
+	}
+
+	path := filepath.Join("testdata", "apply_filter.snapshot")
+
+	if *updateSnapshots {
+		if err := ioutil.WriteFile(path, snapshot.Bytes(), 0644); err != nil {
+			t.Fatalf("updating snapshot: %s", err)
+		}
+	}
+
+	want, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading snapshot (run with -update to create it): %s", err)
+	}
+
+	if !bytes.Equal(snapshot.Bytes(), want) {
+		t.Errorf("generated queries differ from %s, diff them by running with -update", path)
+	}
+}
+
+func TestRacesRepoList(t *testing.T) {
+	repo, testDB := newTestRacesRepo(t)
+
+	fixtures := []*racing.Race{
+		racetest.NewRace().Name("Melbourne sprint").Distance(1000).Build(),
+		racetest.NewRace().Number(2).Name("Melbourne mile").Distance(1600).Hidden().Build(),
+		racetest.NewRace().Meeting(2).Name("Auckland sprint").Country("NZ").Build(),
+		racetest.NewRace().Meeting(3).Name("Sha Tin cup").Country("HK").Distance(2000).Hidden().Build(),
+	}
+	for _, race := range fixtures {
+		insertRace(t, testDB, race)
+	}
+
+	tests := []struct {
+		name    string
+		filter  *racing.ListRacesRequestFilter
+		wantIDs []int64
+	}{
+		{
+			name:    "no filter",
+			wantIDs: []int64{1, 2, 3, 4},
+		},
+		{
+			name:    "meeting",
+			filter:  &racing.ListRacesRequestFilter{MeetingIds: []int64{1}},
+			wantIDs: []int64{1, 2},
+		},
+		{
+			name:    "meetings",
+			filter:  &racing.ListRacesRequestFilter{MeetingIds: []int64{2, 3}},
+			wantIDs: []int64{3, 4},
+		},
+		{
+			name:    "country",
+			filter:  &racing.ListRacesRequestFilter{Countries: []string{"nz"}},
+			wantIDs: []int64{3},
+		},
+		{
+			name:    "visible only",
+			filter:  &racing.ListRacesRequestFilter{Visibility: racing.ListRacesRequestFilter_VISIBLE_ONLY},
+			wantIDs: []int64{1, 3},
+		},
+		{
+			name:    "hidden only",
+			filter:  &racing.ListRacesRequestFilter{Visibility: racing.ListRacesRequestFilter_HIDDEN_ONLY},
+			wantIDs: []int64{2, 4},
+		},
+		{
+			name:    "distance range",
+			filter:  &racing.ListRacesRequestFilter{MinDistance: 1100, MaxDistance: 1600},
+			wantIDs: []int64{2, 3},
+		},
+		{
+			name: "meeting and visibility",
+			filter: &racing.ListRacesRequestFilter{
+				MeetingIds: []int64{1},
+				Visibility: racing.ListRacesRequestFilter_HIDDEN_ONLY,
+			},
+			wantIDs: []int64{2},
+		},
+		{
+			name:   "no matches",
+			filter: &racing.ListRacesRequestFilter{MeetingIds: []int64{99}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			races, err := repo.List(tt.filter)
+			if err != nil {
+				t.Fatalf("List() error = %s", err)
+			}
+
+			if got := raceIDs(races); !equalIDs(got, tt.wantIDs) {
+				t.Errorf("List() ids = %v, want %v", got, tt.wantIDs)
+			}
+		})
+	}
+}
+
+func TestRacesRepoListPage(t *testing.T) {
+	repo, testDB := newTestRacesRepo(t)
+
+	for number := int64(1); number <= 7; number++ {
+		insertRace(t, testDB, racetest.NewRace().Number(number).Visible(number != 4).Build())
+	}
+
+	filter := &racing.ListRacesRequestFilter{Visibility: racing.ListRacesRequestFilter_VISIBLE_ONLY}
+
+	var (
+		pages [][]int64
+		after int64
+	)
+
+	for {
+		page, err := repo.ListPage(filter, Audience{}, after, 2)
+		if err != nil {
+			t.Fatalf("ListPage() error = %s", err)
+		}
+
+		if len(page) == 0 {
+			break
+		}
+
+		pages = append(pages, raceIDs(page))
+		after = page[len(page)-1].Id
+	}
+
+	want := [][]int64{{1, 2}, {3, 5}, {6, 7}}
+
+	if len(pages) != len(want) {
+		t.Fatalf("ListPage() pages = %v, want %v", pages, want)
+	}
+
+	for i := range want {
+		if !equalIDs(pages[i], want[i]) {
+			t.Errorf("ListPage() pages = %v, want %v", pages, want)
+		}
+	}
+}
+
+func TestRacesRepoListMeetingPage(t *testing.T) {
+	repo, testDB := newTestRacesRepo(t)
+
+	for _, race := range []*racing.Race{
+		racetest.NewRace().Meeting(3).Number(2).Build(),
+		racetest.NewRace().Meeting(1).Number(1).Build(),
+		racetest.NewRace().Meeting(3).Number(1).Build(),
+		racetest.NewRace().Meeting(3).Number(3).Build(),
+		racetest.NewRace().Meeting(5).Number(1).Build(),
+		racetest.NewRace().Meeting(8).Number(1).Hidden().Build(),
+	} {
+		insertRace(t, testDB, race)
+	}
+
+	if err := repo.SetRestrictions(5, []string{"SA"}); err != nil {
+		t.Fatalf("SetRestrictions(5) error = %s", err)
+	}
+
+	visibleOnly := &racing.ListRacesRequestFilter{Visibility: racing.ListRacesRequestFilter_VISIBLE_ONLY}
+
+	tests := []struct {
+		name      string
+		filter    *racing.ListRacesRequestFilter
+		audience  Audience
+		after     int64
+		limit     int
+		raceLimit int
+		want      []int64
+	}{
+		// Meetings are in ID order, and their races in number order.
+		{"every meeting", nil, Audience{}, 0, 10, 10, []int64{2, 3, 1, 4, 5, 6}},
+		{"first page", nil, Audience{}, 0, 2, 10, []int64{2, 3, 1, 4}},
+		{"next page", nil, Audience{}, 3, 2, 10, []int64{5, 6}},
+		{"past the last page", nil, Audience{}, 8, 2, 10, nil},
+		{"first races of each meeting", nil, Audience{}, 0, 10, 2, []int64{2, 3, 1, 5, 6}},
+		{"filtered", visibleOnly, Audience{}, 3, 2, 10, []int64{5}},
+		{"in a jurisdiction", nil, Audience{Jurisdiction: "SA"}, 3, 2, 10, []int64{6}},
+	}
+
+	for _, tt := range tests {
+		races, err := repo.ListMeetingPage(tt.filter, tt.audience, tt.after, tt.limit, tt.raceLimit)
+		if err != nil {
+			t.Fatalf("ListMeetingPage(%s) error = %s", tt.name, err)
+		}
+
+		if got := raceIDs(races); !equalIDs(got, tt.want) {
+			t.Errorf("ListMeetingPage(%s) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRacesRepoListBetween(t *testing.T) {
+	repo, testDB := newTestRacesRepo(t)
+
+	from := time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	unscheduled := racetest.NewRace().ID(6).Number(6).Build()
+	unscheduled.AdvertisedStartTime = nil
+
+	for _, race := range []*racing.Race{
+		racetest.NewRace().ID(1).Number(1).StartsAt(from.Add(3 * time.Hour)).Build(),
+		racetest.NewRace().ID(2).Number(2).StartsAt(from).Build(),
+		racetest.NewRace().ID(3).Number(3).StartsAt(from.Add(-time.Second)).Build(),
+		racetest.NewRace().ID(4).Number(4).StartsAt(from.Add(24 * time.Hour)).Build(),
+		racetest.NewRace().ID(5).Meeting(2).StartsAt(from.Add(3 * time.Hour)).Build(),
+		unscheduled,
+	} {
+		insertRace(t, testDB, race)
+	}
+
+	if err := repo.SetRestrictions(1, []string{"SA"}); err != nil {
+		t.Fatalf("SetRestrictions() error = %s", err)
+	}
+
+	tests := []struct {
+		name     string
+		filter   *racing.ListRacesRequestFilter
+		audience Audience
+		want     []int64
+	}{
+		// Races are in start order, then by ID, and the range excludes its
+		// end.
+		{"every race", nil, Audience{}, []int64{2, 1, 5}},
+		{"filtered", &racing.ListRacesRequestFilter{MeetingIds: []int64{2}}, Audience{}, []int64{5}},
+		{"in a jurisdiction", nil, Audience{Jurisdiction: "SA"}, []int64{2, 5}},
+	}
+
+	for _, tt := range tests {
+		races, err := repo.ListBetween(tt.filter, tt.audience, from, from.Add(24*time.Hour))
+		if err != nil {
+			t.Fatalf("ListBetween(%s) error = %s", tt.name, err)
+		}
+
+		if got := raceIDs(races); !equalIDs(got, tt.want) {
+			t.Errorf("ListBetween(%s) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRacesRepoListArchived(t *testing.T) {
+	repo, testDB := newTestRacesRepo(t)
+
+	day := time.Date(2021, 2, 27, 0, 0, 0, 0, time.UTC)
+
+	unscheduled := racetest.NewRace().ID(5).Number(5).Build()
+	unscheduled.AdvertisedStartTime = nil
+
+	for _, race := range []*racing.Race{
+		racetest.NewRace().ID(1).Number(1).StartsAt(day.Add(12 * time.Hour)).Build(),
+		racetest.NewRace().ID(2).Number(2).StartsAt(day.AddDate(0, 0, 1)).Build(),
+		racetest.NewRace().ID(3).Meeting(2).StartsAt(day.AddDate(0, 0, 1).Add(23 * time.Hour)).Build(),
+		racetest.NewRace().ID(4).Number(4).StartsAt(day.AddDate(0, 0, 2)).Build(),
+		unscheduled,
+	} {
+		insertRace(t, testDB, race)
+	}
+
+	// Archived races keep their restrictions and brand overrides.
+	if err := repo.SetRestrictions(2, []string{"SA"}); err != nil {
+		t.Fatalf("SetRestrictions() error = %s", err)
+	}
+
+	if err := repo.SetBrandVisibility(4, "neds", proto.Bool(false)); err != nil {
+		t.Fatalf("SetBrandVisibility() error = %s", err)
+	}
+
+	if _, err := repo.Archive(day.AddDate(0, 0, 3)); err != nil {
+		t.Fatalf("Archive() error = %s", err)
+	}
+
+	tests := []struct {
+		name     string
+		filter   *racing.ListRacesRequestFilter
+		audience Audience
+		from, to time.Time
+		after    int64
+		want     []int64
+	}{
+		{name: "every race", want: []int64{1, 2, 3, 4}},
+		{name: "on a day", from: day.AddDate(0, 0, 1), to: day.AddDate(0, 0, 2), want: []int64{2, 3}},
+		{name: "from a day", from: day.AddDate(0, 0, 1), want: []int64{2, 3, 4}},
+		{name: "until a day", to: day.AddDate(0, 0, 1), want: []int64{1}},
+		{name: "filtered", filter: &racing.ListRacesRequestFilter{MeetingIds: []int64{2}}, want: []int64{3}},
+		{name: "next page", from: day.AddDate(0, 0, 1), after: 2, want: []int64{3, 4}},
+		{name: "in a jurisdiction", audience: Audience{Jurisdiction: "SA"}, want: []int64{1, 3, 4}},
+		{name: "for a brand", filter: &racing.ListRacesRequestFilter{Visibility: racing.ListRacesRequestFilter_VISIBLE_ONLY}, audience: Audience{Brand: "neds"}, want: []int64{1, 2, 3}},
+	}
+
+	for _, tt := range tests {
+		races, err := repo.ListArchived(tt.filter, tt.audience, tt.from, tt.to, tt.after, 10)
+		if err != nil {
+			t.Fatalf("ListArchived(%s) error = %s", tt.name, err)
+		}
+
+		if got := raceIDs(races); !equalIDs(got, tt.want) {
+			t.Errorf("ListArchived(%s) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRacesRepoPurge(t *testing.T) {
+	repo, testDB := newTestRacesRepo(t)
+
+	now := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	for _, race := range []*racing.Race{
+		racetest.NewRace().Number(1).StartsAt(now.Add(-72 * time.Hour)).Build(),
+		racetest.NewRace().Number(2).StartsAt(now.Add(-72 * time.Hour)).Status(racing.Race_CLOSED).Build(),
+		// Races still open past their start are overdue, e.g. awaiting
+		// results, so are kept however long ago they were due.
+		racetest.NewRace().Number(3).StartsAt(now.Add(-48 * time.Hour)).Build(),
+		racetest.NewRace().Number(4).StartsAt(now.Add(-48 * time.Hour)).Status(racing.Race_FINAL).Build(),
+		racetest.NewRace().Number(5).StartsAt(now.Add(-48 * time.Hour)).Status(racing.Race_ABANDONED).Build(),
+		racetest.NewRace().Number(6).StartsAt(now.Add(time.Hour)).Status(racing.Race_CLOSED).Build(),
+	} {
+		insertRace(t, testDB, race)
+	}
+
+	if _, err := repo.Archive(now.Add(-60 * time.Hour)); err != nil {
+		t.Fatalf("Archive() error = %s", err)
+	}
+
+	purged, err := repo.Purge(now.Add(-24 * time.Hour))
+	if err != nil {
+		t.Fatalf("Purge() error = %s", err)
+	}
+
+	if purged != 3 {
+		t.Errorf("Purge() = %d, want 3", purged)
+	}
+
+	races, err := repo.List(nil)
+	if err != nil {
+		t.Fatalf("List() error = %s", err)
+	}
+
+	if got := raceIDs(races); !equalIDs(got, []int64{3, 6}) {
+		t.Errorf("races after Purge() = %v, want [3 6]", got)
+	}
+
+	archived, err := repo.ListArchived(nil, Audience{}, time.Time{}, time.Time{}, 0, 10)
+	if err != nil {
+		t.Fatalf("ListArchived() error = %s", err)
+	}
+
+	if got := raceIDs(archived); !equalIDs(got, []int64{1}) {
+		t.Errorf("archived races after Purge() = %v, want [1]", got)
+	}
+}
+
+func TestRacesRepoListScansFields(t *testing.T) {
+	repo, testDB := newTestRacesRepo(t)
+
+	want := racetest.NewRace().
+		Meeting(7).
+		Number(3).
+		Name("Flemington").
+		Distance(1400).
+		Conditions("Good 4", "Fine").
+		Image("venues/flemington.jpg").
+		ExternalRef("feed", "F1").
+		Build()
+	insertRace(t, testDB, want)
+
+	races, err := repo.List(nil)
+	if err != nil {
+		t.Fatalf("List() error = %s", err)
+	}
+
+	if len(races) != 1 {
+		t.Fatalf("List() returned %d races, want 1", len(races))
+	}
+
+	got := races[0]
+
+	if got.Id != want.Id || got.MeetingId != want.MeetingId || got.Name != want.Name || got.Number != want.Number ||
+		got.Visible != want.Visible || got.Country != want.Country || got.Distance != want.Distance ||
+		got.TrackCondition != want.TrackCondition || got.Weather != want.Weather ||
+		got.VenueImageUrl != want.VenueImageUrl || got.VenueTimeZone != want.VenueTimeZone || got.Status != want.Status {
+		t.Errorf("List() race = %v, want %v", got, want)
+	}
+
+	if !got.AdvertisedStartTime.AsTime().Equal(want.AdvertisedStartTime.AsTime()) {
+		t.Errorf("advertised start time = %s, want %s", got.AdvertisedStartTime.AsTime(), want.AdvertisedStartTime.AsTime())
+	}
+
+	if got.GetExternalRef().GetSource() != "feed" || got.GetExternalRef().GetSourceId() != "F1" {
+		t.Errorf("external ref = %v, want feed/F1", got.ExternalRef)
+	}
+}
+
+func TestRacesRepoListMissingStartTime(t *testing.T) {
+	repo, testDB := newTestRacesRepo(t)
+
+	scheduled := racetest.NewRace().Name("Scheduled").Build()
+	insertRace(t, testDB, scheduled)
+
+	unscheduled := racetest.NewRace().Number(2).Name("Unscheduled").Build()
+	unscheduled.AdvertisedStartTime = nil
+	insertRace(t, testDB, unscheduled)
+
+	races, err := repo.List(nil)
+	if err != nil {
+		t.Fatalf("List() error = %s", err)
+	}
+
+	if len(races) != 2 {
+		t.Fatalf("List() returned %d races, want 2", len(races))
+	}
+
+	if got := races[1]; got.AdvertisedStartTime != nil || got.SecondsToStart != 0 {
+		t.Errorf("race without a start time listed with start %v, %d seconds to start", got.AdvertisedStartTime, got.SecondsToStart)
+	}
+
+	races, err = repo.List(&racing.ListRacesRequestFilter{MissingStartTime: true})
+	if err != nil {
+		t.Fatalf("List() error = %s", err)
+	}
+
+	if got, want := raceIDs(races), []int64{unscheduled.Id}; !reflect.DeepEqual(got, want) {
+		t.Errorf("List(missing start time) = %v, want %v", got, want)
+	}
+}
+
+func TestRacesRepoListEmpty(t *testing.T) {
+	repo, _ := newTestRacesRepo(t)
+
+	races, err := repo.List(nil)
+	if err != nil {
+		t.Fatalf("List() error = %s", err)
+	}
+
+	if len(races) != 0 {
+		t.Errorf("List() returned %d races, want none", len(races))
+	}
+}
+
+func TestRacesRepoListScanError(t *testing.T) {
+	repo, testDB := newTestRacesRepo(t)
+
+	insertRace(t, testDB, racetest.NewRace().Name("Corrupt").Build())
+
+	// Names are scanned into strings, which can't hold NULL.
+	if _, err := testDB.Exec(`UPDATE races SET name = NULL`); err != nil {
+		t.Fatalf("corrupting race: %s", err)
+	}
+
+	if _, err := repo.List(nil); err == nil || !strings.Contains(err.Error(), "Scan") {
+		t.Errorf("List() error = %v, want a scan error", err)
+	}
+}
+
+func TestRacesRepoGetByExternalRef(t *testing.T) {
+	repo, testDB := newTestRacesRepo(t)
+
+	race := racetest.NewRace().Name("Referenced").ExternalRef("feed", "R1").Build()
+	insertRace(t, testDB, race)
+
+	got, err := repo.GetByExternalRef("feed", "R1")
+	if err != nil {
+		t.Fatalf("GetByExternalRef() error = %s", err)
+	}
+
+	if got.Id != race.Id {
+		t.Errorf("GetByExternalRef() id = %d, want %d", got.Id, race.Id)
+	}
+
+	for _, ref := range [][2]string{{"feed", "R2"}, {"other", "R1"}} {
+		if _, err := repo.GetByExternalRef(ref[0], ref[1]); err != ErrNotFound {
+			t.Errorf("GetByExternalRef(%q, %q) error = %v, want ErrNotFound", ref[0], ref[1], err)
+		}
+	}
+}
+
+func TestRacesRepoFeatured(t *testing.T) {
+	testDB := newTestDB(t)
+
+	now := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	repo := NewRacesRepo(testDB, WithoutDummyData(), WithClock(ClockFunc(func() time.Time { return now })))
+	if err := repo.Init(); err != nil {
+		t.Fatalf("initialising races repo: %s", err)
+	}
+
+	races := []*racing.Race{
+		racetest.NewRace().Number(1).Build(),
+		racetest.NewRace().Number(2).Build(),
+		racetest.NewRace().Number(3).Hidden().Build(),
+		racetest.NewRace().Number(4).StartsAt(now.Add(-48 * time.Hour)).Build(),
+	}
+	for _, race := range races {
+		insertRace(t, testDB, race)
+	}
+
+	// Races are listed in the order they're featured, and featuring one again
+	// keeps its place.
+	for _, id := range []int64{2, 3, 1, 4, 2} {
+		if err := repo.SetFeatured(id, true); err != nil {
+			t.Fatalf("SetFeatured(%d) error = %s", id, err)
+		}
+
+		now = now.Add(time.Minute)
+	}
+
+	featured, err := repo.ListFeatured()
+	if err != nil {
+		t.Fatalf("ListFeatured() error = %s", err)
+	}
+
+	// Hidden races stay featured, but aren't listed.
+	if got, want := raceIDs(featured), []int64{2, 1, 4}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ListFeatured() = %v, want %v", got, want)
+	}
+
+	if err := repo.SetFeatured(1, false); err != nil {
+		t.Fatalf("SetFeatured(1, false) error = %s", err)
+	}
+
+	// Archived races stop being featured, and can't be featured again.
+	if _, err := repo.Archive(now.Add(-24 * time.Hour)); err != nil {
+		t.Fatalf("Archive() error = %s", err)
+	}
+
+	if err := repo.SetFeatured(4, true); !errors.Is(err, ErrNotFound) {
+		t.Errorf("SetFeatured(archived race) error = %v, want %s", err, ErrNotFound)
+	}
+
+	if err := repo.SetFeatured(99, false); !errors.Is(err, ErrNotFound) {
+		t.Errorf("SetFeatured(missing race) error = %v, want %s", err, ErrNotFound)
+	}
+
+	featured, err = repo.ListFeatured()
+	if err != nil {
+		t.Fatalf("ListFeatured() error = %s", err)
+	}
+
+	if got, want := raceIDs(featured), []int64{2}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ListFeatured() = %v, want %v", got, want)
+	}
+}
+
+func TestRacesRepoRestrictions(t *testing.T) {
+	repo, testDB := newTestRacesRepo(t)
+
+	for _, race := range []*racing.Race{
+		racetest.NewRace().Number(1).Build(),
+		racetest.NewRace().Number(2).Build(),
+		racetest.NewRace().Number(3).Build(),
+	} {
+		insertRace(t, testDB, race)
+	}
+
+	restrictions := map[int64][]string{
+		1: {"SA", "NSW"},
+		2: {"SA"},
+		3: {"VIC"},
+	}
+	for id, jurisdictions := range restrictions {
+		if err := repo.SetRestrictions(id, jurisdictions); err != nil {
+			t.Fatalf("SetRestrictions(%d) error = %s", id, err)
+		}
+	}
+
+	// Setting restrictions replaces them.
+	if err := repo.SetRestrictions(2, []string{"NSW"}); err != nil {
+		t.Fatalf("SetRestrictions(2) error = %s", err)
+	}
+
+	tests := []struct {
+		jurisdiction string
+		want         map[int64]bool
+		// wantListed are the races listed for callers from the jurisdiction.
+		wantListed []int64
+	}{
+		{"SA", map[int64]bool{1: true}, []int64{2, 3}},
+		{"NSW", map[int64]bool{1: true, 2: true}, []int64{3}},
+		{"QLD", map[int64]bool{}, []int64{1, 2, 3}},
+		{"", map[int64]bool{}, []int64{1, 2, 3}},
+	}
+
+	for _, tt := range tests {
+		got, err := repo.Restricted([]int64{1, 2, 3, 99}, tt.jurisdiction)
+		if err != nil {
+			t.Fatalf("Restricted(%q) error = %s", tt.jurisdiction, err)
+		}
+
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Restricted(%q) = %v, want %v", tt.jurisdiction, got, tt.want)
+		}
+
+		listed, err := repo.ListPage(nil, Audience{Jurisdiction: tt.jurisdiction}, 0, 10)
+		if err != nil {
+			t.Fatalf("ListPage(%q) error = %s", tt.jurisdiction, err)
+		}
+
+		if got := raceIDs(listed); !equalIDs(got, tt.wantListed) {
+			t.Errorf("ListPage(%q) = %v, want %v", tt.jurisdiction, got, tt.wantListed)
+		}
+	}
+
+	if err := repo.SetRestrictions(99, []string{"SA"}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("SetRestrictions(missing race) error = %v, want %s", err, ErrNotFound)
+	}
+}
+
+func TestRacesRepoBrandVisibility(t *testing.T) {
+	repo, testDB := newTestRacesRepo(t)
+
+	for _, race := range []*racing.Race{
+		racetest.NewRace().Number(1).Build(),
+		racetest.NewRace().Number(2).Hidden().Build(),
+		racetest.NewRace().Number(3).Build(),
+	} {
+		insertRace(t, testDB, race)
+	}
+
+	overrides := []struct {
+		raceID  int64
+		brand   string
+		visible *bool
+	}{
+		{1, "neds", proto.Bool(false)},
+		{2, "neds", proto.Bool(true)},
+		{3, "neds", proto.Bool(false)},
+		{3, "ladbrokes", proto.Bool(true)},
+		// Overriding again replaces the override, or removes it.
+		{1, "neds", proto.Bool(true)},
+		{3, "neds", nil},
+	}
+	for _, o := range overrides {
+		if err := repo.SetBrandVisibility(o.raceID, o.brand, o.visible); err != nil {
+			t.Fatalf("SetBrandVisibility(%d, %s) error = %s", o.raceID, o.brand, err)
+		}
+	}
+
+	tests := []struct {
+		brand string
+		want  map[int64]bool
+		// wantVisible are the races listed as visible for the brand.
+		wantVisible []int64
+	}{
+		{"neds", map[int64]bool{1: true, 2: true}, []int64{1, 2, 3}},
+		{"ladbrokes", map[int64]bool{3: true}, []int64{1, 3}},
+		{"other", map[int64]bool{}, []int64{1, 3}},
+		{"", map[int64]bool{}, []int64{1, 3}},
+	}
+
+	visibleOnly := &racing.ListRacesRequestFilter{Visibility: racing.ListRacesRequestFilter_VISIBLE_ONLY}
+
+	for _, tt := range tests {
+		got, err := repo.BrandVisibility([]int64{1, 2, 3, 99}, tt.brand)
+		if err != nil {
+			t.Fatalf("BrandVisibility(%q) error = %s", tt.brand, err)
+		}
+
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("BrandVisibility(%q) = %v, want %v", tt.brand, got, tt.want)
+		}
+
+		listed, err := repo.ListPage(visibleOnly, Audience{Brand: tt.brand}, 0, 10)
+		if err != nil {
+			t.Fatalf("ListPage(%q) error = %s", tt.brand, err)
+		}
+
+		if got := raceIDs(listed); !equalIDs(got, tt.wantVisible) {
+			t.Errorf("ListPage(%q) visible races = %v, want %v", tt.brand, got, tt.wantVisible)
+		}
+
+		for _, race := range listed {
+			if !race.Visible {
+				t.Errorf("ListPage(%q) race %d isn't visible, want it shown for the brand", tt.brand, race.Id)
+			}
+		}
+	}
+
+	if err := repo.SetBrandVisibility(99, "neds", proto.Bool(true)); !errors.Is(err, ErrNotFound) {
+		t.Errorf("SetBrandVisibility(missing race) error = %v, want %s", err, ErrNotFound)
+	}
+}
+
+func TestRacesRepoSetBrandVisibilityLogsChange(t *testing.T) {
+	testDB := newTestDB(t)
+
+	repo := NewRacesRepo(testDB, WithoutDummyData(), WithOutbox(), WithEventLog())
+	if err := repo.Init(); err != nil {
+		t.Fatalf("Init() error = %s", err)
+	}
+
+	race := racetest.NewRace().ExternalRef("feed", "R1").Build()
+	if _, err := repo.Upsert(race); err != nil {
+		t.Fatalf("Upsert() error = %s", err)
+	}
+
+	before, err := repo.Changes(0, 10)
+	if err != nil {
+		t.Fatalf("Changes() error = %s", err)
+	}
+
+	lastChange, _ := strconv.ParseInt(before[len(before)-1].Token, 10, 64)
+
+	for _, visible := range []*bool{proto.Bool(false), nil} {
+		if err := repo.SetBrandVisibility(race.Id, "neds", visible); err != nil {
+			t.Fatalf("SetBrandVisibility(%v) error = %s", visible, err)
+		}
+	}
+
+	changes, err := repo.Changes(lastChange, 10)
+	if err != nil {
+		t.Fatalf("Changes() error = %s", err)
+	}
+
+	if len(changes) != 2 || changes[0].RaceId != race.Id || changes[0].Operation != racing.RaceChange_UPSERTED {
+		t.Errorf("Changes() after setting brand visibility = %v, want the race upserted twice", changes)
+	}
+
+	var events, outboxed int
+	if err := testDB.QueryRow(`SELECT COUNT(*) FROM race_events WHERE race_id = ?`, race.Id).Scan(&events); err != nil {
+		t.Fatalf("counting race events: %s", err)
+	}
+
+	if err := testDB.QueryRow(`SELECT COUNT(*) FROM outbox WHERE key = ?`, strconv.FormatInt(race.Id, 10)).Scan(&outboxed); err != nil {
+		t.Fatalf("counting outbox events: %s", err)
+	}
+
+	// The race was created, then had its brand visibility set and cleared.
+	if events != 3 || outboxed != 2 {
+		t.Errorf("logged %d race events and %d outbox events, want 3 and 2", events, outboxed)
+	}
+}
+
+func TestRacesRepoListPopular(t *testing.T) {
+	repo, testDB := newTestRacesRepo(t)
+
+	for _, race := range []*racing.Race{
+		racetest.NewRace().Number(1).Build(),
+		racetest.NewRace().Meeting(5).Number(2).Build(),
+		racetest.NewRace().Meeting(5).Number(3).Build(),
+		racetest.NewRace().Number(4).Build(),
+	} {
+		insertRace(t, testDB, race)
+	}
+
+	now := time.Date(2021, 3, 1, 12, 30, 0, 0, time.UTC)
+
+	for _, views := range []struct {
+		raceID int64
+		count  int64
+		at     time.Time
+	}{
+		{1, 5, now},
+		// Views in the same hour are added together.
+		{1, 2, now.Add(-20 * time.Minute)},
+		{2, 2, now.Add(-time.Hour)},
+		{2, 4, now.Add(-3 * time.Hour)},
+		{3, 1, now.Add(-40 * time.Minute)},
+	} {
+		if err := repo.RecordViews(views.raceID, views.count, views.at); err != nil {
+			t.Fatalf("RecordViews(%d) error = %s", views.raceID, err)
+		}
+	}
+
+	if err := repo.SetRestrictions(1, []string{"SA"}); err != nil {
+		t.Fatalf("SetRestrictions(1) error = %s", err)
+	}
+
+	hidden := false
+	if err := repo.SetBrandVisibility(3, "neds", &hidden); err != nil {
+		t.Fatalf("SetBrandVisibility(3) error = %s", err)
+	}
+
+	visibleOnly := &racing.ListRacesRequestFilter{Visibility: racing.ListRacesRequestFilter_VISIBLE_ONLY}
+
+	tests := []struct {
+		name     string
+		filter   *racing.ListRacesRequestFilter
+		audience Audience
+		since    time.Time
+		offset   int64
+		limit    int
+		// want are the races ranked, as race:views.
+		want []string
+	}{
+		{"this hour", nil, Audience{}, now, 0, 10, []string{"1:7", "2:0", "3:0", "4:0"}},
+		// Views are counted by the hour, so from the start of the hour.
+		{"since the previous hour", nil, Audience{}, now.Add(-time.Hour), 0, 10, []string{"1:7", "2:2", "3:1", "4:0"}},
+		{"since a while ago", nil, Audience{}, now.Add(-4 * time.Hour), 0, 10, []string{"1:7", "2:6", "3:1", "4:0"}},
+		{"since later", nil, Audience{}, now.Add(time.Hour), 0, 10, []string{"1:0", "2:0", "3:0", "4:0"}},
+		{"a page", nil, Audience{}, now.Add(-4 * time.Hour), 1, 2, []string{"2:6", "3:1"}},
+		{"past the last page", nil, Audience{}, now, 4, 10, nil},
+		{"filtered", &racing.ListRacesRequestFilter{MeetingIds: []int64{5}}, Audience{}, now.Add(-time.Hour), 0, 10, []string{"2:2", "3:1"}},
+		{"in a jurisdiction", nil, Audience{Jurisdiction: "SA"}, now.Add(-time.Hour), 0, 10, []string{"2:2", "3:1", "4:0"}},
+		{"for a brand", visibleOnly, Audience{Brand: "neds"}, now.Add(-time.Hour), 0, 10, []string{"1:7", "2:2", "4:0"}},
+	}
+
+	for _, tt := range tests {
+		popular, err := repo.ListPopular(tt.filter, tt.audience, tt.since, tt.offset, tt.limit)
+		if err != nil {
+			t.Fatalf("ListPopular(%s) error = %s", tt.name, err)
+		}
+
+		var got []string
+		for _, race := range popular {
+			got = append(got, fmt.Sprintf("%d:%d", race.Race.Id, race.Views))
+		}
+
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ListPopular(%s) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	if err := repo.RecordViews(99, 1, now); !errors.Is(err, ErrNotFound) {
+		t.Errorf("RecordViews(missing race) error = %v, want %s", err, ErrNotFound)
+	}
+}
+
+func TestRacesRepoStats(t *testing.T) {
+	repo, testDB := newTestRacesRepo(t)
+
+	day := time.Date(2021, 3, 1, 9, 0, 0, 0, time.UTC)
+
+	unscheduled := racetest.NewRace().Meeting(10).Number(2).Country("NZ").Build()
+	unscheduled.AdvertisedStartTime = nil
+
+	for _, race := range []*racing.Race{
+		racetest.NewRace().Meeting(2).Country("AU").StartsAt(day).Build(),
+		racetest.NewRace().Meeting(2).Number(2).Country("AU").StartsAt(day.Add(16 * time.Hour)).Status(racing.Race_CLOSED).Build(),
+		racetest.NewRace().Meeting(10).Country("NZ").StartsAt(day.Add(time.Hour)).Build(),
+		unscheduled,
+	} {
+		insertRace(t, testDB, race)
+	}
+
+	counts := func(pairs ...interface{}) []*racing.StatsCount {
+		var counts []*racing.StatsCount
+		for i := 0; i < len(pairs); i += 2 {
+			counts = append(counts, &racing.StatsCount{Key: pairs[i].(string), Count: int64(pairs[i+1].(int))})
+		}
+
+		return counts
+	}
+
+	tests := []struct {
+		name   string
+		filter *racing.ListRacesRequestFilter
+		want   *racing.RaceStats
+	}{
+		{
+			name: "every race",
+			want: &racing.RaceStats{
+				Total: 4,
+				// Meetings are in ID order, rather than as text.
+				ByMeeting: counts("2", 2, "10", 2),
+				ByCountry: counts("AU", 2, "NZ", 2),
+				ByStatus:  counts("CLOSED", 1, "OPEN", 3),
+				// Days are UTC, and races without a start time have no day.
+				ByDay: counts("", 1, "2021-03-01", 2, "2021-03-02", 1),
+			},
+		},
+		{
+			name:   "filtered",
+			filter: &racing.ListRacesRequestFilter{Countries: []string{"au"}},
+			want: &racing.RaceStats{
+				Total:     2,
+				ByMeeting: counts("2", 2),
+				ByCountry: counts("AU", 2),
+				ByStatus:  counts("CLOSED", 1, "OPEN", 1),
+				ByDay:     counts("2021-03-01", 1, "2021-03-02", 1),
+			},
+		},
+		{
+			name:   "nothing matching",
+			filter: &racing.ListRacesRequestFilter{MeetingIds: []int64{99}},
+			want:   &racing.RaceStats{},
+		},
+	}
+
+	for _, tt := range tests {
+		got, err := repo.Stats(tt.filter)
+		if err != nil {
+			t.Fatalf("Stats(%s) error = %s", tt.name, err)
+		}
+
+		if !proto.Equal(got, tt.want) {
+			t.Errorf("Stats(%s) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
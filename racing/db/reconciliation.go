@@ -0,0 +1,82 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"sync"
+	"time"
+
+	"git.neds.sh/matty/entain/racing/proto/racing"
+	"google.golang.org/protobuf/proto"
+)
+
+// ReconciliationRepo provides repository access to the reports of reconciling
+// races against the feed they're ingested from.
+type ReconciliationRepo interface {
+	// Init will initialise our reconciliation repository.
+	Init() error
+
+	// SaveReport will store a report, replacing the last report for its
+	// source.
+	SaveReport(report *racing.ReconciliationReport) error
+
+	// LatestReport will return the most recent report, or ErrNotFound if no
+	// reconciliation has run.
+	LatestReport() (*racing.ReconciliationReport, error)
+}
+
+type reconciliationRepo struct {
+	db   *sql.DB
+	init sync.Once
+}
+
+// NewReconciliationRepo creates a new reconciliation repository.
+func NewReconciliationRepo(db *sql.DB) ReconciliationRepo {
+	return &reconciliationRepo{db: db}
+}
+
+// Init prepares the reconciliation repository tables.
+func (r *reconciliationRepo) Init() error {
+	var err error
+
+	r.init.Do(func() {
+		err = r.createTables()
+	})
+
+	return err
+}
+
+func (r *reconciliationRepo) SaveReport(report *racing.ReconciliationReport) error {
+	payload, err := proto.Marshal(report)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.Exec(
+		getReconciliationQueries()[reportsSave],
+		report.Source,
+		report.ReconciledAt.AsTime().UTC().Format(time.RFC3339),
+		payload,
+	)
+
+	return err
+}
+
+func (r *reconciliationRepo) LatestReport() (*racing.ReconciliationReport, error) {
+	var payload []byte
+
+	err := r.db.QueryRow(getReconciliationQueries()[reportsLatest]).Scan(&payload)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var report racing.ReconciliationReport
+	if err := proto.Unmarshal(payload, &report); err != nil {
+		return nil, err
+	}
+
+	return &report, nil
+}
@@ -0,0 +1,87 @@
+package db
+
+import (
+	"context"
+	"strings"
+)
+
+// jurisdictionKey keys the jurisdiction a request was made from in its
+// context.
+type jurisdictionKey struct{}
+
+// ContextWithJurisdiction returns a copy of ctx made from the given
+// jurisdiction (e.g. NSW), so races restricted in it aren't shown.
+func ContextWithJurisdiction(ctx context.Context, jurisdiction string) context.Context {
+	return context.WithValue(ctx, jurisdictionKey{}, jurisdiction)
+}
+
+// JurisdictionFromContext returns the jurisdiction ctx was made from, if it
+// says.
+func JurisdictionFromContext(ctx context.Context) (string, bool) {
+	jurisdiction, ok := ctx.Value(jurisdictionKey{}).(string)
+
+	return jurisdiction, ok
+}
+
+func (r *racesRepo) SetRestrictions(raceID int64, jurisdictions []string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var exists bool
+	if err := tx.QueryRow(getRaceQueries()[raceUnarchived], raceID).Scan(&exists); err != nil {
+		return err
+	}
+
+	if !exists {
+		return ErrNotFound
+	}
+
+	if _, err := tx.Exec(getRaceQueries()[raceUnrestrict], raceID); err != nil {
+		return err
+	}
+
+	for _, jurisdiction := range jurisdictions {
+		if _, err := tx.Exec(getRaceQueries()[raceRestrict], raceID, jurisdiction); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (r *racesRepo) Restricted(raceIDs []int64, jurisdiction string) (map[int64]bool, error) {
+	restricted := make(map[int64]bool)
+
+	if len(raceIDs) == 0 || jurisdiction == "" {
+		return restricted, nil
+	}
+
+	query := getRaceQueries()[restrictedRaceIDs] +
+		" AND race_id IN (" + strings.Repeat("?,", len(raceIDs)-1) + "?)"
+
+	args := []interface{}{jurisdiction}
+	for _, raceID := range raceIDs {
+		args = append(args, raceID)
+	}
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var raceID int64
+
+		if err := rows.Scan(&raceID); err != nil {
+			return nil, err
+		}
+
+		restricted[raceID] = true
+	}
+
+	return restricted, rows.Err()
+}
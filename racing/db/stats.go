@@ -0,0 +1,60 @@
+package db
+
+import (
+	"git.neds.sh/matty/entain/racing/proto/racing"
+)
+
+func (r *racesRepo) Stats(filter *racing.ListRacesRequestFilter) (*racing.RaceStats, error) {
+	var (
+		stats racing.RaceStats
+		err   error
+	)
+
+	for _, grouping := range []struct {
+		query  string
+		counts *[]*racing.StatsCount
+	}{
+		{raceStatsByMeeting, &stats.ByMeeting},
+		{raceStatsByCountry, &stats.ByCountry},
+		{raceStatsByStatus, &stats.ByStatus},
+		{raceStatsByDay, &stats.ByDay},
+	} {
+		if *grouping.counts, err = r.countBy(grouping.query, filter); err != nil {
+			return nil, err
+		}
+	}
+
+	// Every race has a meeting, so is counted once by it.
+	for _, count := range stats.ByMeeting {
+		stats.Total += count.Count
+	}
+
+	return &stats, nil
+}
+
+// countBy counts the races matching the filter, grouped by the first column
+// of the given stats query.
+func (r *racesRepo) countBy(name string, filter *racing.ListRacesRequestFilter) ([]*racing.StatsCount, error) {
+	query, args := r.applyFilter(getRaceQueries()[name], filter)
+	query += " GROUP BY 1 ORDER BY 1"
+
+	rows, err := r.query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var counts []*racing.StatsCount
+
+	for rows.Next() {
+		var count racing.StatsCount
+
+		if err := rows.Scan(&count.Key, &count.Count); err != nil {
+			return nil, err
+		}
+
+		counts = append(counts, &count)
+	}
+
+	return counts, rows.Err()
+}
@@ -0,0 +1,236 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"git.neds.sh/matty/entain/racing/proto/racing"
+	"github.com/golang/protobuf/ptypes"
+)
+
+// raceTransitions are the statuses a race can move to from each status. FINAL
+// and ABANDONED races can't move on.
+var raceTransitions = map[racing.Race_Status][]racing.Race_Status{
+	racing.Race_OPEN:    {racing.Race_CLOSED, racing.Race_ABANDONED},
+	racing.Race_CLOSED:  {racing.Race_INTERIM, racing.Race_ABANDONED},
+	racing.Race_INTERIM: {racing.Race_FINAL, racing.Race_ABANDONED},
+}
+
+// canTransition reports whether a race can move from one status to another.
+// Races without a status, such as those written before statuses existed, can
+// move to any status.
+func canTransition(from, to racing.Race_Status) bool {
+	if to == racing.Race_UNSPECIFIED {
+		return false
+	}
+
+	if from == racing.Race_UNSPECIFIED {
+		return true
+	}
+
+	for _, allowed := range raceTransitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+
+	return false
+}
+
+// invalidTransition returns an ErrInvalidTransition describing the move.
+func invalidTransition(from, to racing.Race_Status) error {
+	return fmt.Errorf("%w from %s to %s", ErrInvalidTransition, from, to)
+}
+
+// recordStatus records a race's move between statuses in its status history,
+// as part of the transaction making the move.
+func (r *racesRepo) recordStatus(tx *sql.Tx, raceID int64, from, to racing.Race_Status, actor string) error {
+	_, err := tx.Exec(
+		getRaceQueries()[raceStatusRecord],
+		raceID,
+		from.String(),
+		to.String(),
+		actor,
+		r.clock.Now().UTC().Format(time.RFC3339),
+	)
+
+	return err
+}
+
+func (r *racesRepo) UpdateStatus(raceID int64, status racing.Race_Status, actor string) (*racing.Race, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var (
+		wasVisible bool
+		previous   sql.NullString
+	)
+
+	err = tx.QueryRow(getRaceQueries()[raceStatusFind], raceID).Scan(&wasVisible, &previous)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	previousStatus := racing.Race_Status(racing.Race_Status_value[previous.String])
+
+	if status != previousStatus {
+		if !canTransition(previousStatus, status) {
+			return nil, invalidTransition(previousStatus, status)
+		}
+
+		if _, err := tx.Exec(getRaceQueries()[raceStatusUpdate], status.String(), raceID); err != nil {
+			return nil, err
+		}
+
+		if err := r.recordStatus(tx, raceID, previousStatus, status, actor); err != nil {
+			return nil, err
+		}
+	}
+
+	rows, err := tx.Query(getRaceQueries()[racesList]+" WHERE id = ?", raceID)
+	if err != nil {
+		return nil, err
+	}
+
+	races, err := r.scanRaces(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(races) == 0 {
+		return nil, ErrNotFound
+	}
+
+	if status != previousStatus {
+		if err := r.writeRaceChanged(tx, races[0], wasVisible, previousStatus); err != nil {
+			return nil, err
+		}
+	}
+
+	return races[0], tx.Commit()
+}
+
+func (r *racesRepo) StatusHistory(raceID int64) ([]*racing.StatusTransition, error) {
+	rows, err := r.db.Query(getRaceQueries()[raceStatusHistory], raceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var transitions []*racing.StatusTransition
+
+	for rows.Next() {
+		var (
+			from, to  string
+			actor     string
+			changedAt time.Time
+		)
+
+		if err := rows.Scan(&from, &to, &actor, &changedAt); err != nil {
+			return nil, err
+		}
+
+		transition, err := newStatusTransition(from, to, actor, changedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		transitions = append(transitions, transition)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Races written before statuses existed have no history, so tell them
+	// apart from races that don't exist.
+	if len(transitions) == 0 {
+		var exists bool
+		if err := r.db.QueryRow(getRaceQueries()[raceExists], raceID).Scan(&exists); err != nil {
+			return nil, err
+		}
+
+		if !exists {
+			return nil, ErrNotFound
+		}
+	}
+
+	return transitions, nil
+}
+
+func (r *racesRepo) StatusChanges(after int64, limit int) ([]*StatusChange, error) {
+	rows, err := r.db.Query(getRaceQueries()[raceStatusChanges], after, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var (
+		changes []*StatusChange
+		raceIDs []int64
+	)
+
+	for rows.Next() {
+		var (
+			change    StatusChange
+			raceID    int64
+			from, to  string
+			actor     string
+			changedAt time.Time
+		)
+
+		if err := rows.Scan(&change.ID, &raceID, &from, &to, &actor, &changedAt); err != nil {
+			return nil, err
+		}
+
+		if change.Transition, err = newStatusTransition(from, to, actor, changedAt); err != nil {
+			return nil, err
+		}
+
+		changes = append(changes, &change)
+		raceIDs = append(raceIDs, raceID)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	args := make([]interface{}, len(raceIDs))
+	for i, raceID := range raceIDs {
+		args[i] = raceID
+	}
+
+	byID, err := r.racesByID(args)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, change := range changes {
+		change.Race = byID[raceIDs[i]]
+	}
+
+	return changes, nil
+}
+
+// newStatusTransition builds a transition from its stored columns.
+func newStatusTransition(from, to, actor string, changedAt time.Time) (*racing.StatusTransition, error) {
+	ts, err := ptypes.TimestampProto(changedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &racing.StatusTransition{
+		FromStatus: racing.Race_Status(racing.Race_Status_value[from]),
+		ToStatus:   racing.Race_Status(racing.Race_Status_value[to]),
+		Actor:      actor,
+		ChangedAt:  ts,
+	}, nil
+}
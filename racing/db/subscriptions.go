@@ -0,0 +1,345 @@
+package db
+
+import (
+	"database/sql"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"git.neds.sh/matty/entain/racing/proto/racing"
+	"github.com/golang/protobuf/ptypes"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// SubscriptionsRepo provides repository access to webhook subscriptions and
+// their deliveries.
+type SubscriptionsRepo interface {
+	// Init will initialise our subscriptions repository.
+	Init() error
+
+	// Create will create a subscription, setting its ID and creation time. It
+	// is notified of changes made from then on.
+	Create(sub *racing.Subscription) error
+
+	// Delete will delete a subscription along with its deliveries, or return
+	// ErrNotFound if it doesn't exist.
+	Delete(id int64) error
+
+	// List will return every subscription, without its secret.
+	List() ([]*Subscriber, error)
+
+	// Enqueue will queue deliveries of the given changes to a subscription,
+	// and move it past the given change in the change log, atomically.
+	Enqueue(subscriptionID int64, changes []*racing.RaceChange, lastChangeID int64) error
+
+	// ListDeliveries will return a subscription's deliveries, oldest first,
+	// or ErrNotFound if the subscription doesn't exist.
+	ListDeliveries(subscriptionID int64) ([]*racing.Delivery, error)
+
+	// Due will return up to limit pending deliveries due to be attempted by
+	// the given time, oldest first.
+	Due(now time.Time, limit int) ([]*DueDelivery, error)
+
+	// RecordAttempt will record the outcome of attempting a delivery, and
+	// when it should next be attempted if it's still pending.
+	RecordAttempt(delivery *racing.Delivery, nextAttemptAt time.Time) error
+}
+
+// Subscriber is a subscription, with how far through the race change log it
+// has been matched against.
+type Subscriber struct {
+	Subscription *racing.Subscription
+	// LastChangeID is the ID of the last change matched against.
+	LastChangeID int64
+}
+
+// DueDelivery is a delivery due to be attempted, with what's needed to send
+// it.
+type DueDelivery struct {
+	ID             int64
+	SubscriptionID int64
+	Attempts       int32
+	// Payload is the JSON encoded race change to send.
+	Payload     []byte
+	CallbackURL string
+	// Secret is the subscription's key to sign the payload with.
+	Secret string
+}
+
+type subscriptionsRepo struct {
+	db   *sql.DB
+	init sync.Once
+}
+
+// NewSubscriptionsRepo creates a new subscriptions repository.
+func NewSubscriptionsRepo(db *sql.DB) SubscriptionsRepo {
+	return &subscriptionsRepo{db: db}
+}
+
+// Init prepares the subscriptions repository tables.
+func (r *subscriptionsRepo) Init() error {
+	var err error
+
+	r.init.Do(func() {
+		err = r.createTables()
+	})
+
+	return err
+}
+
+func (r *subscriptionsRepo) Create(sub *racing.Subscription) error {
+	now := time.Now().UTC().Truncate(time.Second)
+
+	res, err := r.db.Exec(
+		getSubscriptionQueries()[subscriptionsInsert],
+		sub.CallbackUrl,
+		formatIDs(sub.GetFilter().GetMeetingIds()),
+		sub.Secret,
+		now.Format(time.RFC3339),
+	)
+	if err != nil {
+		return err
+	}
+
+	if sub.Id, err = res.LastInsertId(); err != nil {
+		return err
+	}
+
+	sub.CreatedAt, err = ptypes.TimestampProto(now)
+
+	return err
+}
+
+func (r *subscriptionsRepo) Delete(id int64) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(getSubscriptionQueries()[subscriptionsDelete], id)
+	if err != nil {
+		return err
+	}
+
+	deleted, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if deleted == 0 {
+		return ErrNotFound
+	}
+
+	if _, err := tx.Exec(getSubscriptionQueries()[deliveriesPurge], id); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *subscriptionsRepo) List() ([]*Subscriber, error) {
+	rows, err := r.db.Query(getSubscriptionQueries()[subscriptionsList] + orderByID)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.scanSubscribers(rows)
+}
+
+func (r *subscriptionsRepo) Enqueue(subscriptionID int64, changes []*racing.RaceChange, lastChangeID int64) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC().Format(time.RFC3339)
+
+	for _, change := range changes {
+		changeID, err := strconv.ParseInt(change.Token, 10, 64)
+		if err != nil {
+			return err
+		}
+
+		payload, err := protojson.Marshal(change)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(
+			getSubscriptionQueries()[deliveriesInsert],
+			subscriptionID,
+			changeID,
+			change.RaceId,
+			payload,
+			racing.Delivery_PENDING.String(),
+			now,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.Exec(getSubscriptionQueries()[subscriptionsSetCursor], lastChangeID, subscriptionID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *subscriptionsRepo) ListDeliveries(subscriptionID int64) ([]*racing.Delivery, error) {
+	rows, err := r.db.Query(getSubscriptionQueries()[subscriptionsList]+" WHERE id = ?", subscriptionID)
+	if err != nil {
+		return nil, err
+	}
+
+	subscribers, err := r.scanSubscribers(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(subscribers) == 0 {
+		return nil, ErrNotFound
+	}
+
+	rows, err = r.db.Query(getSubscriptionQueries()[deliveriesList], subscriptionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []*racing.Delivery
+
+	for rows.Next() {
+		var (
+			delivery      racing.Delivery
+			changeID      int64
+			status        string
+			lastAttemptAt sql.NullTime
+			responseCode  sql.NullInt32
+			lastError     sql.NullString
+		)
+
+		if err := rows.Scan(&delivery.Id, &delivery.SubscriptionId, &changeID, &delivery.RaceId, &status, &delivery.Attempts, &lastAttemptAt, &responseCode, &lastError); err != nil {
+			return nil, err
+		}
+
+		delivery.ChangeToken = strconv.FormatInt(changeID, 10)
+		delivery.Status = racing.Delivery_Status(racing.Delivery_Status_value[status])
+		delivery.LastResponseCode = responseCode.Int32
+		delivery.LastError = lastError.String
+
+		if lastAttemptAt.Valid {
+			if delivery.LastAttemptAt, err = ptypes.TimestampProto(lastAttemptAt.Time); err != nil {
+				return nil, err
+			}
+		}
+
+		deliveries = append(deliveries, &delivery)
+	}
+
+	return deliveries, rows.Err()
+}
+
+func (r *subscriptionsRepo) Due(now time.Time, limit int) ([]*DueDelivery, error) {
+	rows, err := r.db.Query(getSubscriptionQueries()[deliveriesDue], now.UTC().Format(time.RFC3339), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var due []*DueDelivery
+
+	for rows.Next() {
+		var delivery DueDelivery
+
+		if err := rows.Scan(&delivery.ID, &delivery.SubscriptionID, &delivery.Attempts, &delivery.Payload, &delivery.CallbackURL, &delivery.Secret); err != nil {
+			return nil, err
+		}
+
+		due = append(due, &delivery)
+	}
+
+	return due, rows.Err()
+}
+
+func (r *subscriptionsRepo) RecordAttempt(delivery *racing.Delivery, nextAttemptAt time.Time) error {
+	_, err := r.db.Exec(
+		getSubscriptionQueries()[deliveriesRecordAttempt],
+		delivery.Status.String(),
+		delivery.Attempts,
+		nextAttemptAt.UTC().Format(time.RFC3339),
+		delivery.LastAttemptAt.AsTime().Format(time.RFC3339),
+		delivery.LastResponseCode,
+		delivery.LastError,
+		delivery.Id,
+	)
+
+	return err
+}
+
+func (r *subscriptionsRepo) scanSubscribers(rows *sql.Rows) ([]*Subscriber, error) {
+	defer rows.Close()
+
+	var subscribers []*Subscriber
+
+	for rows.Next() {
+		var (
+			sub        racing.Subscription
+			subscriber = Subscriber{Subscription: &sub}
+			meetingIDs string
+			createdAt  time.Time
+		)
+
+		if err := rows.Scan(&sub.Id, &sub.CallbackUrl, &meetingIDs, &subscriber.LastChangeID, &createdAt); err != nil {
+			return nil, err
+		}
+
+		ids, err := parseIDs(meetingIDs)
+		if err != nil {
+			return nil, err
+		}
+
+		sub.Filter = &racing.SubscriptionFilter{MeetingIds: ids}
+
+		if sub.CreatedAt, err = ptypes.TimestampProto(createdAt); err != nil {
+			return nil, err
+		}
+
+		subscribers = append(subscribers, &subscriber)
+	}
+
+	return subscribers, rows.Err()
+}
+
+// formatIDs stores a list of IDs as comma separated text.
+func formatIDs(ids []int64) string {
+	formatted := make([]string, 0, len(ids))
+	for _, id := range ids {
+		formatted = append(formatted, strconv.FormatInt(id, 10))
+	}
+
+	return strings.Join(formatted, ",")
+}
+
+// parseIDs reads a list of IDs stored by formatIDs.
+func parseIDs(s string) ([]int64, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	var ids []int64
+
+	for _, field := range strings.Split(s, ",") {
+		id, err := strconv.ParseInt(field, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
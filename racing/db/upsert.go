@@ -0,0 +1,242 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"expvar"
+	"strconv"
+	"time"
+
+	"git.neds.sh/matty/entain/racing/proto/racing"
+	"github.com/mattn/go-sqlite3"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// UpsertResult describes what an upsert did with a race.
+type UpsertResult int
+
+const (
+	// UpsertSkipped means the race already existed, unchanged.
+	UpsertSkipped UpsertResult = iota
+	// UpsertCreated means the race didn't exist, and was created.
+	UpsertCreated
+	// UpsertUpdated means the race already existed, and was updated.
+	UpsertUpdated
+)
+
+func (u UpsertResult) String() string {
+	switch u {
+	case UpsertCreated:
+		return "created"
+	case UpsertUpdated:
+		return "updated"
+	default:
+		return "skipped"
+	}
+}
+
+// racesUpserted counts upserted races by their UpsertResult.
+var racesUpserted = expvar.NewMap("races_upserted_total")
+
+// UpsertOutcome is what a batched upsert did with a race.
+type UpsertOutcome struct {
+	Result UpsertResult
+	// Err is why the race couldn't be written, in which case it was skipped.
+	Err error
+}
+
+func (r *racesRepo) Upsert(race *racing.Race) (UpsertResult, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return UpsertSkipped, err
+	}
+	defer tx.Rollback()
+
+	result, err := r.upsert(tx, race)
+	if err != nil {
+		return UpsertSkipped, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return UpsertSkipped, err
+	}
+
+	racesUpserted.Add(result.String(), 1)
+
+	return result, nil
+}
+
+func (r *racesRepo) UpsertBatch(races []*racing.Race) ([]UpsertOutcome, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	queries := getRaceQueries()
+	outcomes := make([]UpsertOutcome, len(races))
+
+	for i, race := range races {
+		if _, err := tx.Exec(queries[raceSavepoint]); err != nil {
+			return nil, err
+		}
+
+		outcomes[i].Result, outcomes[i].Err = r.upsert(tx, race)
+
+		if outcomes[i].Err != nil {
+			if _, err := tx.Exec(queries[raceRollbackTo]); err != nil {
+				return nil, err
+			}
+		}
+
+		if _, err := tx.Exec(queries[raceRelease]); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	for _, outcome := range outcomes {
+		if outcome.Err == nil {
+			racesUpserted.Add(outcome.Result.String(), 1)
+		}
+	}
+
+	return outcomes, nil
+}
+
+// upsert creates or updates a race as part of a transaction.
+func (r *racesRepo) upsert(tx *sql.Tx, race *racing.Race) (UpsertResult, error) {
+	ref := race.GetExternalRef()
+	if ref.GetSource() == "" || ref.GetSourceId() == "" {
+		return UpsertSkipped, ErrMissingExternalRef
+	}
+
+	var (
+		id             int64
+		wasVisible     bool
+		status         sql.NullString
+		previousStatus racing.Race_Status
+		result         UpsertResult
+	)
+
+	err := tx.QueryRow(getRaceQueries()[racesFindByRef], ref.Source, ref.SourceId).Scan(&id, &wasVisible, &status)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		// New races are open unless the source says otherwise.
+		if race.Status == racing.Race_UNSPECIFIED {
+			race.Status = racing.Race_OPEN
+		}
+
+		res, err := tx.Exec(getRaceQueries()[racesInsert], append(raceFields(race), ref.Source, ref.SourceId)...)
+		if err != nil {
+			return UpsertSkipped, translateError(err)
+		}
+
+		if id, err = res.LastInsertId(); err != nil {
+			return UpsertSkipped, err
+		}
+
+		result = UpsertCreated
+	case err != nil:
+		return UpsertSkipped, err
+	default:
+		previousStatus = racing.Race_Status(racing.Race_Status_value[status.String])
+
+		// Sources that don't track status leave it as it is.
+		if race.Status == racing.Race_UNSPECIFIED {
+			race.Status = previousStatus
+		}
+
+		if race.Status != previousStatus && !canTransition(previousStatus, race.Status) {
+			return UpsertSkipped, invalidTransition(previousStatus, race.Status)
+		}
+
+		res, err := tx.Exec(getRaceQueries()[racesUpdate], append(raceFields(race), id)...)
+		if err != nil {
+			return UpsertSkipped, translateError(err)
+		}
+
+		updated, err := res.RowsAffected()
+		if err != nil {
+			return UpsertSkipped, err
+		}
+
+		if updated > 0 {
+			result = UpsertUpdated
+		}
+	}
+
+	race.Id = id
+
+	if race.Status != previousStatus {
+		if err := r.recordStatus(tx, id, previousStatus, race.Status, ref.Source); err != nil {
+			return UpsertSkipped, err
+		}
+	}
+
+	if result == UpsertUpdated && (race.Visible != wasVisible || race.Status != previousStatus) {
+		if err := r.writeRaceChanged(tx, race, wasVisible, previousStatus); err != nil {
+			return UpsertSkipped, err
+		}
+	}
+
+	return result, nil
+}
+
+// raceFields returns the values of the race's columns, in the order they're
+// written by the insert and update queries.
+func raceFields(race *racing.Race) []interface{} {
+	return []interface{}{
+		race.MeetingId,
+		race.Name,
+		race.Number,
+		race.Visible,
+		advertisedStartTime(race),
+		race.VenueTimeZone,
+		race.Country,
+		race.Distance,
+		race.TrackCondition,
+		race.Weather,
+		race.VenueImageUrl,
+		race.Status.String(),
+	}
+}
+
+// advertisedStartTime returns the value of the race's advertised_start_time
+// column, which is NULL if it has no start time.
+func advertisedStartTime(race *racing.Race) interface{} {
+	if race.AdvertisedStartTime == nil {
+		return nil
+	}
+
+	return race.AdvertisedStartTime.AsTime().Format(time.RFC3339)
+}
+
+// writeRaceChanged writes a RaceChanged event to the outbox, if enabled, as
+// part of the transaction making the change.
+func (r *racesRepo) writeRaceChanged(tx *sql.Tx, race *racing.Race, previousVisible bool, previousStatus racing.Race_Status) error {
+	if !r.outbox {
+		return nil
+	}
+
+	return writeOutboxEvent(tx, strconv.FormatInt(race.Id, 10), &racing.RaceChanged{
+		Race:            race,
+		PreviousVisible: previousVisible,
+		PreviousStatus:  previousStatus,
+		ChangedAt:       timestamppb.New(r.clock.Now()),
+	})
+}
+
+// translateError maps constraint violations to the errors exported by this
+// package.
+func translateError(err error) error {
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
+		return ErrAlreadyExists
+	}
+
+	return err
+}
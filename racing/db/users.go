@@ -0,0 +1,19 @@
+package db
+
+import "context"
+
+// userKey keys the user a request was authenticated as in its context.
+type userKey struct{}
+
+// ContextWithUser returns a copy of ctx authenticated as the given user, whose
+// watchlist is used.
+func ContextWithUser(ctx context.Context, userID string) context.Context {
+	return context.WithValue(ctx, userKey{}, userID)
+}
+
+// UserFromContext returns the user ctx was authenticated as, if it was.
+func UserFromContext(ctx context.Context) (string, bool) {
+	userID, ok := ctx.Value(userKey{}).(string)
+
+	return userID, ok
+}
@@ -0,0 +1,72 @@
+package db
+
+import (
+	"strings"
+	"time"
+
+	"git.neds.sh/matty/entain/racing/proto/racing"
+)
+
+// viewHour returns the hour views seen at the given time are counted in.
+func viewHour(at time.Time) time.Time {
+	return at.UTC().Truncate(time.Hour)
+}
+
+func (r *racesRepo) RecordViews(raceID int64, views int64, at time.Time) error {
+	res, err := r.db.Exec(getRaceQueries()[raceViewsRecord], raceID, viewHour(at).Format(time.RFC3339), views)
+	if err != nil {
+		return err
+	}
+
+	recorded, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if recorded == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (r *racesRepo) ListPopular(filter *racing.ListRacesRequestFilter, audience Audience, since time.Time, offset int64, limit int) ([]*racing.PopularRace, error) {
+	listed, clauses, args := r.listFor(racesList, filter, audience)
+
+	if len(clauses) != 0 {
+		listed += " WHERE " + strings.Join(clauses, " AND ")
+	}
+
+	// Races are ranked as they're read, so only a page of them is scanned
+	// however many are listed.
+	query := "SELECT listed.*, COALESCE(viewed.views, 0) AS views" +
+		" FROM (" + listed + ") AS listed" +
+		" LEFT JOIN (" + getRaceQueries()[raceViewsList] + ") AS viewed ON viewed.race_id = listed.id" +
+		" ORDER BY views DESC, listed.id LIMIT ? OFFSET ?"
+
+	args = append(args, viewHour(since).Format(time.RFC3339), limit, offset)
+
+	rows, err := r.query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var popular []*racing.PopularRace
+
+	// Use a single point in time, so countdowns are consistent across the list.
+	now := r.clock.Now()
+
+	for rows.Next() {
+		var views int64
+
+		race, err := r.scanRace(rows, now, &views)
+		if err != nil {
+			return nil, err
+		}
+
+		popular = append(popular, &racing.PopularRace{Race: race, Views: views})
+	}
+
+	return popular, rows.Err()
+}
@@ -0,0 +1,17 @@
+package events
+
+import (
+	"context"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// Publisher publishes protobuf messages to a message broker.
+type Publisher interface {
+	// Publish will publish the message, keyed so that messages with the same
+	// key are delivered in order.
+	Publish(ctx context.Context, key string, msg proto.Message) error
+
+	// Close will flush any pending messages and release the publisher.
+	Close() error
+}
@@ -0,0 +1,47 @@
+package events
+
+import (
+	"context"
+
+	"github.com/segmentio/kafka-go"
+	"google.golang.org/protobuf/proto"
+)
+
+// KafkaPublisher publishes messages to a Kafka topic.
+type KafkaPublisher struct {
+	writer *kafka.Writer
+}
+
+// NewKafkaPublisher creates a publisher writing to the given topic.
+func NewKafkaPublisher(brokers []string, topic string) *KafkaPublisher {
+	return &KafkaPublisher{
+		writer: &kafka.Writer{
+			Addr:         kafka.TCP(brokers...),
+			Topic:        topic,
+			Balancer:     &kafka.Hash{},
+			RequiredAcks: kafka.RequireAll,
+		},
+	}
+}
+
+// Publish will publish the message, with its full name in a type header so
+// consumers know how to decode it.
+func (p *KafkaPublisher) Publish(ctx context.Context, key string, msg proto.Message) error {
+	value, err := proto.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	return p.writer.WriteMessages(ctx, kafka.Message{
+		Key:   []byte(key),
+		Value: value,
+		Headers: []kafka.Header{
+			{Key: "type", Value: []byte(msg.ProtoReflect().Descriptor().FullName())},
+		},
+	})
+}
+
+// Close will flush any pending messages and close the connection to Kafka.
+func (p *KafkaPublisher) Close() error {
+	return p.writer.Close()
+}
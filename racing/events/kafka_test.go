@@ -0,0 +1,37 @@
+package events
+
+import (
+	"testing"
+
+	"github.com/segmentio/kafka-go"
+)
+
+func TestNewKafkaPublisherKeepsKeysInOrder(t *testing.T) {
+	p := NewKafkaPublisher([]string{"kafka-1:9092", "kafka-2:9092"}, "races")
+	t.Cleanup(func() { p.Close() })
+
+	if p.writer.Topic != "races" || p.writer.Addr.String() != "kafka-1:9092,kafka-2:9092" {
+		t.Errorf("writer writes to %s on %s, want races on both brokers", p.writer.Topic, p.writer.Addr)
+	}
+
+	// A change only counts as published once every replica has it.
+	if p.writer.RequiredAcks != kafka.RequireAll {
+		t.Errorf("writer requires %d acks, want every replica's", p.writer.RequiredAcks)
+	}
+
+	// Messages for a race must land on the same partition to stay in order.
+	partitions := []int{0, 1, 2, 3, 4, 5}
+
+	tests := []string{"1", "2", "12345"}
+
+	for _, key := range tests {
+		msg := kafka.Message{Key: []byte(key)}
+		want := p.writer.Balancer.Balance(msg, partitions...)
+
+		for i := 0; i < 10; i++ {
+			if got := p.writer.Balancer.Balance(msg, partitions...); got != want {
+				t.Errorf("key %s balanced to partition %d, then %d, want the same partition", key, want, got)
+			}
+		}
+	}
+}
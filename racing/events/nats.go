@@ -0,0 +1,56 @@
+package events
+
+import (
+	"context"
+
+	"github.com/nats-io/nats.go"
+	"google.golang.org/protobuf/proto"
+)
+
+// NATSPublisher publishes messages to NATS JetStream. A stream capturing the
+// publisher's subjects must already exist.
+type NATSPublisher struct {
+	conn    *nats.Conn
+	js      nats.JetStreamContext
+	subject string
+}
+
+// NewNATSPublisher connects to the NATS server at the given URL, to publish
+// under the given subject.
+func NewNATSPublisher(url, subject string) (*NATSPublisher, error) {
+	conn, err := nats.Connect(url, nats.Name("racing"))
+	if err != nil {
+		return nil, err
+	}
+
+	js, err := conn.JetStream()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return &NATSPublisher{conn: conn, js: js, subject: subject}, nil
+}
+
+// Publish will publish the message on the publisher's subject suffixed with
+// the key, so consumers can filter on it, and with its full name in a type
+// header so consumers know how to decode it.
+func (p *NATSPublisher) Publish(ctx context.Context, key string, msg proto.Message) error {
+	value, err := proto.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	natsMsg := nats.NewMsg(p.subject + "." + key)
+	natsMsg.Header.Set("type", string(msg.ProtoReflect().Descriptor().FullName()))
+	natsMsg.Data = value
+
+	_, err = p.js.PublishMsg(natsMsg, nats.Context(ctx))
+
+	return err
+}
+
+// Close will flush any pending messages and close the connection to NATS.
+func (p *NATSPublisher) Close() error {
+	return p.conn.Drain()
+}
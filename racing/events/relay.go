@@ -0,0 +1,69 @@
+package events
+
+import (
+	"context"
+	"expvar"
+	"time"
+
+	"git.neds.sh/matty/entain/racing/db"
+)
+
+const (
+	// relayBatchSize is how many events are read from the outbox at a time.
+	relayBatchSize = 100
+	// publishTimeout bounds how long publishing a single event may take.
+	publishTimeout = 5 * time.Second
+)
+
+// eventsPublished counts the outbox events published to the broker.
+var eventsPublished = expvar.NewInt("outbox_events_published_total")
+
+// Relay publishes the events in the outbox, in order. An event is only
+// removed from the outbox once it's been published, so every event is
+// published at least once.
+type Relay struct {
+	outbox    db.OutboxRepo
+	publisher Publisher
+}
+
+// NewRelay creates a new relay.
+func NewRelay(outbox db.OutboxRepo, publisher Publisher) *Relay {
+	return &Relay{
+		outbox:    outbox,
+		publisher: publisher,
+	}
+}
+
+// Relay publishes every event in the outbox. It stops at the first event that
+// fails to publish, so that later events aren't published ahead of it.
+func (r *Relay) Relay(ctx context.Context) error {
+	for {
+		pending, err := r.outbox.Pending(relayBatchSize)
+		if err != nil {
+			return err
+		}
+
+		for _, event := range pending {
+			if err := r.publish(ctx, event); err != nil {
+				return err
+			}
+
+			if err := r.outbox.Delete(event.ID); err != nil {
+				return err
+			}
+
+			eventsPublished.Add(1)
+		}
+
+		if len(pending) < relayBatchSize {
+			return nil
+		}
+	}
+}
+
+func (r *Relay) publish(ctx context.Context, event *db.OutboxEvent) error {
+	ctx, cancel := context.WithTimeout(ctx, publishTimeout)
+	defer cancel()
+
+	return r.publisher.Publish(ctx, event.Key, event.Message)
+}
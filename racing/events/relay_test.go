@@ -0,0 +1,232 @@
+package events
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"git.neds.sh/matty/entain/racing/db"
+	"git.neds.sh/matty/entain/racing/proto/racing"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// errBrokerDown is returned by the recording publisher for failed keys.
+var errBrokerDown = errors.New("broker unavailable")
+
+// recordingPublisher records the keys of the messages published, failing to
+// publish those in fail.
+type recordingPublisher struct {
+	published []string
+	fail      map[string]bool
+}
+
+func (p *recordingPublisher) Publish(ctx context.Context, key string, msg proto.Message) error {
+	if _, ok := ctx.Deadline(); !ok {
+		return errors.New("published without a timeout")
+	}
+
+	if p.fail[key] {
+		return errBrokerDown
+	}
+
+	p.published = append(p.published, key)
+
+	return nil
+}
+
+func (p *recordingPublisher) Close() error {
+	return nil
+}
+
+// memoryOutbox is an outbox holding events in memory, oldest first.
+type memoryOutbox struct {
+	events  []*db.OutboxEvent
+	deleted []int64
+}
+
+func (o *memoryOutbox) Pending(limit int) ([]*db.OutboxEvent, error) {
+	if len(o.events) < limit {
+		limit = len(o.events)
+	}
+
+	return o.events[:limit], nil
+}
+
+func (o *memoryOutbox) Delete(id int64) error {
+	for i, event := range o.events {
+		if event.ID == id {
+			o.events = append(o.events[:i:i], o.events[i+1:]...)
+			o.deleted = append(o.deleted, id)
+
+			return nil
+		}
+	}
+
+	return nil
+}
+
+// outboxEvents returns events for the given keys, numbered from 1.
+func outboxEvents(keys ...string) []*db.OutboxEvent {
+	events := make([]*db.OutboxEvent, 0, len(keys))
+	for i, key := range keys {
+		events = append(events, &db.OutboxEvent{ID: int64(i + 1), Key: key, Message: &racing.RaceChanged{}})
+	}
+
+	return events
+}
+
+func TestRelayPublishesInOrder(t *testing.T) {
+	keys := make([]string, relayBatchSize+1)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i % 3)
+	}
+
+	tests := []struct {
+		name          string
+		pending       []*db.OutboxEvent
+		fail          map[string]bool
+		wantPublished []string
+		wantDeleted   []int64
+		wantErr       error
+	}{
+		{
+			"empty",
+			nil,
+			nil,
+			nil,
+			nil,
+			nil,
+		},
+		{
+			"every event",
+			outboxEvents("1", "2", "1"),
+			nil,
+			[]string{"1", "2", "1"},
+			[]int64{1, 2, 3},
+			nil,
+		},
+		{
+			"more than a batch",
+			outboxEvents(keys...),
+			nil,
+			keys,
+			nil,
+			nil,
+		},
+		{
+			"stops at a failure",
+			outboxEvents("1", "2", "3"),
+			map[string]bool{"2": true},
+			[]string{"1"},
+			[]int64{1},
+			errBrokerDown,
+		},
+		{
+			"first fails",
+			outboxEvents("1", "2"),
+			map[string]bool{"1": true},
+			nil,
+			nil,
+			errBrokerDown,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outbox := &memoryOutbox{events: tt.pending}
+			publisher := &recordingPublisher{fail: tt.fail}
+
+			if err := NewRelay(outbox, publisher).Relay(context.Background()); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Relay() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if !reflect.DeepEqual(publisher.published, tt.wantPublished) {
+				t.Errorf("published %v, want %v", publisher.published, tt.wantPublished)
+			}
+
+			if tt.wantDeleted != nil && !reflect.DeepEqual(outbox.deleted, tt.wantDeleted) {
+				t.Errorf("deleted %v, want %v", outbox.deleted, tt.wantDeleted)
+			}
+
+			if tt.wantErr == nil && len(outbox.events) != 0 {
+				t.Errorf("%d events left in the outbox, want none", len(outbox.events))
+			}
+		})
+	}
+}
+
+func TestRelayRetriesFromOutbox(t *testing.T) {
+	testDB, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("opening test database: %s", err)
+	}
+
+	// Every connection to :memory: gets its own database, so share one.
+	testDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { testDB.Close() })
+
+	racesRepo := db.NewRacesRepo(testDB, db.WithoutDummyData(), db.WithOutbox())
+	if err := racesRepo.Init(); err != nil {
+		t.Fatalf("initialising races repo: %s", err)
+	}
+
+	races := make(map[int64]*racing.Race)
+
+	for i := int64(1); i <= 3; i++ {
+		race := &racing.Race{
+			MeetingId:           1,
+			Number:              i,
+			Visible:             true,
+			AdvertisedStartTime: timestamppb.Now(),
+			ExternalRef:         &racing.ExternalRef{Source: "test", SourceId: strconv.FormatInt(i, 10)},
+		}
+		if _, err := racesRepo.Upsert(race); err != nil {
+			t.Fatalf("Upsert() error = %s", err)
+		}
+
+		races[race.Id] = race
+	}
+
+	// Each change of visibility writes an event keyed by its race.
+	var changes []string
+
+	for _, id := range []int64{2, 1, 3} {
+		races[id].Visible = false
+		if _, err := racesRepo.Upsert(races[id]); err != nil {
+			t.Fatalf("Upsert(%d) error = %s", id, err)
+		}
+		changes = append(changes, strconv.FormatInt(id, 10))
+	}
+
+	publisher := &recordingPublisher{fail: map[string]bool{"1": true}}
+	relay := NewRelay(db.NewOutboxRepo(testDB), publisher)
+
+	if err := relay.Relay(context.Background()); !errors.Is(err, errBrokerDown) {
+		t.Fatalf("Relay() error = %v, want %s", err, errBrokerDown)
+	}
+
+	if want := changes[:1]; !reflect.DeepEqual(publisher.published, want) {
+		t.Errorf("published %v before the broker failed, want %v", publisher.published, want)
+	}
+
+	// Once the broker recovers, the rest are published in the order they
+	// were made, without publishing the first again.
+	publisher.fail = nil
+
+	if err := relay.Relay(context.Background()); err != nil {
+		t.Fatalf("Relay() error = %s", err)
+	}
+
+	if !reflect.DeepEqual(publisher.published, changes) {
+		t.Errorf("published %v, want %v", publisher.published, changes)
+	}
+
+	pending, err := db.NewOutboxRepo(testDB).Pending(relayBatchSize)
+	if err != nil || len(pending) != 0 {
+		t.Errorf("Pending() = %d events, %v, want the outbox empty", len(pending), err)
+	}
+}
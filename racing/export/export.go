@@ -0,0 +1,182 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"expvar"
+	"fmt"
+	"strconv"
+	"time"
+
+	"git.neds.sh/matty/entain/racing/db"
+	"git.neds.sh/matty/entain/racing/proto/racing"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// Format is the file format races are exported in.
+type Format string
+
+const (
+	// JSON exports races as JSON Lines, one race object per line.
+	JSON Format = "json"
+	// CSV exports races as CSV, with a header row.
+	CSV Format = "csv"
+)
+
+var (
+	// exportedRaces counts the races exported.
+	exportedRaces = expvar.NewInt("races_exported_total")
+	// csvHeader names the columns of CSV exports.
+	csvHeader = []string{
+		"id",
+		"meeting_id",
+		"name",
+		"number",
+		"visible",
+		"advertised_start_time",
+		"venue_time_zone",
+		"country",
+		"distance",
+		"track_condition",
+		"weather",
+		"venue_image_url",
+		"external_source",
+		"external_id",
+		"status",
+	}
+)
+
+// Store is object storage that exports are written to.
+type Store interface {
+	// Put writes an object, replacing any already at the key.
+	Put(ctx context.Context, key string, body []byte, contentType string) error
+}
+
+// Exporter writes snapshots of races matching a filter to object storage.
+type Exporter struct {
+	racesRepo db.RacesRepo
+	store     Store
+	format    Format
+	filter    *racing.ListRacesRequestFilter
+	prefix    string
+}
+
+// NewExporter creates a new exporter, writing races matching the filter under
+// the given key prefix.
+func NewExporter(racesRepo db.RacesRepo, store Store, format Format, filter *racing.ListRacesRequestFilter, prefix string) (*Exporter, error) {
+	if format != JSON && format != CSV {
+		return nil, fmt.Errorf("unsupported export format %q", format)
+	}
+
+	return &Exporter{
+		racesRepo: racesRepo,
+		store:     store,
+		format:    format,
+		filter:    filter,
+		prefix:    prefix,
+	}, nil
+}
+
+// Export writes a snapshot of the races as they are now, keyed by when it was
+// taken, e.g. races/2021/03/01/races-20210301T120000Z.json, so pipelines can
+// pick up snapshots by date.
+func (e *Exporter) Export(ctx context.Context) error {
+	now := time.Now().UTC()
+
+	races, err := e.racesRepo.List(e.filter)
+	if err != nil {
+		return err
+	}
+
+	var (
+		body        []byte
+		contentType string
+	)
+
+	switch e.format {
+	case CSV:
+		body, err = encodeCSV(races)
+		contentType = "text/csv"
+	default:
+		body, err = encodeJSON(races)
+		contentType = "application/x-ndjson"
+	}
+	if err != nil {
+		return err
+	}
+
+	key := fmt.Sprintf("%sraces/%s/races-%s.%s", e.prefix, now.Format("2006/01/02"), now.Format("20060102T150405Z"), e.format)
+
+	if err := e.store.Put(ctx, key, body, contentType); err != nil {
+		return err
+	}
+
+	exportedRaces.Add(int64(len(races)))
+
+	return nil
+}
+
+// encodeJSON encodes races as JSON Lines.
+func encodeJSON(races []*racing.Race) ([]byte, error) {
+	var buf bytes.Buffer
+
+	for _, race := range races {
+		line, err := protojson.Marshal(race)
+		if err != nil {
+			return nil, err
+		}
+
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+
+	return buf.Bytes(), nil
+}
+
+// encodeCSV encodes races as CSV, one row per race.
+func encodeCSV(races []*racing.Race) ([]byte, error) {
+	var buf bytes.Buffer
+
+	w := csv.NewWriter(&buf)
+
+	if err := w.Write(csvHeader); err != nil {
+		return nil, err
+	}
+
+	for _, race := range races {
+		if err := w.Write([]string{
+			strconv.FormatInt(race.Id, 10),
+			strconv.FormatInt(race.MeetingId, 10),
+			race.Name,
+			strconv.FormatInt(race.Number, 10),
+			strconv.FormatBool(race.Visible),
+			formatStartTime(race),
+			race.VenueTimeZone,
+			race.Country,
+			strconv.FormatInt(race.Distance, 10),
+			race.TrackCondition,
+			race.Weather,
+			race.VenueImageUrl,
+			race.GetExternalRef().GetSource(),
+			race.GetExternalRef().GetSourceId(),
+			race.Status.String(),
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	w.Flush()
+
+	return buf.Bytes(), w.Error()
+}
+
+// formatStartTime formats the race's advertised start time, or returns an
+// empty string if it has none.
+func formatStartTime(race *racing.Race) string {
+	if race.AdvertisedStartTime == nil {
+		return ""
+	}
+
+	return race.AdvertisedStartTime.AsTime().Format(time.RFC3339)
+}
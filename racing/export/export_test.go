@@ -0,0 +1,176 @@
+package export
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"git.neds.sh/matty/entain/racing/db"
+	"git.neds.sh/matty/entain/racing/proto/racing"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// memoryStore is a store keeping the objects put in it, failing puts if err
+// is set.
+type memoryStore struct {
+	objects      map[string]string
+	contentTypes map[string]string
+	err          error
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{objects: map[string]string{}, contentTypes: map[string]string{}}
+}
+
+func (s *memoryStore) Put(_ context.Context, key string, body []byte, contentType string) error {
+	if s.err != nil {
+		return s.err
+	}
+
+	s.objects[key] = string(body)
+	s.contentTypes[key] = contentType
+
+	return nil
+}
+
+// stubRaces is a races repository listing the given races for one filter.
+type stubRaces struct {
+	db.RacesRepo
+	t      *testing.T
+	filter *racing.ListRacesRequestFilter
+	races  []*racing.Race
+}
+
+func (r *stubRaces) List(filter *racing.ListRacesRequestFilter) ([]*racing.Race, error) {
+	if !proto.Equal(filter, r.filter) {
+		r.t.Errorf("List(%v), want %v", filter, r.filter)
+	}
+
+	return r.races, nil
+}
+
+func TestNewExporterRejectsUnsupportedFormats(t *testing.T) {
+	tests := []struct {
+		format  Format
+		wantErr bool
+	}{
+		{JSON, false},
+		{CSV, false},
+		{"xml", true},
+		{"", true},
+	}
+
+	for _, tt := range tests {
+		if _, err := NewExporter(nil, newMemoryStore(), tt.format, nil, ""); (err != nil) != tt.wantErr {
+			t.Errorf("NewExporter(%q) error = %v, want error %t", tt.format, err, tt.wantErr)
+		}
+	}
+}
+
+func TestExporterExport(t *testing.T) {
+	start := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	races := []*racing.Race{
+		{
+			Id:                  1,
+			MeetingId:           5,
+			Name:                "Flemington",
+			Number:              1,
+			Visible:             true,
+			AdvertisedStartTime: timestamppb.New(start),
+			VenueTimeZone:       "Australia/Melbourne",
+			Country:             "AU",
+			Distance:            1200,
+			ExternalRef:         &racing.ExternalRef{Source: "tab", SourceId: "f1"},
+			Status:              racing.Race_OPEN,
+		},
+		{
+			Id:                  2,
+			MeetingId:           5,
+			Name:                "Say \"hi\", Flemington",
+			Number:              2,
+			AdvertisedStartTime: timestamppb.New(start.Add(time.Hour)),
+			Status:              racing.Race_CLOSED,
+		},
+	}
+
+	tests := []struct {
+		format          Format
+		prefix          string
+		wantKey         string
+		wantContentType string
+		wantBody        string
+	}{
+		{
+			JSON,
+			"",
+			`^races/\d{4}/\d{2}/\d{2}/races-\d{8}T\d{6}Z\.json$`,
+			"application/x-ndjson",
+			`^\{"id":"1",[^\n]*"name":"Flemington"[^\n]*\}\n\{"id":"2",[^\n]*\}\n$`,
+		},
+		{
+			CSV,
+			"exports/",
+			`^exports/races/\d{4}/\d{2}/\d{2}/races-\d{8}T\d{6}Z\.csv$`,
+			"text/csv",
+			`^id,meeting_id,name,number,visible,advertised_start_time,venue_time_zone,country,distance,track_condition,weather,venue_image_url,external_source,external_id,status\n` +
+				`1,5,Flemington,1,true,2021-03-01T12:00:00Z,Australia/Melbourne,AU,1200,,,,tab,f1,OPEN\n` +
+				`2,5,"Say ""hi"", Flemington",2,false,[^,]*,[^,]*,[^,]*,[^,]*,,,,,,CLOSED\n$`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.format), func(t *testing.T) {
+			filter := &racing.ListRacesRequestFilter{MeetingIds: []int64{5}}
+			racesRepo := &stubRaces{t: t, filter: filter, races: races}
+
+			store := newMemoryStore()
+
+			exporter, err := NewExporter(racesRepo, store, tt.format, filter, tt.prefix)
+			if err != nil {
+				t.Fatalf("NewExporter() error = %s", err)
+			}
+
+			if err := exporter.Export(context.Background()); err != nil {
+				t.Fatalf("Export() error = %s", err)
+			}
+
+			if len(store.objects) != 1 {
+				t.Fatalf("Export() put %d objects, want 1", len(store.objects))
+			}
+
+			for key, body := range store.objects {
+				if !regexp.MustCompile(tt.wantKey).MatchString(key) {
+					t.Errorf("Export() key = %q, want matching %s", key, tt.wantKey)
+				}
+
+				if got := store.contentTypes[key]; got != tt.wantContentType {
+					t.Errorf("Export() content type = %q, want %q", got, tt.wantContentType)
+				}
+
+				if !regexp.MustCompile(tt.wantBody).MatchString(body) {
+					t.Errorf("Export() body = %q, want matching %s", body, tt.wantBody)
+				}
+			}
+		})
+	}
+}
+
+func TestExporterExportStoreFailure(t *testing.T) {
+	racesRepo := &stubRaces{t: t}
+
+	store := newMemoryStore()
+	store.err = errors.New("bucket not found")
+
+	exporter, err := NewExporter(racesRepo, store, JSON, nil, "")
+	if err != nil {
+		t.Fatalf("NewExporter() error = %s", err)
+	}
+
+	if err := exporter.Export(context.Background()); !errors.Is(err, store.err) {
+		t.Errorf("Export() error = %v, want %s", err, store.err)
+	}
+}
@@ -0,0 +1,45 @@
+package export
+
+import (
+	"bytes"
+	"context"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// S3Store writes exports to a bucket on S3 compatible storage, such as AWS
+// S3, GCS (through its XML API) or MinIO.
+type S3Store struct {
+	client *minio.Client
+	bucket string
+}
+
+// NewS3Store creates a store writing to the bucket at the given endpoint, e.g.
+// s3.amazonaws.com or storage.googleapis.com. Credentials are read from the
+// AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY environment variables, or their
+// MINIO_ equivalents.
+func NewS3Store(endpoint, region, bucket string, secure bool) (*S3Store, error) {
+	client, err := minio.New(endpoint, &minio.Options{
+		Creds: credentials.NewChainCredentials([]credentials.Provider{
+			&credentials.EnvAWS{},
+			&credentials.EnvMinio{},
+		}),
+		Region: region,
+		Secure: secure,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &S3Store{client: client, bucket: bucket}, nil
+}
+
+// Put uploads an object to the bucket.
+func (s *S3Store) Put(ctx context.Context, key string, body []byte, contentType string) error {
+	_, err := s.client.PutObject(ctx, s.bucket, key, bytes.NewReader(body), int64(len(body)), minio.PutObjectOptions{
+		ContentType: contentType,
+	})
+
+	return err
+}
@@ -0,0 +1,199 @@
+// Package features toggles risky features, such as new filters, per request,
+// from a JSON file of flags that is reloaded while the service runs, so they
+// can be rolled out gradually and turned off without a deploy.
+package features
+
+import (
+	"context"
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"hash/fnv"
+	"io/ioutil"
+	"log"
+	"math"
+	"math/rand"
+	"os"
+	"sync"
+	"time"
+)
+
+// The features that can be toggled.
+const (
+	// MissingStartTimeFilter lets ListRaces filter for races without an
+	// advertised start time.
+	MissingStartTimeFilter = "missing-start-time-filter"
+)
+
+// defaults are whether each feature is on when the flags don't say.
+var defaults = map[string]bool{
+	MissingStartTimeFilter: true,
+}
+
+// featuresEnabled counts the requests each feature was on for.
+var featuresEnabled = expvar.NewMap("features_enabled_total")
+
+// Flag describes how a feature is rolled out.
+type Flag struct {
+	// Enabled turns the feature on.
+	Enabled bool `json:"enabled"`
+	// Rollout is the proportion of requests, from 0 to 1, an enabled feature
+	// is on for. It's on for every request when unset.
+	Rollout *float64 `json:"rollout"`
+}
+
+// Parse parses flags from JSON, keyed by feature name, e.g.
+//
+//	{"missing-start-time-filter": {"enabled": true, "rollout": 0.1}}
+func Parse(data []byte) (map[string]Flag, error) {
+	var flags map[string]Flag
+	if err := json.Unmarshal(data, &flags); err != nil {
+		return nil, err
+	}
+
+	for name, flag := range flags {
+		if flag.Rollout != nil && (*flag.Rollout < 0 || *flag.Rollout > 1) {
+			return nil, fmt.Errorf("rollout of %s must be between 0 and 1", name)
+		}
+	}
+
+	return flags, nil
+}
+
+// Flags are the feature flags read from a file, kept up to date with it.
+type Flags struct {
+	path string
+
+	mu      sync.RWMutex
+	flags   map[string]Flag
+	modTime time.Time
+}
+
+// Load reads the feature flags in the file at the given path.
+func Load(path string) (*Flags, error) {
+	f := &Flags{path: path}
+
+	if err := f.Reload(); err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// Run reloads the flags every interval until ctx is done. A file that fails
+// to load is logged, and the flags last loaded are kept.
+func (f *Flags) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if err := f.Reload(); err != nil {
+			log.Printf("failed reloading feature flags: %s\n", err)
+		}
+	}
+}
+
+// Reload reads the flags from the file again, if it has changed since they
+// were last read.
+func (f *Flags) Reload() error {
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return err
+	}
+
+	f.mu.RLock()
+	unchanged := info.ModTime().Equal(f.modTime)
+	f.mu.RUnlock()
+
+	if unchanged {
+		return nil
+	}
+
+	data, err := ioutil.ReadFile(f.path)
+	if err != nil {
+		return err
+	}
+
+	flags, err := Parse(data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", f.path, err)
+	}
+
+	f.mu.Lock()
+	f.flags = flags
+	f.modTime = info.ModTime()
+	f.mu.Unlock()
+
+	log.Printf("loaded %d feature flags from %s\n", len(flags), f.path)
+
+	return nil
+}
+
+// Evaluate decides which features are on for a request. Requests with the
+// same rollout key, e.g. a user or device ID, get the same features while
+// the flags are unchanged; requests without one are rolled out to at random.
+func (f *Flags) Evaluate(rolloutKey string) Set {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	set := make(Set, len(f.flags))
+
+	for name, flag := range f.flags {
+		on := flag.Enabled
+		if on && flag.Rollout != nil {
+			on = bucket(name, rolloutKey) < *flag.Rollout
+		}
+
+		set[name] = on
+	}
+
+	return set
+}
+
+// bucket places a request in [0, 1) for rolling a feature out to, so a
+// feature is rolled out to a stable proportion of rollout keys. Each feature
+// is rolled out to different keys.
+func bucket(name, rolloutKey string) float64 {
+	if rolloutKey == "" {
+		return rand.Float64()
+	}
+
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(name + "/" + rolloutKey))
+
+	return float64(h.Sum32()) / (math.MaxUint32 + 1)
+}
+
+// Set is whether each feature is on for a request.
+type Set map[string]bool
+
+// setKey keys the features on for a request in its context.
+type setKey struct{}
+
+// NewContext returns a copy of ctx with the given features on for it.
+func NewContext(ctx context.Context, set Set) context.Context {
+	return context.WithValue(ctx, setKey{}, set)
+}
+
+// Enabled reports whether a feature is on for the request ctx belongs to,
+// falling back to its default when its flag isn't set.
+func Enabled(ctx context.Context, name string) bool {
+	set, _ := ctx.Value(setKey{}).(Set)
+
+	on, ok := set[name]
+	if !ok {
+		on = defaults[name]
+	}
+
+	if on {
+		featuresEnabled.Add(name, 1)
+	}
+
+	return on
+}
@@ -0,0 +1,137 @@
+package features
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeFlags writes flags to the file at path, modified at the given time.
+func writeFlags(t *testing.T, path, flags string, modTime time.Time) {
+	t.Helper()
+
+	if err := ioutil.WriteFile(path, []byte(flags), 0644); err != nil {
+		t.Fatalf("writing flags: %s", err)
+	}
+
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatalf("setting flags modification time: %s", err)
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		flags   string
+		wantErr bool
+	}{
+		{`{}`, false},
+		{`{"new-filter": {"enabled": true}}`, false},
+		{`{"new-filter": {"enabled": true, "rollout": 0.25}}`, false},
+		{`{"new-filter": {"enabled": true, "rollout": 1.5}}`, true},
+		{`{"new-filter": {"enabled": true, "rollout": -0.1}}`, true},
+		{`{"new-filter": true}`, true},
+	}
+
+	for _, tt := range tests {
+		if _, err := Parse([]byte(tt.flags)); (err != nil) != tt.wantErr {
+			t.Errorf("Parse(%s) error = %v, want error %t", tt.flags, err, tt.wantErr)
+		}
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "flags.json")
+	writeFlags(t, path, `{
+		"on": {"enabled": true},
+		"off": {"enabled": false, "rollout": 1},
+		"none": {"enabled": true, "rollout": 0},
+		"half": {"enabled": true, "rollout": 0.5}
+	}`, time.Now())
+
+	flags, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %s", err)
+	}
+
+	var halfOn int
+
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("user-%d", i)
+		set := flags.Evaluate(key)
+
+		if !set["on"] || set["off"] || set["none"] {
+			t.Fatalf("Evaluate(%q) = %v, want only on, and maybe half", key, set)
+		}
+
+		// The same key gets the same features.
+		if again := flags.Evaluate(key); again["half"] != set["half"] {
+			t.Fatalf("Evaluate(%q) half = %t, then %t", key, set["half"], again["half"])
+		}
+
+		if set["half"] {
+			halfOn++
+		}
+	}
+
+	if halfOn < 400 || halfOn > 600 {
+		t.Errorf("half rolled out to %d of 1000 keys, want about 500", halfOn)
+	}
+}
+
+func TestReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "flags.json")
+	loadedAt := time.Now().Add(-time.Minute)
+	writeFlags(t, path, `{"feature": {"enabled": true}}`, loadedAt)
+
+	flags, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %s", err)
+	}
+
+	// Broken flags are refused, keeping those last loaded.
+	writeFlags(t, path, `{"feature": {"enabled": true, "rollout": 2}}`, loadedAt.Add(time.Second))
+
+	if err := flags.Reload(); err == nil {
+		t.Error("Reload() of invalid flags error = nil, want an error")
+	}
+
+	if !flags.Evaluate("")["feature"] {
+		t.Error("feature off after failed reload, want it on")
+	}
+
+	writeFlags(t, path, `{"feature": {"enabled": false}}`, loadedAt.Add(2*time.Second))
+
+	if err := flags.Reload(); err != nil {
+		t.Fatalf("Reload() error = %s", err)
+	}
+
+	if flags.Evaluate("")["feature"] {
+		t.Error("feature on after turning it off, want it off")
+	}
+}
+
+func TestEnabled(t *testing.T) {
+	ctx := context.Background()
+
+	if !Enabled(ctx, MissingStartTimeFilter) {
+		t.Errorf("Enabled(%s) without flags = false, want its default", MissingStartTimeFilter)
+	}
+
+	if Enabled(ctx, "unknown") {
+		t.Error("Enabled(unknown) = true, want false")
+	}
+
+	ctx = NewContext(ctx, Set{MissingStartTimeFilter: false, "new-filter": true})
+
+	if Enabled(ctx, MissingStartTimeFilter) {
+		t.Errorf("Enabled(%s) turned off = true, want false", MissingStartTimeFilter)
+	}
+
+	if !Enabled(ctx, "new-filter") {
+		t.Error("Enabled(new-filter) turned on = false, want true")
+	}
+}
@@ -0,0 +1,128 @@
+// Package fixtures loads races described declaratively in YAML or JSON files,
+// for seeding specific scenarios, such as an abandoned race or one about to
+// jump, that tests and environments can reuse.
+//
+// A fixture file lists races by their fields, as named in the protos. Rather
+// than an advertised start time, races can give how long from now they start
+// in, e.g.
+//
+//	races:
+//	  - external_ref: {source_id: abandoned}
+//	    meeting_id: 1
+//	    number: 1
+//	    name: Abandoned at Flemington
+//	    status: ABANDONED
+//	    starts_in: -10m
+package fixtures
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"git.neds.sh/matty/entain/racing/proto/racing"
+	"github.com/ghodss/yaml"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Source is the external source races are referenced by, unless their
+// fixture gives another.
+const Source = "fixtures"
+
+// file is the layout of a fixture file.
+type file struct {
+	Races []map[string]json.RawMessage `json:"races"`
+}
+
+// Load reads the races in a YAML or JSON fixture file, starting relative to
+// now.
+func Load(path string, now time.Time) ([]*racing.Race, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	races, err := Parse(data, now)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return races, nil
+}
+
+// Parse parses the races in YAML or JSON fixtures, starting relative to now.
+func Parse(data []byte, now time.Time) ([]*racing.Race, error) {
+	// JSON is YAML, so both are read the same way.
+	data, err := yaml.YAMLToJSON(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var f file
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, err
+	}
+
+	races := make([]*racing.Race, 0, len(f.Races))
+
+	for i, fields := range f.Races {
+		race, err := parseRace(fields, now)
+		if err != nil {
+			return nil, fmt.Errorf("race %d: %w", i, err)
+		}
+
+		races = append(races, race)
+	}
+
+	return races, nil
+}
+
+// parseRace parses a race's fields, working out when it starts.
+func parseRace(fields map[string]json.RawMessage, now time.Time) (*racing.Race, error) {
+	var startsIn time.Duration
+
+	if raw, ok := fields["starts_in"]; ok {
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return nil, fmt.Errorf("starts_in must be a duration, e.g. 30s or -2h: %w", err)
+		}
+
+		var err error
+		if startsIn, err = time.ParseDuration(s); err != nil {
+			return nil, fmt.Errorf("invalid starts_in: %w", err)
+		}
+
+		delete(fields, "starts_in")
+	}
+
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+
+	var race racing.Race
+	if err := protojson.Unmarshal(data, &race); err != nil {
+		return nil, err
+	}
+
+	if race.AdvertisedStartTime == nil {
+		race.AdvertisedStartTime = timestamppb.New(now.Add(startsIn).Truncate(time.Second))
+	}
+
+	// Races are seeded by reference, so seeding them again updates them.
+	if race.ExternalRef.GetSourceId() == "" {
+		return nil, fmt.Errorf("%q needs an external_ref.source_id", race.Name)
+	}
+
+	if race.ExternalRef.Source == "" {
+		race.ExternalRef.Source = Source
+	}
+
+	if race.Status == racing.Race_UNSPECIFIED {
+		race.Status = racing.Race_OPEN
+	}
+
+	return &race, nil
+}
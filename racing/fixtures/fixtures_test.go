@@ -0,0 +1,93 @@
+package fixtures
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"git.neds.sh/matty/entain/racing/proto/racing"
+)
+
+func TestLoad(t *testing.T) {
+	now := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	races, err := Load(filepath.Join("scenarios", "race-day.yaml"), now)
+	if err != nil {
+		t.Fatalf("Load() error = %s", err)
+	}
+
+	if len(races) != 5 {
+		t.Fatalf("Load() returned %d races, want 5", len(races))
+	}
+
+	jumping := races[0]
+
+	if got, want := jumping.AdvertisedStartTime.AsTime(), now.Add(30*time.Second); !got.Equal(want) {
+		t.Errorf("advertised start = %s, want %s", got, want)
+	}
+
+	if jumping.Status != racing.Race_OPEN {
+		t.Errorf("status = %s, want OPEN by default", jumping.Status)
+	}
+
+	if ref := jumping.ExternalRef; ref.Source != Source || ref.SourceId != "jumping-soon" {
+		t.Errorf("external ref = %v, want %s/jumping-soon", ref, Source)
+	}
+
+	if abandoned := races[3]; abandoned.Status != racing.Race_ABANDONED || abandoned.Country != "NZ" {
+		t.Errorf("abandoned race = %v", abandoned)
+	}
+}
+
+func TestParse(t *testing.T) {
+	now := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		data    string
+		wantErr string
+	}{
+		{
+			name: "json",
+			data: `{"races": [{"external_ref": {"source": "feed", "source_id": "F1"}, "name": "JSON", "advertisedStartTime": "2021-03-02T00:00:00Z"}]}`,
+		},
+		{
+			name:    "missing reference",
+			data:    "races:\n  - name: Unreferenced\n",
+			wantErr: "needs an external_ref.source_id",
+		},
+		{
+			name:    "invalid starts_in",
+			data:    "races:\n  - external_ref: {source_id: F1}\n    starts_in: soon\n",
+			wantErr: "invalid starts_in",
+		},
+		{
+			name:    "unknown field",
+			data:    "races:\n  - external_ref: {source_id: F1}\n    colour: red\n",
+			wantErr: "colour",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			races, err := Parse([]byte(tt.data), now)
+
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Parse() error = %v, want %q", err, tt.wantErr)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Parse() error = %s", err)
+			}
+
+			if len(races) != 1 || races[0].ExternalRef.Source != "feed" {
+				t.Errorf("Parse() = %v", races)
+			}
+		})
+	}
+}
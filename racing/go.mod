@@ -3,13 +3,21 @@ module git.neds.sh/matty/entain/racing
 go 1.16
 
 require (
+	github.com/blevesearch/bleve/v2 v2.0.1
+	github.com/ghodss/yaml v1.0.0
+	github.com/golang/mock v1.4.4
 	github.com/golang/protobuf v1.4.3
 	github.com/grpc-ecosystem/grpc-gateway/v2 v2.3.0
 	github.com/mattn/go-sqlite3 v1.14.6
+	github.com/minio/minio-go/v7 v7.0.10
+	github.com/nats-io/nats.go v1.11.0
+	github.com/segmentio/kafka-go v0.4.12
 	golang.org/x/net v0.0.0-20210226172049-e18ecbb05110
+	golang.org/x/text v0.3.5
 	google.golang.org/genproto v0.0.0-20210226172003-ab064af71705
 	google.golang.org/grpc v1.36.0
 	google.golang.org/grpc/cmd/protoc-gen-go-grpc v1.1.0
 	google.golang.org/protobuf v1.25.1-0.20201208041424-160c7477e0e8
+	pgregory.net/rapid v0.4.7
 	syreclabs.com/go/faker v1.2.3
 )
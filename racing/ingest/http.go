@@ -0,0 +1,129 @@
+package ingest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"git.neds.sh/matty/entain/racing/proto/racing"
+	"github.com/golang/protobuf/ptypes"
+)
+
+// feedPayload is the JSON document served by an HTTP racing feed.
+type feedPayload struct {
+	PublishedAt time.Time  `json:"published_at"`
+	Races       []feedRace `json:"races"`
+}
+
+// feedRace is a race as published by an HTTP racing feed. Its status is
+// optional, for feeds that don't track it.
+type feedRace struct {
+	ID                  string    `json:"id"`
+	MeetingID           int64     `json:"meeting_id"`
+	Name                string    `json:"name"`
+	Number              int64     `json:"number"`
+	Visible             bool      `json:"visible"`
+	AdvertisedStartTime time.Time `json:"advertised_start_time"`
+	VenueTimeZone       string    `json:"venue_time_zone"`
+	Country             string    `json:"country"`
+	Distance            int64     `json:"distance"`
+	TrackCondition      string    `json:"track_condition"`
+	Weather             string    `json:"weather"`
+	VenueImage          string    `json:"venue_image"`
+	Status              string    `json:"status"`
+}
+
+// HTTPConnector fetches races from a feed serving them as JSON over HTTP.
+type HTTPConnector struct {
+	source string
+	url    string
+	client *http.Client
+}
+
+// NewHTTPConnector creates a connector for the JSON feed at the given URL.
+// Races are referenced by the given source name and their ID in the feed.
+func NewHTTPConnector(source, url string, client *http.Client) *HTTPConnector {
+	return &HTTPConnector{
+		source: source,
+		url:    url,
+		client: client,
+	}
+}
+
+// Fetch will return the races currently published by the feed.
+func (c *HTTPConnector) Fetch(ctx context.Context) (*Snapshot, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Accept", "application/json")
+
+	// Fall back to when we asked, for feeds that don't say when they published.
+	fetchedAt := time.Now()
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("racing feed responded with %s", resp.Status)
+	}
+
+	var payload feedPayload
+	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+		return nil, fmt.Errorf("decoding racing feed: %w", err)
+	}
+
+	snapshot := &Snapshot{PublishedAt: payload.PublishedAt}
+	if snapshot.PublishedAt.IsZero() {
+		snapshot.PublishedAt = fetchedAt
+	}
+
+	for _, fr := range payload.Races {
+		race, err := c.toRace(fr)
+		if err != nil {
+			return nil, err
+		}
+
+		snapshot.Races = append(snapshot.Races, race)
+	}
+
+	return snapshot, nil
+}
+
+// toRace maps a feed race onto our race resource.
+func (c *HTTPConnector) toRace(fr feedRace) (*racing.Race, error) {
+	if fr.ID == "" {
+		return nil, fmt.Errorf("racing feed race %q has no id", fr.Name)
+	}
+
+	ts, err := ptypes.TimestampProto(fr.AdvertisedStartTime)
+	if err != nil {
+		return nil, err
+	}
+
+	return &racing.Race{
+		MeetingId:           fr.MeetingID,
+		Name:                fr.Name,
+		Number:              fr.Number,
+		Visible:             fr.Visible,
+		AdvertisedStartTime: ts,
+		VenueTimeZone:       fr.VenueTimeZone,
+		Country:             fr.Country,
+		Distance:            fr.Distance,
+		TrackCondition:      fr.TrackCondition,
+		Weather:             fr.Weather,
+		VenueImageUrl:       fr.VenueImage,
+		Status:              racing.Race_Status(racing.Race_Status_value[strings.ToUpper(fr.Status)]),
+		ExternalRef: &racing.ExternalRef{
+			Source:   c.source,
+			SourceId: fr.ID,
+		},
+	}, nil
+}
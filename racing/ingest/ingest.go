@@ -0,0 +1,81 @@
+package ingest
+
+import (
+	"context"
+	"expvar"
+	"log"
+	"time"
+
+	"git.neds.sh/matty/entain/racing/db"
+	"git.neds.sh/matty/entain/racing/proto/racing"
+)
+
+var (
+	// feedLag is how far behind the feed, in seconds, our races were after the
+	// last successful ingest.
+	feedLag = expvar.NewFloat("racing_feed_lag_seconds")
+	// feedErrors counts failed feed fetches and race writes.
+	feedErrors = expvar.NewInt("racing_feed_errors_total")
+)
+
+// Snapshot is the set of races a feed published at a point in time.
+type Snapshot struct {
+	// Races are the races in the feed, each with an external reference.
+	Races []*racing.Race
+	// PublishedAt is when the feed published the snapshot.
+	PublishedAt time.Time
+}
+
+// Connector fetches races from an external feed.
+type Connector interface {
+	// Fetch will return the races currently published by the feed.
+	Fetch(ctx context.Context) (*Snapshot, error)
+}
+
+// Ingester reads a feed connector, writing its races through the races repo.
+type Ingester struct {
+	connector Connector
+	racesRepo db.RacesRepo
+}
+
+// NewIngester creates a new ingester.
+func NewIngester(connector Connector, racesRepo db.RacesRepo) *Ingester {
+	return &Ingester{
+		connector: connector,
+		racesRepo: racesRepo,
+	}
+}
+
+// Ingest fetches the feed once, and upserts every race in it. A race that
+// fails to write is logged and skipped, so it can't hold up the rest.
+func (i *Ingester) Ingest(ctx context.Context) error {
+	snapshot, err := i.connector.Fetch(ctx)
+	if err != nil {
+		feedErrors.Add(1)
+		return err
+	}
+
+	results := make(map[db.UpsertResult]int)
+
+	for _, race := range snapshot.Races {
+		result, err := i.racesRepo.Upsert(race)
+		if err != nil {
+			feedErrors.Add(1)
+			log.Printf("failed ingesting race %s/%s: %s\n", race.GetExternalRef().GetSource(), race.GetExternalRef().GetSourceId(), err)
+
+			continue
+		}
+
+		results[result]++
+	}
+
+	lag := time.Since(snapshot.PublishedAt)
+	feedLag.Set(lag.Seconds())
+
+	log.Printf(
+		"ingested racing feed: %d created, %d updated, %d skipped, %s behind\n",
+		results[db.UpsertCreated], results[db.UpsertUpdated], results[db.UpsertSkipped], lag.Round(time.Millisecond),
+	)
+
+	return nil
+}
@@ -0,0 +1,208 @@
+package ingest
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"git.neds.sh/matty/entain/racing/db"
+	"git.neds.sh/matty/entain/racing/proto/racing"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// staticConnector is a connector returning the same snapshot, or error, every
+// time.
+type staticConnector struct {
+	snapshot *Snapshot
+	err      error
+}
+
+func (c staticConnector) Fetch(context.Context) (*Snapshot, error) {
+	return c.snapshot, c.err
+}
+
+// feedTestStart is when the earliest race in the test feed starts.
+var feedTestStart = time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+
+// snapshotRace returns a race from the test feed, numbered within meeting 1
+// and starting number minutes after the earliest race in it.
+func snapshotRace(sourceID string, number int64) *racing.Race {
+	return &racing.Race{
+		MeetingId:           1,
+		Name:                "Race " + sourceID,
+		Number:              number,
+		Visible:             true,
+		AdvertisedStartTime: timestamppb.New(feedTestStart.Add(time.Duration(number-1) * time.Minute)),
+		VenueTimeZone:       "Australia/Melbourne",
+		Country:             "AU",
+		Distance:            1200,
+		ExternalRef:         &racing.ExternalRef{Source: "tab", SourceId: sourceID},
+	}
+}
+
+// newTestRacesRepo creates a races repository without dummy races on an empty
+// in-memory database.
+func newTestRacesRepo(t *testing.T) db.RacesRepo {
+	t.Helper()
+
+	testDB, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("opening test database: %s", err)
+	}
+
+	// Every connection to :memory: gets its own database, so share one.
+	testDB.SetMaxOpenConns(1)
+
+	t.Cleanup(func() { testDB.Close() })
+
+	repo := db.NewRacesRepo(testDB, db.WithoutDummyData())
+	if err := repo.Init(); err != nil {
+		t.Fatalf("initialising races repo: %s", err)
+	}
+
+	return repo
+}
+
+func TestIngesterIngest(t *testing.T) {
+	errFeed := errors.New("feed unavailable")
+
+	renamed := snapshotRace("a", 1)
+	renamed.Name = "Renamed"
+
+	// clash takes a number already taken at the meeting, so can't be written.
+	clash := snapshotRace("clash", 2)
+
+	tests := []struct {
+		name      string
+		snapshots []staticConnector
+		wantErr   error
+		// wantNames are the names of the races held after ingesting, by
+		// their ID in the feed, with "" for races that shouldn't be held.
+		wantNames map[string]string
+	}{
+		{
+			"creates races",
+			[]staticConnector{{snapshot: &Snapshot{Races: []*racing.Race{snapshotRace("a", 1), snapshotRace("b", 2)}}}},
+			nil,
+			map[string]string{"a": "Race a", "b": "Race b"},
+		},
+		{
+			"updates races",
+			[]staticConnector{
+				{snapshot: &Snapshot{Races: []*racing.Race{snapshotRace("a", 1)}}},
+				{snapshot: &Snapshot{Races: []*racing.Race{renamed}}},
+			},
+			nil,
+			map[string]string{"a": "Renamed"},
+		},
+		{
+			"skips races that fail to write",
+			[]staticConnector{{snapshot: &Snapshot{Races: []*racing.Race{snapshotRace("a", 1), snapshotRace("b", 2), clash, snapshotRace("c", 3)}}}},
+			nil,
+			map[string]string{"a": "Race a", "b": "Race b", "clash": "", "c": "Race c"},
+		},
+		{
+			"feed unavailable",
+			[]staticConnector{{err: errFeed}},
+			errFeed,
+			map[string]string{"a": ""},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			racesRepo := newTestRacesRepo(t)
+
+			var err error
+			for _, connector := range tt.snapshots {
+				err = NewIngester(connector, racesRepo).Ingest(context.Background())
+			}
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Ingest() error = %v, want %v", err, tt.wantErr)
+			}
+
+			for sourceID, wantName := range tt.wantNames {
+				race, err := racesRepo.GetByExternalRef("tab", sourceID)
+				if errors.Is(err, db.ErrNotFound) {
+					race, err = &racing.Race{}, nil
+				}
+				if err != nil {
+					t.Fatalf("GetByExternalRef(%s) error = %s", sourceID, err)
+				}
+
+				if race.Name != wantName {
+					t.Errorf("race %s named %q, want %q", sourceID, race.Name, wantName)
+				}
+			}
+		})
+	}
+}
+
+func TestHTTPConnectorFetch(t *testing.T) {
+	tests := []struct {
+		name        string
+		code        int
+		body        string
+		wantErr     bool
+		wantRaces   int
+		wantFetched bool
+	}{
+		{
+			"published",
+			http.StatusOK,
+			`{"published_at": "2021-03-01T12:00:00Z", "races": [{"id": "f1", "meeting_id": 5, "name": "Flemington", "number": 1, "advertised_start_time": "2021-03-01T13:00:00Z"}]}`,
+			false,
+			1,
+			false,
+		},
+		{
+			"unknown publish time",
+			http.StatusOK,
+			`{"races": [{"id": "f1", "advertised_start_time": "2021-03-01T13:00:00Z"}]}`,
+			false,
+			1,
+			true,
+		},
+		{"race without an id", http.StatusOK, `{"races": [{"name": "Flemington"}]}`, true, 0, false},
+		{"malformed", http.StatusOK, `{"races": [`, true, 0, false},
+		{"unavailable", http.StatusServiceUnavailable, ``, true, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.code)
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			t.Cleanup(server.Close)
+
+			before := time.Now()
+
+			snapshot, err := NewHTTPConnector("tab", server.URL, server.Client()).Fetch(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Fetch() error = %v, want error %t", err, tt.wantErr)
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			if len(snapshot.Races) != tt.wantRaces {
+				t.Fatalf("Fetch() = %d races, want %d", len(snapshot.Races), tt.wantRaces)
+			}
+
+			if ref := snapshot.Races[0].ExternalRef; ref.Source != "tab" || ref.SourceId != "f1" {
+				t.Errorf("Fetch() race referenced as %s/%s, want tab/f1", ref.Source, ref.SourceId)
+			}
+
+			if fetched := !snapshot.PublishedAt.Before(before); fetched != tt.wantFetched {
+				t.Errorf("Fetch() published at %s, want when fetched %t", snapshot.PublishedAt, tt.wantFetched)
+			}
+		})
+	}
+}
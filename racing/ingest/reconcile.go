@@ -0,0 +1,195 @@
+package ingest
+
+import (
+	"context"
+	"expvar"
+	"log"
+	"time"
+
+	"git.neds.sh/matty/entain/racing/db"
+	"git.neds.sh/matty/entain/racing/proto/racing"
+	"github.com/golang/protobuf/ptypes"
+)
+
+// maxReportedDrift caps how many drifted races a reconciliation report
+// describes.
+const maxReportedDrift = 1000
+
+// feedDrift is how many races differed from the feed at the last
+// reconciliation, by kind of drift.
+var feedDrift = expvar.NewMap("racing_feed_drift")
+
+// Reconciler compares the races held locally against a snapshot of the feed
+// they're ingested from, reporting where they've drifted apart, e.g. from
+// missed or failed ingests, or races changed by hand.
+type Reconciler struct {
+	source             string
+	connector          Connector
+	racesRepo          db.RacesRepo
+	reconciliationRepo db.ReconciliationRepo
+	heal               bool
+}
+
+// NewReconciler creates a new reconciler for the feed the connector reads,
+// whose races are referenced by the given source name. With heal set, missing
+// and mismatched races are corrected from the feed.
+func NewReconciler(source string, connector Connector, racesRepo db.RacesRepo, reconciliationRepo db.ReconciliationRepo, heal bool) *Reconciler {
+	return &Reconciler{
+		source:             source,
+		connector:          connector,
+		racesRepo:          racesRepo,
+		reconciliationRepo: reconciliationRepo,
+		heal:               heal,
+	}
+}
+
+// Reconcile fetches the feed once, compares it against the races held
+// locally, and saves a report of the drift.
+//
+// Feeds only publish the races around now, so local races missing from the
+// feed are only reported as extra if they start within the period the feed
+// covers.
+func (r *Reconciler) Reconcile(ctx context.Context) error {
+	snapshot, err := r.connector.Fetch(ctx)
+	if err != nil {
+		return err
+	}
+
+	races, err := r.racesRepo.List(nil)
+	if err != nil {
+		return err
+	}
+
+	local := make(map[string]*racing.Race)
+
+	for _, race := range races {
+		if race.GetExternalRef().GetSource() == r.source {
+			local[race.ExternalRef.SourceId] = race
+		}
+	}
+
+	report := &racing.ReconciliationReport{
+		Source:       r.source,
+		ReconciledAt: ptypes.TimestampNow(),
+		FeedRaces:    int32(len(snapshot.Races)),
+		LocalRaces:   int32(len(local)),
+	}
+
+	var (
+		heal     []*racing.Race
+		earliest time.Time
+		inFeed   = make(map[string]bool, len(snapshot.Races))
+	)
+
+	for _, feedRace := range snapshot.Races {
+		ref := feedRace.GetExternalRef()
+		inFeed[ref.GetSourceId()] = true
+
+		if start := feedRace.AdvertisedStartTime.AsTime(); earliest.IsZero() || start.Before(earliest) {
+			earliest = start
+		}
+
+		race, ok := local[ref.GetSourceId()]
+		if !ok {
+			report.Missing++
+			addDrift(report, &racing.RaceDrift{Kind: racing.RaceDrift_MISSING, ExternalRef: ref})
+			heal = append(heal, feedRace)
+
+			continue
+		}
+
+		if fields := mismatchedFields(race, feedRace); len(fields) > 0 {
+			report.Mismatched++
+			addDrift(report, &racing.RaceDrift{Kind: racing.RaceDrift_MISMATCHED, ExternalRef: ref, RaceId: race.Id, Fields: fields})
+			heal = append(heal, feedRace)
+		}
+	}
+
+	for sourceID, race := range local {
+		if inFeed[sourceID] || race.AdvertisedStartTime.AsTime().Before(earliest) {
+			continue
+		}
+
+		report.Extra++
+		addDrift(report, &racing.RaceDrift{Kind: racing.RaceDrift_EXTRA, ExternalRef: race.ExternalRef, RaceId: race.Id})
+	}
+
+	if r.heal && len(heal) > 0 {
+		outcomes, err := r.racesRepo.UpsertBatch(heal)
+		if err != nil {
+			return err
+		}
+
+		for i, outcome := range outcomes {
+			if outcome.Err != nil {
+				log.Printf("failed healing race %s/%s: %s\n", r.source, heal[i].GetExternalRef().GetSourceId(), outcome.Err)
+
+				continue
+			}
+
+			if outcome.Result != db.UpsertSkipped {
+				report.Healed++
+			}
+		}
+	}
+
+	feedDrift.Set("missing", newInt(report.Missing))
+	feedDrift.Set("extra", newInt(report.Extra))
+	feedDrift.Set("mismatched", newInt(report.Mismatched))
+
+	if report.Missing+report.Extra+report.Mismatched > 0 {
+		log.Printf(
+			"racing feed drift: %d missing, %d extra, %d mismatched, %d healed\n",
+			report.Missing, report.Extra, report.Mismatched, report.Healed,
+		)
+	}
+
+	return r.reconciliationRepo.SaveReport(report)
+}
+
+// addDrift describes a drifted race in the report, until it's described as
+// many as it can.
+func addDrift(report *racing.ReconciliationReport, drift *racing.RaceDrift) {
+	if len(report.Drift) < maxReportedDrift {
+		report.Drift = append(report.Drift, drift)
+	}
+}
+
+// mismatchedFields returns the names of the fields a local race differs from
+// the feed in. Status is only compared for feeds that track it.
+func mismatchedFields(local, feed *racing.Race) []string {
+	var fields []string
+
+	compare := func(name string, equal bool) {
+		if !equal {
+			fields = append(fields, name)
+		}
+	}
+
+	// Start times are stored to the second.
+	localStart := local.AdvertisedStartTime.AsTime().Truncate(time.Second)
+	feedStart := feed.AdvertisedStartTime.AsTime().Truncate(time.Second)
+
+	compare("meeting_id", local.MeetingId == feed.MeetingId)
+	compare("name", local.Name == feed.Name)
+	compare("number", local.Number == feed.Number)
+	compare("visible", local.Visible == feed.Visible)
+	compare("advertised_start_time", localStart.Equal(feedStart))
+	compare("venue_time_zone", local.VenueTimeZone == feed.VenueTimeZone)
+	compare("country", local.Country == feed.Country)
+	compare("distance", local.Distance == feed.Distance)
+	compare("track_condition", local.TrackCondition == feed.TrackCondition)
+	compare("weather", local.Weather == feed.Weather)
+	compare("venue_image_url", local.VenueImageUrl == feed.VenueImageUrl)
+	compare("status", feed.Status == racing.Race_UNSPECIFIED || local.Status == feed.Status)
+
+	return fields
+}
+
+// newInt returns an expvar integer holding n.
+func newInt(n int32) *expvar.Int {
+	v := new(expvar.Int)
+	v.Set(int64(n))
+
+	return v
+}
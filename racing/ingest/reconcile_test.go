@@ -0,0 +1,267 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"git.neds.sh/matty/entain/racing/db"
+	"git.neds.sh/matty/entain/racing/proto/racing"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// trackedRace returns an open race in a test feed that tracks status, starting
+// the given time after the earliest race in it, with any edits made to it.
+func trackedRace(sourceID string, startsAfter time.Duration, edits ...func(race *racing.Race)) *racing.Race {
+	race := &racing.Race{
+		MeetingId:           1,
+		Name:                "Test race",
+		Number:              1,
+		Visible:             true,
+		AdvertisedStartTime: timestamppb.New(feedTestStart.Add(startsAfter)),
+		VenueTimeZone:       "Australia/Melbourne",
+		Country:             "AU",
+		Distance:            1200,
+		ExternalRef:         &racing.ExternalRef{Source: "tab", SourceId: sourceID},
+		Status:              racing.Race_OPEN,
+	}
+
+	for _, edit := range edits {
+		edit(race)
+	}
+
+	return race
+}
+
+// withID sets a race's ID, as held locally.
+func withID(id int64) func(race *racing.Race) {
+	return func(race *racing.Race) { race.Id = id }
+}
+
+// stubRaces is a races repository holding local races, upserting with the
+// given outcomes.
+type stubRaces struct {
+	db.RacesRepo
+	local    []*racing.Race
+	outcomes []db.UpsertOutcome
+	upserted []*racing.Race
+}
+
+func (r *stubRaces) List(*racing.ListRacesRequestFilter) ([]*racing.Race, error) {
+	return r.local, nil
+}
+
+func (r *stubRaces) UpsertBatch(races []*racing.Race) ([]db.UpsertOutcome, error) {
+	r.upserted = append(r.upserted, races...)
+
+	return r.outcomes, nil
+}
+
+// reportRecorder is a reconciliation repository keeping the last report saved.
+type reportRecorder struct {
+	db.ReconciliationRepo
+	report *racing.ReconciliationReport
+}
+
+func (r *reportRecorder) SaveReport(report *racing.ReconciliationReport) error {
+	r.report = report
+
+	return nil
+}
+
+// drifts summarises the drift in a report, e.g. "MISMATCHED a [name]".
+func drifts(report *racing.ReconciliationReport) []string {
+	var summary []string
+
+	for _, drift := range report.Drift {
+		s := fmt.Sprintf("%s %s", drift.Kind, drift.ExternalRef.SourceId)
+		if len(drift.Fields) > 0 {
+			s += fmt.Sprintf(" %v", drift.Fields)
+		}
+
+		summary = append(summary, s)
+	}
+
+	return summary
+}
+
+func TestReconcilerReportsDrift(t *testing.T) {
+	tests := []struct {
+		name       string
+		feed       []*racing.Race
+		local      []*racing.Race
+		wantDrift  []string
+		wantCounts [3]int32 // missing, extra, mismatched
+	}{
+		{
+			"in sync",
+			[]*racing.Race{trackedRace("a", 0)},
+			[]*racing.Race{trackedRace("a", 0, withID(1))},
+			nil,
+			[3]int32{0, 0, 0},
+		},
+		{
+			"missing",
+			[]*racing.Race{trackedRace("a", 0), trackedRace("b", time.Hour)},
+			[]*racing.Race{trackedRace("a", 0, withID(1))},
+			[]string{"MISSING b"},
+			[3]int32{1, 0, 0},
+		},
+		{
+			"mismatched",
+			[]*racing.Race{trackedRace("a", 0, func(r *racing.Race) { r.Name, r.Distance = "Feed name", 1600 })},
+			[]*racing.Race{trackedRace("a", 0, withID(1))},
+			[]string{"MISMATCHED a [name distance]"},
+			[3]int32{0, 0, 1},
+		},
+		{
+			"extra within the feed",
+			[]*racing.Race{trackedRace("a", 0)},
+			[]*racing.Race{trackedRace("a", 0, withID(1)), trackedRace("b", time.Minute, withID(2))},
+			[]string{"EXTRA b"},
+			[3]int32{0, 1, 0},
+		},
+		{
+			"extra before the feed",
+			[]*racing.Race{trackedRace("a", 0)},
+			[]*racing.Race{trackedRace("a", 0, withID(1)), trackedRace("b", -time.Minute, withID(2))},
+			nil,
+			[3]int32{0, 0, 0},
+		},
+		{
+			"other source",
+			[]*racing.Race{trackedRace("a", 0)},
+			[]*racing.Race{
+				trackedRace("a", 0, withID(1)),
+				trackedRace("b", time.Hour, withID(2), func(r *racing.Race) { r.ExternalRef.Source = "other" }),
+				trackedRace("", time.Hour, withID(3), func(r *racing.Race) { r.ExternalRef = nil }),
+			},
+			nil,
+			[3]int32{0, 0, 0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			racesRepo := &stubRaces{local: tt.local}
+			reconciliationRepo := &reportRecorder{}
+
+			reconciler := NewReconciler("tab", staticConnector{snapshot: &Snapshot{Races: tt.feed}}, racesRepo, reconciliationRepo, false)
+
+			if err := reconciler.Reconcile(context.Background()); err != nil {
+				t.Fatalf("Reconcile() error = %s", err)
+			}
+
+			report := reconciliationRepo.report
+
+			if got := drifts(report); !reflect.DeepEqual(got, tt.wantDrift) {
+				t.Errorf("Reconcile() drift = %v, want %v", got, tt.wantDrift)
+			}
+
+			if got := [3]int32{report.Missing, report.Extra, report.Mismatched}; got != tt.wantCounts {
+				t.Errorf("Reconcile() missing, extra, mismatched = %v, want %v", got, tt.wantCounts)
+			}
+
+			if report.Source != "tab" || report.FeedRaces != int32(len(tt.feed)) {
+				t.Errorf("Reconcile() report of %s with %d feed races, want tab with %d", report.Source, report.FeedRaces, len(tt.feed))
+			}
+		})
+	}
+}
+
+func TestReconcilerHeals(t *testing.T) {
+	tests := []struct {
+		name string
+		heal bool
+		// outcomes are what upserting the missing and mismatched races do.
+		outcomes   []db.UpsertOutcome
+		wantHealed int32
+	}{
+		{"report only", false, nil, 0},
+		{"healed", true, []db.UpsertOutcome{{Result: db.UpsertCreated}, {Result: db.UpsertUpdated}}, 2},
+		{"partly healed", true, []db.UpsertOutcome{{Err: db.ErrAlreadyExists}, {Result: db.UpsertUpdated}}, 1},
+		{"already healed", true, []db.UpsertOutcome{{Result: db.UpsertSkipped}, {Result: db.UpsertSkipped}}, 0},
+	}
+
+	missing := trackedRace("missing", 0)
+	mismatched := trackedRace("mismatched", 0, func(r *racing.Race) { r.Name = "Feed name" })
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			racesRepo := &stubRaces{local: []*racing.Race{trackedRace("mismatched", 0, withID(1))}, outcomes: tt.outcomes}
+			reconciliationRepo := &reportRecorder{}
+
+			snapshot := &Snapshot{Races: []*racing.Race{missing, mismatched}}
+			reconciler := NewReconciler("tab", staticConnector{snapshot: snapshot}, racesRepo, reconciliationRepo, tt.heal)
+
+			if err := reconciler.Reconcile(context.Background()); err != nil {
+				t.Fatalf("Reconcile() error = %s", err)
+			}
+
+			var wantUpserted []*racing.Race
+			if tt.heal {
+				wantUpserted = []*racing.Race{missing, mismatched}
+			}
+
+			if !reflect.DeepEqual(racesRepo.upserted, wantUpserted) {
+				t.Errorf("Reconcile() upserted %v, want %v", racesRepo.upserted, wantUpserted)
+			}
+
+			if healed := reconciliationRepo.report.Healed; healed != tt.wantHealed {
+				t.Errorf("Reconcile() healed %d, want %d", healed, tt.wantHealed)
+			}
+		})
+	}
+}
+
+func TestReconcilerFetchFailure(t *testing.T) {
+	errFeed := errors.New("feed unavailable")
+	reconciler := NewReconciler("tab", staticConnector{err: errFeed}, &stubRaces{}, &reportRecorder{}, true)
+
+	if err := reconciler.Reconcile(context.Background()); !errors.Is(err, errFeed) {
+		t.Errorf("Reconcile() error = %v, want %s", err, errFeed)
+	}
+}
+
+func TestMismatchedFields(t *testing.T) {
+	local := trackedRace("a", 0)
+
+	tests := []struct {
+		name       string
+		feed       *racing.Race
+		wantFields []string
+	}{
+		{"same", trackedRace("a", 0), nil},
+		{"sub-second start", trackedRace("a", 500*time.Millisecond), nil},
+		{"start", trackedRace("a", time.Second), []string{"advertised_start_time"}},
+		{"untracked status", trackedRace("a", 0, func(r *racing.Race) { r.Status = racing.Race_UNSPECIFIED }), nil},
+		{"status", trackedRace("a", 0, func(r *racing.Race) { r.Status = racing.Race_CLOSED }), []string{"status"}},
+		{"visibility", trackedRace("a", 0, func(r *racing.Race) { r.Visible = false }), []string{"visible"}},
+		{"conditions", trackedRace("a", 0, func(r *racing.Race) { r.TrackCondition, r.Weather = "Heavy 8", "Rain" }), []string{"track_condition", "weather"}},
+		{"venue", trackedRace("a", 0, func(r *racing.Race) { r.VenueTimeZone, r.Country = "Australia/Sydney", "NZ" }), []string{"venue_time_zone", "country"}},
+		{"meeting", trackedRace("a", 0, func(r *racing.Race) { r.MeetingId, r.Number = 2, 3 }), []string{"meeting_id", "number"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mismatchedFields(local, tt.feed); !reflect.DeepEqual(got, tt.wantFields) {
+				t.Errorf("mismatchedFields() = %v, want %v", got, tt.wantFields)
+			}
+		})
+	}
+}
+
+func TestAddDriftCapsReport(t *testing.T) {
+	report := &racing.ReconciliationReport{}
+
+	for i := 0; i < maxReportedDrift+10; i++ {
+		addDrift(report, &racing.RaceDrift{Kind: racing.RaceDrift_MISSING, ExternalRef: &racing.ExternalRef{}})
+	}
+
+	if len(report.Drift) != maxReportedDrift {
+		t.Errorf("report describes %d races, want %d", len(report.Drift), maxReportedDrift)
+	}
+}
@@ -0,0 +1,51 @@
+package interceptors
+
+import (
+	"context"
+	"strings"
+
+	"git.neds.sh/matty/entain/racing/db"
+	"git.neds.sh/matty/entain/racing/proto/racing"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// brandHeader is the metadata key the brand a request is made through is
+// sent as, e.g. neds. The gateway forwards the X-Brand HTTP header as it.
+const brandHeader = "x-brand"
+
+// Brand returns a unary server interceptor that reads the brand a request is
+// made through out of x-brand metadata, so races are shown or hidden as they
+// are for it. Requests without it see races as they are for every brand.
+func Brand() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := withBrand(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// withBrand returns a copy of ctx made through the brand sent with the
+// request, if any.
+func withBrand(ctx context.Context) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	values := md.Get(brandHeader)
+	if len(values) == 0 || values[0] == "" {
+		return ctx, nil
+	}
+
+	// Brands are matched as races are shown or hidden for them, once lower
+	// cased.
+	brand := strings.ToLower(values[0])
+	if !racing.BrandPattern.MatchString(brand) {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid %s: must be 1 to 32 letters, digits or hyphens, e.g. neds", brandHeader)
+	}
+
+	return db.ContextWithBrand(ctx, brand), nil
+}
@@ -0,0 +1,89 @@
+package interceptors
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"log"
+
+	"git.neds.sh/matty/entain/racing/db"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// errorsMapped counts the errors given a status code by the error mapping
+// interceptors, by code.
+var errorsMapped = expvar.NewMap("errors_mapped_total")
+
+// Errors returns a unary server interceptor that gives errors returned
+// without a status code the code they should have, rather than leaving them
+// to surface as Unknown. It belongs first in the chain, to see the errors of
+// every interceptor after it.
+func Errors() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		resp, err := handler(ctx, req)
+		if err != nil {
+			return nil, toStatus(info.FullMethod, err)
+		}
+
+		return resp, nil
+	}
+}
+
+// StreamErrors returns a stream server interceptor that gives errors returned
+// without a status code the code they should have.
+func StreamErrors() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := handler(srv, ss); err != nil {
+			return toStatus(info.FullMethod, err)
+		}
+
+		return nil
+	}
+}
+
+// toStatus returns err as a status error, mapping the errors exported by the
+// repositories and context to their codes. Any other error is an Internal
+// error, logged rather than returned, as its message may give away how the
+// service works.
+func toStatus(method string, err error) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+
+	code := codeOf(err)
+	errorsMapped.Add(code.String(), 1)
+
+	if code == codes.Internal {
+		log.Printf("%s failed: %s\n", method, err)
+
+		return status.Error(codes.Internal, "internal error")
+	}
+
+	return status.Error(code, err.Error())
+}
+
+// codeOf returns the status code an error should have.
+func codeOf(err error) codes.Code {
+	switch {
+	case errors.Is(err, context.Canceled):
+		return codes.Canceled
+	case errors.Is(err, context.DeadlineExceeded):
+		return codes.DeadlineExceeded
+	case errors.Is(err, db.ErrNotFound):
+		return codes.NotFound
+	case errors.Is(err, db.ErrAlreadyExists):
+		return codes.AlreadyExists
+	case errors.Is(err, db.ErrInvalidTransition), errors.Is(err, db.ErrEventLogDisabled):
+		return codes.FailedPrecondition
+	case errors.Is(err, db.ErrKeyReused), errors.Is(err, db.ErrMissingExternalRef):
+		return codes.InvalidArgument
+	case errors.Is(err, db.ErrKeyInUse):
+		return codes.Aborted
+	case db.IsUnavailable(err):
+		return codes.Unavailable
+	default:
+		return codes.Internal
+	}
+}
@@ -0,0 +1,72 @@
+package interceptors
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+
+	"git.neds.sh/matty/entain/racing/db"
+	"github.com/mattn/go-sqlite3"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestErrorsMapsCodes(t *testing.T) {
+	tests := []struct {
+		err  error
+		want codes.Code
+	}{
+		{status.Error(codes.InvalidArgument, "invalid filter"), codes.InvalidArgument},
+		{context.Canceled, codes.Canceled},
+		{fmt.Errorf("querying races: %w", context.DeadlineExceeded), codes.DeadlineExceeded},
+		{db.ErrNotFound, codes.NotFound},
+		{fmt.Errorf("race 7: %w", db.ErrNotFound), codes.NotFound},
+		{db.ErrAlreadyExists, codes.AlreadyExists},
+		{fmt.Errorf("%w from FINAL to OPEN", db.ErrInvalidTransition), codes.FailedPrecondition},
+		{db.ErrEventLogDisabled, codes.FailedPrecondition},
+		{db.ErrKeyReused, codes.InvalidArgument},
+		{db.ErrMissingExternalRef, codes.InvalidArgument},
+		{db.ErrKeyInUse, codes.Aborted},
+		{sql.ErrConnDone, codes.Unavailable},
+		{sqlite3.Error{Code: sqlite3.ErrBusy}, codes.Unavailable},
+		{sqlite3.Error{Code: sqlite3.ErrLocked}, codes.Unavailable},
+		{sqlite3.Error{Code: sqlite3.ErrCorrupt}, codes.Internal},
+		{errors.New("no such column: foo"), codes.Internal},
+	}
+
+	interceptor := Errors()
+	info := &grpc.UnaryServerInfo{FullMethod: "/racing.Racing/ListRaces"}
+
+	for _, test := range tests {
+		_, err := interceptor(context.Background(), nil, info, func(context.Context, interface{}) (interface{}, error) {
+			return nil, test.err
+		})
+
+		if got := status.Code(err); got != test.want {
+			t.Errorf("error %q: code = %s, want %s", test.err, got, test.want)
+		}
+	}
+}
+
+func TestErrorsHidesInternalErrors(t *testing.T) {
+	_, err := Errors()(context.Background(), nil, &grpc.UnaryServerInfo{}, func(context.Context, interface{}) (interface{}, error) {
+		return nil, errors.New("no such table: races")
+	})
+
+	if got, want := status.Convert(err).Message(), "internal error"; got != want {
+		t.Errorf("message = %q, want %q", got, want)
+	}
+}
+
+func TestErrorsPassesResponsesThrough(t *testing.T) {
+	resp, err := Errors()(context.Background(), nil, &grpc.UnaryServerInfo{}, func(context.Context, interface{}) (interface{}, error) {
+		return "ok", nil
+	})
+
+	if resp != "ok" || err != nil {
+		t.Errorf("got %v, %v, want ok, nil", resp, err)
+	}
+}
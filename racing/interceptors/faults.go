@@ -0,0 +1,175 @@
+package interceptors
+
+import (
+	"context"
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// anyMethod keys the fault injected into every method without its own.
+const anyMethod = "*"
+
+// maxDropWait is the longest a dropped response is held for, when the client
+// set no deadline.
+const maxDropWait = time.Minute
+
+// faultsInjected counts the faults injected, by kind.
+var faultsInjected = expvar.NewMap("faults_injected_total")
+
+// Fault describes the faults injected into calls to a method.
+type Fault struct {
+	// Latency delays every call.
+	Latency time.Duration
+	// ErrorRate is the proportion of calls, from 0 to 1, failed with Code
+	// rather than handled.
+	ErrorRate float64
+	// Code is the code calls are failed with, Unavailable by default.
+	Code codes.Code
+	// DropRate is the proportion of unary calls, from 0 to 1, that are
+	// handled but never answered, as though the response was lost, so the
+	// client times out.
+	DropRate float64
+}
+
+// faultJSON is how a fault is written in JSON, with its latency as a
+// duration string, e.g. "250ms".
+type faultJSON struct {
+	Latency   string      `json:"latency"`
+	ErrorRate float64     `json:"error_rate"`
+	Code      *codes.Code `json:"code"`
+	DropRate  float64     `json:"drop_rate"`
+}
+
+// ParseFaults parses faults to inject from JSON, keyed by full method name,
+// or "*" for every other method, e.g.
+//
+//	{"/racing.Racing/ListRaces": {"latency": "250ms", "error_rate": 0.1, "code": "UNAVAILABLE", "drop_rate": 0.05}}
+func ParseFaults(spec string) (map[string]Fault, error) {
+	var parsed map[string]faultJSON
+	if err := json.Unmarshal([]byte(spec), &parsed); err != nil {
+		return nil, err
+	}
+
+	faults := make(map[string]Fault, len(parsed))
+
+	for method, f := range parsed {
+		fault := Fault{ErrorRate: f.ErrorRate, Code: codes.Unavailable, DropRate: f.DropRate}
+
+		if f.Latency != "" {
+			latency, err := time.ParseDuration(f.Latency)
+			if err != nil {
+				return nil, fmt.Errorf("invalid latency for %s: %w", method, err)
+			}
+
+			fault.Latency = latency
+		}
+
+		if f.Code != nil {
+			fault.Code = *f.Code
+		}
+
+		if fault.ErrorRate < 0 || fault.ErrorRate > 1 || fault.DropRate < 0 || fault.DropRate > 1 {
+			return nil, fmt.Errorf("error and drop rates for %s must be between 0 and 1", method)
+		}
+
+		if fault.Code == codes.OK {
+			return nil, fmt.Errorf("can't fail calls to %s with OK", method)
+		}
+
+		faults[method] = fault
+	}
+
+	return faults, nil
+}
+
+// faultFor returns the fault to inject into calls to a method, if any.
+func faultFor(faults map[string]Fault, method string) (Fault, bool) {
+	if fault, ok := faults[method]; ok {
+		return fault, true
+	}
+
+	fault, ok := faults[anyMethod]
+
+	return fault, ok
+}
+
+// Faults returns a unary server interceptor injecting latency, errors and
+// dropped responses into calls, for testing how clients such as the gateway
+// cope with a struggling service. It must never be enabled in production.
+func Faults(faults map[string]Fault) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		fault, ok := faultFor(faults, info.FullMethod)
+		if !ok {
+			return handler(ctx, req)
+		}
+
+		if err := fault.inject(ctx); err != nil {
+			return nil, err
+		}
+
+		resp, err := handler(ctx, req)
+
+		if rand.Float64() < fault.DropRate {
+			faultsInjected.Add("drop", 1)
+
+			return nil, drop(ctx)
+		}
+
+		return resp, err
+	}
+}
+
+// StreamFaults returns a stream server interceptor injecting latency and
+// errors into calls. Streamed responses aren't dropped.
+func StreamFaults(faults map[string]Fault) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		fault, ok := faultFor(faults, info.FullMethod)
+		if !ok {
+			return handler(srv, ss)
+		}
+
+		if err := fault.inject(ss.Context()); err != nil {
+			return err
+		}
+
+		return handler(srv, ss)
+	}
+}
+
+// inject delays the call, then returns an error if it should fail.
+func (f Fault) inject(ctx context.Context) error {
+	if f.Latency > 0 {
+		faultsInjected.Add("latency", 1)
+
+		select {
+		case <-ctx.Done():
+			return status.Error(codes.DeadlineExceeded, ctx.Err().Error())
+		case <-time.After(f.Latency):
+		}
+	}
+
+	if rand.Float64() < f.ErrorRate {
+		faultsInjected.Add("error", 1)
+
+		return status.Errorf(f.Code, "injected fault")
+	}
+
+	return nil
+}
+
+// drop holds a call that has been handled until the client gives up on it.
+func drop(ctx context.Context) error {
+	select {
+	case <-ctx.Done():
+		return status.Error(codes.DeadlineExceeded, ctx.Err().Error())
+	case <-time.After(maxDropWait):
+		return status.Error(codes.Unavailable, "injected fault: response dropped")
+	}
+}
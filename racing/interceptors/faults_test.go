@@ -0,0 +1,171 @@
+package interceptors
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestParseFaults(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		want    map[string]Fault
+		wantErr bool
+	}{
+		{
+			name: "empty",
+			spec: `{}`,
+			want: map[string]Fault{},
+		},
+		{
+			name: "every field",
+			spec: `{"/racing.Racing/ListRaces": {"latency": "250ms", "error_rate": 0.1, "code": "RESOURCE_EXHAUSTED", "drop_rate": 0.05}}`,
+			want: map[string]Fault{
+				"/racing.Racing/ListRaces": {Latency: 250 * time.Millisecond, ErrorRate: 0.1, Code: codes.ResourceExhausted, DropRate: 0.05},
+			},
+		},
+		{
+			name: "defaults to unavailable",
+			spec: `{"*": {"error_rate": 1}}`,
+			want: map[string]Fault{"*": {ErrorRate: 1, Code: codes.Unavailable}},
+		},
+		{
+			name: "numeric code",
+			spec: `{"*": {"error_rate": 0.5, "code": 13}}`,
+			want: map[string]Fault{"*": {ErrorRate: 0.5, Code: codes.Internal}},
+		},
+		{
+			name: "methods",
+			spec: `{"*": {"latency": "1s"}, "/racing.Racing/GetRace": {"drop_rate": 1}}`,
+			want: map[string]Fault{
+				"*":                      {Latency: time.Second, Code: codes.Unavailable},
+				"/racing.Racing/GetRace": {Code: codes.Unavailable, DropRate: 1},
+			},
+		},
+		{name: "malformed", spec: `{"*": `, wantErr: true},
+		{name: "not an object", spec: `["*"]`, wantErr: true},
+		{name: "invalid latency", spec: `{"*": {"latency": "soon"}}`, wantErr: true},
+		{name: "latency without unit", spec: `{"*": {"latency": "250"}}`, wantErr: true},
+		{name: "unknown code", spec: `{"*": {"code": "BROKEN"}}`, wantErr: true},
+		{name: "ok code", spec: `{"*": {"error_rate": 1, "code": "OK"}}`, wantErr: true},
+		{name: "error rate above 1", spec: `{"*": {"error_rate": 1.5}}`, wantErr: true},
+		{name: "negative error rate", spec: `{"*": {"error_rate": -0.1}}`, wantErr: true},
+		{name: "drop rate above 1", spec: `{"*": {"drop_rate": 2}}`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseFaults(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseFaults() error = %v, want error %t", err, tt.wantErr)
+			}
+
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseFaults() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFaults(t *testing.T) {
+	tests := []struct {
+		name    string
+		faults  map[string]Fault
+		method  string
+		timeout time.Duration
+		// wantHandled is whether the call reaches the handler.
+		wantHandled bool
+		wantCode    codes.Code
+	}{
+		{
+			name:        "no faults",
+			faults:      map[string]Fault{},
+			method:      "/racing.Racing/ListRaces",
+			wantHandled: true,
+		},
+		{
+			name:        "other method",
+			faults:      map[string]Fault{"/racing.Racing/GetRace": {ErrorRate: 1, Code: codes.Unavailable}},
+			method:      "/racing.Racing/ListRaces",
+			wantHandled: true,
+		},
+		{
+			name:     "method error",
+			faults:   map[string]Fault{"/racing.Racing/ListRaces": {ErrorRate: 1, Code: codes.Internal}},
+			method:   "/racing.Racing/ListRaces",
+			wantCode: codes.Internal,
+		},
+		{
+			name: "method overrides every method",
+			faults: map[string]Fault{
+				"*":                        {ErrorRate: 1, Code: codes.Unavailable},
+				"/racing.Racing/ListRaces": {Code: codes.Unavailable},
+			},
+			method:      "/racing.Racing/ListRaces",
+			wantHandled: true,
+		},
+		{
+			name:     "every method",
+			faults:   map[string]Fault{"*": {ErrorRate: 1, Code: codes.Unavailable}},
+			method:   "/racing.Racing/ListRaces",
+			wantCode: codes.Unavailable,
+		},
+		{
+			name:        "latency",
+			faults:      map[string]Fault{"*": {Latency: time.Millisecond}},
+			method:      "/racing.Racing/ListRaces",
+			timeout:     time.Second,
+			wantHandled: true,
+		},
+		{
+			name:     "latency past the deadline",
+			faults:   map[string]Fault{"*": {Latency: time.Minute}},
+			method:   "/racing.Racing/ListRaces",
+			timeout:  10 * time.Millisecond,
+			wantCode: codes.DeadlineExceeded,
+		},
+		{
+			name:        "dropped",
+			faults:      map[string]Fault{"*": {DropRate: 1}},
+			method:      "/racing.Racing/ListRaces",
+			timeout:     10 * time.Millisecond,
+			wantHandled: true,
+			wantCode:    codes.DeadlineExceeded,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.timeout)
+				defer cancel()
+			}
+
+			var handled bool
+
+			resp, err := Faults(tt.faults)(ctx, nil, &grpc.UnaryServerInfo{FullMethod: tt.method}, func(context.Context, interface{}) (interface{}, error) {
+				handled = true
+				return "response", nil
+			})
+			if code := status.Code(err); code != tt.wantCode {
+				t.Fatalf("code = %s, want %s", code, tt.wantCode)
+			}
+
+			if handled != tt.wantHandled {
+				t.Errorf("handled = %t, want %t", handled, tt.wantHandled)
+			}
+
+			if wantResp := tt.wantCode == codes.OK; (resp != nil) != wantResp {
+				t.Errorf("response = %v, want response %t", resp, wantResp)
+			}
+		})
+	}
+}
@@ -0,0 +1,30 @@
+package interceptors
+
+import (
+	"context"
+
+	"git.neds.sh/matty/entain/racing/features"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// rolloutKeyHeader is the metadata key clients send a stable ID as, e.g. a
+// user or device ID, so they get the same features on every request while a
+// feature is rolled out gradually.
+const rolloutKeyHeader = "x-rollout-key"
+
+// Features returns a unary server interceptor deciding which features are on
+// for each request from the feature flags, once, so a flag changing part way
+// through a request doesn't change how it's handled.
+func Features(flags *features.Flags) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+
+		var rolloutKey string
+		if values := md.Get(rolloutKeyHeader); len(values) > 0 {
+			rolloutKey = values[0]
+		}
+
+		return handler(features.NewContext(ctx, flags.Evaluate(rolloutKey)), req)
+	}
+}
@@ -0,0 +1,389 @@
+package interceptors
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"git.neds.sh/matty/entain/racing/db"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+const (
+	// idempotencyKeyHeader is the metadata key clients send idempotency keys
+	// in. The gateway forwards the Idempotency-Key HTTP header as it.
+	idempotencyKeyHeader = "idempotency-key"
+	// replayedHeader is set on responses replayed for a retried request.
+	replayedHeader = "idempotent-replayed"
+	// maxIdempotencyKeyLen caps the length of idempotency keys.
+	maxIdempotencyKeyLen = 255
+	// idempotencyLease is how long a key is claimed for at a time while its
+	// request is handled. The claim is renewed until the request has been
+	// handled, so a key whose request was abandoned, e.g. by the service
+	// stopping, can be retried soon after, rather than once the ttl passes.
+	idempotencyLease = 30 * time.Second
+)
+
+// Idempotency returns a unary server interceptor that makes the given methods
+// idempotent for requests with an idempotency key. The response to the first
+// request with a key is kept until the ttl passes, and returned for retries
+// of the request, rather than handling it again. Keys are scoped to the brand
+// a request is made through and the user it's authenticated as, so it must
+// run after Brand and User.
+func Idempotency(repo db.IdempotencyRepo, ttl time.Duration, methods ...string) grpc.UnaryServerInterceptor {
+	idempotent := methodSet(methods)
+
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		key, err := idempotencyKey(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		if key == "" || !idempotent[info.FullMethod] {
+			return handler(ctx, req)
+		}
+
+		key = scopedKey(ctx, key)
+
+		fingerprint, err := fingerprint(req)
+		if err != nil {
+			return nil, err
+		}
+
+		replayed, err := reserve(repo, key, info.FullMethod, fingerprint)
+		if err != nil {
+			return nil, err
+		}
+
+		if replayed != nil {
+			_ = grpc.SetHeader(ctx, metadata.Pairs(replayedHeader, "true"))
+
+			return replayed, nil
+		}
+
+		stop := hold(repo, key, info.FullMethod)
+		resp, err := handler(ctx, req)
+		stop()
+
+		complete(repo, key, info.FullMethod, nil, resp, err, ttl)
+
+		return resp, err
+	}
+}
+
+// StreamIdempotency returns a stream server interceptor that makes the given
+// client streaming methods idempotent, like Idempotency. As the requests
+// aren't known up front, they're fingerprinted as they're received, and a
+// retry's are read in full before its response is replayed.
+func StreamIdempotency(repo db.IdempotencyRepo, ttl time.Duration, methods ...string) grpc.StreamServerInterceptor {
+	idempotent := methodSet(methods)
+
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		key, err := idempotencyKey(ss.Context())
+		if err != nil {
+			return err
+		}
+
+		if key == "" || !idempotent[info.FullMethod] {
+			return handler(srv, ss)
+		}
+
+		key = scopedKey(ss.Context(), key)
+
+		replayed, err := reserve(repo, key, info.FullMethod, nil)
+		if err != nil {
+			return err
+		}
+
+		if replayed != nil {
+			return replay(repo, ss, key, info.FullMethod)
+		}
+
+		recording := &recordingStream{ServerStream: ss, fingerprint: sha256.New()}
+
+		stop := hold(repo, key, info.FullMethod)
+		err = handler(srv, recording)
+		stop()
+
+		complete(repo, key, info.FullMethod, recording.fingerprint.Sum(nil), recording.resp, err, ttl)
+
+		return err
+	}
+}
+
+// replay returns the response to the request a retried client stream repeats,
+// once it has been read in full and found to be the same request.
+func replay(repo db.IdempotencyRepo, ss grpc.ServerStream, key, method string) error {
+	msgType, err := requestType(method)
+	if err != nil {
+		return err
+	}
+
+	fingerprint := sha256.New()
+
+	for {
+		req := msgType.New().Interface()
+
+		err := ss.RecvMsg(req)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := writeFingerprint(fingerprint, req); err != nil {
+			return err
+		}
+	}
+
+	replayed, err := reserve(repo, key, method, fingerprint.Sum(nil))
+	if err != nil {
+		return err
+	}
+
+	// The key expired while the retry was read, so the retry claimed it, but
+	// has nothing left to handle.
+	if replayed == nil {
+		if err := repo.Release(key, method); err != nil {
+			log.Printf("failed releasing idempotency key %q: %s\n", key, err)
+		}
+
+		return status.Errorf(codes.Aborted, "%s expired while retrying", idempotencyKeyHeader)
+	}
+
+	_ = ss.SetHeader(metadata.Pairs(replayedHeader, "true"))
+
+	return ss.SendMsg(replayed)
+}
+
+// recordingStream records the response sent on a client streaming call, and
+// fingerprints the requests received.
+type recordingStream struct {
+	grpc.ServerStream
+	resp        interface{}
+	fingerprint hash.Hash
+}
+
+func (s *recordingStream) RecvMsg(m interface{}) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+
+	return writeFingerprint(s.fingerprint, m)
+}
+
+func (s *recordingStream) SendMsg(m interface{}) error {
+	s.resp = m
+
+	return s.ServerStream.SendMsg(m)
+}
+
+// scopedKey scopes an idempotency key to the brand a request is made through
+// and the user it's authenticated as.
+func scopedKey(ctx context.Context, key string) string {
+	// Keys are kept per user, so a user is never replayed responses made for
+	// another, e.g. to watch a race. User IDs can hold anything, so are
+	// prefixed with their length to keep them apart from the key.
+	if userID, ok := db.UserFromContext(ctx); ok {
+		key = strconv.Itoa(len(userID)) + ":" + userID + "/" + key
+	}
+
+	// Keys are kept per brand, so a brand's clients are never replayed
+	// responses made for another's.
+	if brand, ok := db.BrandFromContext(ctx); ok {
+		key = brand + "/" + key
+	}
+
+	return key
+}
+
+// requestType returns the type of the requests a method takes.
+func requestType(method string) (protoreflect.MessageType, error) {
+	name := protoreflect.FullName(strings.Replace(strings.TrimPrefix(method, "/"), "/", ".", 1))
+
+	desc, err := protoregistry.GlobalFiles.FindDescriptorByName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	methodDesc, ok := desc.(protoreflect.MethodDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a method", method)
+	}
+
+	return protoregistry.GlobalTypes.FindMessageByName(methodDesc.Input().FullName())
+}
+
+// methodSet indexes method names.
+func methodSet(methods []string) map[string]bool {
+	set := make(map[string]bool, len(methods))
+	for _, method := range methods {
+		set[method] = true
+	}
+
+	return set
+}
+
+// idempotencyKey returns the idempotency key sent with a request, if any.
+func idempotencyKey(ctx context.Context) (string, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	values := md.Get(idempotencyKeyHeader)
+	if len(values) == 0 {
+		return "", nil
+	}
+
+	if len(values[0]) > maxIdempotencyKeyLen {
+		return "", status.Errorf(codes.InvalidArgument, "invalid %s: value length must be at most %d bytes", idempotencyKeyHeader, maxIdempotencyKeyLen)
+	}
+
+	return values[0], nil
+}
+
+// fingerprint identifies a request by a hash of its contents.
+func fingerprint(req interface{}) ([]byte, error) {
+	msg, ok := req.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("request %T is not a protobuf message", req)
+	}
+
+	encoded, err := proto.MarshalOptions{Deterministic: true}.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256(encoded)
+
+	return sum[:], nil
+}
+
+// writeFingerprint adds a request received on a stream to the stream's
+// fingerprint. Each request is prefixed with its length, so the same requests
+// split differently fingerprint differently.
+func writeFingerprint(fingerprint hash.Hash, req interface{}) error {
+	msg, ok := req.(proto.Message)
+	if !ok {
+		return fmt.Errorf("request %T is not a protobuf message", req)
+	}
+
+	encoded, err := proto.MarshalOptions{Deterministic: true}.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	fingerprint.Write(protowire.AppendVarint(nil, uint64(len(encoded))))
+	fingerprint.Write(encoded)
+
+	return nil
+}
+
+// reserve claims an idempotency key for a lease, returning the response to
+// replay if the request has already been handled.
+func reserve(repo db.IdempotencyRepo, key, method string, fingerprint []byte) (proto.Message, error) {
+	stored, err := repo.Reserve(key, method, fingerprint, time.Now().Add(idempotencyLease))
+	switch {
+	case errors.Is(err, db.ErrKeyReused):
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, db.ErrKeyInUse):
+		return nil, status.Error(codes.Aborted, err.Error())
+	case err != nil:
+		return nil, err
+	case stored == nil:
+		return nil, nil
+	}
+
+	var packed anypb.Any
+	if err := proto.Unmarshal(stored, &packed); err != nil {
+		return nil, err
+	}
+
+	return packed.UnmarshalNew()
+}
+
+// hold renews the lease on a claimed idempotency key until the returned func
+// is called, once its request has been handled.
+func hold(repo db.IdempotencyRepo, key, method string) func() {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+
+	go func() {
+		defer close(stopped)
+
+		ticker := time.NewTicker(idempotencyLease / 3)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := repo.Extend(key, method, time.Now().Add(idempotencyLease)); err != nil {
+					log.Printf("failed extending idempotency key %q: %s\n", key, err)
+				}
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// complete records the response to a request, and the fingerprint of a
+// request that wasn't known up front, keeping them until the ttl passes, or
+// releases its key if it failed so it can be retried. A response that can't
+// be recorded releases the key too, as holding it would block retries until
+// it expires.
+func complete(repo db.IdempotencyRepo, key, method string, fingerprint []byte, resp interface{}, handlerErr error, ttl time.Duration) {
+	err := handlerErr
+
+	if err == nil {
+		err = record(repo, key, method, fingerprint, resp, ttl)
+		if err != nil {
+			log.Printf("failed recording response for idempotency key %q: %s\n", key, err)
+		}
+	}
+
+	if err != nil {
+		if err := repo.Release(key, method); err != nil {
+			log.Printf("failed releasing idempotency key %q: %s\n", key, err)
+		}
+	}
+}
+
+// record stores the response to the request a key was claimed for.
+func record(repo db.IdempotencyRepo, key, method string, fingerprint []byte, resp interface{}, ttl time.Duration) error {
+	msg, ok := resp.(proto.Message)
+	if !ok {
+		return fmt.Errorf("response %T is not a protobuf message", resp)
+	}
+
+	packed, err := anypb.New(msg)
+	if err != nil {
+		return err
+	}
+
+	encoded, err := proto.Marshal(packed)
+	if err != nil {
+		return err
+	}
+
+	return repo.Complete(key, method, fingerprint, encoded, time.Now().Add(ttl))
+}
@@ -0,0 +1,180 @@
+package interceptors
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"testing"
+	"time"
+
+	"git.neds.sh/matty/entain/racing/db"
+	"git.neds.sh/matty/entain/racing/mocks"
+	"git.neds.sh/matty/entain/racing/proto/racing"
+	"github.com/golang/mock/gomock"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestIdempotencyScopesKeys(t *testing.T) {
+	const method = "/racing.Racing/WatchRace"
+
+	tests := []struct {
+		name    string
+		brand   string
+		userID  string
+		wantKey string
+	}{
+		{name: "no scope", wantKey: "retry"},
+		{name: "brand", brand: "neds", wantKey: "neds/retry"},
+		{name: "user", userID: "punter", wantKey: "6:punter/retry"},
+		{name: "brand and user", brand: "neds", userID: "punter", wantKey: "neds/6:punter/retry"},
+		// A user ID holding a slash stays apart from the key.
+		{name: "user with a slash", userID: "a/b", wantKey: "3:a/b/retry"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := mocks.NewMockIdempotencyRepo(gomock.NewController(t))
+
+			repo.EXPECT().Reserve(tt.wantKey, method, gomock.Any(), gomock.Any()).Return(nil, nil)
+			repo.EXPECT().Complete(tt.wantKey, method, nil, gomock.Any(), gomock.Any()).Return(nil)
+
+			ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(idempotencyKeyHeader, "retry"))
+			if tt.brand != "" {
+				ctx = db.ContextWithBrand(ctx, tt.brand)
+			}
+			if tt.userID != "" {
+				ctx = db.ContextWithUser(ctx, tt.userID)
+			}
+
+			_, err := Idempotency(repo, time.Hour, method)(ctx, &racing.WatchRaceRequest{RaceId: 1}, &grpc.UnaryServerInfo{FullMethod: method}, func(context.Context, interface{}) (interface{}, error) {
+				return &racing.WatchedRace{RaceId: 1}, nil
+			})
+			if err != nil {
+				t.Fatalf("Idempotency() error = %s", err)
+			}
+		})
+	}
+}
+
+// ingestStream is a client stream of races to ingest, recording the response
+// and headers sent.
+type ingestStream struct {
+	grpc.ServerStream
+	ctx    context.Context
+	races  []*racing.Race
+	resp   interface{}
+	header metadata.MD
+}
+
+func (s *ingestStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *ingestStream) RecvMsg(m interface{}) error {
+	if len(s.races) == 0 {
+		return io.EOF
+	}
+
+	m.(*racing.Race).Name = s.races[0].Name
+	s.races = s.races[1:]
+
+	return nil
+}
+
+func (s *ingestStream) SendMsg(m interface{}) error {
+	s.resp = m
+
+	return nil
+}
+
+func (s *ingestStream) SetHeader(md metadata.MD) error {
+	s.header = metadata.Join(s.header, md)
+
+	return nil
+}
+
+func TestStreamIdempotency(t *testing.T) {
+	const method = "/racing.Racing/IngestRaces"
+
+	testDB, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("opening test database: %s", err)
+	}
+
+	// Every connection to :memory: gets its own database, so share one.
+	testDB.SetMaxOpenConns(1)
+
+	t.Cleanup(func() { testDB.Close() })
+
+	repo := db.NewIdempotencyRepo(testDB)
+	if err := repo.Init(); err != nil {
+		t.Fatalf("initialising idempotency repo: %s", err)
+	}
+
+	interceptor := StreamIdempotency(repo, time.Hour, method)
+
+	var handled int
+
+	// ingest streams races named for the user given, returning how many
+	// races were handled and whether the response was replayed.
+	ingest := func(userID string, names ...string) (int32, bool, error) {
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(idempotencyKeyHeader, "retry"))
+		ctx = db.ContextWithUser(ctx, userID)
+
+		stream := &ingestStream{ctx: ctx}
+		for _, name := range names {
+			stream.races = append(stream.races, &racing.Race{Name: name})
+		}
+
+		err := interceptor(nil, stream, &grpc.StreamServerInfo{FullMethod: method, IsClientStream: true}, func(_ interface{}, ss grpc.ServerStream) error {
+			handled++
+
+			var created int32
+			for {
+				var race racing.Race
+				if err := ss.RecvMsg(&race); err == io.EOF {
+					break
+				}
+
+				created++
+			}
+
+			return ss.SendMsg(&racing.IngestRacesResponse{Created: created})
+		})
+
+		resp, _ := stream.resp.(*racing.IngestRacesResponse)
+
+		return resp.GetCreated(), len(stream.header.Get(replayedHeader)) > 0, err
+	}
+
+	tests := []struct {
+		name         string
+		userID       string
+		races        []string
+		wantCode     codes.Code
+		wantCreated  int32
+		wantReplayed bool
+		wantHandled  int
+	}{
+		{name: "first", userID: "punter", races: []string{"a", "b"}, wantCreated: 2, wantHandled: 1},
+		{name: "retry", userID: "punter", races: []string{"a", "b"}, wantCreated: 2, wantReplayed: true, wantHandled: 1},
+		{name: "different races", userID: "punter", races: []string{"a", "c"}, wantCode: codes.InvalidArgument, wantHandled: 1},
+		{name: "fewer races", userID: "punter", races: []string{"a"}, wantCode: codes.InvalidArgument, wantHandled: 1},
+		{name: "another user", userID: "other", races: []string{"a", "c", "d"}, wantCreated: 3, wantHandled: 2},
+	}
+
+	for _, tt := range tests {
+		created, replayed, err := ingest(tt.userID, tt.races...)
+		if code := status.Code(err); code != tt.wantCode {
+			t.Fatalf("%s: code = %s, want %s", tt.name, code, tt.wantCode)
+		}
+
+		if created != tt.wantCreated || replayed != tt.wantReplayed || handled != tt.wantHandled {
+			t.Errorf("%s: created %d, replayed %t after handling %d streams, want %d, %t after %d",
+				tt.name, created, replayed, handled, tt.wantCreated, tt.wantReplayed, tt.wantHandled)
+		}
+	}
+}
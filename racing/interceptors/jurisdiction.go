@@ -0,0 +1,52 @@
+package interceptors
+
+import (
+	"context"
+	"strings"
+
+	"git.neds.sh/matty/entain/racing/db"
+	"git.neds.sh/matty/entain/racing/proto/racing"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// jurisdictionHeader is the metadata key the jurisdiction a request is made
+// from is sent as, e.g. NSW. The gateway forwards the X-Jurisdiction HTTP
+// header as it.
+const jurisdictionHeader = "x-jurisdiction"
+
+// Jurisdiction returns a unary server interceptor that reads the jurisdiction
+// a request is made from out of x-jurisdiction metadata, so races restricted
+// in it aren't shown. Requests without it are shown every race.
+func Jurisdiction() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := withJurisdiction(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// withJurisdiction returns a copy of ctx made from the jurisdiction sent with
+// the request, if any.
+func withJurisdiction(ctx context.Context) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	values := md.Get(jurisdictionHeader)
+	if len(values) == 0 || values[0] == "" {
+		return ctx, nil
+	}
+
+	// Jurisdictions are matched as races are restricted in them, once upper
+	// cased.
+	jurisdiction := strings.ToUpper(values[0])
+	if !racing.JurisdictionPattern.MatchString(jurisdiction) {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid %s: must be 1 to 16 letters, digits or hyphens, e.g. NSW", jurisdictionHeader)
+	}
+
+	return db.ContextWithJurisdiction(ctx, jurisdiction), nil
+}
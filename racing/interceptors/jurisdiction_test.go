@@ -0,0 +1,50 @@
+package interceptors
+
+import (
+	"context"
+	"testing"
+
+	"git.neds.sh/matty/entain/racing/db"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestJurisdiction(t *testing.T) {
+	tests := []struct {
+		name     string
+		md       metadata.MD
+		want     string
+		wantOK   bool
+		wantCode codes.Code
+	}{
+		{name: "no header"},
+		{name: "empty header", md: metadata.Pairs(jurisdictionHeader, "")},
+		{name: "header", md: metadata.Pairs(jurisdictionHeader, "NSW"), want: "NSW", wantOK: true},
+		{name: "lowercase header", md: metadata.Pairs(jurisdictionHeader, "sa"), want: "SA", wantOK: true},
+		{name: "invalid header", md: metadata.Pairs(jurisdictionHeader, "New South Wales"), wantCode: codes.InvalidArgument},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := metadata.NewIncomingContext(context.Background(), tt.md)
+
+			var (
+				got   string
+				gotOK bool
+			)
+			_, err := Jurisdiction()(ctx, nil, &grpc.UnaryServerInfo{}, func(ctx context.Context, _ interface{}) (interface{}, error) {
+				got, gotOK = db.JurisdictionFromContext(ctx)
+				return nil, nil
+			})
+			if code := status.Code(err); code != tt.wantCode {
+				t.Fatalf("code = %s, want %s", code, tt.wantCode)
+			}
+
+			if got != tt.want || gotOK != tt.wantOK {
+				t.Errorf("jurisdiction = %q, %t, want %q, %t", got, gotOK, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package interceptors
+
+import (
+	"context"
+	"time"
+
+	"git.neds.sh/matty/entain/racing/db"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// fakeNowHeader is the metadata key clients send the time to pretend it is
+// in, as an RFC 3339 timestamp. The gateway forwards the X-Fake-Now HTTP
+// header as it.
+const fakeNowHeader = "x-fake-now"
+
+// TimeTravel returns a unary server interceptor that lets requests pretend it
+// is another time, given in x-fake-now metadata, so QA can see how races
+// count down to and past their start without waiting for it. It must never be
+// enabled in production.
+func TimeTravel() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := withFakeNow(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// withFakeNow returns a copy of ctx in which it is the time sent with the
+// request, if any.
+func withFakeNow(ctx context.Context) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	values := md.Get(fakeNowHeader)
+	if len(values) == 0 {
+		return ctx, nil
+	}
+
+	now, err := time.Parse(time.RFC3339, values[0])
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid %s: must be an RFC 3339 timestamp, e.g. 2021-03-01T09:30:00Z", fakeNowHeader)
+	}
+
+	return db.ContextWithNow(ctx, now), nil
+}
@@ -0,0 +1,49 @@
+package interceptors
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// timeZoneHeader is the metadata key clients send the IANA time zone to
+// format local start times in. The gateway forwards the Accept-Timezone HTTP
+// header, and the tz query parameter, as it.
+const timeZoneHeader = "accept-timezone"
+
+// timeZoneField is the request field the time zone sent is copied into.
+const timeZoneField protoreflect.Name = "time_zone"
+
+// TimeZone returns a unary server interceptor that copies the time zone sent
+// in accept-timezone metadata into requests that take a time_zone but don't
+// give one, so clients can set it once for every request. It must come before
+// Validation, so the time zone is validated like any other.
+func TimeZone() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+
+		if values := md.Get(timeZoneHeader); len(values) > 0 {
+			if m, ok := req.(proto.Message); ok {
+				setTimeZone(m, values[0])
+			}
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// setTimeZone sets the request's time zone, if it takes one and hasn't got
+// one.
+func setTimeZone(req proto.Message, timeZone string) {
+	m := req.ProtoReflect()
+
+	field := m.Descriptor().Fields().ByName(timeZoneField)
+	if field == nil || field.Kind() != protoreflect.StringKind || field.IsList() || m.Has(field) {
+		return
+	}
+
+	m.Set(field, protoreflect.ValueOfString(timeZone))
+}
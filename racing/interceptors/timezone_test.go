@@ -0,0 +1,63 @@
+package interceptors
+
+import (
+	"context"
+	"testing"
+
+	"git.neds.sh/matty/entain/racing/proto/racing"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestTimeZoneSetsRequestTimeZone(t *testing.T) {
+	tests := []struct {
+		name string
+		md   metadata.MD
+		req  proto.Message
+		want proto.Message
+	}{
+		{
+			name: "no header",
+			req:  &racing.ListRacesRequest{},
+			want: &racing.ListRacesRequest{},
+		},
+		{
+			name: "header",
+			md:   metadata.Pairs(timeZoneHeader, "Australia/Melbourne"),
+			req:  &racing.ListRacesRequest{},
+			want: &racing.ListRacesRequest{TimeZone: "Australia/Melbourne"},
+		},
+		{
+			name: "request's own time zone",
+			md:   metadata.Pairs(timeZoneHeader, "Australia/Melbourne"),
+			req:  &racing.ListArchivedRacesRequest{TimeZone: "Asia/Hong_Kong"},
+			want: &racing.ListArchivedRacesRequest{TimeZone: "Asia/Hong_Kong"},
+		},
+		{
+			name: "request without a time zone",
+			md:   metadata.Pairs(timeZoneHeader, "Australia/Melbourne"),
+			req:  &racing.ListMarketsRequest{},
+			want: &racing.ListMarketsRequest{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := metadata.NewIncomingContext(context.Background(), tt.md)
+
+			var got interface{}
+			_, err := TimeZone()(ctx, tt.req, &grpc.UnaryServerInfo{}, func(_ context.Context, req interface{}) (interface{}, error) {
+				got = req
+				return nil, nil
+			})
+			if err != nil {
+				t.Fatalf("error = %s", err)
+			}
+
+			if !proto.Equal(got.(proto.Message), tt.want) {
+				t.Errorf("request = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
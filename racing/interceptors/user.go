@@ -0,0 +1,50 @@
+package interceptors
+
+import (
+	"context"
+
+	"git.neds.sh/matty/entain/racing/db"
+	"git.neds.sh/matty/entain/racing/proto/racing"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// userHeader is the metadata key the user a request is authenticated as is
+// sent as. The proxy in front of the gateway authenticates callers and sets
+// the X-User-Id HTTP header, replacing any the caller sent, which the gateway
+// forwards as it.
+const userHeader = "x-user-id"
+
+// User returns a unary server interceptor that reads the user a request is
+// authenticated as out of x-user-id metadata, so watchlists are kept for, and
+// status changes recorded against, the caller rather than a user it names.
+// Requests without it aren't authenticated.
+func User() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := withUser(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// withUser returns a copy of ctx authenticated as the user sent with the
+// request, if any.
+func withUser(ctx context.Context) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	values := md.Get(userHeader)
+	if len(values) == 0 || values[0] == "" {
+		return ctx, nil
+	}
+
+	if len(values[0]) > racing.MaxUserIDLen {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid %s: must be at most %d bytes", userHeader, racing.MaxUserIDLen)
+	}
+
+	return db.ContextWithUser(ctx, values[0]), nil
+}
@@ -0,0 +1,52 @@
+package interceptors
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"git.neds.sh/matty/entain/racing/db"
+	"git.neds.sh/matty/entain/racing/proto/racing"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestUser(t *testing.T) {
+	tests := []struct {
+		name     string
+		md       metadata.MD
+		want     string
+		wantOK   bool
+		wantCode codes.Code
+	}{
+		{name: "no header"},
+		{name: "empty header", md: metadata.Pairs(userHeader, "")},
+		{name: "header", md: metadata.Pairs(userHeader, "punter"), want: "punter", wantOK: true},
+		{name: "longest header", md: metadata.Pairs(userHeader, strings.Repeat("a", racing.MaxUserIDLen)), want: strings.Repeat("a", racing.MaxUserIDLen), wantOK: true},
+		{name: "too long header", md: metadata.Pairs(userHeader, strings.Repeat("a", racing.MaxUserIDLen+1)), wantCode: codes.InvalidArgument},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := metadata.NewIncomingContext(context.Background(), tt.md)
+
+			var (
+				got   string
+				gotOK bool
+			)
+			_, err := User()(ctx, nil, &grpc.UnaryServerInfo{}, func(ctx context.Context, _ interface{}) (interface{}, error) {
+				got, gotOK = db.UserFromContext(ctx)
+				return nil, nil
+			})
+			if code := status.Code(err); code != tt.wantCode {
+				t.Fatalf("code = %s, want %s", code, tt.wantCode)
+			}
+
+			if got != tt.want || gotOK != tt.wantOK {
+				t.Errorf("user = %q, %t, want %q, %t", got, gotOK, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
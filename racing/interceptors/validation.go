@@ -0,0 +1,82 @@
+package interceptors
+
+import (
+	"context"
+	"errors"
+
+	"git.neds.sh/matty/entain/racing/proto/racing"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// validator is implemented by requests that can validate themselves.
+type validator interface {
+	Validate() error
+}
+
+// Validation returns a unary server interceptor that rejects requests failing
+// their validation rules with an InvalidArgument error, before they reach the
+// service. The error details the field that failed, as a BadRequest.
+func Validation() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if v, ok := req.(validator); ok {
+			if err := v.Validate(); err != nil {
+				return nil, invalidArgument(err)
+			}
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// StreamValidation returns a stream server interceptor that rejects received
+// requests failing their validation rules with an InvalidArgument error.
+func StreamValidation() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return handler(srv, &validatingStream{ServerStream: ss})
+	}
+}
+
+// validatingStream validates each request received on a stream.
+type validatingStream struct {
+	grpc.ServerStream
+}
+
+func (s *validatingStream) RecvMsg(m interface{}) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+
+	if v, ok := m.(validator); ok {
+		if err := v.Validate(); err != nil {
+			return invalidArgument(err)
+		}
+	}
+
+	return nil
+}
+
+// invalidArgument returns an InvalidArgument error for a request that failed
+// validation, with a BadRequest field violation describing which field failed
+// and why, for clients to show against the field.
+func invalidArgument(err error) error {
+	st := status.New(codes.InvalidArgument, err.Error())
+
+	var validationErr racing.ValidationError
+	if !errors.As(err, &validationErr) {
+		return st.Err()
+	}
+
+	detailed, detailsErr := st.WithDetails(&errdetails.BadRequest{
+		FieldViolations: []*errdetails.BadRequest_FieldViolation{
+			{Field: validationErr.Field, Description: validationErr.Reason},
+		},
+	})
+	if detailsErr != nil {
+		return st.Err()
+	}
+
+	return detailed.Err()
+}
@@ -0,0 +1,51 @@
+package interceptors
+
+import (
+	"context"
+	"testing"
+
+	"git.neds.sh/matty/entain/racing/proto/racing"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestValidationDetailsFieldViolations(t *testing.T) {
+	req := &racing.ListRacesRequest{
+		Filter: &racing.ListRacesRequestFilter{Countries: []string{"AU", "AUS"}},
+	}
+
+	_, err := Validation()(context.Background(), req, &grpc.UnaryServerInfo{}, func(context.Context, interface{}) (interface{}, error) {
+		t.Fatal("handler called for an invalid request")
+		return nil, nil
+	})
+
+	st := status.Convert(err)
+	if st.Code() != codes.InvalidArgument {
+		t.Fatalf("code = %s, want %s", st.Code(), codes.InvalidArgument)
+	}
+
+	details := st.Details()
+	if len(details) != 1 {
+		t.Fatalf("got %d details, want 1", len(details))
+	}
+
+	badRequest, ok := details[0].(*errdetails.BadRequest)
+	if !ok {
+		t.Fatalf("details[0] is a %T, want a BadRequest", details[0])
+	}
+
+	violations := badRequest.GetFieldViolations()
+	if len(violations) != 1 {
+		t.Fatalf("got %d field violations, want 1", len(violations))
+	}
+
+	if got, want := violations[0].Field, "filter.countries[1]"; got != want {
+		t.Errorf("field = %q, want %q", got, want)
+	}
+
+	if violations[0].Description == "" {
+		t.Error("field violation has no description")
+	}
+}
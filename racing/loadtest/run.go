@@ -0,0 +1,162 @@
+package loadtest
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// step is how often the scenario's rate is sampled, to schedule requests.
+const step = time.Millisecond
+
+// Target makes a single request, returning an error if it failed.
+type Target func(ctx context.Context) error
+
+// Result summarises the requests made by a run.
+type Result struct {
+	Requests int
+	Errors   int
+	Duration time.Duration
+	P50      time.Duration
+	P95      time.Duration
+	P99      time.Duration
+	Max      time.Duration
+	// FirstError is the first request error seen, to help explain failures.
+	FirstError error
+}
+
+// ErrorRate returns the proportion of requests that failed.
+func (r Result) ErrorRate() float64 {
+	if r.Requests == 0 {
+		return 0
+	}
+
+	return float64(r.Errors) / float64(r.Requests)
+}
+
+// Violations describes each threshold the result broke, if any.
+func (r Result) Violations(t Thresholds) []string {
+	var violations []string
+
+	for _, latency := range []struct {
+		name      string
+		got, want time.Duration
+	}{
+		{"p50", r.P50, t.P50},
+		{"p95", r.P95, t.P95},
+		{"p99", r.P99, t.P99},
+	} {
+		if latency.want > 0 && latency.got > latency.want {
+			violations = append(violations, fmt.Sprintf("%s latency %s is over %s", latency.name, latency.got, latency.want))
+		}
+	}
+
+	if t.MaxErrorRate > 0 && r.ErrorRate() > t.MaxErrorRate {
+		violations = append(violations, fmt.Sprintf("error rate %.2f%% is over %.2f%%", r.ErrorRate()*100, t.MaxErrorRate*100))
+	}
+
+	return violations
+}
+
+// Run drives load at the target following the scenario, until it ends or the
+// context is cancelled. Requests are sent on schedule whether or not earlier
+// ones have returned, so a slow target doesn't slow the load down, up to
+// maxInFlight requests at a time.
+func Run(ctx context.Context, scenario Scenario, target Target, maxInFlight int) Result {
+	var (
+		mu        sync.Mutex
+		wg        sync.WaitGroup
+		latencies []time.Duration
+		result    Result
+		inFlight  = make(chan struct{}, maxInFlight)
+	)
+
+	start := time.Now()
+
+	// Requests owed accumulate as the scenario goes, so they're spread evenly
+	// even while the rate is changing, or too low for a request every step.
+	owed := 0.0
+
+	for at := time.Duration(0); ; at += step {
+		rate, ok := scenario.rateAt(at)
+		if !ok {
+			break
+		}
+
+		for owed += rate * step.Seconds(); owed >= 1; owed-- {
+			select {
+			case <-ctx.Done():
+				wg.Wait()
+				return summarise(result, latencies, time.Since(start))
+			case <-time.After(time.Until(start.Add(at))):
+			}
+
+			select {
+			case <-ctx.Done():
+				wg.Wait()
+				return summarise(result, latencies, time.Since(start))
+			case inFlight <- struct{}{}:
+			}
+
+			wg.Add(1)
+
+			go func() {
+				defer wg.Done()
+				defer func() { <-inFlight }()
+
+				sent := time.Now()
+				err := target(ctx)
+				latency := time.Since(sent)
+
+				mu.Lock()
+				defer mu.Unlock()
+
+				result.Requests++
+				latencies = append(latencies, latency)
+
+				if err != nil {
+					result.Errors++
+
+					if result.FirstError == nil {
+						result.FirstError = err
+					}
+				}
+			}()
+		}
+	}
+
+	wg.Wait()
+
+	return summarise(result, latencies, time.Since(start))
+}
+
+// summarise fills in the result's latency percentiles.
+func summarise(result Result, latencies []time.Duration, duration time.Duration) Result {
+	result.Duration = duration
+
+	if len(latencies) == 0 {
+		return result
+	}
+
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+
+	result.P50 = percentile(latencies, 50)
+	result.P95 = percentile(latencies, 95)
+	result.P99 = percentile(latencies, 99)
+	result.Max = latencies[len(latencies)-1]
+
+	return result
+}
+
+// percentile returns the latency that p percent of the sorted latencies are
+// at or under.
+func percentile(sorted []time.Duration, p int) time.Duration {
+	i := (len(sorted)*p+99)/100 - 1
+	if i < 0 {
+		i = 0
+	}
+
+	return sorted[i]
+}
@@ -0,0 +1,57 @@
+package loadtest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRunFollowsScenario(t *testing.T) {
+	tests := []struct {
+		name     string
+		scenario Scenario
+		want     int
+	}{
+		{"ramp-up", RampUp(400, 500*time.Millisecond, Thresholds{}), 100},
+		{"spike", Spike(100, 400, 600*time.Millisecond, Thresholds{}), 120},
+		{"sustained", Sustained(200, 500*time.Millisecond, Thresholds{}), 95},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := Run(context.Background(), tt.scenario, func(context.Context) error { return nil }, 10)
+
+			// Allow for the scheduler, and the rate changing within a step.
+			if result.Requests < tt.want*9/10 || result.Requests > tt.want*11/10 {
+				t.Errorf("made %d requests, want about %d", result.Requests, tt.want)
+			}
+		})
+	}
+}
+
+func TestResultViolations(t *testing.T) {
+	failing := errors.New("failed")
+
+	calls := 0
+	result := Run(context.Background(), Sustained(200, 100*time.Millisecond, Thresholds{}), func(context.Context) error {
+		calls++
+		if calls%2 == 0 {
+			return failing
+		}
+
+		return nil
+	}, 1)
+
+	if !errors.Is(result.FirstError, failing) {
+		t.Errorf("first error = %v, want %v", result.FirstError, failing)
+	}
+
+	if got := result.Violations(Thresholds{MaxErrorRate: 0.6, P99: time.Second}); len(got) != 0 {
+		t.Errorf("violations within thresholds = %v, want none", got)
+	}
+
+	if got := result.Violations(Thresholds{MaxErrorRate: 0.1, P50: time.Nanosecond}); len(got) != 2 {
+		t.Errorf("violations = %v, want the error rate and p50", got)
+	}
+}
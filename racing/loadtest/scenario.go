@@ -0,0 +1,136 @@
+// Package loadtest drives load at the racing service and the REST gateway
+// following a scenario, e.g. ramping up to a peak or spiking, and checks the
+// latencies and errors seen against thresholds, to validate capacity ahead of
+// big events.
+package loadtest
+
+import (
+	"fmt"
+	"time"
+)
+
+// Stage changes the request rate linearly, from the rate the previous stage
+// ended at (or zero), to Rate over Duration. A stage at the same rate as the
+// one before holds it.
+type Stage struct {
+	// Rate is the requests per second reached by the end of the stage.
+	Rate float64
+	// Duration is how long the stage lasts.
+	Duration time.Duration
+}
+
+// Thresholds are the limits a run must stay within to pass. Zero values
+// aren't checked.
+type Thresholds struct {
+	P50 time.Duration
+	P95 time.Duration
+	P99 time.Duration
+	// MaxErrorRate is the proportion of requests allowed to fail, from 0 to 1.
+	MaxErrorRate float64
+}
+
+// Scenario describes how load is driven over time, and the thresholds it
+// must stay within.
+type Scenario struct {
+	Name       string
+	Stages     []Stage
+	Thresholds Thresholds
+}
+
+// Duration returns how long the scenario runs for.
+func (s Scenario) Duration() time.Duration {
+	var total time.Duration
+	for _, stage := range s.Stages {
+		total += stage.Duration
+	}
+
+	return total
+}
+
+// rateAt returns the requests per second the scenario drives at a point in
+// it, and false once the scenario is over.
+func (s Scenario) rateAt(elapsed time.Duration) (float64, bool) {
+	var from float64
+
+	for _, stage := range s.Stages {
+		if elapsed < stage.Duration {
+			progress := float64(elapsed) / float64(stage.Duration)
+
+			return from + (stage.Rate-from)*progress, true
+		}
+
+		elapsed -= stage.Duration
+		from = stage.Rate
+	}
+
+	return 0, false
+}
+
+// RampUp ramps from no load up to the peak rate over the duration, to find
+// where latency starts to climb.
+func RampUp(peak float64, duration time.Duration, thresholds Thresholds) Scenario {
+	return Scenario{
+		Name:       "ramp-up",
+		Stages:     []Stage{{Rate: peak, Duration: duration}},
+		Thresholds: thresholds,
+	}
+}
+
+// Spike holds a base rate, then jumps to the peak rate for the middle third
+// of the duration before dropping back, like the rush of bets before a big
+// race jumps.
+func Spike(base, peak float64, duration time.Duration, thresholds Thresholds) Scenario {
+	third := duration / 3
+
+	// The jump itself takes a moment, as a rate can't change instantly.
+	jump := third / 10
+	if jump > time.Second {
+		jump = time.Second
+	}
+
+	return Scenario{
+		Name: "spike",
+		Stages: []Stage{
+			{Rate: base, Duration: jump},
+			{Rate: base, Duration: third - jump},
+			{Rate: peak, Duration: jump},
+			{Rate: peak, Duration: third - jump},
+			{Rate: base, Duration: jump},
+			{Rate: base, Duration: duration - 2*third - jump},
+		},
+		Thresholds: thresholds,
+	}
+}
+
+// Sustained quickly reaches the rate, then holds it for the duration, to
+// check nothing degrades under steady load.
+func Sustained(rate float64, duration time.Duration, thresholds Thresholds) Scenario {
+	warmUp := duration / 10
+	if warmUp > 10*time.Second {
+		warmUp = 10 * time.Second
+	}
+
+	return Scenario{
+		Name: "sustained",
+		Stages: []Stage{
+			{Rate: rate, Duration: warmUp},
+			{Rate: rate, Duration: duration - warmUp},
+		},
+		Thresholds: thresholds,
+	}
+}
+
+// NewScenario returns the scenario with the given name, driving up to the
+// rate over the duration.
+func NewScenario(name string, rate float64, duration time.Duration, thresholds Thresholds) (Scenario, error) {
+	switch name {
+	case "ramp-up":
+		return RampUp(rate, duration, thresholds), nil
+	case "spike":
+		return Spike(rate/10, rate, duration, thresholds), nil
+	case "sustained":
+		return Sustained(rate, duration, thresholds), nil
+	default:
+		return Scenario{}, fmt.Errorf("unknown scenario %q, want ramp-up, spike or sustained", name)
+	}
+}
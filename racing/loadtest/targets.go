@@ -0,0 +1,52 @@
+package loadtest
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"git.neds.sh/matty/entain/racing/proto/racing"
+)
+
+// ListRaces lists races from the racing service over gRPC.
+func ListRaces(client racing.RacingClient, filter *racing.ListRacesRequestFilter) Target {
+	return func(ctx context.Context) error {
+		_, err := client.ListRaces(ctx, &racing.ListRacesRequest{Filter: filter})
+
+		return err
+	}
+}
+
+// GatewayListRaces lists races through the REST gateway at the base URL, with
+// the JSON request body, e.g. {"filter": {"meeting_ids": [1]}}.
+func GatewayListRaces(client *http.Client, baseURL string, body []byte) Target {
+	return func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, baseURL+"/v1/list-races", bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		// Read the whole response, as clients would, and so the connection
+		// can be reused.
+		if _, err := io.Copy(ioutil.Discard, resp.Body); err != nil {
+			return err
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("unexpected response: %s", resp.Status)
+		}
+
+		return nil
+	}
+}
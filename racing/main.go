@@ -1,21 +1,147 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"errors"
+	"expvar"
 	"flag"
+	"fmt"
 	"log"
 	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	_ "time/tzdata"
 
+	"git.neds.sh/matty/entain/racing/alerts"
 	"git.neds.sh/matty/entain/racing/db"
+	"git.neds.sh/matty/entain/racing/events"
+	"git.neds.sh/matty/entain/racing/export"
+	"git.neds.sh/matty/entain/racing/features"
+	"git.neds.sh/matty/entain/racing/fixtures"
+	"git.neds.sh/matty/entain/racing/ingest"
+	"git.neds.sh/matty/entain/racing/interceptors"
+	"git.neds.sh/matty/entain/racing/notifications"
 	"git.neds.sh/matty/entain/racing/proto/racing"
+	"git.neds.sh/matty/entain/racing/scheduler"
+	"git.neds.sh/matty/entain/racing/search"
 	"git.neds.sh/matty/entain/racing/service"
+	"git.neds.sh/matty/entain/racing/webhooks"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/protobuf/encoding/protojson"
 )
 
 var (
-	grpcEndpoint = flag.String("grpc-endpoint", "localhost:9000", "gRPC server endpoint")
+	grpcEndpoint      = flag.String("grpc-endpoint", "localhost:9000", "gRPC server endpoint")
+	metricsEndpoint   = flag.String("metrics-endpoint", "localhost:9100", "Metrics HTTP endpoint, serving /debug/vars")
+	archiveAfterDays  = flag.Int("archive-after-days", 7, "Days after their advertised start that races are archived (0 disables archiving)")
+	archiveInterval   = flag.Duration("archive-interval", time.Hour, "How often to check for races to archive")
+	raceRetentionDays = flag.Int("race-retention-days", 90, "Days after their advertised start that closed races are deleted (0 keeps races forever)")
+	retentionInterval = flag.Duration("retention-interval", 24*time.Hour, "How often to enforce the retention policy")
+	imageBaseURL      = flag.String("image-base-url", "https://cdn.example.com/racing", "Base URL that race image paths are served from")
+	feedURL           = flag.String("feed-url", "", "URL of an external JSON racing feed to ingest races from, instead of seeding dummy races")
+	feedSource        = flag.String("feed-source", "racing-feed", "Name that races from the external feed are referenced by")
+	feedInterval      = flag.Duration("feed-interval", 30*time.Second, "How often to poll the external racing feed")
+	reconcileInterval = flag.Duration("reconcile-interval", 15*time.Minute, "How often to reconcile races against the external racing feed, reporting drift (0 disables reconciliation)")
+	reconcileHeal     = flag.Bool("reconcile-heal", false, "Correct races found missing or mismatched by reconciliation from the external racing feed")
+	kafkaBrokers      = flag.String("kafka-brokers", "", "Comma separated Kafka brokers to publish race changes to (empty disables publishing)")
+	kafkaTopic        = flag.String("kafka-topic", "racing.race-changes", "Kafka topic to publish race changes to")
+	natsURL           = flag.String("nats-url", "", "NATS server to publish race changes to with JetStream, instead of Kafka (empty disables publishing)")
+	natsSubject       = flag.String("nats-subject", "racing.race-changes", "NATS subject prefix to publish race changes under, suffixed with the race ID")
+	webhookInterval   = flag.Duration("webhook-interval", 5*time.Second, "How often to notify webhook subscriptions of race changes, and retry failed notifications")
+	notifyInterval    = flag.Duration("notification-interval", 10*time.Second, "How often to send push notifications to devices following races")
+	jumpAlertLead     = flag.Duration("jump-alert-lead", 5*time.Minute, "How long before races start that their followers are notified they're about to jump")
+	pushGatewayURL    = flag.String("push-gateway-url", "", "HTTP push gateway that notifications to devices on the \"push\" channel are sent through (empty only logs notifications, on the \"log\" channel)")
+	outboxInterval    = flag.Duration("outbox-interval", time.Second, "How often to publish race changes waiting in the outbox")
+	replicaID         = flag.String("replica-id", defaultReplicaID(), "Unique name of this replica, when contending to be the leader that runs scheduled jobs")
+	leaderLeaseTTL    = flag.Duration("leader-lease-ttl", 15*time.Second, "How long the leader's lease lasts without being renewed, before another replica takes over")
+	eventLog          = flag.Bool("event-log", false, "Record every write to races in an append-only event log, enabling replay and as_of queries")
+	replayEventLog    = flag.Bool("replay-event-log", false, "Rebuild races from the event log on start up (requires -event-log)")
+	exportEndpoint    = flag.String("export-endpoint", "", "S3 compatible object storage endpoint to export race snapshots to, e.g. s3.amazonaws.com (empty disables exporting)")
+	exportRegion      = flag.String("export-region", "", "Region of the export bucket")
+	exportBucket      = flag.String("export-bucket", "", "Bucket to export race snapshots to")
+	exportPrefix      = flag.String("export-prefix", "", "Key prefix to export race snapshots under, e.g. analytics/")
+	exportInsecure    = flag.Bool("export-insecure", false, "Connect to the export endpoint over plain HTTP (local testing)")
+	exportFormat      = flag.String("export-format", "json", "Format to export race snapshots in: json (JSON Lines) or csv")
+	exportFilter      = flag.String("export-filter", "{}", "ListRaces filter, as JSON, restricting the races exported, e.g. {\"countries\":[\"AU\"]}")
+	exportInterval    = flag.Duration("export-interval", time.Hour, "How often to export race snapshots")
+	alertWebhookURL   = flag.String("alert-webhook-url", "", "URL to POST alerts to when feed ingestion or outbox relaying keeps failing (empty disables)")
+	pagerDutyKey      = flag.String("pagerduty-routing-key", "", "PagerDuty Events API integration key to page with when feed ingestion or outbox relaying keeps failing (empty disables)")
+	alertAfter        = flag.Int("alert-after-failures", 3, "How many runs in a row of feed ingestion or outbox relaying must fail before alerting")
+	searchIndexPath   = flag.String("search-index", "", "Directory of the local search index that races are mirrored into for SearchRaces (empty disables search)")
+	searchInterval    = flag.Duration("search-interval", 5*time.Second, "How often to mirror race changes into the search index")
+	idempotencyTTL    = flag.Duration("idempotency-key-ttl", 24*time.Hour, "How long responses to requests with an idempotency key are kept, to return for retries")
+	seed              = flag.Int64("seed", 0, "Random seed that dummy races and markets are generated from, so every empty database gets identical data (0 uses $RACING_SEED, or a random seed if unset)")
+	seedRaces         = flag.Int("seed-races", db.DefaultSeedConfig.Races, "How many dummy races to seed an empty database with, e.g. 100000 for performance testing")
+	seedMeetings      = flag.Int("seed-meetings", db.DefaultSeedConfig.Meetings, "How many meetings dummy races are spread across")
+	seedStartsBefore  = flag.Duration("seed-starts-before", db.DefaultSeedConfig.StartsBefore, "How long before now dummy races may be advertised to start")
+	seedStartsAfter   = flag.Duration("seed-starts-after", db.DefaultSeedConfig.StartsAfter, "How long after now dummy races may be advertised to start")
+	seedVisibleRatio  = flag.Float64("seed-visible-ratio", db.DefaultSeedConfig.VisibleRatio, "Proportion of dummy races that are visible, from 0 to 1")
+	seedFixtures      = flag.String("seed-fixtures", "", "Comma separated YAML or JSON fixture files of races to seed, e.g. fixtures/scenarios/race-day.yaml, updating them if already seeded")
+	environment       = flag.String("environment", "development", "Environment the service runs in, e.g. development, staging or production")
+	faultInjection    = flag.String("fault-injection", "", "Faults to inject into RPCs, as JSON keyed by method or \"*\", e.g. {\"/racing.Racing/ListRaces\":{\"latency\":\"250ms\",\"error_rate\":0.1,\"code\":\"UNAVAILABLE\",\"drop_rate\":0.05}} (refused in production)")
+	timeTravel        = flag.Bool("time-travel", false, "Let requests pretend it is another time, given as an RFC 3339 timestamp in x-fake-now metadata (the X-Fake-Now header through the gateway), counting races down from it (refused in production)")
+	featureFlags      = flag.String("feature-flags", "", "JSON file of feature flags keyed by feature, e.g. {\"missing-start-time-filter\":{\"enabled\":true,\"rollout\":0.1}}, reloaded while running (empty leaves every feature at its default)")
+	featureFlagsEvery = flag.Duration("feature-flags-interval", 10*time.Second, "How often to check the feature flags file for changes")
+	explainQueries    = flag.Bool("explain-queries", false, "Log the query plan of list queries (debug)")
 )
 
+// idempotentMethods are the mutating RPCs that accept an idempotency key, so
+// retries (e.g. of a feed import) aren't handled twice.
+var idempotentMethods = []string{
+	"/racing.Racing/UpdateRaceStatus",
+	"/racing.Racing/SetRaceFeatured",
+	"/racing.Racing/SetRaceRestrictions",
+	"/racing.Racing/SetBrandVisibility",
+	"/racing.Racing/RecordRaceViews",
+	"/racing.Racing/WatchRace",
+	"/racing.Racing/UnwatchRace",
+	"/racing.Racing/CreateSubscription",
+	"/racing.Racing/DeleteSubscription",
+	"/racing.Racing/RegisterDevice",
+	"/racing.Racing/UnregisterDevice",
+	"/racing.Racing/IngestRaces",
+}
+
+var (
+	// racesPurged counts the races deleted by the retention policy.
+	racesPurged = expvar.NewInt("races_purged_total")
+)
+
+// defaultReplicaID names the replica after its host and process.
+func defaultReplicaID() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "racing"
+	}
+
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
+}
+
+// seedRandom seeds dummy data from the -seed flag, or the RACING_SEED
+// environment variable.
+func seedRandom() error {
+	seedValue := *seed
+
+	if env := os.Getenv("RACING_SEED"); seedValue == 0 && env != "" {
+		var err error
+		if seedValue, err = strconv.ParseInt(env, 10, 64); err != nil {
+			return fmt.Errorf("invalid RACING_SEED %q: %w", env, err)
+		}
+	}
+
+	if seedValue != 0 {
+		db.SeedRandom(seedValue)
+	}
+
+	return nil
+}
+
 func main() {
 	flag.Parse()
 
@@ -35,20 +161,255 @@ func run() error {
 		return err
 	}
 
-	racesRepo := db.NewRacesRepo(racingDB)
+	var (
+		repoOpts    []db.RacesRepoOption
+		marketsOpts []db.MarketsRepoOption
+	)
+
+	if err := seedRandom(); err != nil {
+		return err
+	}
+
+	if *explainQueries {
+		repoOpts = append(repoOpts, db.WithQueryPlanLogging())
+	}
+
+	seedConfig := db.SeedConfig{
+		Races:        *seedRaces,
+		Meetings:     *seedMeetings,
+		StartsBefore: *seedStartsBefore,
+		StartsAfter:  *seedStartsAfter,
+		VisibleRatio: *seedVisibleRatio,
+	}
+	if err := seedConfig.Validate(); err != nil {
+		return err
+	}
+
+	repoOpts = append(repoOpts, db.WithSeedConfig(seedConfig))
+
+	publisher, err := newPublisher()
+	if err != nil {
+		return err
+	}
+
+	if publisher != nil {
+		defer publisher.Close()
+
+		repoOpts = append(repoOpts, db.WithOutbox())
+	}
+
+	if *eventLog {
+		repoOpts = append(repoOpts, db.WithEventLog())
+	}
+
+	if *feedURL != "" {
+		repoOpts = append(repoOpts, db.WithoutDummyData())
+		marketsOpts = append(marketsOpts, db.WithoutDummyMarkets())
+	}
+
+	racesRepo := db.NewRacesRepo(racingDB, repoOpts...)
 	if err := racesRepo.Init(); err != nil {
 		return err
 	}
 
-	grpcServer := grpc.NewServer()
+	if *replayEventLog {
+		replayed, err := racesRepo.Replay()
+		if err != nil {
+			return err
+		}
+
+		log.Printf("replayed event log, correcting %d races\n", replayed)
+	}
+
+	if *seedFixtures != "" {
+		if err := seedFromFixtures(racesRepo, strings.Split(*seedFixtures, ",")); err != nil {
+			return err
+		}
+	}
+
+	marketsRepo := db.NewMarketsRepo(racingDB, marketsOpts...)
+	if err := marketsRepo.Init(); err != nil {
+		return err
+	}
+
+	subscriptionsRepo := db.NewSubscriptionsRepo(racingDB)
+	if err := subscriptionsRepo.Init(); err != nil {
+		return err
+	}
+
+	notificationsRepo := db.NewNotificationsRepo(racingDB)
+	if err := notificationsRepo.Init(); err != nil {
+		return err
+	}
+
+	reconciliationRepo := db.NewReconciliationRepo(racingDB)
+	if err := reconciliationRepo.Init(); err != nil {
+		return err
+	}
+
+	idempotencyRepo := db.NewIdempotencyRepo(racingDB)
+	if err := idempotencyRepo.Init(); err != nil {
+		return err
+	}
+
+	leasesRepo := db.NewLeasesRepo(racingDB)
+	if err := leasesRepo.Init(); err != nil {
+		return err
+	}
+
+	elector := scheduler.NewLeaseElector(leasesRepo, *replicaID, *leaderLeaseTTL)
+	go elector.Run(context.Background())
+
+	jobs := scheduler.New(elector, alertHooks()...)
+
+	notifier := webhooks.NewNotifier(racesRepo, subscriptionsRepo, webhooks.NewClient())
+	jobs.Add(newJob("notify-webhooks", *webhookInterval, notifier.Notify))
+
+	providers := map[string]notifications.Provider{"log": notifications.LogProvider{}}
+	if *pushGatewayURL != "" {
+		providers["push"] = notifications.NewGatewayProvider(*pushGatewayURL, &http.Client{Timeout: 10 * time.Second})
+	}
+
+	fanout := notifications.NewFanout(racesRepo, notificationsRepo, providers, *jumpAlertLead)
+	jobs.Add(newJob("notify-devices", *notifyInterval, fanout.Notify))
+
+	if publisher != nil {
+		relay := events.NewRelay(db.NewOutboxRepo(racingDB), publisher)
+		job := newJob("relay-outbox", *outboxInterval, relay.Relay)
+		job.AlertAfter = *alertAfter
+		jobs.Add(job)
+	}
+
+	if *feedURL != "" {
+		connector := ingest.NewHTTPConnector(*feedSource, *feedURL, &http.Client{Timeout: *feedInterval})
+		ingester := ingest.NewIngester(connector, racesRepo)
+		job := newJob("ingest-feed", *feedInterval, ingester.Ingest)
+		job.AlertAfter = *alertAfter
+		jobs.Add(job)
+
+		if *reconcileInterval > 0 {
+			reconciler := ingest.NewReconciler(*feedSource, connector, racesRepo, reconciliationRepo, *reconcileHeal)
+			jobs.Add(newJob("reconcile-feed", *reconcileInterval, reconciler.Reconcile))
+		}
+	}
+
+	if *exportEndpoint != "" {
+		exporter, err := newExporter(racesRepo)
+		if err != nil {
+			return err
+		}
+
+		jobs.Add(newJob("export-races", *exportInterval, exporter.Export))
+	}
+
+	if *archiveAfterDays > 0 {
+		jobs.Add(newJob("archive-races", *archiveInterval, archiveRaces(racesRepo)))
+	}
+
+	if *raceRetentionDays > 0 {
+		jobs.Add(newJob("purge-races", *retentionInterval, purgeRaces(racesRepo)))
+	}
+
+	jobs.Add(newJob("purge-idempotency-keys", time.Hour, purgeIdempotencyKeys(idempotencyRepo)))
+
+	go jobs.Run(context.Background())
+
+	serviceOpts := []service.RacingServiceOption{service.WithImageBaseURL(*imageBaseURL)}
+
+	// Every replica keeps its own search index, so indexing isn't a job only
+	// run by the leader.
+	if *searchIndexPath != "" {
+		index, err := search.Open(*searchIndexPath)
+		if err != nil {
+			return err
+		}
+		defer index.Close()
+
+		go search.NewIndexer(racesRepo, index).Run(context.Background(), *searchInterval)
+
+		serviceOpts = append(serviceOpts, service.WithSearchIndex(index))
+	}
+
+	go func() {
+		if err := http.ListenAndServe(*metricsEndpoint, nil); err != nil {
+			log.Printf("failed running metrics server: %s\n", err)
+		}
+	}()
+
+	unaryInterceptors := []grpc.UnaryServerInterceptor{
+		interceptors.Errors(),
+		interceptors.TimeZone(),
+		interceptors.Jurisdiction(),
+		interceptors.Brand(),
+		interceptors.User(),
+		interceptors.Validation(),
+		interceptors.Idempotency(idempotencyRepo, *idempotencyTTL, idempotentMethods...),
+	}
+	streamInterceptors := []grpc.StreamServerInterceptor{
+		interceptors.StreamErrors(),
+		interceptors.StreamValidation(),
+		interceptors.StreamIdempotency(idempotencyRepo, *idempotencyTTL, idempotentMethods...),
+	}
+
+	if *faultInjection != "" {
+		if *environment == "production" {
+			return errors.New("fault injection can't be enabled in production")
+		}
+
+		faults, err := interceptors.ParseFaults(*faultInjection)
+		if err != nil {
+			return fmt.Errorf("invalid -fault-injection: %w", err)
+		}
+
+		// Inject faults first, as though they were in front of the service.
+		unaryInterceptors = append([]grpc.UnaryServerInterceptor{interceptors.Faults(faults)}, unaryInterceptors...)
+		streamInterceptors = append([]grpc.StreamServerInterceptor{interceptors.StreamFaults(faults)}, streamInterceptors...)
+
+		log.Printf("injecting faults into %d methods\n", len(faults))
+	}
+
+	if *timeTravel {
+		if *environment == "production" {
+			return errors.New("time travel can't be enabled in production")
+		}
+
+		unaryInterceptors = append(unaryInterceptors, interceptors.TimeTravel())
+
+		log.Println("time travel enabled")
+	}
+
+	if *featureFlags != "" {
+		flags, err := features.Load(*featureFlags)
+		if err != nil {
+			return fmt.Errorf("invalid -feature-flags: %w", err)
+		}
+
+		go flags.Run(context.Background(), *featureFlagsEvery)
+
+		unaryInterceptors = append(unaryInterceptors, interceptors.Features(flags))
+	}
+
+	grpcServer := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(unaryInterceptors...),
+		grpc.ChainStreamInterceptor(streamInterceptors...),
+	)
 
 	racing.RegisterRacingServer(
 		grpcServer,
 		service.NewRacingService(
 			racesRepo,
+			marketsRepo,
+			subscriptionsRepo,
+			notificationsRepo,
+			reconciliationRepo,
+			serviceOpts...,
 		),
 	)
 
+	// Report the service as serving for as long as it's up, for load balancers
+	// and smoke tests.
+	grpc_health_v1.RegisterHealthServer(grpcServer, health.NewServer())
+
 	log.Printf("gRPC server listening on: %s\n", *grpcEndpoint)
 
 	if err := grpcServer.Serve(conn); err != nil {
@@ -57,3 +418,134 @@ func run() error {
 
 	return nil
 }
+
+// seedFromFixtures seeds the races described in fixture files.
+func seedFromFixtures(racesRepo db.RacesRepo, paths []string) error {
+	for _, path := range paths {
+		races, err := fixtures.Load(path, time.Now())
+		if err != nil {
+			return err
+		}
+
+		outcomes, err := racesRepo.UpsertBatch(races)
+		if err != nil {
+			return err
+		}
+
+		for i, outcome := range outcomes {
+			if outcome.Err != nil {
+				return fmt.Errorf("%s: seeding %q: %w", path, races[i].Name, outcome.Err)
+			}
+		}
+
+		log.Printf("seeded %d races from %s\n", len(races), path)
+	}
+
+	return nil
+}
+
+// newPublisher creates a publisher for the configured message transport, or
+// returns nil if publishing is disabled.
+func newPublisher() (events.Publisher, error) {
+	switch {
+	case *kafkaBrokers != "" && *natsURL != "":
+		return nil, errors.New("only one of -kafka-brokers and -nats-url may be set")
+	case *kafkaBrokers != "":
+		return events.NewKafkaPublisher(strings.Split(*kafkaBrokers, ","), *kafkaTopic), nil
+	case *natsURL != "":
+		return events.NewNATSPublisher(*natsURL, *natsSubject)
+	default:
+		return nil, nil
+	}
+}
+
+// newExporter creates an exporter for the configured bucket, format and filter.
+func newExporter(racesRepo db.RacesRepo) (*export.Exporter, error) {
+	var filter racing.ListRacesRequestFilter
+	if err := protojson.Unmarshal([]byte(*exportFilter), &filter); err != nil {
+		return nil, fmt.Errorf("invalid -export-filter: %w", err)
+	}
+
+	if err := filter.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid -export-filter: %w", err)
+	}
+
+	store, err := export.NewS3Store(*exportEndpoint, *exportRegion, *exportBucket, !*exportInsecure)
+	if err != nil {
+		return nil, err
+	}
+
+	return export.NewExporter(racesRepo, store, export.Format(*exportFormat), &filter, *exportPrefix)
+}
+
+// alertHooks creates the configured hooks to fire when jobs keep failing.
+func alertHooks() []alerts.Hook {
+	var hooks []alerts.Hook
+
+	client := &http.Client{Timeout: 10 * time.Second}
+
+	if *alertWebhookURL != "" {
+		hooks = append(hooks, alerts.NewWebhookHook(*alertWebhookURL, *replicaID, client))
+	}
+
+	if *pagerDutyKey != "" {
+		hooks = append(hooks, alerts.NewPagerDutyHook(*pagerDutyKey, *replicaID, client))
+	}
+
+	return hooks
+}
+
+// newJob creates a scheduled job, jittered by up to a tenth of its interval.
+func newJob(name string, interval time.Duration, run func(ctx context.Context) error) scheduler.Job {
+	return scheduler.Job{
+		Name:     name,
+		Interval: interval,
+		Jitter:   interval / 10,
+		Run:      run,
+	}
+}
+
+// archiveRaces moves old races into the archive, keeping the races table (and
+// therefore ListRaces queries) small.
+func archiveRaces(racesRepo db.RacesRepo) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		archived, err := racesRepo.Archive(time.Now().AddDate(0, 0, -*archiveAfterDays))
+		if err != nil {
+			return err
+		}
+
+		if archived > 0 {
+			log.Printf("archived %d races\n", archived)
+		}
+
+		return nil
+	}
+}
+
+// purgeRaces deletes races that have been closed for longer than the
+// configured retention period.
+func purgeRaces(racesRepo db.RacesRepo) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		purged, err := racesRepo.Purge(time.Now().AddDate(0, 0, -*raceRetentionDays))
+		if err != nil {
+			return err
+		}
+
+		if purged > 0 {
+			racesPurged.Add(purged)
+			log.Printf("purged %d races\n", purged)
+		}
+
+		return nil
+	}
+}
+
+// purgeIdempotencyKeys deletes idempotency keys, and the responses kept for
+// them, once they've expired.
+func purgeIdempotencyKeys(idempotencyRepo db.IdempotencyRepo) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		_, err := idempotencyRepo.Purge(time.Now())
+
+		return err
+	}
+}
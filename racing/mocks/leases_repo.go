@@ -0,0 +1,63 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: ../db/leases.go
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	gomock "github.com/golang/mock/gomock"
+	reflect "reflect"
+	time "time"
+)
+
+// MockLeasesRepo is a mock of LeasesRepo interface
+type MockLeasesRepo struct {
+	ctrl     *gomock.Controller
+	recorder *MockLeasesRepoMockRecorder
+}
+
+// MockLeasesRepoMockRecorder is the mock recorder for MockLeasesRepo
+type MockLeasesRepoMockRecorder struct {
+	mock *MockLeasesRepo
+}
+
+// NewMockLeasesRepo creates a new mock instance
+func NewMockLeasesRepo(ctrl *gomock.Controller) *MockLeasesRepo {
+	mock := &MockLeasesRepo{ctrl: ctrl}
+	mock.recorder = &MockLeasesRepoMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockLeasesRepo) EXPECT() *MockLeasesRepoMockRecorder {
+	return m.recorder
+}
+
+// Init mocks base method
+func (m *MockLeasesRepo) Init() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Init")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Init indicates an expected call of Init
+func (mr *MockLeasesRepoMockRecorder) Init() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Init", reflect.TypeOf((*MockLeasesRepo)(nil).Init))
+}
+
+// Acquire mocks base method
+func (m *MockLeasesRepo) Acquire(name, holder string, until time.Time) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Acquire", name, holder, until)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Acquire indicates an expected call of Acquire
+func (mr *MockLeasesRepoMockRecorder) Acquire(name, holder, until interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Acquire", reflect.TypeOf((*MockLeasesRepo)(nil).Acquire), name, holder, until)
+}
@@ -0,0 +1,108 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: ../db/markets.go
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	racing "git.neds.sh/matty/entain/racing/proto/racing"
+	gomock "github.com/golang/mock/gomock"
+	reflect "reflect"
+)
+
+// MockMarketsRepo is a mock of MarketsRepo interface
+type MockMarketsRepo struct {
+	ctrl     *gomock.Controller
+	recorder *MockMarketsRepoMockRecorder
+}
+
+// MockMarketsRepoMockRecorder is the mock recorder for MockMarketsRepo
+type MockMarketsRepoMockRecorder struct {
+	mock *MockMarketsRepo
+}
+
+// NewMockMarketsRepo creates a new mock instance
+func NewMockMarketsRepo(ctrl *gomock.Controller) *MockMarketsRepo {
+	mock := &MockMarketsRepo{ctrl: ctrl}
+	mock.recorder = &MockMarketsRepoMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockMarketsRepo) EXPECT() *MockMarketsRepoMockRecorder {
+	return m.recorder
+}
+
+// Init mocks base method
+func (m *MockMarketsRepo) Init() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Init")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Init indicates an expected call of Init
+func (mr *MockMarketsRepoMockRecorder) Init() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Init", reflect.TypeOf((*MockMarketsRepo)(nil).Init))
+}
+
+// List mocks base method
+func (m *MockMarketsRepo) List(filter *racing.ListMarketsRequestFilter) ([]*racing.Market, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "List", filter)
+	ret0, _ := ret[0].([]*racing.Market)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// List indicates an expected call of List
+func (mr *MockMarketsRepoMockRecorder) List(filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*MockMarketsRepo)(nil).List), filter)
+}
+
+// Get mocks base method
+func (m *MockMarketsRepo) Get(id int64) (*racing.Market, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Get", id)
+	ret0, _ := ret[0].(*racing.Market)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Get indicates an expected call of Get
+func (mr *MockMarketsRepoMockRecorder) Get(id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockMarketsRepo)(nil).Get), id)
+}
+
+// ListSelections mocks base method
+func (m *MockMarketsRepo) ListSelections(filter *racing.ListSelectionsRequestFilter) ([]*racing.Selection, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListSelections", filter)
+	ret0, _ := ret[0].([]*racing.Selection)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListSelections indicates an expected call of ListSelections
+func (mr *MockMarketsRepoMockRecorder) ListSelections(filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListSelections", reflect.TypeOf((*MockMarketsRepo)(nil).ListSelections), filter)
+}
+
+// GetSelection mocks base method
+func (m *MockMarketsRepo) GetSelection(id int64) (*racing.Selection, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSelection", id)
+	ret0, _ := ret[0].(*racing.Selection)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSelection indicates an expected call of GetSelection
+func (mr *MockMarketsRepoMockRecorder) GetSelection(id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSelection", reflect.TypeOf((*MockMarketsRepo)(nil).GetSelection), id)
+}
@@ -0,0 +1,16 @@
+// Package mocks provides gomock mocks of the racing repositories and service,
+// so the layers above them can be tested without a database.
+//
+// Regenerate them with go generate, with mockgen (github.com/golang/mock
+// v1.4.4) on the PATH.
+package mocks
+
+//go:generate mockgen -source=../db/races.go -destination=races_repo.go -package=mocks
+//go:generate mockgen -source=../db/markets.go -destination=markets_repo.go -package=mocks
+//go:generate mockgen -source=../db/subscriptions.go -destination=subscriptions_repo.go -package=mocks
+//go:generate mockgen -source=../db/notifications.go -destination=notifications_repo.go -package=mocks
+//go:generate mockgen -source=../db/reconciliation.go -destination=reconciliation_repo.go -package=mocks
+//go:generate mockgen -source=../db/idempotency.go -destination=idempotency_repo.go -package=mocks
+//go:generate mockgen -source=../db/outbox.go -destination=outbox_repo.go -package=mocks
+//go:generate mockgen -source=../db/leases.go -destination=leases_repo.go -package=mocks
+//go:generate mockgen -source=../service/racing.go -destination=racing_service.go -package=mocks
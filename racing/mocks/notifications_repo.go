@@ -0,0 +1,166 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: ../db/notifications.go
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	db "git.neds.sh/matty/entain/racing/db"
+	racing "git.neds.sh/matty/entain/racing/proto/racing"
+	gomock "github.com/golang/mock/gomock"
+	reflect "reflect"
+	time "time"
+)
+
+// MockNotificationsRepo is a mock of NotificationsRepo interface
+type MockNotificationsRepo struct {
+	ctrl     *gomock.Controller
+	recorder *MockNotificationsRepoMockRecorder
+}
+
+// MockNotificationsRepoMockRecorder is the mock recorder for MockNotificationsRepo
+type MockNotificationsRepoMockRecorder struct {
+	mock *MockNotificationsRepo
+}
+
+// NewMockNotificationsRepo creates a new mock instance
+func NewMockNotificationsRepo(ctrl *gomock.Controller) *MockNotificationsRepo {
+	mock := &MockNotificationsRepo{ctrl: ctrl}
+	mock.recorder = &MockNotificationsRepoMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockNotificationsRepo) EXPECT() *MockNotificationsRepoMockRecorder {
+	return m.recorder
+}
+
+// Init mocks base method
+func (m *MockNotificationsRepo) Init() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Init")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Init indicates an expected call of Init
+func (mr *MockNotificationsRepoMockRecorder) Init() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Init", reflect.TypeOf((*MockNotificationsRepo)(nil).Init))
+}
+
+// RegisterDevice mocks base method
+func (m *MockNotificationsRepo) RegisterDevice(device *racing.Device, audience db.Audience) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RegisterDevice", device, audience)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RegisterDevice indicates an expected call of RegisterDevice
+func (mr *MockNotificationsRepoMockRecorder) RegisterDevice(device, audience interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RegisterDevice", reflect.TypeOf((*MockNotificationsRepo)(nil).RegisterDevice), device, audience)
+}
+
+// UnregisterDevice mocks base method
+func (m *MockNotificationsRepo) UnregisterDevice(channel, token string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UnregisterDevice", channel, token)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UnregisterDevice indicates an expected call of UnregisterDevice
+func (mr *MockNotificationsRepoMockRecorder) UnregisterDevice(channel, token interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UnregisterDevice", reflect.TypeOf((*MockNotificationsRepo)(nil).UnregisterDevice), channel, token)
+}
+
+// Following mocks base method
+func (m *MockNotificationsRepo) Following(race *racing.Race) ([]*racing.Device, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Following", race)
+	ret0, _ := ret[0].([]*racing.Device)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Following indicates an expected call of Following
+func (mr *MockNotificationsRepoMockRecorder) Following(race interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Following", reflect.TypeOf((*MockNotificationsRepo)(nil).Following), race)
+}
+
+// Cursor mocks base method
+func (m *MockNotificationsRepo) Cursor(name string, initial int64) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Cursor", name, initial)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Cursor indicates an expected call of Cursor
+func (mr *MockNotificationsRepoMockRecorder) Cursor(name, initial interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Cursor", reflect.TypeOf((*MockNotificationsRepo)(nil).Cursor), name, initial)
+}
+
+// SetCursor mocks base method
+func (m *MockNotificationsRepo) SetCursor(name string, position int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetCursor", name, position)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetCursor indicates an expected call of SetCursor
+func (mr *MockNotificationsRepoMockRecorder) SetCursor(name, position interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetCursor", reflect.TypeOf((*MockNotificationsRepo)(nil).SetCursor), name, position)
+}
+
+// Watch mocks base method
+func (m *MockNotificationsRepo) Watch(userID string, raceID int64, at time.Time) (*racing.WatchedRace, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Watch", userID, raceID, at)
+	ret0, _ := ret[0].(*racing.WatchedRace)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Watch indicates an expected call of Watch
+func (mr *MockNotificationsRepoMockRecorder) Watch(userID, raceID, at interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Watch", reflect.TypeOf((*MockNotificationsRepo)(nil).Watch), userID, raceID, at)
+}
+
+// Unwatch mocks base method
+func (m *MockNotificationsRepo) Unwatch(userID string, raceID int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Unwatch", userID, raceID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Unwatch indicates an expected call of Unwatch
+func (mr *MockNotificationsRepoMockRecorder) Unwatch(userID, raceID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Unwatch", reflect.TypeOf((*MockNotificationsRepo)(nil).Unwatch), userID, raceID)
+}
+
+// Watchlist mocks base method
+func (m *MockNotificationsRepo) Watchlist(userID string) ([]*racing.WatchedRace, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Watchlist", userID)
+	ret0, _ := ret[0].([]*racing.WatchedRace)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Watchlist indicates an expected call of Watchlist
+func (mr *MockNotificationsRepoMockRecorder) Watchlist(userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Watchlist", reflect.TypeOf((*MockNotificationsRepo)(nil).Watchlist), userID)
+}
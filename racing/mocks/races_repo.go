@@ -0,0 +1,466 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: ../db/races.go
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	db "git.neds.sh/matty/entain/racing/db"
+	racing "git.neds.sh/matty/entain/racing/proto/racing"
+	gomock "github.com/golang/mock/gomock"
+	reflect "reflect"
+	time "time"
+)
+
+// MockRacesRepo is a mock of RacesRepo interface
+type MockRacesRepo struct {
+	ctrl     *gomock.Controller
+	recorder *MockRacesRepoMockRecorder
+}
+
+// MockRacesRepoMockRecorder is the mock recorder for MockRacesRepo
+type MockRacesRepoMockRecorder struct {
+	mock *MockRacesRepo
+}
+
+// NewMockRacesRepo creates a new mock instance
+func NewMockRacesRepo(ctrl *gomock.Controller) *MockRacesRepo {
+	mock := &MockRacesRepo{ctrl: ctrl}
+	mock.recorder = &MockRacesRepoMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockRacesRepo) EXPECT() *MockRacesRepoMockRecorder {
+	return m.recorder
+}
+
+// Init mocks base method
+func (m *MockRacesRepo) Init() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Init")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Init indicates an expected call of Init
+func (mr *MockRacesRepoMockRecorder) Init() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Init", reflect.TypeOf((*MockRacesRepo)(nil).Init))
+}
+
+// List mocks base method
+func (m *MockRacesRepo) List(filter *racing.ListRacesRequestFilter) ([]*racing.Race, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "List", filter)
+	ret0, _ := ret[0].([]*racing.Race)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// List indicates an expected call of List
+func (mr *MockRacesRepoMockRecorder) List(filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*MockRacesRepo)(nil).List), filter)
+}
+
+// ListPage mocks base method
+func (m *MockRacesRepo) ListPage(filter *racing.ListRacesRequestFilter, audience db.Audience, after int64, limit int) ([]*racing.Race, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListPage", filter, audience, after, limit)
+	ret0, _ := ret[0].([]*racing.Race)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListPage indicates an expected call of ListPage
+func (mr *MockRacesRepoMockRecorder) ListPage(filter, audience, after, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListPage", reflect.TypeOf((*MockRacesRepo)(nil).ListPage), filter, audience, after, limit)
+}
+
+// ListMeetingPage mocks base method
+func (m *MockRacesRepo) ListMeetingPage(filter *racing.ListRacesRequestFilter, audience db.Audience, after int64, limit, raceLimit int) ([]*racing.Race, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListMeetingPage", filter, audience, after, limit, raceLimit)
+	ret0, _ := ret[0].([]*racing.Race)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListMeetingPage indicates an expected call of ListMeetingPage
+func (mr *MockRacesRepoMockRecorder) ListMeetingPage(filter, audience, after, limit, raceLimit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListMeetingPage", reflect.TypeOf((*MockRacesRepo)(nil).ListMeetingPage), filter, audience, after, limit, raceLimit)
+}
+
+// ListAt mocks base method
+func (m *MockRacesRepo) ListAt(filter *racing.ListRacesRequestFilter, at time.Time) ([]*racing.Race, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListAt", filter, at)
+	ret0, _ := ret[0].([]*racing.Race)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListAt indicates an expected call of ListAt
+func (mr *MockRacesRepoMockRecorder) ListAt(filter, at interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListAt", reflect.TypeOf((*MockRacesRepo)(nil).ListAt), filter, at)
+}
+
+// ListArchived mocks base method
+func (m *MockRacesRepo) ListArchived(filter *racing.ListRacesRequestFilter, audience db.Audience, from, to time.Time, after int64, limit int) ([]*racing.Race, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListArchived", filter, audience, from, to, after, limit)
+	ret0, _ := ret[0].([]*racing.Race)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListArchived indicates an expected call of ListArchived
+func (mr *MockRacesRepoMockRecorder) ListArchived(filter, audience, from, to, after, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListArchived", reflect.TypeOf((*MockRacesRepo)(nil).ListArchived), filter, audience, from, to, after, limit)
+}
+
+// Archive mocks base method
+func (m *MockRacesRepo) Archive(before time.Time) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Archive", before)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Archive indicates an expected call of Archive
+func (mr *MockRacesRepoMockRecorder) Archive(before interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Archive", reflect.TypeOf((*MockRacesRepo)(nil).Archive), before)
+}
+
+// Purge mocks base method
+func (m *MockRacesRepo) Purge(before time.Time) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Purge", before)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Purge indicates an expected call of Purge
+func (mr *MockRacesRepoMockRecorder) Purge(before interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Purge", reflect.TypeOf((*MockRacesRepo)(nil).Purge), before)
+}
+
+// LocalisedNames mocks base method
+func (m *MockRacesRepo) LocalisedNames(raceIDs []int64, locale string) (map[int64]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LocalisedNames", raceIDs, locale)
+	ret0, _ := ret[0].(map[int64]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// LocalisedNames indicates an expected call of LocalisedNames
+func (mr *MockRacesRepoMockRecorder) LocalisedNames(raceIDs, locale interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LocalisedNames", reflect.TypeOf((*MockRacesRepo)(nil).LocalisedNames), raceIDs, locale)
+}
+
+// GetByExternalRef mocks base method
+func (m *MockRacesRepo) GetByExternalRef(source, sourceID string) (*racing.Race, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetByExternalRef", source, sourceID)
+	ret0, _ := ret[0].(*racing.Race)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetByExternalRef indicates an expected call of GetByExternalRef
+func (mr *MockRacesRepoMockRecorder) GetByExternalRef(source, sourceID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetByExternalRef", reflect.TypeOf((*MockRacesRepo)(nil).GetByExternalRef), source, sourceID)
+}
+
+// Upsert mocks base method
+func (m *MockRacesRepo) Upsert(race *racing.Race) (db.UpsertResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Upsert", race)
+	ret0, _ := ret[0].(db.UpsertResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Upsert indicates an expected call of Upsert
+func (mr *MockRacesRepoMockRecorder) Upsert(race interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Upsert", reflect.TypeOf((*MockRacesRepo)(nil).Upsert), race)
+}
+
+// UpsertBatch mocks base method
+func (m *MockRacesRepo) UpsertBatch(races []*racing.Race) ([]db.UpsertOutcome, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpsertBatch", races)
+	ret0, _ := ret[0].([]db.UpsertOutcome)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpsertBatch indicates an expected call of UpsertBatch
+func (mr *MockRacesRepoMockRecorder) UpsertBatch(races interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpsertBatch", reflect.TypeOf((*MockRacesRepo)(nil).UpsertBatch), races)
+}
+
+// UpdateStatus mocks base method
+func (m *MockRacesRepo) UpdateStatus(raceID int64, status racing.Race_Status, actor string) (*racing.Race, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateStatus", raceID, status, actor)
+	ret0, _ := ret[0].(*racing.Race)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateStatus indicates an expected call of UpdateStatus
+func (mr *MockRacesRepoMockRecorder) UpdateStatus(raceID, status, actor interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateStatus", reflect.TypeOf((*MockRacesRepo)(nil).UpdateStatus), raceID, status, actor)
+}
+
+// StatusHistory mocks base method
+func (m *MockRacesRepo) StatusHistory(raceID int64) ([]*racing.StatusTransition, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "StatusHistory", raceID)
+	ret0, _ := ret[0].([]*racing.StatusTransition)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// StatusHistory indicates an expected call of StatusHistory
+func (mr *MockRacesRepoMockRecorder) StatusHistory(raceID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StatusHistory", reflect.TypeOf((*MockRacesRepo)(nil).StatusHistory), raceID)
+}
+
+// StatusChanges mocks base method
+func (m *MockRacesRepo) StatusChanges(after int64, limit int) ([]*db.StatusChange, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "StatusChanges", after, limit)
+	ret0, _ := ret[0].([]*db.StatusChange)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// StatusChanges indicates an expected call of StatusChanges
+func (mr *MockRacesRepoMockRecorder) StatusChanges(after, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StatusChanges", reflect.TypeOf((*MockRacesRepo)(nil).StatusChanges), after, limit)
+}
+
+// ListStarting mocks base method
+func (m *MockRacesRepo) ListStarting(after, before time.Time) ([]*racing.Race, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListStarting", after, before)
+	ret0, _ := ret[0].([]*racing.Race)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListStarting indicates an expected call of ListStarting
+func (mr *MockRacesRepoMockRecorder) ListStarting(after, before interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListStarting", reflect.TypeOf((*MockRacesRepo)(nil).ListStarting), after, before)
+}
+
+// ListBetween mocks base method
+func (m *MockRacesRepo) ListBetween(filter *racing.ListRacesRequestFilter, audience db.Audience, from, to time.Time) ([]*racing.Race, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListBetween", filter, audience, from, to)
+	ret0, _ := ret[0].([]*racing.Race)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListBetween indicates an expected call of ListBetween
+func (mr *MockRacesRepoMockRecorder) ListBetween(filter, audience, from, to interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListBetween", reflect.TypeOf((*MockRacesRepo)(nil).ListBetween), filter, audience, from, to)
+}
+
+// ListByIDs mocks base method
+func (m *MockRacesRepo) ListByIDs(raceIDs []int64) ([]*racing.Race, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListByIDs", raceIDs)
+	ret0, _ := ret[0].([]*racing.Race)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListByIDs indicates an expected call of ListByIDs
+func (mr *MockRacesRepoMockRecorder) ListByIDs(raceIDs interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListByIDs", reflect.TypeOf((*MockRacesRepo)(nil).ListByIDs), raceIDs)
+}
+
+// SetFeatured mocks base method
+func (m *MockRacesRepo) SetFeatured(raceID int64, featured bool) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetFeatured", raceID, featured)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetFeatured indicates an expected call of SetFeatured
+func (mr *MockRacesRepoMockRecorder) SetFeatured(raceID, featured interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetFeatured", reflect.TypeOf((*MockRacesRepo)(nil).SetFeatured), raceID, featured)
+}
+
+// ListFeatured mocks base method
+func (m *MockRacesRepo) ListFeatured() ([]*racing.Race, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListFeatured")
+	ret0, _ := ret[0].([]*racing.Race)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListFeatured indicates an expected call of ListFeatured
+func (mr *MockRacesRepoMockRecorder) ListFeatured() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListFeatured", reflect.TypeOf((*MockRacesRepo)(nil).ListFeatured))
+}
+
+// SetRestrictions mocks base method
+func (m *MockRacesRepo) SetRestrictions(raceID int64, jurisdictions []string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetRestrictions", raceID, jurisdictions)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetRestrictions indicates an expected call of SetRestrictions
+func (mr *MockRacesRepoMockRecorder) SetRestrictions(raceID, jurisdictions interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetRestrictions", reflect.TypeOf((*MockRacesRepo)(nil).SetRestrictions), raceID, jurisdictions)
+}
+
+// Restricted mocks base method
+func (m *MockRacesRepo) Restricted(raceIDs []int64, jurisdiction string) (map[int64]bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Restricted", raceIDs, jurisdiction)
+	ret0, _ := ret[0].(map[int64]bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Restricted indicates an expected call of Restricted
+func (mr *MockRacesRepoMockRecorder) Restricted(raceIDs, jurisdiction interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Restricted", reflect.TypeOf((*MockRacesRepo)(nil).Restricted), raceIDs, jurisdiction)
+}
+
+// SetBrandVisibility mocks base method
+func (m *MockRacesRepo) SetBrandVisibility(raceID int64, brand string, visible *bool) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetBrandVisibility", raceID, brand, visible)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetBrandVisibility indicates an expected call of SetBrandVisibility
+func (mr *MockRacesRepoMockRecorder) SetBrandVisibility(raceID, brand, visible interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetBrandVisibility", reflect.TypeOf((*MockRacesRepo)(nil).SetBrandVisibility), raceID, brand, visible)
+}
+
+// BrandVisibility mocks base method
+func (m *MockRacesRepo) BrandVisibility(raceIDs []int64, brand string) (map[int64]bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BrandVisibility", raceIDs, brand)
+	ret0, _ := ret[0].(map[int64]bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// BrandVisibility indicates an expected call of BrandVisibility
+func (mr *MockRacesRepoMockRecorder) BrandVisibility(raceIDs, brand interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BrandVisibility", reflect.TypeOf((*MockRacesRepo)(nil).BrandVisibility), raceIDs, brand)
+}
+
+// RecordViews mocks base method
+func (m *MockRacesRepo) RecordViews(raceID, views int64, at time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RecordViews", raceID, views, at)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RecordViews indicates an expected call of RecordViews
+func (mr *MockRacesRepoMockRecorder) RecordViews(raceID, views, at interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RecordViews", reflect.TypeOf((*MockRacesRepo)(nil).RecordViews), raceID, views, at)
+}
+
+// ListPopular mocks base method
+func (m *MockRacesRepo) ListPopular(filter *racing.ListRacesRequestFilter, audience db.Audience, since time.Time, offset int64, limit int) ([]*racing.PopularRace, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListPopular", filter, audience, since, offset, limit)
+	ret0, _ := ret[0].([]*racing.PopularRace)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListPopular indicates an expected call of ListPopular
+func (mr *MockRacesRepoMockRecorder) ListPopular(filter, audience, since, offset, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListPopular", reflect.TypeOf((*MockRacesRepo)(nil).ListPopular), filter, audience, since, offset, limit)
+}
+
+// Stats mocks base method
+func (m *MockRacesRepo) Stats(filter *racing.ListRacesRequestFilter) (*racing.RaceStats, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Stats", filter)
+	ret0, _ := ret[0].(*racing.RaceStats)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Stats indicates an expected call of Stats
+func (mr *MockRacesRepoMockRecorder) Stats(filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Stats", reflect.TypeOf((*MockRacesRepo)(nil).Stats), filter)
+}
+
+// Replay mocks base method
+func (m *MockRacesRepo) Replay() (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Replay")
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Replay indicates an expected call of Replay
+func (mr *MockRacesRepoMockRecorder) Replay() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Replay", reflect.TypeOf((*MockRacesRepo)(nil).Replay))
+}
+
+// Changes mocks base method
+func (m *MockRacesRepo) Changes(after int64, limit int) ([]*racing.RaceChange, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Changes", after, limit)
+	ret0, _ := ret[0].([]*racing.RaceChange)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Changes indicates an expected call of Changes
+func (mr *MockRacesRepoMockRecorder) Changes(after, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Changes", reflect.TypeOf((*MockRacesRepo)(nil).Changes), after, limit)
+}
@@ -0,0 +1,498 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: ../service/racing.go
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	racing "git.neds.sh/matty/entain/racing/proto/racing"
+	gomock "github.com/golang/mock/gomock"
+	context "golang.org/x/net/context"
+	reflect "reflect"
+)
+
+// MockRacing is a mock of Racing interface
+type MockRacing struct {
+	ctrl     *gomock.Controller
+	recorder *MockRacingMockRecorder
+}
+
+// MockRacingMockRecorder is the mock recorder for MockRacing
+type MockRacingMockRecorder struct {
+	mock *MockRacing
+}
+
+// NewMockRacing creates a new mock instance
+func NewMockRacing(ctrl *gomock.Controller) *MockRacing {
+	mock := &MockRacing{ctrl: ctrl}
+	mock.recorder = &MockRacingMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockRacing) EXPECT() *MockRacingMockRecorder {
+	return m.recorder
+}
+
+// ListRaces mocks base method
+func (m *MockRacing) ListRaces(ctx context.Context, in *racing.ListRacesRequest) (*racing.ListRacesResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListRaces", ctx, in)
+	ret0, _ := ret[0].(*racing.ListRacesResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListRaces indicates an expected call of ListRaces
+func (mr *MockRacingMockRecorder) ListRaces(ctx, in interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListRaces", reflect.TypeOf((*MockRacing)(nil).ListRaces), ctx, in)
+}
+
+// ListArchivedRaces mocks base method
+func (m *MockRacing) ListArchivedRaces(ctx context.Context, in *racing.ListArchivedRacesRequest) (*racing.ListArchivedRacesResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListArchivedRaces", ctx, in)
+	ret0, _ := ret[0].(*racing.ListArchivedRacesResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListArchivedRaces indicates an expected call of ListArchivedRaces
+func (mr *MockRacingMockRecorder) ListArchivedRaces(ctx, in interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListArchivedRaces", reflect.TypeOf((*MockRacing)(nil).ListArchivedRaces), ctx, in)
+}
+
+// GetRaceByExternalRef mocks base method
+func (m *MockRacing) GetRaceByExternalRef(ctx context.Context, in *racing.GetRaceByExternalRefRequest) (*racing.Race, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRaceByExternalRef", ctx, in)
+	ret0, _ := ret[0].(*racing.Race)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRaceByExternalRef indicates an expected call of GetRaceByExternalRef
+func (mr *MockRacingMockRecorder) GetRaceByExternalRef(ctx, in interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRaceByExternalRef", reflect.TypeOf((*MockRacing)(nil).GetRaceByExternalRef), ctx, in)
+}
+
+// ListMarkets mocks base method
+func (m *MockRacing) ListMarkets(ctx context.Context, in *racing.ListMarketsRequest) (*racing.ListMarketsResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListMarkets", ctx, in)
+	ret0, _ := ret[0].(*racing.ListMarketsResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListMarkets indicates an expected call of ListMarkets
+func (mr *MockRacingMockRecorder) ListMarkets(ctx, in interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListMarkets", reflect.TypeOf((*MockRacing)(nil).ListMarkets), ctx, in)
+}
+
+// GetMarket mocks base method
+func (m *MockRacing) GetMarket(ctx context.Context, in *racing.GetMarketRequest) (*racing.Market, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetMarket", ctx, in)
+	ret0, _ := ret[0].(*racing.Market)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetMarket indicates an expected call of GetMarket
+func (mr *MockRacingMockRecorder) GetMarket(ctx, in interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMarket", reflect.TypeOf((*MockRacing)(nil).GetMarket), ctx, in)
+}
+
+// ListSelections mocks base method
+func (m *MockRacing) ListSelections(ctx context.Context, in *racing.ListSelectionsRequest) (*racing.ListSelectionsResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListSelections", ctx, in)
+	ret0, _ := ret[0].(*racing.ListSelectionsResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListSelections indicates an expected call of ListSelections
+func (mr *MockRacingMockRecorder) ListSelections(ctx, in interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListSelections", reflect.TypeOf((*MockRacing)(nil).ListSelections), ctx, in)
+}
+
+// GetSelection mocks base method
+func (m *MockRacing) GetSelection(ctx context.Context, in *racing.GetSelectionRequest) (*racing.Selection, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSelection", ctx, in)
+	ret0, _ := ret[0].(*racing.Selection)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSelection indicates an expected call of GetSelection
+func (mr *MockRacingMockRecorder) GetSelection(ctx, in interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSelection", reflect.TypeOf((*MockRacing)(nil).GetSelection), ctx, in)
+}
+
+// WatchChanges mocks base method
+func (m *MockRacing) WatchChanges(in *racing.WatchChangesRequest, stream racing.Racing_WatchChangesServer) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WatchChanges", in, stream)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// WatchChanges indicates an expected call of WatchChanges
+func (mr *MockRacingMockRecorder) WatchChanges(in, stream interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WatchChanges", reflect.TypeOf((*MockRacing)(nil).WatchChanges), in, stream)
+}
+
+// UpdateRaceStatus mocks base method
+func (m *MockRacing) UpdateRaceStatus(ctx context.Context, in *racing.UpdateRaceStatusRequest) (*racing.Race, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateRaceStatus", ctx, in)
+	ret0, _ := ret[0].(*racing.Race)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateRaceStatus indicates an expected call of UpdateRaceStatus
+func (mr *MockRacingMockRecorder) UpdateRaceStatus(ctx, in interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateRaceStatus", reflect.TypeOf((*MockRacing)(nil).UpdateRaceStatus), ctx, in)
+}
+
+// ListStatusHistory mocks base method
+func (m *MockRacing) ListStatusHistory(ctx context.Context, in *racing.ListStatusHistoryRequest) (*racing.ListStatusHistoryResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListStatusHistory", ctx, in)
+	ret0, _ := ret[0].(*racing.ListStatusHistoryResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListStatusHistory indicates an expected call of ListStatusHistory
+func (mr *MockRacingMockRecorder) ListStatusHistory(ctx, in interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListStatusHistory", reflect.TypeOf((*MockRacing)(nil).ListStatusHistory), ctx, in)
+}
+
+// SetRaceFeatured mocks base method
+func (m *MockRacing) SetRaceFeatured(ctx context.Context, in *racing.SetRaceFeaturedRequest) (*racing.SetRaceFeaturedResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetRaceFeatured", ctx, in)
+	ret0, _ := ret[0].(*racing.SetRaceFeaturedResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SetRaceFeatured indicates an expected call of SetRaceFeatured
+func (mr *MockRacingMockRecorder) SetRaceFeatured(ctx, in interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetRaceFeatured", reflect.TypeOf((*MockRacing)(nil).SetRaceFeatured), ctx, in)
+}
+
+// ListFeaturedRaces mocks base method
+func (m *MockRacing) ListFeaturedRaces(ctx context.Context, in *racing.ListFeaturedRacesRequest) (*racing.ListFeaturedRacesResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListFeaturedRaces", ctx, in)
+	ret0, _ := ret[0].(*racing.ListFeaturedRacesResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListFeaturedRaces indicates an expected call of ListFeaturedRaces
+func (mr *MockRacingMockRecorder) ListFeaturedRaces(ctx, in interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListFeaturedRaces", reflect.TypeOf((*MockRacing)(nil).ListFeaturedRaces), ctx, in)
+}
+
+// SetRaceRestrictions mocks base method
+func (m *MockRacing) SetRaceRestrictions(ctx context.Context, in *racing.SetRaceRestrictionsRequest) (*racing.SetRaceRestrictionsResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetRaceRestrictions", ctx, in)
+	ret0, _ := ret[0].(*racing.SetRaceRestrictionsResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SetRaceRestrictions indicates an expected call of SetRaceRestrictions
+func (mr *MockRacingMockRecorder) SetRaceRestrictions(ctx, in interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetRaceRestrictions", reflect.TypeOf((*MockRacing)(nil).SetRaceRestrictions), ctx, in)
+}
+
+// SetBrandVisibility mocks base method
+func (m *MockRacing) SetBrandVisibility(ctx context.Context, in *racing.SetBrandVisibilityRequest) (*racing.SetBrandVisibilityResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetBrandVisibility", ctx, in)
+	ret0, _ := ret[0].(*racing.SetBrandVisibilityResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SetBrandVisibility indicates an expected call of SetBrandVisibility
+func (mr *MockRacingMockRecorder) SetBrandVisibility(ctx, in interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetBrandVisibility", reflect.TypeOf((*MockRacing)(nil).SetBrandVisibility), ctx, in)
+}
+
+// RecordRaceViews mocks base method
+func (m *MockRacing) RecordRaceViews(ctx context.Context, in *racing.RecordRaceViewsRequest) (*racing.RecordRaceViewsResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RecordRaceViews", ctx, in)
+	ret0, _ := ret[0].(*racing.RecordRaceViewsResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RecordRaceViews indicates an expected call of RecordRaceViews
+func (mr *MockRacingMockRecorder) RecordRaceViews(ctx, in interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RecordRaceViews", reflect.TypeOf((*MockRacing)(nil).RecordRaceViews), ctx, in)
+}
+
+// ListPopularRaces mocks base method
+func (m *MockRacing) ListPopularRaces(ctx context.Context, in *racing.ListPopularRacesRequest) (*racing.ListPopularRacesResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListPopularRaces", ctx, in)
+	ret0, _ := ret[0].(*racing.ListPopularRacesResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListPopularRaces indicates an expected call of ListPopularRaces
+func (mr *MockRacingMockRecorder) ListPopularRaces(ctx, in interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListPopularRaces", reflect.TypeOf((*MockRacing)(nil).ListPopularRaces), ctx, in)
+}
+
+// GetRaceStats mocks base method
+func (m *MockRacing) GetRaceStats(ctx context.Context, in *racing.GetRaceStatsRequest) (*racing.RaceStats, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRaceStats", ctx, in)
+	ret0, _ := ret[0].(*racing.RaceStats)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRaceStats indicates an expected call of GetRaceStats
+func (mr *MockRacingMockRecorder) GetRaceStats(ctx, in interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRaceStats", reflect.TypeOf((*MockRacing)(nil).GetRaceStats), ctx, in)
+}
+
+// ListRacesByDay mocks base method
+func (m *MockRacing) ListRacesByDay(ctx context.Context, in *racing.ListRacesByDayRequest) (*racing.ListRacesByDayResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListRacesByDay", ctx, in)
+	ret0, _ := ret[0].(*racing.ListRacesByDayResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListRacesByDay indicates an expected call of ListRacesByDay
+func (mr *MockRacingMockRecorder) ListRacesByDay(ctx, in interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListRacesByDay", reflect.TypeOf((*MockRacing)(nil).ListRacesByDay), ctx, in)
+}
+
+// ListMeetings mocks base method
+func (m *MockRacing) ListMeetings(ctx context.Context, in *racing.ListMeetingsRequest) (*racing.ListMeetingsResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListMeetings", ctx, in)
+	ret0, _ := ret[0].(*racing.ListMeetingsResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListMeetings indicates an expected call of ListMeetings
+func (mr *MockRacingMockRecorder) ListMeetings(ctx, in interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListMeetings", reflect.TypeOf((*MockRacing)(nil).ListMeetings), ctx, in)
+}
+
+// ListRelatedRaces mocks base method
+func (m *MockRacing) ListRelatedRaces(ctx context.Context, in *racing.ListRelatedRacesRequest) (*racing.ListRelatedRacesResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListRelatedRaces", ctx, in)
+	ret0, _ := ret[0].(*racing.ListRelatedRacesResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListRelatedRaces indicates an expected call of ListRelatedRaces
+func (mr *MockRacingMockRecorder) ListRelatedRaces(ctx, in interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListRelatedRaces", reflect.TypeOf((*MockRacing)(nil).ListRelatedRaces), ctx, in)
+}
+
+// CreateSubscription mocks base method
+func (m *MockRacing) CreateSubscription(ctx context.Context, in *racing.CreateSubscriptionRequest) (*racing.Subscription, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateSubscription", ctx, in)
+	ret0, _ := ret[0].(*racing.Subscription)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateSubscription indicates an expected call of CreateSubscription
+func (mr *MockRacingMockRecorder) CreateSubscription(ctx, in interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateSubscription", reflect.TypeOf((*MockRacing)(nil).CreateSubscription), ctx, in)
+}
+
+// DeleteSubscription mocks base method
+func (m *MockRacing) DeleteSubscription(ctx context.Context, in *racing.DeleteSubscriptionRequest) (*racing.DeleteSubscriptionResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteSubscription", ctx, in)
+	ret0, _ := ret[0].(*racing.DeleteSubscriptionResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteSubscription indicates an expected call of DeleteSubscription
+func (mr *MockRacingMockRecorder) DeleteSubscription(ctx, in interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteSubscription", reflect.TypeOf((*MockRacing)(nil).DeleteSubscription), ctx, in)
+}
+
+// ListDeliveries mocks base method
+func (m *MockRacing) ListDeliveries(ctx context.Context, in *racing.ListDeliveriesRequest) (*racing.ListDeliveriesResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListDeliveries", ctx, in)
+	ret0, _ := ret[0].(*racing.ListDeliveriesResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListDeliveries indicates an expected call of ListDeliveries
+func (mr *MockRacingMockRecorder) ListDeliveries(ctx, in interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListDeliveries", reflect.TypeOf((*MockRacing)(nil).ListDeliveries), ctx, in)
+}
+
+// RegisterDevice mocks base method
+func (m *MockRacing) RegisterDevice(ctx context.Context, in *racing.RegisterDeviceRequest) (*racing.Device, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RegisterDevice", ctx, in)
+	ret0, _ := ret[0].(*racing.Device)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RegisterDevice indicates an expected call of RegisterDevice
+func (mr *MockRacingMockRecorder) RegisterDevice(ctx, in interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RegisterDevice", reflect.TypeOf((*MockRacing)(nil).RegisterDevice), ctx, in)
+}
+
+// UnregisterDevice mocks base method
+func (m *MockRacing) UnregisterDevice(ctx context.Context, in *racing.UnregisterDeviceRequest) (*racing.UnregisterDeviceResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UnregisterDevice", ctx, in)
+	ret0, _ := ret[0].(*racing.UnregisterDeviceResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UnregisterDevice indicates an expected call of UnregisterDevice
+func (mr *MockRacingMockRecorder) UnregisterDevice(ctx, in interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UnregisterDevice", reflect.TypeOf((*MockRacing)(nil).UnregisterDevice), ctx, in)
+}
+
+// WatchRace mocks base method
+func (m *MockRacing) WatchRace(ctx context.Context, in *racing.WatchRaceRequest) (*racing.WatchedRace, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WatchRace", ctx, in)
+	ret0, _ := ret[0].(*racing.WatchedRace)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// WatchRace indicates an expected call of WatchRace
+func (mr *MockRacingMockRecorder) WatchRace(ctx, in interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WatchRace", reflect.TypeOf((*MockRacing)(nil).WatchRace), ctx, in)
+}
+
+// UnwatchRace mocks base method
+func (m *MockRacing) UnwatchRace(ctx context.Context, in *racing.UnwatchRaceRequest) (*racing.UnwatchRaceResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UnwatchRace", ctx, in)
+	ret0, _ := ret[0].(*racing.UnwatchRaceResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UnwatchRace indicates an expected call of UnwatchRace
+func (mr *MockRacingMockRecorder) UnwatchRace(ctx, in interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UnwatchRace", reflect.TypeOf((*MockRacing)(nil).UnwatchRace), ctx, in)
+}
+
+// ListWatchlist mocks base method
+func (m *MockRacing) ListWatchlist(ctx context.Context, in *racing.ListWatchlistRequest) (*racing.ListWatchlistResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListWatchlist", ctx, in)
+	ret0, _ := ret[0].(*racing.ListWatchlistResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListWatchlist indicates an expected call of ListWatchlist
+func (mr *MockRacingMockRecorder) ListWatchlist(ctx, in interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListWatchlist", reflect.TypeOf((*MockRacing)(nil).ListWatchlist), ctx, in)
+}
+
+// IngestRaces mocks base method
+func (m *MockRacing) IngestRaces(stream racing.Racing_IngestRacesServer) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IngestRaces", stream)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// IngestRaces indicates an expected call of IngestRaces
+func (mr *MockRacingMockRecorder) IngestRaces(stream interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IngestRaces", reflect.TypeOf((*MockRacing)(nil).IngestRaces), stream)
+}
+
+// SearchRaces mocks base method
+func (m *MockRacing) SearchRaces(ctx context.Context, in *racing.SearchRacesRequest) (*racing.SearchRacesResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SearchRaces", ctx, in)
+	ret0, _ := ret[0].(*racing.SearchRacesResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SearchRaces indicates an expected call of SearchRaces
+func (mr *MockRacingMockRecorder) SearchRaces(ctx, in interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SearchRaces", reflect.TypeOf((*MockRacing)(nil).SearchRaces), ctx, in)
+}
+
+// GetReconciliationReport mocks base method
+func (m *MockRacing) GetReconciliationReport(ctx context.Context, in *racing.GetReconciliationReportRequest) (*racing.ReconciliationReport, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetReconciliationReport", ctx, in)
+	ret0, _ := ret[0].(*racing.ReconciliationReport)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetReconciliationReport indicates an expected call of GetReconciliationReport
+func (mr *MockRacingMockRecorder) GetReconciliationReport(ctx, in interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetReconciliationReport", reflect.TypeOf((*MockRacing)(nil).GetReconciliationReport), ctx, in)
+}
@@ -0,0 +1,77 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: ../db/reconciliation.go
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	racing "git.neds.sh/matty/entain/racing/proto/racing"
+	gomock "github.com/golang/mock/gomock"
+	reflect "reflect"
+)
+
+// MockReconciliationRepo is a mock of ReconciliationRepo interface
+type MockReconciliationRepo struct {
+	ctrl     *gomock.Controller
+	recorder *MockReconciliationRepoMockRecorder
+}
+
+// MockReconciliationRepoMockRecorder is the mock recorder for MockReconciliationRepo
+type MockReconciliationRepoMockRecorder struct {
+	mock *MockReconciliationRepo
+}
+
+// NewMockReconciliationRepo creates a new mock instance
+func NewMockReconciliationRepo(ctrl *gomock.Controller) *MockReconciliationRepo {
+	mock := &MockReconciliationRepo{ctrl: ctrl}
+	mock.recorder = &MockReconciliationRepoMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockReconciliationRepo) EXPECT() *MockReconciliationRepoMockRecorder {
+	return m.recorder
+}
+
+// Init mocks base method
+func (m *MockReconciliationRepo) Init() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Init")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Init indicates an expected call of Init
+func (mr *MockReconciliationRepoMockRecorder) Init() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Init", reflect.TypeOf((*MockReconciliationRepo)(nil).Init))
+}
+
+// SaveReport mocks base method
+func (m *MockReconciliationRepo) SaveReport(report *racing.ReconciliationReport) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveReport", report)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveReport indicates an expected call of SaveReport
+func (mr *MockReconciliationRepoMockRecorder) SaveReport(report interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveReport", reflect.TypeOf((*MockReconciliationRepo)(nil).SaveReport), report)
+}
+
+// LatestReport mocks base method
+func (m *MockReconciliationRepo) LatestReport() (*racing.ReconciliationReport, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LatestReport")
+	ret0, _ := ret[0].(*racing.ReconciliationReport)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// LatestReport indicates an expected call of LatestReport
+func (mr *MockReconciliationRepoMockRecorder) LatestReport() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LatestReport", reflect.TypeOf((*MockReconciliationRepo)(nil).LatestReport))
+}
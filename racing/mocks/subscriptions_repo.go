@@ -0,0 +1,151 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: ../db/subscriptions.go
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	db "git.neds.sh/matty/entain/racing/db"
+	racing "git.neds.sh/matty/entain/racing/proto/racing"
+	gomock "github.com/golang/mock/gomock"
+	reflect "reflect"
+	time "time"
+)
+
+// MockSubscriptionsRepo is a mock of SubscriptionsRepo interface
+type MockSubscriptionsRepo struct {
+	ctrl     *gomock.Controller
+	recorder *MockSubscriptionsRepoMockRecorder
+}
+
+// MockSubscriptionsRepoMockRecorder is the mock recorder for MockSubscriptionsRepo
+type MockSubscriptionsRepoMockRecorder struct {
+	mock *MockSubscriptionsRepo
+}
+
+// NewMockSubscriptionsRepo creates a new mock instance
+func NewMockSubscriptionsRepo(ctrl *gomock.Controller) *MockSubscriptionsRepo {
+	mock := &MockSubscriptionsRepo{ctrl: ctrl}
+	mock.recorder = &MockSubscriptionsRepoMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockSubscriptionsRepo) EXPECT() *MockSubscriptionsRepoMockRecorder {
+	return m.recorder
+}
+
+// Init mocks base method
+func (m *MockSubscriptionsRepo) Init() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Init")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Init indicates an expected call of Init
+func (mr *MockSubscriptionsRepoMockRecorder) Init() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Init", reflect.TypeOf((*MockSubscriptionsRepo)(nil).Init))
+}
+
+// Create mocks base method
+func (m *MockSubscriptionsRepo) Create(sub *racing.Subscription) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Create", sub)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Create indicates an expected call of Create
+func (mr *MockSubscriptionsRepoMockRecorder) Create(sub interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockSubscriptionsRepo)(nil).Create), sub)
+}
+
+// Delete mocks base method
+func (m *MockSubscriptionsRepo) Delete(id int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Delete", id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Delete indicates an expected call of Delete
+func (mr *MockSubscriptionsRepoMockRecorder) Delete(id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockSubscriptionsRepo)(nil).Delete), id)
+}
+
+// List mocks base method
+func (m *MockSubscriptionsRepo) List() ([]*db.Subscriber, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "List")
+	ret0, _ := ret[0].([]*db.Subscriber)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// List indicates an expected call of List
+func (mr *MockSubscriptionsRepoMockRecorder) List() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*MockSubscriptionsRepo)(nil).List))
+}
+
+// Enqueue mocks base method
+func (m *MockSubscriptionsRepo) Enqueue(subscriptionID int64, changes []*racing.RaceChange, lastChangeID int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Enqueue", subscriptionID, changes, lastChangeID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Enqueue indicates an expected call of Enqueue
+func (mr *MockSubscriptionsRepoMockRecorder) Enqueue(subscriptionID, changes, lastChangeID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Enqueue", reflect.TypeOf((*MockSubscriptionsRepo)(nil).Enqueue), subscriptionID, changes, lastChangeID)
+}
+
+// ListDeliveries mocks base method
+func (m *MockSubscriptionsRepo) ListDeliveries(subscriptionID int64) ([]*racing.Delivery, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListDeliveries", subscriptionID)
+	ret0, _ := ret[0].([]*racing.Delivery)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListDeliveries indicates an expected call of ListDeliveries
+func (mr *MockSubscriptionsRepoMockRecorder) ListDeliveries(subscriptionID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListDeliveries", reflect.TypeOf((*MockSubscriptionsRepo)(nil).ListDeliveries), subscriptionID)
+}
+
+// Due mocks base method
+func (m *MockSubscriptionsRepo) Due(now time.Time, limit int) ([]*db.DueDelivery, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Due", now, limit)
+	ret0, _ := ret[0].([]*db.DueDelivery)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Due indicates an expected call of Due
+func (mr *MockSubscriptionsRepoMockRecorder) Due(now, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Due", reflect.TypeOf((*MockSubscriptionsRepo)(nil).Due), now, limit)
+}
+
+// RecordAttempt mocks base method
+func (m *MockSubscriptionsRepo) RecordAttempt(delivery *racing.Delivery, nextAttemptAt time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RecordAttempt", delivery, nextAttemptAt)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RecordAttempt indicates an expected call of RecordAttempt
+func (mr *MockSubscriptionsRepoMockRecorder) RecordAttempt(delivery, nextAttemptAt interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RecordAttempt", reflect.TypeOf((*MockSubscriptionsRepo)(nil).RecordAttempt), delivery, nextAttemptAt)
+}
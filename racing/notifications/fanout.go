@@ -0,0 +1,186 @@
+package notifications
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+	"math"
+	"time"
+
+	"git.neds.sh/matty/entain/racing/db"
+	"git.neds.sh/matty/entain/racing/proto/racing"
+)
+
+const (
+	// batchSize is how many status changes are handled at a time.
+	batchSize = 100
+	// maxAge is how old a status change can be and still be notified, so
+	// catching up after an outage doesn't push stale news.
+	maxAge = 10 * time.Minute
+
+	// statusCursor names how far through the status history notifications
+	// have got.
+	statusCursor = "status-changes"
+	// jumpCursor names the time, in Unix seconds, up to which races have been
+	// notified as about to jump.
+	jumpCursor = "jumps"
+)
+
+// notificationsSent counts notifications by their outcome.
+var notificationsSent = expvar.NewMap("push_notifications_total")
+
+// Fanout notifies the devices following races of their status changes, and
+// when they're about to jump.
+type Fanout struct {
+	racesRepo         db.RacesRepo
+	notificationsRepo db.NotificationsRepo
+	providers         map[string]Provider
+	jumpLead          time.Duration
+}
+
+// NewFanout creates a new fanout, sending to devices through the provider for
+// their channel, and notifying races jumpLead before they start.
+func NewFanout(racesRepo db.RacesRepo, notificationsRepo db.NotificationsRepo, providers map[string]Provider, jumpLead time.Duration) *Fanout {
+	return &Fanout{
+		racesRepo:         racesRepo,
+		notificationsRepo: notificationsRepo,
+		providers:         providers,
+		jumpLead:          jumpLead,
+	}
+}
+
+// Notify notifies followers of every status change and jump since it was
+// last run.
+func (f *Fanout) Notify(ctx context.Context) error {
+	if err := f.notifyStatusChanges(ctx); err != nil {
+		return err
+	}
+
+	return f.notifyJumps(ctx)
+}
+
+// notifyStatusChanges notifies followers of status changes since the last
+// one notified.
+func (f *Fanout) notifyStatusChanges(ctx context.Context) error {
+	after, err := f.notificationsRepo.Cursor(statusCursor, 0)
+	if err != nil {
+		return err
+	}
+
+	for {
+		changes, err := f.racesRepo.StatusChanges(after, batchSize)
+		if err != nil {
+			return err
+		}
+
+		if len(changes) == 0 {
+			return nil
+		}
+
+		for _, change := range changes {
+			// Races being created isn't news, and races no longer on the board
+			// aren't followed.
+			if change.Transition.FromStatus == racing.Race_UNSPECIFIED || change.Race == nil {
+				continue
+			}
+
+			if time.Since(change.Transition.ChangedAt.AsTime()) > maxAge {
+				continue
+			}
+
+			if err := f.fanout(ctx, change.Race, Notification{
+				RaceID: change.Race.Id,
+				Title:  title(change.Race),
+				Body:   fmt.Sprintf("Now %s", change.Transition.ToStatus),
+			}); err != nil {
+				return err
+			}
+		}
+
+		after = changes[len(changes)-1].ID
+
+		if err := f.notificationsRepo.SetCursor(statusCursor, after); err != nil {
+			return err
+		}
+
+		if len(changes) < batchSize {
+			return nil
+		}
+	}
+}
+
+// notifyJumps notifies followers of races that have come within the jump lead
+// of starting since it last ran.
+func (f *Fanout) notifyJumps(ctx context.Context) error {
+	now := time.Now()
+	until := now.Add(f.jumpLead)
+
+	notified, err := f.notificationsRepo.Cursor(jumpCursor, now.Unix())
+	if err != nil {
+		return err
+	}
+
+	// Races that started while nothing was running have already jumped.
+	after := time.Unix(notified, 0)
+	if after.Before(now) {
+		after = now
+	}
+
+	if !after.Before(until) {
+		return nil
+	}
+
+	races, err := f.racesRepo.ListStarting(after, until)
+	if err != nil {
+		return err
+	}
+
+	for _, race := range races {
+		body := "Jumps in 1 minute"
+		if minutes := math.Ceil(race.AdvertisedStartTime.AsTime().Sub(now).Minutes()); minutes > 1 {
+			body = fmt.Sprintf("Jumps in %.0f minutes", minutes)
+		}
+
+		if err := f.fanout(ctx, race, Notification{
+			RaceID: race.Id,
+			Title:  title(race),
+			Body:   body,
+		}); err != nil {
+			return err
+		}
+	}
+
+	return f.notificationsRepo.SetCursor(jumpCursor, until.Unix())
+}
+
+// fanout sends a notification to every device following a race that it's
+// listed for, so devices aren't sent races hidden from them. Sending is best
+// effort, so failures are counted rather than retried.
+func (f *Fanout) fanout(ctx context.Context, race *racing.Race, n Notification) error {
+	devices, err := f.notificationsRepo.Following(race)
+	if err != nil {
+		return err
+	}
+
+	for _, device := range devices {
+		provider, ok := f.providers[device.Channel]
+		if !ok {
+			notificationsSent.Add("unsupported", 1)
+			continue
+		}
+
+		if err := provider.Send(ctx, device.Token, n); err != nil {
+			notificationsSent.Add("failed", 1)
+			continue
+		}
+
+		notificationsSent.Add("sent", 1)
+	}
+
+	return nil
+}
+
+// title returns how a race is referred to in notifications.
+func title(race *racing.Race) string {
+	return fmt.Sprintf("R%d %s", race.Number, race.Name)
+}
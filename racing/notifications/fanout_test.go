@@ -0,0 +1,279 @@
+package notifications
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"git.neds.sh/matty/entain/racing/db"
+	"git.neds.sh/matty/entain/racing/proto/racing"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// recordingProvider records the notifications sent through it, failing
+// sends to the tokens in fail.
+type recordingProvider struct {
+	sent []string
+	fail map[string]bool
+}
+
+func (p *recordingProvider) Send(_ context.Context, token string, n Notification) error {
+	if p.fail[token] {
+		return errors.New("push service unavailable")
+	}
+
+	p.sent = append(p.sent, token+": "+n.Title+": "+n.Body)
+
+	return nil
+}
+
+// stubRaces is a races repository returning the given status changes and
+// starting races, recording the window races were listed in.
+type stubRaces struct {
+	db.RacesRepo
+	changes  []*db.StatusChange
+	starting []*racing.Race
+	listed   bool
+	after    time.Time
+	before   time.Time
+}
+
+func (r *stubRaces) StatusChanges(after int64, limit int) ([]*db.StatusChange, error) {
+	var changes []*db.StatusChange
+	for _, change := range r.changes {
+		if change.ID > after && len(changes) < limit {
+			changes = append(changes, change)
+		}
+	}
+
+	return changes, nil
+}
+
+func (r *stubRaces) ListStarting(after, before time.Time) ([]*racing.Race, error) {
+	r.listed, r.after, r.before = true, after, before
+
+	return r.starting, nil
+}
+
+// stubNotifications is a notifications repository holding cursors in memory,
+// with every race followed by the given devices.
+type stubNotifications struct {
+	db.NotificationsRepo
+	cursors map[string]int64
+	devices []*racing.Device
+}
+
+func (r *stubNotifications) Following(race *racing.Race) ([]*racing.Device, error) {
+	return r.devices, nil
+}
+
+func (r *stubNotifications) Cursor(name string, initial int64) (int64, error) {
+	if position, ok := r.cursors[name]; ok {
+		return position, nil
+	}
+
+	return initial, nil
+}
+
+func (r *stubNotifications) SetCursor(name string, position int64) error {
+	r.cursors[name] = position
+
+	return nil
+}
+
+// testFanout wires a fanout to stub repositories and a recording provider.
+type testFanout struct {
+	*Fanout
+	races         *stubRaces
+	notifications *stubNotifications
+	push          *recordingProvider
+}
+
+func newTestFanout() *testFanout {
+	f := &testFanout{
+		races: &stubRaces{},
+		notifications: &stubNotifications{
+			cursors: make(map[string]int64),
+			devices: []*racing.Device{{Channel: "push", Token: "device"}},
+		},
+		push: &recordingProvider{},
+	}
+
+	f.Fanout = NewFanout(f.races, f.notifications, map[string]Provider{"push": f.push}, 5*time.Minute)
+
+	return f
+}
+
+// testRace returns a visible race starting the given time from now.
+func testRace(id, number int64, name string, startsIn time.Duration) *racing.Race {
+	return &racing.Race{
+		Id:                  id,
+		MeetingId:           1,
+		Name:                name,
+		Number:              number,
+		Visible:             true,
+		AdvertisedStartTime: timestamppb.New(time.Now().Add(startsIn)),
+	}
+}
+
+// statusChange returns a change of a race's status at the given time.
+func statusChange(id int64, race *racing.Race, from, to racing.Race_Status, at time.Time) *db.StatusChange {
+	return &db.StatusChange{
+		ID:         id,
+		Transition: &racing.StatusTransition{FromStatus: from, ToStatus: to, ChangedAt: timestamppb.New(at)},
+		Race:       race,
+	}
+}
+
+func TestFanoutNotifiesStatusChanges(t *testing.T) {
+	now := time.Now()
+	race := testRace(1, 3, "Flemington", time.Hour)
+
+	tests := []struct {
+		name     string
+		change   *db.StatusChange
+		wantSent []string
+	}{
+		{
+			"closed",
+			statusChange(11, race, racing.Race_OPEN, racing.Race_CLOSED, now),
+			[]string{"device: R3 Flemington: Now CLOSED"},
+		},
+		{
+			"created",
+			statusChange(11, race, racing.Race_UNSPECIFIED, racing.Race_OPEN, now),
+			nil,
+		},
+		{
+			"archived",
+			statusChange(11, nil, racing.Race_OPEN, racing.Race_CLOSED, now),
+			nil,
+		},
+		{
+			"stale",
+			statusChange(11, race, racing.Race_OPEN, racing.Race_CLOSED, now.Add(-maxAge-time.Minute)),
+			nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newTestFanout()
+			f.notifications.cursors[statusCursor] = 10
+			f.races.changes = []*db.StatusChange{statusChange(10, race, racing.Race_OPEN, racing.Race_CLOSED, now), tt.change}
+
+			if err := f.notifyStatusChanges(context.Background()); err != nil {
+				t.Fatalf("notifyStatusChanges() error = %s", err)
+			}
+
+			if !reflect.DeepEqual(f.push.sent, tt.wantSent) {
+				t.Errorf("sent %q, want %q", f.push.sent, tt.wantSent)
+			}
+
+			if cursor := f.notifications.cursors[statusCursor]; cursor != 11 {
+				t.Errorf("status cursor = %d, want 11", cursor)
+			}
+		})
+	}
+}
+
+func TestFanoutNotifiesJumps(t *testing.T) {
+	tests := []struct {
+		name     string
+		startsIn time.Duration
+		wantBody string
+	}{
+		{"seconds away", 30 * time.Second, "Jumps in 1 minute"},
+		{"a minute away", time.Minute, "Jumps in 1 minute"},
+		{"over a minute away", 90 * time.Second, "Jumps in 2 minutes"},
+		{"five minutes away", 5*time.Minute - time.Second, "Jumps in 5 minutes"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newTestFanout()
+			f.races.starting = []*racing.Race{testRace(1, 2, "Randwick", tt.startsIn)}
+
+			if err := f.notifyJumps(context.Background()); err != nil {
+				t.Fatalf("notifyJumps() error = %s", err)
+			}
+
+			if want := []string{"device: R2 Randwick: " + tt.wantBody}; !reflect.DeepEqual(f.push.sent, want) {
+				t.Errorf("sent %q, want %q", f.push.sent, want)
+			}
+
+			if cursor, want := f.notifications.cursors[jumpCursor], f.races.before.Unix(); cursor != want {
+				t.Errorf("jump cursor = %d, want %d", cursor, want)
+			}
+		})
+	}
+}
+
+func TestFanoutJumpWindow(t *testing.T) {
+	tests := []struct {
+		name string
+		// notified is how far from now races have been notified up to.
+		notified   time.Duration
+		wantListed bool
+		// wantAfter is how far from now races are listed after.
+		wantAfter time.Duration
+	}{
+		{"caught up", time.Minute, true, time.Minute},
+		{"behind", -time.Hour, true, 0},
+		{"up to date", 5*time.Minute + time.Second, false, 0},
+		{"ahead", time.Hour, false, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newTestFanout()
+
+			now := time.Now()
+			f.notifications.cursors[jumpCursor] = now.Add(tt.notified).Unix()
+
+			if err := f.notifyJumps(context.Background()); err != nil {
+				t.Fatalf("notifyJumps() error = %s", err)
+			}
+
+			if f.races.listed != tt.wantListed {
+				t.Fatalf("listed starting races %t, want %t", f.races.listed, tt.wantListed)
+			}
+
+			if !tt.wantListed {
+				return
+			}
+
+			if want := now.Add(tt.wantAfter); f.races.after.Sub(want) < -time.Second || f.races.after.Sub(want) > time.Second {
+				t.Errorf("ListStarting() after %s, want about %s", f.races.after, want)
+			}
+
+			if lead := f.races.before.Sub(f.races.after); lead > 5*time.Minute-tt.wantAfter+time.Second {
+				t.Errorf("ListStarting() window = %s, want within the jump lead", lead)
+			}
+		})
+	}
+}
+
+func TestFanoutSendsBestEffort(t *testing.T) {
+	f := newTestFanout()
+	f.push.fail = map[string]bool{"broken": true}
+	f.notifications.devices = []*racing.Device{
+		{Channel: "push", Token: "first"},
+		{Channel: "push", Token: "broken"},
+		{Channel: "sms", Token: "unsupported"},
+		{Channel: "push", Token: "last"},
+	}
+
+	race := testRace(1, 1, "Caulfield", time.Hour)
+
+	if err := f.fanout(context.Background(), race, Notification{RaceID: 1, Title: title(race), Body: "Now CLOSED"}); err != nil {
+		t.Fatalf("fanout() error = %s", err)
+	}
+
+	want := []string{"first: R1 Caulfield: Now CLOSED", "last: R1 Caulfield: Now CLOSED"}
+	if !reflect.DeepEqual(f.push.sent, want) {
+		t.Errorf("sent %q, want %q", f.push.sent, want)
+	}
+}
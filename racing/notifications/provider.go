@@ -0,0 +1,86 @@
+package notifications
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+)
+
+// Notification is a push notification about a race.
+type Notification struct {
+	RaceID int64  `json:"race_id"`
+	Title  string `json:"title"`
+	Body   string `json:"body"`
+}
+
+// Provider sends push notifications to devices over a channel, such as a
+// push service.
+type Provider interface {
+	// Send sends a notification to the device with the given token.
+	Send(ctx context.Context, token string, n Notification) error
+}
+
+// LogProvider logs notifications rather than sending them, for development.
+type LogProvider struct{}
+
+// Send logs the notification.
+func (LogProvider) Send(_ context.Context, token string, n Notification) error {
+	log.Printf("push notification to %s: %s: %s\n", token, n.Title, n.Body)
+
+	return nil
+}
+
+// GatewayProvider sends notifications through an HTTP push gateway, which
+// relays them on to the push services devices are registered with.
+type GatewayProvider struct {
+	url    string
+	client *http.Client
+}
+
+// NewGatewayProvider creates a provider POSTing notifications to the gateway
+// at the given URL.
+func NewGatewayProvider(url string, client *http.Client) *GatewayProvider {
+	return &GatewayProvider{url: url, client: client}
+}
+
+// gatewayMessage is the JSON body POSTed to the gateway.
+type gatewayMessage struct {
+	Token string `json:"token"`
+	Notification
+}
+
+// Send POSTs the notification to the gateway, returning an error unless it
+// was accepted.
+func (p *GatewayProvider) Send(ctx context.Context, token string, n Notification) error {
+	body, err := json.Marshal(gatewayMessage{Token: token, Notification: n})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// Drain the body, so the connection can be reused.
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected response: %s", resp.Status)
+	}
+
+	return nil
+}
@@ -0,0 +1,790 @@
+package racing
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// These validation rules mirror what protoc-gen-validate would generate, so
+// requests can be validated generically by checking for a Validate method.
+
+const (
+	// maxTimeZoneLen caps the length of IANA time zone names.
+	maxTimeZoneLen = 64
+	// maxLocaleLen caps the length of BCP 47 language tags.
+	maxLocaleLen = 35
+	// maxExternalRefLen caps the length of external sources and their IDs.
+	maxExternalRefLen = 128
+	// maxCallbackURLLen caps the length of subscription callback URLs.
+	maxCallbackURLLen = 2048
+	// maxDeviceTokenLen caps the length of push notification device tokens.
+	maxDeviceTokenLen = 4096
+	// MaxUserIDLen caps the length of the IDs of users watchlists are kept
+	// for, and callers are authenticated as.
+	MaxUserIDLen = 128
+	// maxSearchQueryLen caps the length of race search queries.
+	maxSearchQueryLen = 256
+	// maxSearchLimit caps how many races a search returns.
+	maxSearchLimit = 100
+	// maxFilterIDs caps how many IDs a filter can list, e.g. meeting_ids.
+	maxFilterIDs = 100
+	// maxRecordedViews caps how many views of a race can be recorded at once.
+	maxRecordedViews = 1000000
+	// maxPopularLimit caps how many popular races are listed.
+	maxPopularLimit = 100
+	// maxPopularWindowHours caps how many hours of views popular races are
+	// ranked by.
+	maxPopularWindowHours = 168
+	// maxRelatedLimit caps how many related races are listed.
+	maxRelatedLimit = 50
+	// maxDaysListed caps how many days races can be listed by day for, after
+	// the first.
+	maxDaysListed = 31
+	// dateLayout is the layout of calendar dates, e.g. 2021-03-01.
+	dateLayout = "2006-01-02"
+)
+
+var (
+	countryCodePattern = regexp.MustCompile(`^[A-Za-z]{2}$`)
+	// channelPattern matches push notification channel names.
+	channelPattern = regexp.MustCompile(`^[a-z0-9-]{1,32}$`)
+	// JurisdictionPattern matches the codes of jurisdictions races can be
+	// restricted in, and callers say they're from, e.g. NSW.
+	JurisdictionPattern = regexp.MustCompile(`^[A-Z0-9-]{1,16}$`)
+	// BrandPattern matches the names of brands races can be shown or hidden
+	// for, and callers say they're from, e.g. neds.
+	BrandPattern = regexp.MustCompile(`^[a-z0-9-]{1,32}$`)
+)
+
+// ValidationError describes a request field that failed validation.
+type ValidationError struct {
+	// Field is the path to the invalid field, e.g. filter.meeting_ids[0].
+	Field string
+	// Reason describes why the field is invalid.
+	Reason string
+}
+
+func (e ValidationError) Error() string {
+	return fmt.Sprintf("invalid %s: %s", e.Field, e.Reason)
+}
+
+// Validate checks the ListRacesRequest against its validation rules.
+func (m *ListRacesRequest) Validate() error {
+	if m == nil {
+		return nil
+	}
+
+	if err := m.GetFilter().validate("filter"); err != nil {
+		return err
+	}
+
+	if m.AsOf != nil {
+		if err := m.AsOf.CheckValid(); err != nil {
+			return ValidationError{
+				Field:  "as_of",
+				Reason: err.Error(),
+			}
+		}
+	}
+
+	if m.GetPageSize() < 0 {
+		return ValidationError{
+			Field:  "page_size",
+			Reason: "value must be greater than or equal to 0",
+		}
+	}
+
+	if m.GetPageToken() != "" {
+		if after, err := strconv.ParseInt(m.GetPageToken(), 10, 64); err != nil || after < 0 {
+			return ValidationError{
+				Field:  "page_token",
+				Reason: "value must be a token returned by ListRaces",
+			}
+		}
+	}
+
+	if _, ok := ListRacesRequest_OrderBy_name[int32(m.GetOrderBy())]; !ok {
+		return ValidationError{
+			Field:  "order_by",
+			Reason: "value must be one of the defined enum values",
+		}
+	}
+
+	if m.GetOrderBy() == ListRacesRequest_POPULARITY && m.AsOf != nil {
+		return ValidationError{
+			Field:  "order_by",
+			Reason: "value can't be POPULARITY with as_of",
+		}
+	}
+
+	return validateLocalisation(m.GetTimeZone(), m.GetLocale())
+}
+
+// Validate checks the ListArchivedRacesRequest against its validation rules.
+func (m *ListArchivedRacesRequest) Validate() error {
+	if m == nil {
+		return nil
+	}
+
+	if err := m.GetFilter().validate("filter"); err != nil {
+		return err
+	}
+
+	if m.DateRange != nil {
+		if err := m.DateRange.validate("date_range", 0); err != nil {
+			return err
+		}
+	}
+
+	if m.GetPageSize() < 0 {
+		return ValidationError{
+			Field:  "page_size",
+			Reason: "value must be greater than or equal to 0",
+		}
+	}
+
+	if m.GetPageToken() != "" {
+		if after, err := strconv.ParseInt(m.GetPageToken(), 10, 64); err != nil || after < 0 {
+			return ValidationError{
+				Field:  "page_token",
+				Reason: "value must be a token returned by ListArchivedRaces",
+			}
+		}
+	}
+
+	return validateLocalisation(m.GetTimeZone(), m.GetLocale())
+}
+
+// Validate checks the ListMeetingsRequest against its validation rules.
+func (m *ListMeetingsRequest) Validate() error {
+	if m == nil {
+		return nil
+	}
+
+	if err := m.GetFilter().validate("filter"); err != nil {
+		return err
+	}
+
+	if m.GetPageSize() < 0 {
+		return ValidationError{
+			Field:  "page_size",
+			Reason: "value must be greater than or equal to 0",
+		}
+	}
+
+	if m.GetPageToken() != "" {
+		if after, err := strconv.ParseInt(m.GetPageToken(), 10, 64); err != nil || after < 0 {
+			return ValidationError{
+				Field:  "page_token",
+				Reason: "value must be a token returned by ListMeetings",
+			}
+		}
+	}
+
+	return validateLocalisation(m.GetTimeZone(), m.GetLocale())
+}
+
+// Validate checks the ListRelatedRacesRequest against its validation rules.
+func (m *ListRelatedRacesRequest) Validate() error {
+	if m == nil {
+		return nil
+	}
+
+	if m.GetRaceId() <= 0 {
+		return ValidationError{
+			Field:  "race_id",
+			Reason: "value must be greater than 0",
+		}
+	}
+
+	if m.GetLimit() < 0 || m.GetLimit() > maxRelatedLimit {
+		return ValidationError{
+			Field:  "limit",
+			Reason: fmt.Sprintf("value must be between 0 and %d", maxRelatedLimit),
+		}
+	}
+
+	return validateLocalisation(m.GetTimeZone(), m.GetLocale())
+}
+
+// Validate checks the GetRaceStatsRequest against its validation rules.
+func (m *GetRaceStatsRequest) Validate() error {
+	if m == nil {
+		return nil
+	}
+
+	return m.GetFilter().validate("filter")
+}
+
+// Validate checks the ListRacesByDayRequest against its validation rules.
+func (m *ListRacesByDayRequest) Validate() error {
+	if m == nil {
+		return nil
+	}
+
+	if err := m.GetDateRange().validate("date_range", maxDaysListed); err != nil {
+		return err
+	}
+
+	if err := m.GetFilter().validate("filter"); err != nil {
+		return err
+	}
+
+	return validateLocalisation("", m.GetLocale())
+}
+
+// validate checks the DateRange is a range of days, spanning at most maxDays
+// days after its first unless maxDays is 0. A start date is required.
+func (m *DateRange) validate(path string, maxDays int) error {
+	start, err := time.Parse(dateLayout, m.GetStartDate())
+	if err != nil {
+		return ValidationError{
+			Field:  path + ".start_date",
+			Reason: "value must be a date formatted as YYYY-MM-DD",
+		}
+	}
+
+	if m.GetEndDate() == "" {
+		return nil
+	}
+
+	end, err := time.Parse(dateLayout, m.GetEndDate())
+	if err != nil {
+		return ValidationError{
+			Field:  path + ".end_date",
+			Reason: "value must be a date formatted as YYYY-MM-DD",
+		}
+	}
+
+	if end.Before(start) {
+		return ValidationError{
+			Field:  path + ".end_date",
+			Reason: "value must be on or after start_date",
+		}
+	}
+
+	if maxDays != 0 && end.After(start.AddDate(0, 0, maxDays)) {
+		return ValidationError{
+			Field:  path + ".end_date",
+			Reason: fmt.Sprintf("value must be at most %d days after start_date", maxDays),
+		}
+	}
+
+	return nil
+}
+
+// Validate checks the ListRacesRequestFilter against its validation rules.
+func (m *ListRacesRequestFilter) Validate() error {
+	return m.validate("")
+}
+
+func (m *ListRacesRequestFilter) validate(path string) error {
+	if m == nil {
+		return nil
+	}
+
+	if path != "" {
+		path += "."
+	}
+
+	if err := validateIDs(path+"meeting_ids", m.GetMeetingIds()); err != nil {
+		return err
+	}
+
+	for i, country := range m.GetCountries() {
+		if !countryCodePattern.MatchString(country) {
+			return ValidationError{
+				Field:  fmt.Sprintf("%scountries[%d]", path, i),
+				Reason: "value must be an ISO 3166-1 alpha-2 country code",
+			}
+		}
+	}
+
+	if m.GetMinDistance() < 0 {
+		return ValidationError{
+			Field:  path + "min_distance",
+			Reason: "value must be greater than or equal to 0",
+		}
+	}
+
+	if m.GetMaxDistance() < 0 {
+		return ValidationError{
+			Field:  path + "max_distance",
+			Reason: "value must be greater than or equal to 0",
+		}
+	}
+
+	if m.GetMaxDistance() > 0 && m.GetMinDistance() > m.GetMaxDistance() {
+		return ValidationError{
+			Field:  path + "min_distance",
+			Reason: "value must be less than or equal to max_distance",
+		}
+	}
+
+	if _, ok := ListRacesRequestFilter_Visibility_name[int32(m.GetVisibility())]; !ok {
+		return ValidationError{
+			Field:  path + "visibility",
+			Reason: "value must be one of the defined enum values",
+		}
+	}
+
+	return nil
+}
+
+func validateLocalisation(timeZone, locale string) error {
+	if len(timeZone) > maxTimeZoneLen {
+		return ValidationError{
+			Field:  "time_zone",
+			Reason: fmt.Sprintf("value length must be at most %d bytes", maxTimeZoneLen),
+		}
+	}
+
+	if timeZone != "" {
+		if _, err := time.LoadLocation(timeZone); err != nil {
+			return ValidationError{
+				Field:  "time_zone",
+				Reason: "value must be an IANA time zone name",
+			}
+		}
+	}
+
+	if len(locale) > maxLocaleLen {
+		return ValidationError{
+			Field:  "locale",
+			Reason: fmt.Sprintf("value length must be at most %d bytes", maxLocaleLen),
+		}
+	}
+
+	return nil
+}
+
+// Validate checks the GetRaceByExternalRefRequest against its validation rules.
+func (m *GetRaceByExternalRefRequest) Validate() error {
+	if m == nil {
+		return nil
+	}
+
+	if m.GetExternalRef() == nil {
+		return ValidationError{
+			Field:  "external_ref",
+			Reason: "value is required",
+		}
+	}
+
+	fields := []struct {
+		name, value string
+	}{
+		{"external_ref.source", m.GetExternalRef().GetSource()},
+		{"external_ref.source_id", m.GetExternalRef().GetSourceId()},
+	}
+
+	for _, field := range fields {
+		if field.value == "" {
+			return ValidationError{
+				Field:  field.name,
+				Reason: "value is required",
+			}
+		}
+
+		if len(field.value) > maxExternalRefLen {
+			return ValidationError{
+				Field:  field.name,
+				Reason: fmt.Sprintf("value length must be at most %d bytes", maxExternalRefLen),
+			}
+		}
+	}
+
+	return nil
+}
+
+// Validate checks the ListMarketsRequest against its validation rules.
+func (m *ListMarketsRequest) Validate() error {
+	if m == nil {
+		return nil
+	}
+
+	if err := validateIDs("filter.race_ids", m.GetFilter().GetRaceIds()); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Validate checks the GetMarketRequest against its validation rules.
+func (m *GetMarketRequest) Validate() error {
+	return validateID(m.GetId())
+}
+
+// Validate checks the ListSelectionsRequest against its validation rules.
+func (m *ListSelectionsRequest) Validate() error {
+	if m == nil {
+		return nil
+	}
+
+	if err := validateIDs("filter.market_ids", m.GetFilter().GetMarketIds()); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Validate checks the GetSelectionRequest against its validation rules.
+func (m *GetSelectionRequest) Validate() error {
+	return validateID(m.GetId())
+}
+
+// Validate checks the WatchChangesRequest against its validation rules.
+func (m *WatchChangesRequest) Validate() error {
+	if m.GetSinceToken() == "" {
+		return nil
+	}
+
+	if after, err := strconv.ParseInt(m.GetSinceToken(), 10, 64); err != nil || after < 0 {
+		return ValidationError{
+			Field:  "since_token",
+			Reason: "value must be a token returned by WatchChanges",
+		}
+	}
+
+	return nil
+}
+
+// Validate checks the UpdateRaceStatusRequest against its validation rules.
+func (m *UpdateRaceStatusRequest) Validate() error {
+	if m == nil {
+		return nil
+	}
+
+	if m.GetRaceId() <= 0 {
+		return ValidationError{
+			Field:  "race_id",
+			Reason: "value must be greater than 0",
+		}
+	}
+
+	if _, ok := Race_Status_name[int32(m.GetStatus())]; !ok || m.GetStatus() == Race_UNSPECIFIED {
+		return ValidationError{
+			Field:  "status",
+			Reason: "value must be a defined status",
+		}
+	}
+
+	return nil
+}
+
+// Validate checks the ListStatusHistoryRequest against its validation rules.
+func (m *ListStatusHistoryRequest) Validate() error {
+	if m.GetRaceId() <= 0 {
+		return ValidationError{
+			Field:  "race_id",
+			Reason: "value must be greater than 0",
+		}
+	}
+
+	return nil
+}
+
+// Validate checks the SetRaceFeaturedRequest against its validation rules.
+func (m *SetRaceFeaturedRequest) Validate() error {
+	if m.GetRaceId() <= 0 {
+		return ValidationError{
+			Field:  "race_id",
+			Reason: "value must be greater than 0",
+		}
+	}
+
+	return nil
+}
+
+// Validate checks the SetRaceRestrictionsRequest against its validation rules.
+func (m *SetRaceRestrictionsRequest) Validate() error {
+	if m.GetRaceId() <= 0 {
+		return ValidationError{
+			Field:  "race_id",
+			Reason: "value must be greater than 0",
+		}
+	}
+
+	if len(m.GetJurisdictions()) > maxFilterIDs {
+		return ValidationError{
+			Field:  "jurisdictions",
+			Reason: fmt.Sprintf("value must have at most %d items", maxFilterIDs),
+		}
+	}
+
+	for i, jurisdiction := range m.GetJurisdictions() {
+		if !JurisdictionPattern.MatchString(jurisdiction) {
+			return ValidationError{
+				Field:  fmt.Sprintf("jurisdictions[%d]", i),
+				Reason: "value must be 1 to 16 uppercase letters, digits or hyphens",
+			}
+		}
+	}
+
+	return nil
+}
+
+// Validate checks the SetBrandVisibilityRequest against its validation rules.
+func (m *SetBrandVisibilityRequest) Validate() error {
+	if m.GetRaceId() <= 0 {
+		return ValidationError{
+			Field:  "race_id",
+			Reason: "value must be greater than 0",
+		}
+	}
+
+	if !BrandPattern.MatchString(m.GetBrand()) {
+		return ValidationError{
+			Field:  "brand",
+			Reason: "value must be 1 to 32 lowercase letters, digits or hyphens",
+		}
+	}
+
+	if _, ok := SetBrandVisibilityRequest_Visibility_name[int32(m.GetVisibility())]; !ok {
+		return ValidationError{
+			Field:  "visibility",
+			Reason: "value must be one of the defined enum values",
+		}
+	}
+
+	return nil
+}
+
+// Validate checks the RecordRaceViewsRequest against its validation rules.
+func (m *RecordRaceViewsRequest) Validate() error {
+	if m.GetRaceId() <= 0 {
+		return ValidationError{
+			Field:  "race_id",
+			Reason: "value must be greater than 0",
+		}
+	}
+
+	if m.GetViews() < 1 || m.GetViews() > maxRecordedViews {
+		return ValidationError{
+			Field:  "views",
+			Reason: fmt.Sprintf("value must be between 1 and %d", maxRecordedViews),
+		}
+	}
+
+	return nil
+}
+
+// Validate checks the ListPopularRacesRequest against its validation rules.
+func (m *ListPopularRacesRequest) Validate() error {
+	if m == nil {
+		return nil
+	}
+
+	if m.GetLimit() < 0 || m.GetLimit() > maxPopularLimit {
+		return ValidationError{
+			Field:  "limit",
+			Reason: fmt.Sprintf("value must be between 0 and %d", maxPopularLimit),
+		}
+	}
+
+	if m.GetWindowHours() < 0 || m.GetWindowHours() > maxPopularWindowHours {
+		return ValidationError{
+			Field:  "window_hours",
+			Reason: fmt.Sprintf("value must be between 0 and %d", maxPopularWindowHours),
+		}
+	}
+
+	return validateLocalisation(m.GetTimeZone(), m.GetLocale())
+}
+
+// Validate checks the ListFeaturedRacesRequest against its validation rules.
+func (m *ListFeaturedRacesRequest) Validate() error {
+	if m == nil {
+		return nil
+	}
+
+	return validateLocalisation(m.GetTimeZone(), m.GetLocale())
+}
+
+// Validate checks the CreateSubscriptionRequest against its validation rules.
+func (m *CreateSubscriptionRequest) Validate() error {
+	if m == nil {
+		return nil
+	}
+
+	if len(m.GetCallbackUrl()) > maxCallbackURLLen {
+		return ValidationError{
+			Field:  "callback_url",
+			Reason: fmt.Sprintf("value length must be at most %d bytes", maxCallbackURLLen),
+		}
+	}
+
+	u, err := url.Parse(m.GetCallbackUrl())
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ValidationError{
+			Field:  "callback_url",
+			Reason: "value must be an absolute http or https URL",
+		}
+	}
+
+	if err := validateIDs("filter.meeting_ids", m.GetFilter().GetMeetingIds()); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Validate checks the DeleteSubscriptionRequest against its validation rules.
+func (m *DeleteSubscriptionRequest) Validate() error {
+	return validateID(m.GetId())
+}
+
+// Validate checks the ListDeliveriesRequest against its validation rules.
+func (m *ListDeliveriesRequest) Validate() error {
+	if m.GetSubscriptionId() <= 0 {
+		return ValidationError{
+			Field:  "subscription_id",
+			Reason: "value must be greater than 0",
+		}
+	}
+
+	return nil
+}
+
+// validateIDs checks a list of IDs to filter by isn't too long to query, and
+// only has IDs greater than 0.
+func validateIDs(field string, ids []int64) error {
+	if len(ids) > maxFilterIDs {
+		return ValidationError{
+			Field:  field,
+			Reason: fmt.Sprintf("value must have at most %d items", maxFilterIDs),
+		}
+	}
+
+	for i, id := range ids {
+		if id <= 0 {
+			return ValidationError{
+				Field:  fmt.Sprintf("%s[%d]", field, i),
+				Reason: "value must be greater than 0",
+			}
+		}
+	}
+
+	return nil
+}
+
+func validateID(id int64) error {
+	if id <= 0 {
+		return ValidationError{
+			Field:  "id",
+			Reason: "value must be greater than 0",
+		}
+	}
+
+	return nil
+}
+
+// Validate checks the RegisterDeviceRequest against its validation rules.
+func (m *RegisterDeviceRequest) Validate() error {
+	if m == nil {
+		return nil
+	}
+
+	if err := validateDevice(m.GetChannel(), m.GetToken()); err != nil {
+		return err
+	}
+
+	if m.GetFilter().GetUserId() != "" {
+		return ValidationError{
+			Field:  "filter.user_id",
+			Reason: "value must be empty, as devices follow the watchlist of the user registering them",
+		}
+	}
+
+	if len(m.GetFilter().GetRaceIds()) == 0 && len(m.GetFilter().GetMeetingIds()) == 0 && !m.GetFollowWatchlist() {
+		return ValidationError{
+			Field:  "filter",
+			Reason: "value must follow at least one race or meeting, or the watchlist",
+		}
+	}
+
+	if err := validateIDs("filter.race_ids", m.GetFilter().GetRaceIds()); err != nil {
+		return err
+	}
+
+	if err := validateIDs("filter.meeting_ids", m.GetFilter().GetMeetingIds()); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Validate checks the UnregisterDeviceRequest against its validation rules.
+func (m *UnregisterDeviceRequest) Validate() error {
+	return validateDevice(m.GetChannel(), m.GetToken())
+}
+
+// validateDevice checks a push notification channel and device token.
+func validateDevice(channel, token string) error {
+	if !channelPattern.MatchString(channel) {
+		return ValidationError{
+			Field:  "channel",
+			Reason: "value must be 1 to 32 lowercase letters, digits or hyphens",
+		}
+	}
+
+	if token == "" || len(token) > maxDeviceTokenLen {
+		return ValidationError{
+			Field:  "token",
+			Reason: fmt.Sprintf("value length must be between 1 and %d bytes", maxDeviceTokenLen),
+		}
+	}
+
+	return nil
+}
+
+// Validate checks the WatchRaceRequest against its validation rules.
+func (m *WatchRaceRequest) Validate() error {
+	if m.GetRaceId() <= 0 {
+		return ValidationError{
+			Field:  "race_id",
+			Reason: "value must be greater than 0",
+		}
+	}
+
+	return nil
+}
+
+// Validate checks the UnwatchRaceRequest against its validation rules.
+func (m *UnwatchRaceRequest) Validate() error {
+	if m.GetRaceId() <= 0 {
+		return ValidationError{
+			Field:  "race_id",
+			Reason: "value must be greater than 0",
+		}
+	}
+
+	return nil
+}
+
+// Validate checks the ListWatchlistRequest against its validation rules.
+func (m *ListWatchlistRequest) Validate() error {
+	return validateLocalisation(m.GetTimeZone(), m.GetLocale())
+}
+
+// Validate checks the SearchRacesRequest against its validation rules.
+func (m *SearchRacesRequest) Validate() error {
+	if m.GetQuery() == "" || len(m.GetQuery()) > maxSearchQueryLen {
+		return ValidationError{
+			Field:  "query",
+			Reason: fmt.Sprintf("value length must be between 1 and %d bytes", maxSearchQueryLen),
+		}
+	}
+
+	if m.GetLimit() < 0 || m.GetLimit() > maxSearchLimit {
+		return ValidationError{
+			Field:  "limit",
+			Reason: fmt.Sprintf("value must be between 0 and %d", maxSearchLimit),
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,78 @@
+package racing
+
+import (
+	"errors"
+	"testing"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestValidateFilterIDs(t *testing.T) {
+	tooMany := make([]int64, maxFilterIDs+1)
+	for i := range tooMany {
+		tooMany[i] = int64(i + 1)
+	}
+
+	tests := []struct {
+		name      string
+		req       interface{ Validate() error }
+		wantField string
+	}{
+		{"meeting ids", &ListRacesRequest{Filter: &ListRacesRequestFilter{MeetingIds: []int64{1, 2}}}, ""},
+		{"as many meeting ids as allowed", &ListRacesRequest{Filter: &ListRacesRequestFilter{MeetingIds: tooMany[:maxFilterIDs]}}, ""},
+		{"zero meeting id", &ListRacesRequest{Filter: &ListRacesRequestFilter{MeetingIds: []int64{1, 0}}}, "filter.meeting_ids[1]"},
+		{"negative meeting id", &ListArchivedRacesRequest{Filter: &ListRacesRequestFilter{MeetingIds: []int64{-3}}}, "filter.meeting_ids[0]"},
+		{"too many meeting ids", &ListRacesRequest{Filter: &ListRacesRequestFilter{MeetingIds: tooMany}}, "filter.meeting_ids"},
+		{"too many race ids", &ListMarketsRequest{Filter: &ListMarketsRequestFilter{RaceIds: tooMany}}, "filter.race_ids"},
+		{"zero market id", &ListSelectionsRequest{Filter: &ListSelectionsRequestFilter{MarketIds: []int64{0}}}, "filter.market_ids[0]"},
+		{"too many subscribed meetings", &CreateSubscriptionRequest{CallbackUrl: "https://example.com/hook", Filter: &SubscriptionFilter{MeetingIds: tooMany}}, "filter.meeting_ids"},
+		{"too many followed races", &RegisterDeviceRequest{Channel: "log", Token: "device", Filter: &DeviceFilter{RaceIds: tooMany}}, "filter.race_ids"},
+		{"zero id", &GetMarketRequest{}, "id"},
+		{"negative id", &GetSelectionRequest{Id: -1}, "id"},
+		{"zero race id", &ListStatusHistoryRequest{}, "race_id"},
+		{"following the watchlist", &RegisterDeviceRequest{Channel: "log", Token: "device", FollowWatchlist: true}, ""},
+		{"following a named user", &RegisterDeviceRequest{Channel: "log", Token: "device", Filter: &DeviceFilter{UserId: "punter"}}, "filter.user_id"},
+		{"following nothing", &RegisterDeviceRequest{Channel: "log", Token: "device", Filter: &DeviceFilter{}}, "filter"},
+		{"watching zero race id", &WatchRaceRequest{}, "race_id"},
+		{"unwatching zero race id", &UnwatchRaceRequest{}, "race_id"},
+		{"listing a watchlist", &ListWatchlistRequest{}, ""},
+		{"restricted in jurisdictions", &SetRaceRestrictionsRequest{RaceId: 1, Jurisdictions: []string{"SA", "NSW"}}, ""},
+		{"lowercase jurisdiction", &SetRaceRestrictionsRequest{RaceId: 1, Jurisdictions: []string{"SA", "nsw"}}, "jurisdictions[1]"},
+		{"hidden for brand", &SetBrandVisibilityRequest{RaceId: 1, Brand: "neds", Visibility: SetBrandVisibilityRequest_HIDDEN}, ""},
+		{"uppercase brand", &SetBrandVisibilityRequest{RaceId: 1, Brand: "Neds"}, "brand"},
+		{"undefined brand visibility", &SetBrandVisibilityRequest{RaceId: 1, Brand: "neds", Visibility: 3}, "visibility"},
+		{"no views", &RecordRaceViewsRequest{RaceId: 1}, "views"},
+		{"too many views", &RecordRaceViewsRequest{RaceId: 1, Views: maxRecordedViews + 1}, "views"},
+		{"popular races for a week", &ListPopularRacesRequest{Limit: maxPopularLimit, WindowHours: maxPopularWindowHours}, ""},
+		{"popular races for too long", &ListPopularRacesRequest{WindowHours: maxPopularWindowHours + 1}, "window_hours"},
+		{"stats for a zero meeting id", &GetRaceStatsRequest{Filter: &ListRacesRequestFilter{MeetingIds: []int64{0}}}, "filter.meeting_ids[0]"},
+		{"races for a month", &ListRacesByDayRequest{DateRange: &DateRange{StartDate: "2021-03-01", EndDate: "2021-04-01"}}, ""},
+		{"races for a day", &ListRacesByDayRequest{DateRange: &DateRange{StartDate: "2021-03-01"}}, ""},
+		{"races without a date range", &ListRacesByDayRequest{}, "date_range.start_date"},
+		{"races for a timestamp", &ListRacesByDayRequest{DateRange: &DateRange{StartDate: "2021-03-01T00:00:00Z"}}, "date_range.start_date"},
+		{"races for a backwards range", &ListRacesByDayRequest{DateRange: &DateRange{StartDate: "2021-03-02", EndDate: "2021-03-01"}}, "date_range.end_date"},
+		{"races for too many days", &ListRacesByDayRequest{DateRange: &DateRange{StartDate: "2021-03-01", EndDate: "2021-04-02"}}, "date_range.end_date"},
+		{"archived races for a year", &ListArchivedRacesRequest{DateRange: &DateRange{StartDate: "2020-03-01", EndDate: "2021-02-28"}}, ""},
+		{"archived races from no date", &ListArchivedRacesRequest{DateRange: &DateRange{EndDate: "2021-02-28"}}, "date_range.start_date"},
+		{"archived races for a bad page token", &ListArchivedRacesRequest{PageToken: "abc"}, "page_token"},
+		{"meetings in a bad time zone", &ListMeetingsRequest{TimeZone: "Mars/Olympus_Mons"}, "time_zone"},
+		{"meetings for a negative page size", &ListMeetingsRequest{PageSize: -1}, "page_size"},
+		{"meetings for a bad page token", &ListMeetingsRequest{PageToken: "-7"}, "page_token"},
+		{"related to zero race id", &ListRelatedRacesRequest{}, "race_id"},
+		{"too many related races", &ListRelatedRacesRequest{RaceId: 1, Limit: maxRelatedLimit + 1}, "limit"},
+		{"races by popularity as of", &ListRacesRequest{OrderBy: ListRacesRequest_POPULARITY, AsOf: &timestamppb.Timestamp{}}, "order_by"},
+	}
+
+	for _, test := range tests {
+		err := test.req.Validate()
+
+		var validationErr ValidationError
+		if errors.As(err, &validationErr) {
+			if validationErr.Field != test.wantField {
+				t.Errorf("%s: invalid field = %q, want %q", test.name, validationErr.Field, test.wantField)
+			}
+		} else if err != nil || test.wantField != "" {
+			t.Errorf("%s: Validate() = %v, want an invalid %q", test.name, err, test.wantField)
+		}
+	}
+}
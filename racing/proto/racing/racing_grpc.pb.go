@@ -20,6 +20,89 @@ const _ = grpc.SupportPackageIsVersion7
 type RacingClient interface {
 	// ListRaces will return a collection of all races.
 	ListRaces(ctx context.Context, in *ListRacesRequest, opts ...grpc.CallOption) (*ListRacesResponse, error)
+	// ListArchivedRaces will return a collection of archived races.
+	ListArchivedRaces(ctx context.Context, in *ListArchivedRacesRequest, opts ...grpc.CallOption) (*ListArchivedRacesResponse, error)
+	// GetRaceByExternalRef will return the race an external source knows by
+	// the given ID.
+	GetRaceByExternalRef(ctx context.Context, in *GetRaceByExternalRefRequest, opts ...grpc.CallOption) (*Race, error)
+	// ListMarkets will return a collection of markets.
+	ListMarkets(ctx context.Context, in *ListMarketsRequest, opts ...grpc.CallOption) (*ListMarketsResponse, error)
+	// GetMarket will return a single market by its ID.
+	GetMarket(ctx context.Context, in *GetMarketRequest, opts ...grpc.CallOption) (*Market, error)
+	// ListSelections will return a collection of market selections.
+	ListSelections(ctx context.Context, in *ListSelectionsRequest, opts ...grpc.CallOption) (*ListSelectionsResponse, error)
+	// GetSelection will return a single market selection by its ID.
+	GetSelection(ctx context.Context, in *GetSelectionRequest, opts ...grpc.CallOption) (*Selection, error)
+	// WatchChanges will replay the changes made to races since the given
+	// token, then stream changes as they're made.
+	WatchChanges(ctx context.Context, in *WatchChangesRequest, opts ...grpc.CallOption) (Racing_WatchChangesClient, error)
+	// CreateSubscription will register a callback URL to be notified of
+	// changes to races matching the given filter.
+	CreateSubscription(ctx context.Context, in *CreateSubscriptionRequest, opts ...grpc.CallOption) (*Subscription, error)
+	// DeleteSubscription will stop notifying a subscription.
+	DeleteSubscription(ctx context.Context, in *DeleteSubscriptionRequest, opts ...grpc.CallOption) (*DeleteSubscriptionResponse, error)
+	// ListDeliveries will return the notifications sent, or being sent, to a
+	// subscription.
+	ListDeliveries(ctx context.Context, in *ListDeliveriesRequest, opts ...grpc.CallOption) (*ListDeliveriesResponse, error)
+	// UpdateRaceStatus will move a race to a new status in its lifecycle,
+	// recording the user the caller is authenticated as against the change.
+	UpdateRaceStatus(ctx context.Context, in *UpdateRaceStatusRequest, opts ...grpc.CallOption) (*Race, error)
+	// ListStatusHistory will return a race's status transitions, oldest first.
+	ListStatusHistory(ctx context.Context, in *ListStatusHistoryRequest, opts ...grpc.CallOption) (*ListStatusHistoryResponse, error)
+	// SetRaceFeatured will feature a race in the carousel marketing curates for
+	// the home screen, or stop featuring it.
+	SetRaceFeatured(ctx context.Context, in *SetRaceFeaturedRequest, opts ...grpc.CallOption) (*SetRaceFeaturedResponse, error)
+	// ListFeaturedRaces will return the visible featured races, in the order
+	// they were featured.
+	ListFeaturedRaces(ctx context.Context, in *ListFeaturedRacesRequest, opts ...grpc.CallOption) (*ListFeaturedRacesResponse, error)
+	// SetRaceRestrictions will replace the jurisdictions a race is restricted
+	// in, that it isn't shown to callers from.
+	SetRaceRestrictions(ctx context.Context, in *SetRaceRestrictionsRequest, opts ...grpc.CallOption) (*SetRaceRestrictionsResponse, error)
+	// SetBrandVisibility will show or hide a race for a brand, overriding
+	// whether it's visible to callers from the brand.
+	SetBrandVisibility(ctx context.Context, in *SetBrandVisibilityRequest, opts ...grpc.CallOption) (*SetBrandVisibilityResponse, error)
+	// RecordRaceViews will count views of a race, for ranking popular races.
+	// Races looked up through the service are counted already; this counts
+	// views seen elsewhere, e.g. by a web cache.
+	RecordRaceViews(ctx context.Context, in *RecordRaceViewsRequest, opts ...grpc.CallOption) (*RecordRaceViewsResponse, error)
+	// ListPopularRaces will return the visible races viewed most recently,
+	// most viewed first.
+	ListPopularRaces(ctx context.Context, in *ListPopularRacesRequest, opts ...grpc.CallOption) (*ListPopularRacesResponse, error)
+	// GetRaceStats will count races, grouped by meeting, country, status and
+	// the day they start on, for dashboards.
+	GetRaceStats(ctx context.Context, in *GetRaceStatsRequest, opts ...grpc.CallOption) (*RaceStats, error)
+	// ListRacesByDay will return the races starting on each calendar day in a
+	// date range, grouped by the day they start on at their venue.
+	ListRacesByDay(ctx context.Context, in *ListRacesByDayRequest, opts ...grpc.CallOption) (*ListRacesByDayResponse, error)
+	// ListMeetings will return meetings, each with their races.
+	ListMeetings(ctx context.Context, in *ListMeetingsRequest, opts ...grpc.CallOption) (*ListMeetingsResponse, error)
+	// ListRelatedRaces will return other visible open races yet to start, at
+	// the same meeting as a race or starting within an hour of it.
+	ListRelatedRaces(ctx context.Context, in *ListRelatedRacesRequest, opts ...grpc.CallOption) (*ListRelatedRacesResponse, error)
+	// RegisterDevice will register a device to be sent push notifications
+	// about the races it follows, replacing what it followed if it was
+	// already registered.
+	RegisterDevice(ctx context.Context, in *RegisterDeviceRequest, opts ...grpc.CallOption) (*Device, error)
+	// UnregisterDevice will stop sending push notifications to a device.
+	UnregisterDevice(ctx context.Context, in *UnregisterDeviceRequest, opts ...grpc.CallOption) (*UnregisterDeviceResponse, error)
+	// WatchRace will add a race to the watchlist of the user the caller is
+	// authenticated as, in x-user-id metadata, so the devices following the
+	// user are notified as it approaches its start.
+	WatchRace(ctx context.Context, in *WatchRaceRequest, opts ...grpc.CallOption) (*WatchedRace, error)
+	// UnwatchRace will remove a race from the caller's watchlist.
+	UnwatchRace(ctx context.Context, in *UnwatchRaceRequest, opts ...grpc.CallOption) (*UnwatchRaceResponse, error)
+	// ListWatchlist will return the races on the caller's watchlist, in the
+	// order they were watched, with their live status.
+	ListWatchlist(ctx context.Context, in *ListWatchlistRequest, opts ...grpc.CallOption) (*ListWatchlistResponse, error)
+	// SearchRaces will return the races best matching a free text query,
+	// tolerating typos and ranking them by relevance.
+	SearchRaces(ctx context.Context, in *SearchRacesRequest, opts ...grpc.CallOption) (*SearchRacesResponse, error)
+	// IngestRaces will create or update the streamed races, deduplicated by
+	// their external references, writing them in batched transactions.
+	IngestRaces(ctx context.Context, opts ...grpc.CallOption) (Racing_IngestRacesClient, error)
+	// GetReconciliationReport will return the report of the last
+	// reconciliation of races against the feed they're ingested from.
+	GetReconciliationReport(ctx context.Context, in *GetReconciliationReportRequest, opts ...grpc.CallOption) (*ReconciliationReport, error)
 }
 
 type racingClient struct {
@@ -39,12 +122,413 @@ func (c *racingClient) ListRaces(ctx context.Context, in *ListRacesRequest, opts
 	return out, nil
 }
 
+func (c *racingClient) ListArchivedRaces(ctx context.Context, in *ListArchivedRacesRequest, opts ...grpc.CallOption) (*ListArchivedRacesResponse, error) {
+	out := new(ListArchivedRacesResponse)
+	err := c.cc.Invoke(ctx, "/racing.Racing/ListArchivedRaces", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *racingClient) GetRaceByExternalRef(ctx context.Context, in *GetRaceByExternalRefRequest, opts ...grpc.CallOption) (*Race, error) {
+	out := new(Race)
+	err := c.cc.Invoke(ctx, "/racing.Racing/GetRaceByExternalRef", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *racingClient) ListMarkets(ctx context.Context, in *ListMarketsRequest, opts ...grpc.CallOption) (*ListMarketsResponse, error) {
+	out := new(ListMarketsResponse)
+	err := c.cc.Invoke(ctx, "/racing.Racing/ListMarkets", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *racingClient) GetMarket(ctx context.Context, in *GetMarketRequest, opts ...grpc.CallOption) (*Market, error) {
+	out := new(Market)
+	err := c.cc.Invoke(ctx, "/racing.Racing/GetMarket", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *racingClient) ListSelections(ctx context.Context, in *ListSelectionsRequest, opts ...grpc.CallOption) (*ListSelectionsResponse, error) {
+	out := new(ListSelectionsResponse)
+	err := c.cc.Invoke(ctx, "/racing.Racing/ListSelections", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *racingClient) GetSelection(ctx context.Context, in *GetSelectionRequest, opts ...grpc.CallOption) (*Selection, error) {
+	out := new(Selection)
+	err := c.cc.Invoke(ctx, "/racing.Racing/GetSelection", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *racingClient) WatchChanges(ctx context.Context, in *WatchChangesRequest, opts ...grpc.CallOption) (Racing_WatchChangesClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Racing_ServiceDesc.Streams[0], "/racing.Racing/WatchChanges", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &racingWatchChangesClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Racing_WatchChangesClient interface {
+	Recv() (*RaceChange, error)
+	grpc.ClientStream
+}
+
+type racingWatchChangesClient struct {
+	grpc.ClientStream
+}
+
+func (x *racingWatchChangesClient) Recv() (*RaceChange, error) {
+	m := new(RaceChange)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *racingClient) CreateSubscription(ctx context.Context, in *CreateSubscriptionRequest, opts ...grpc.CallOption) (*Subscription, error) {
+	out := new(Subscription)
+	err := c.cc.Invoke(ctx, "/racing.Racing/CreateSubscription", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *racingClient) DeleteSubscription(ctx context.Context, in *DeleteSubscriptionRequest, opts ...grpc.CallOption) (*DeleteSubscriptionResponse, error) {
+	out := new(DeleteSubscriptionResponse)
+	err := c.cc.Invoke(ctx, "/racing.Racing/DeleteSubscription", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *racingClient) ListDeliveries(ctx context.Context, in *ListDeliveriesRequest, opts ...grpc.CallOption) (*ListDeliveriesResponse, error) {
+	out := new(ListDeliveriesResponse)
+	err := c.cc.Invoke(ctx, "/racing.Racing/ListDeliveries", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *racingClient) UpdateRaceStatus(ctx context.Context, in *UpdateRaceStatusRequest, opts ...grpc.CallOption) (*Race, error) {
+	out := new(Race)
+	err := c.cc.Invoke(ctx, "/racing.Racing/UpdateRaceStatus", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *racingClient) ListStatusHistory(ctx context.Context, in *ListStatusHistoryRequest, opts ...grpc.CallOption) (*ListStatusHistoryResponse, error) {
+	out := new(ListStatusHistoryResponse)
+	err := c.cc.Invoke(ctx, "/racing.Racing/ListStatusHistory", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *racingClient) SetRaceFeatured(ctx context.Context, in *SetRaceFeaturedRequest, opts ...grpc.CallOption) (*SetRaceFeaturedResponse, error) {
+	out := new(SetRaceFeaturedResponse)
+	err := c.cc.Invoke(ctx, "/racing.Racing/SetRaceFeatured", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *racingClient) ListFeaturedRaces(ctx context.Context, in *ListFeaturedRacesRequest, opts ...grpc.CallOption) (*ListFeaturedRacesResponse, error) {
+	out := new(ListFeaturedRacesResponse)
+	err := c.cc.Invoke(ctx, "/racing.Racing/ListFeaturedRaces", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *racingClient) SetRaceRestrictions(ctx context.Context, in *SetRaceRestrictionsRequest, opts ...grpc.CallOption) (*SetRaceRestrictionsResponse, error) {
+	out := new(SetRaceRestrictionsResponse)
+	err := c.cc.Invoke(ctx, "/racing.Racing/SetRaceRestrictions", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *racingClient) SetBrandVisibility(ctx context.Context, in *SetBrandVisibilityRequest, opts ...grpc.CallOption) (*SetBrandVisibilityResponse, error) {
+	out := new(SetBrandVisibilityResponse)
+	err := c.cc.Invoke(ctx, "/racing.Racing/SetBrandVisibility", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *racingClient) RecordRaceViews(ctx context.Context, in *RecordRaceViewsRequest, opts ...grpc.CallOption) (*RecordRaceViewsResponse, error) {
+	out := new(RecordRaceViewsResponse)
+	err := c.cc.Invoke(ctx, "/racing.Racing/RecordRaceViews", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *racingClient) ListPopularRaces(ctx context.Context, in *ListPopularRacesRequest, opts ...grpc.CallOption) (*ListPopularRacesResponse, error) {
+	out := new(ListPopularRacesResponse)
+	err := c.cc.Invoke(ctx, "/racing.Racing/ListPopularRaces", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *racingClient) GetRaceStats(ctx context.Context, in *GetRaceStatsRequest, opts ...grpc.CallOption) (*RaceStats, error) {
+	out := new(RaceStats)
+	err := c.cc.Invoke(ctx, "/racing.Racing/GetRaceStats", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *racingClient) ListRacesByDay(ctx context.Context, in *ListRacesByDayRequest, opts ...grpc.CallOption) (*ListRacesByDayResponse, error) {
+	out := new(ListRacesByDayResponse)
+	err := c.cc.Invoke(ctx, "/racing.Racing/ListRacesByDay", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *racingClient) ListMeetings(ctx context.Context, in *ListMeetingsRequest, opts ...grpc.CallOption) (*ListMeetingsResponse, error) {
+	out := new(ListMeetingsResponse)
+	err := c.cc.Invoke(ctx, "/racing.Racing/ListMeetings", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *racingClient) ListRelatedRaces(ctx context.Context, in *ListRelatedRacesRequest, opts ...grpc.CallOption) (*ListRelatedRacesResponse, error) {
+	out := new(ListRelatedRacesResponse)
+	err := c.cc.Invoke(ctx, "/racing.Racing/ListRelatedRaces", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *racingClient) RegisterDevice(ctx context.Context, in *RegisterDeviceRequest, opts ...grpc.CallOption) (*Device, error) {
+	out := new(Device)
+	err := c.cc.Invoke(ctx, "/racing.Racing/RegisterDevice", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *racingClient) UnregisterDevice(ctx context.Context, in *UnregisterDeviceRequest, opts ...grpc.CallOption) (*UnregisterDeviceResponse, error) {
+	out := new(UnregisterDeviceResponse)
+	err := c.cc.Invoke(ctx, "/racing.Racing/UnregisterDevice", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *racingClient) WatchRace(ctx context.Context, in *WatchRaceRequest, opts ...grpc.CallOption) (*WatchedRace, error) {
+	out := new(WatchedRace)
+	err := c.cc.Invoke(ctx, "/racing.Racing/WatchRace", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *racingClient) UnwatchRace(ctx context.Context, in *UnwatchRaceRequest, opts ...grpc.CallOption) (*UnwatchRaceResponse, error) {
+	out := new(UnwatchRaceResponse)
+	err := c.cc.Invoke(ctx, "/racing.Racing/UnwatchRace", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *racingClient) ListWatchlist(ctx context.Context, in *ListWatchlistRequest, opts ...grpc.CallOption) (*ListWatchlistResponse, error) {
+	out := new(ListWatchlistResponse)
+	err := c.cc.Invoke(ctx, "/racing.Racing/ListWatchlist", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *racingClient) SearchRaces(ctx context.Context, in *SearchRacesRequest, opts ...grpc.CallOption) (*SearchRacesResponse, error) {
+	out := new(SearchRacesResponse)
+	err := c.cc.Invoke(ctx, "/racing.Racing/SearchRaces", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *racingClient) IngestRaces(ctx context.Context, opts ...grpc.CallOption) (Racing_IngestRacesClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Racing_ServiceDesc.Streams[1], "/racing.Racing/IngestRaces", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &racingIngestRacesClient{stream}
+	return x, nil
+}
+
+type Racing_IngestRacesClient interface {
+	Send(*Race) error
+	CloseAndRecv() (*IngestRacesResponse, error)
+	grpc.ClientStream
+}
+
+type racingIngestRacesClient struct {
+	grpc.ClientStream
+}
+
+func (x *racingIngestRacesClient) Send(m *Race) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *racingIngestRacesClient) CloseAndRecv() (*IngestRacesResponse, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(IngestRacesResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *racingClient) GetReconciliationReport(ctx context.Context, in *GetReconciliationReportRequest, opts ...grpc.CallOption) (*ReconciliationReport, error) {
+	out := new(ReconciliationReport)
+	err := c.cc.Invoke(ctx, "/racing.Racing/GetReconciliationReport", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // RacingServer is the server API for Racing service.
 // All implementations should embed UnimplementedRacingServer
 // for forward compatibility
 type RacingServer interface {
 	// ListRaces will return a collection of all races.
 	ListRaces(context.Context, *ListRacesRequest) (*ListRacesResponse, error)
+	// ListArchivedRaces will return a collection of archived races.
+	ListArchivedRaces(context.Context, *ListArchivedRacesRequest) (*ListArchivedRacesResponse, error)
+	// GetRaceByExternalRef will return the race an external source knows by
+	// the given ID.
+	GetRaceByExternalRef(context.Context, *GetRaceByExternalRefRequest) (*Race, error)
+	// ListMarkets will return a collection of markets.
+	ListMarkets(context.Context, *ListMarketsRequest) (*ListMarketsResponse, error)
+	// GetMarket will return a single market by its ID.
+	GetMarket(context.Context, *GetMarketRequest) (*Market, error)
+	// ListSelections will return a collection of market selections.
+	ListSelections(context.Context, *ListSelectionsRequest) (*ListSelectionsResponse, error)
+	// GetSelection will return a single market selection by its ID.
+	GetSelection(context.Context, *GetSelectionRequest) (*Selection, error)
+	// WatchChanges will replay the changes made to races since the given
+	// token, then stream changes as they're made.
+	WatchChanges(*WatchChangesRequest, Racing_WatchChangesServer) error
+	// CreateSubscription will register a callback URL to be notified of
+	// changes to races matching the given filter.
+	CreateSubscription(context.Context, *CreateSubscriptionRequest) (*Subscription, error)
+	// DeleteSubscription will stop notifying a subscription.
+	DeleteSubscription(context.Context, *DeleteSubscriptionRequest) (*DeleteSubscriptionResponse, error)
+	// ListDeliveries will return the notifications sent, or being sent, to a
+	// subscription.
+	ListDeliveries(context.Context, *ListDeliveriesRequest) (*ListDeliveriesResponse, error)
+	// UpdateRaceStatus will move a race to a new status in its lifecycle,
+	// recording the user the caller is authenticated as against the change.
+	UpdateRaceStatus(context.Context, *UpdateRaceStatusRequest) (*Race, error)
+	// ListStatusHistory will return a race's status transitions, oldest first.
+	ListStatusHistory(context.Context, *ListStatusHistoryRequest) (*ListStatusHistoryResponse, error)
+	// SetRaceFeatured will feature a race in the carousel marketing curates for
+	// the home screen, or stop featuring it.
+	SetRaceFeatured(context.Context, *SetRaceFeaturedRequest) (*SetRaceFeaturedResponse, error)
+	// ListFeaturedRaces will return the visible featured races, in the order
+	// they were featured.
+	ListFeaturedRaces(context.Context, *ListFeaturedRacesRequest) (*ListFeaturedRacesResponse, error)
+	// SetRaceRestrictions will replace the jurisdictions a race is restricted
+	// in, that it isn't shown to callers from.
+	SetRaceRestrictions(context.Context, *SetRaceRestrictionsRequest) (*SetRaceRestrictionsResponse, error)
+	// SetBrandVisibility will show or hide a race for a brand, overriding
+	// whether it's visible to callers from the brand.
+	SetBrandVisibility(context.Context, *SetBrandVisibilityRequest) (*SetBrandVisibilityResponse, error)
+	// RecordRaceViews will count views of a race, for ranking popular races.
+	// Races looked up through the service are counted already; this counts
+	// views seen elsewhere, e.g. by a web cache.
+	RecordRaceViews(context.Context, *RecordRaceViewsRequest) (*RecordRaceViewsResponse, error)
+	// ListPopularRaces will return the visible races viewed most recently,
+	// most viewed first.
+	ListPopularRaces(context.Context, *ListPopularRacesRequest) (*ListPopularRacesResponse, error)
+	// GetRaceStats will count races, grouped by meeting, country, status and
+	// the day they start on, for dashboards.
+	GetRaceStats(context.Context, *GetRaceStatsRequest) (*RaceStats, error)
+	// ListRacesByDay will return the races starting on each calendar day in a
+	// date range, grouped by the day they start on at their venue.
+	ListRacesByDay(context.Context, *ListRacesByDayRequest) (*ListRacesByDayResponse, error)
+	// ListMeetings will return meetings, each with their races.
+	ListMeetings(context.Context, *ListMeetingsRequest) (*ListMeetingsResponse, error)
+	// ListRelatedRaces will return other visible open races yet to start, at
+	// the same meeting as a race or starting within an hour of it.
+	ListRelatedRaces(context.Context, *ListRelatedRacesRequest) (*ListRelatedRacesResponse, error)
+	// RegisterDevice will register a device to be sent push notifications
+	// about the races it follows, replacing what it followed if it was
+	// already registered.
+	RegisterDevice(context.Context, *RegisterDeviceRequest) (*Device, error)
+	// UnregisterDevice will stop sending push notifications to a device.
+	UnregisterDevice(context.Context, *UnregisterDeviceRequest) (*UnregisterDeviceResponse, error)
+	// WatchRace will add a race to the watchlist of the user the caller is
+	// authenticated as, in x-user-id metadata, so the devices following the
+	// user are notified as it approaches its start.
+	WatchRace(context.Context, *WatchRaceRequest) (*WatchedRace, error)
+	// UnwatchRace will remove a race from the caller's watchlist.
+	UnwatchRace(context.Context, *UnwatchRaceRequest) (*UnwatchRaceResponse, error)
+	// ListWatchlist will return the races on the caller's watchlist, in the
+	// order they were watched, with their live status.
+	ListWatchlist(context.Context, *ListWatchlistRequest) (*ListWatchlistResponse, error)
+	// SearchRaces will return the races best matching a free text query,
+	// tolerating typos and ranking them by relevance.
+	SearchRaces(context.Context, *SearchRacesRequest) (*SearchRacesResponse, error)
+	// IngestRaces will create or update the streamed races, deduplicated by
+	// their external references, writing them in batched transactions.
+	IngestRaces(Racing_IngestRacesServer) error
+	// GetReconciliationReport will return the report of the last
+	// reconciliation of races against the feed they're ingested from.
+	GetReconciliationReport(context.Context, *GetReconciliationReportRequest) (*ReconciliationReport, error)
 }
 
 // UnimplementedRacingServer should be embedded to have forward compatible implementations.
@@ -54,6 +538,96 @@ type UnimplementedRacingServer struct {
 func (UnimplementedRacingServer) ListRaces(context.Context, *ListRacesRequest) (*ListRacesResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method ListRaces not implemented")
 }
+func (UnimplementedRacingServer) ListArchivedRaces(context.Context, *ListArchivedRacesRequest) (*ListArchivedRacesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListArchivedRaces not implemented")
+}
+func (UnimplementedRacingServer) GetRaceByExternalRef(context.Context, *GetRaceByExternalRefRequest) (*Race, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetRaceByExternalRef not implemented")
+}
+func (UnimplementedRacingServer) ListMarkets(context.Context, *ListMarketsRequest) (*ListMarketsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListMarkets not implemented")
+}
+func (UnimplementedRacingServer) GetMarket(context.Context, *GetMarketRequest) (*Market, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetMarket not implemented")
+}
+func (UnimplementedRacingServer) ListSelections(context.Context, *ListSelectionsRequest) (*ListSelectionsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListSelections not implemented")
+}
+func (UnimplementedRacingServer) GetSelection(context.Context, *GetSelectionRequest) (*Selection, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetSelection not implemented")
+}
+func (UnimplementedRacingServer) WatchChanges(*WatchChangesRequest, Racing_WatchChangesServer) error {
+	return status.Errorf(codes.Unimplemented, "method WatchChanges not implemented")
+}
+func (UnimplementedRacingServer) CreateSubscription(context.Context, *CreateSubscriptionRequest) (*Subscription, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateSubscription not implemented")
+}
+func (UnimplementedRacingServer) DeleteSubscription(context.Context, *DeleteSubscriptionRequest) (*DeleteSubscriptionResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteSubscription not implemented")
+}
+func (UnimplementedRacingServer) ListDeliveries(context.Context, *ListDeliveriesRequest) (*ListDeliveriesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListDeliveries not implemented")
+}
+func (UnimplementedRacingServer) UpdateRaceStatus(context.Context, *UpdateRaceStatusRequest) (*Race, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateRaceStatus not implemented")
+}
+func (UnimplementedRacingServer) ListStatusHistory(context.Context, *ListStatusHistoryRequest) (*ListStatusHistoryResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListStatusHistory not implemented")
+}
+func (UnimplementedRacingServer) SetRaceFeatured(context.Context, *SetRaceFeaturedRequest) (*SetRaceFeaturedResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetRaceFeatured not implemented")
+}
+func (UnimplementedRacingServer) ListFeaturedRaces(context.Context, *ListFeaturedRacesRequest) (*ListFeaturedRacesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListFeaturedRaces not implemented")
+}
+func (UnimplementedRacingServer) SetRaceRestrictions(context.Context, *SetRaceRestrictionsRequest) (*SetRaceRestrictionsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetRaceRestrictions not implemented")
+}
+func (UnimplementedRacingServer) SetBrandVisibility(context.Context, *SetBrandVisibilityRequest) (*SetBrandVisibilityResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetBrandVisibility not implemented")
+}
+func (UnimplementedRacingServer) RecordRaceViews(context.Context, *RecordRaceViewsRequest) (*RecordRaceViewsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RecordRaceViews not implemented")
+}
+func (UnimplementedRacingServer) ListPopularRaces(context.Context, *ListPopularRacesRequest) (*ListPopularRacesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListPopularRaces not implemented")
+}
+func (UnimplementedRacingServer) GetRaceStats(context.Context, *GetRaceStatsRequest) (*RaceStats, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetRaceStats not implemented")
+}
+func (UnimplementedRacingServer) ListRacesByDay(context.Context, *ListRacesByDayRequest) (*ListRacesByDayResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListRacesByDay not implemented")
+}
+func (UnimplementedRacingServer) ListMeetings(context.Context, *ListMeetingsRequest) (*ListMeetingsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListMeetings not implemented")
+}
+func (UnimplementedRacingServer) ListRelatedRaces(context.Context, *ListRelatedRacesRequest) (*ListRelatedRacesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListRelatedRaces not implemented")
+}
+func (UnimplementedRacingServer) RegisterDevice(context.Context, *RegisterDeviceRequest) (*Device, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RegisterDevice not implemented")
+}
+func (UnimplementedRacingServer) UnregisterDevice(context.Context, *UnregisterDeviceRequest) (*UnregisterDeviceResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UnregisterDevice not implemented")
+}
+func (UnimplementedRacingServer) WatchRace(context.Context, *WatchRaceRequest) (*WatchedRace, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method WatchRace not implemented")
+}
+func (UnimplementedRacingServer) UnwatchRace(context.Context, *UnwatchRaceRequest) (*UnwatchRaceResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UnwatchRace not implemented")
+}
+func (UnimplementedRacingServer) ListWatchlist(context.Context, *ListWatchlistRequest) (*ListWatchlistResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListWatchlist not implemented")
+}
+func (UnimplementedRacingServer) SearchRaces(context.Context, *SearchRacesRequest) (*SearchRacesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SearchRaces not implemented")
+}
+func (UnimplementedRacingServer) IngestRaces(Racing_IngestRacesServer) error {
+	return status.Errorf(codes.Unimplemented, "method IngestRaces not implemented")
+}
+func (UnimplementedRacingServer) GetReconciliationReport(context.Context, *GetReconciliationReportRequest) (*ReconciliationReport, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetReconciliationReport not implemented")
+}
 
 // UnsafeRacingServer may be embedded to opt out of forward compatibility for this service.
 // Use of this interface is not recommended, as added methods to RacingServer will
@@ -84,18 +658,692 @@ func _Racing_ListRaces_Handler(srv interface{}, ctx context.Context, dec func(in
 	return interceptor(ctx, in, info, handler)
 }
 
-// Racing_ServiceDesc is the grpc.ServiceDesc for Racing service.
-// It's only intended for direct use with grpc.RegisterService,
-// and not to be introspected or modified (even as a copy)
-var Racing_ServiceDesc = grpc.ServiceDesc{
-	ServiceName: "racing.Racing",
-	HandlerType: (*RacingServer)(nil),
-	Methods: []grpc.MethodDesc{
-		{
-			MethodName: "ListRaces",
-			Handler:    _Racing_ListRaces_Handler,
+func _Racing_ListArchivedRaces_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListArchivedRacesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RacingServer).ListArchivedRaces(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/racing.Racing/ListArchivedRaces",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RacingServer).ListArchivedRaces(ctx, req.(*ListArchivedRacesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Racing_GetRaceByExternalRef_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetRaceByExternalRefRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RacingServer).GetRaceByExternalRef(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/racing.Racing/GetRaceByExternalRef",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RacingServer).GetRaceByExternalRef(ctx, req.(*GetRaceByExternalRefRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Racing_ListMarkets_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListMarketsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RacingServer).ListMarkets(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/racing.Racing/ListMarkets",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RacingServer).ListMarkets(ctx, req.(*ListMarketsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Racing_GetMarket_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetMarketRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RacingServer).GetMarket(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/racing.Racing/GetMarket",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RacingServer).GetMarket(ctx, req.(*GetMarketRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Racing_ListSelections_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListSelectionsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RacingServer).ListSelections(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/racing.Racing/ListSelections",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RacingServer).ListSelections(ctx, req.(*ListSelectionsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Racing_GetSelection_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetSelectionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RacingServer).GetSelection(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/racing.Racing/GetSelection",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RacingServer).GetSelection(ctx, req.(*GetSelectionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Racing_WatchChanges_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchChangesRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(RacingServer).WatchChanges(m, &racingWatchChangesServer{stream})
+}
+
+type Racing_WatchChangesServer interface {
+	Send(*RaceChange) error
+	grpc.ServerStream
+}
+
+type racingWatchChangesServer struct {
+	grpc.ServerStream
+}
+
+func (x *racingWatchChangesServer) Send(m *RaceChange) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Racing_CreateSubscription_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateSubscriptionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RacingServer).CreateSubscription(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/racing.Racing/CreateSubscription",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RacingServer).CreateSubscription(ctx, req.(*CreateSubscriptionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Racing_DeleteSubscription_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteSubscriptionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RacingServer).DeleteSubscription(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/racing.Racing/DeleteSubscription",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RacingServer).DeleteSubscription(ctx, req.(*DeleteSubscriptionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Racing_ListDeliveries_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListDeliveriesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RacingServer).ListDeliveries(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/racing.Racing/ListDeliveries",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RacingServer).ListDeliveries(ctx, req.(*ListDeliveriesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Racing_UpdateRaceStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateRaceStatusRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RacingServer).UpdateRaceStatus(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/racing.Racing/UpdateRaceStatus",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RacingServer).UpdateRaceStatus(ctx, req.(*UpdateRaceStatusRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Racing_ListStatusHistory_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListStatusHistoryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RacingServer).ListStatusHistory(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/racing.Racing/ListStatusHistory",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RacingServer).ListStatusHistory(ctx, req.(*ListStatusHistoryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Racing_SetRaceFeatured_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetRaceFeaturedRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RacingServer).SetRaceFeatured(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/racing.Racing/SetRaceFeatured",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RacingServer).SetRaceFeatured(ctx, req.(*SetRaceFeaturedRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Racing_ListFeaturedRaces_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListFeaturedRacesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RacingServer).ListFeaturedRaces(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/racing.Racing/ListFeaturedRaces",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RacingServer).ListFeaturedRaces(ctx, req.(*ListFeaturedRacesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Racing_SetRaceRestrictions_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetRaceRestrictionsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RacingServer).SetRaceRestrictions(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/racing.Racing/SetRaceRestrictions",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RacingServer).SetRaceRestrictions(ctx, req.(*SetRaceRestrictionsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Racing_SetBrandVisibility_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetBrandVisibilityRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RacingServer).SetBrandVisibility(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/racing.Racing/SetBrandVisibility",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RacingServer).SetBrandVisibility(ctx, req.(*SetBrandVisibilityRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Racing_RecordRaceViews_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RecordRaceViewsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RacingServer).RecordRaceViews(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/racing.Racing/RecordRaceViews",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RacingServer).RecordRaceViews(ctx, req.(*RecordRaceViewsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Racing_ListPopularRaces_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListPopularRacesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RacingServer).ListPopularRaces(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/racing.Racing/ListPopularRaces",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RacingServer).ListPopularRaces(ctx, req.(*ListPopularRacesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Racing_GetRaceStats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetRaceStatsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RacingServer).GetRaceStats(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/racing.Racing/GetRaceStats",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RacingServer).GetRaceStats(ctx, req.(*GetRaceStatsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Racing_ListRacesByDay_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListRacesByDayRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RacingServer).ListRacesByDay(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/racing.Racing/ListRacesByDay",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RacingServer).ListRacesByDay(ctx, req.(*ListRacesByDayRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Racing_ListMeetings_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListMeetingsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RacingServer).ListMeetings(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/racing.Racing/ListMeetings",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RacingServer).ListMeetings(ctx, req.(*ListMeetingsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Racing_ListRelatedRaces_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListRelatedRacesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RacingServer).ListRelatedRaces(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/racing.Racing/ListRelatedRaces",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RacingServer).ListRelatedRaces(ctx, req.(*ListRelatedRacesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Racing_RegisterDevice_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RegisterDeviceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RacingServer).RegisterDevice(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/racing.Racing/RegisterDevice",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RacingServer).RegisterDevice(ctx, req.(*RegisterDeviceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Racing_UnregisterDevice_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UnregisterDeviceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RacingServer).UnregisterDevice(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/racing.Racing/UnregisterDevice",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RacingServer).UnregisterDevice(ctx, req.(*UnregisterDeviceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Racing_WatchRace_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(WatchRaceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RacingServer).WatchRace(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/racing.Racing/WatchRace",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RacingServer).WatchRace(ctx, req.(*WatchRaceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Racing_UnwatchRace_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UnwatchRaceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RacingServer).UnwatchRace(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/racing.Racing/UnwatchRace",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RacingServer).UnwatchRace(ctx, req.(*UnwatchRaceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Racing_ListWatchlist_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListWatchlistRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RacingServer).ListWatchlist(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/racing.Racing/ListWatchlist",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RacingServer).ListWatchlist(ctx, req.(*ListWatchlistRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Racing_SearchRaces_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SearchRacesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RacingServer).SearchRaces(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/racing.Racing/SearchRaces",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RacingServer).SearchRaces(ctx, req.(*SearchRacesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Racing_IngestRaces_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(RacingServer).IngestRaces(&racingIngestRacesServer{stream})
+}
+
+type Racing_IngestRacesServer interface {
+	SendAndClose(*IngestRacesResponse) error
+	Recv() (*Race, error)
+	grpc.ServerStream
+}
+
+type racingIngestRacesServer struct {
+	grpc.ServerStream
+}
+
+func (x *racingIngestRacesServer) SendAndClose(m *IngestRacesResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *racingIngestRacesServer) Recv() (*Race, error) {
+	m := new(Race)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _Racing_GetReconciliationReport_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetReconciliationReportRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RacingServer).GetReconciliationReport(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/racing.Racing/GetReconciliationReport",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RacingServer).GetReconciliationReport(ctx, req.(*GetReconciliationReportRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Racing_ServiceDesc is the grpc.ServiceDesc for Racing service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Racing_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "racing.Racing",
+	HandlerType: (*RacingServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListRaces",
+			Handler:    _Racing_ListRaces_Handler,
+		},
+		{
+			MethodName: "ListArchivedRaces",
+			Handler:    _Racing_ListArchivedRaces_Handler,
+		},
+		{
+			MethodName: "GetRaceByExternalRef",
+			Handler:    _Racing_GetRaceByExternalRef_Handler,
+		},
+		{
+			MethodName: "ListMarkets",
+			Handler:    _Racing_ListMarkets_Handler,
+		},
+		{
+			MethodName: "GetMarket",
+			Handler:    _Racing_GetMarket_Handler,
+		},
+		{
+			MethodName: "ListSelections",
+			Handler:    _Racing_ListSelections_Handler,
+		},
+		{
+			MethodName: "GetSelection",
+			Handler:    _Racing_GetSelection_Handler,
+		},
+		{
+			MethodName: "CreateSubscription",
+			Handler:    _Racing_CreateSubscription_Handler,
+		},
+		{
+			MethodName: "DeleteSubscription",
+			Handler:    _Racing_DeleteSubscription_Handler,
+		},
+		{
+			MethodName: "ListDeliveries",
+			Handler:    _Racing_ListDeliveries_Handler,
+		},
+		{
+			MethodName: "UpdateRaceStatus",
+			Handler:    _Racing_UpdateRaceStatus_Handler,
+		},
+		{
+			MethodName: "ListStatusHistory",
+			Handler:    _Racing_ListStatusHistory_Handler,
+		},
+		{
+			MethodName: "SetRaceFeatured",
+			Handler:    _Racing_SetRaceFeatured_Handler,
+		},
+		{
+			MethodName: "ListFeaturedRaces",
+			Handler:    _Racing_ListFeaturedRaces_Handler,
+		},
+		{
+			MethodName: "SetRaceRestrictions",
+			Handler:    _Racing_SetRaceRestrictions_Handler,
+		},
+		{
+			MethodName: "SetBrandVisibility",
+			Handler:    _Racing_SetBrandVisibility_Handler,
+		},
+		{
+			MethodName: "RecordRaceViews",
+			Handler:    _Racing_RecordRaceViews_Handler,
+		},
+		{
+			MethodName: "ListPopularRaces",
+			Handler:    _Racing_ListPopularRaces_Handler,
+		},
+		{
+			MethodName: "GetRaceStats",
+			Handler:    _Racing_GetRaceStats_Handler,
+		},
+		{
+			MethodName: "ListRacesByDay",
+			Handler:    _Racing_ListRacesByDay_Handler,
+		},
+		{
+			MethodName: "ListMeetings",
+			Handler:    _Racing_ListMeetings_Handler,
+		},
+		{
+			MethodName: "ListRelatedRaces",
+			Handler:    _Racing_ListRelatedRaces_Handler,
+		},
+		{
+			MethodName: "RegisterDevice",
+			Handler:    _Racing_RegisterDevice_Handler,
+		},
+		{
+			MethodName: "UnregisterDevice",
+			Handler:    _Racing_UnregisterDevice_Handler,
+		},
+		{
+			MethodName: "WatchRace",
+			Handler:    _Racing_WatchRace_Handler,
+		},
+		{
+			MethodName: "UnwatchRace",
+			Handler:    _Racing_UnwatchRace_Handler,
+		},
+		{
+			MethodName: "ListWatchlist",
+			Handler:    _Racing_ListWatchlist_Handler,
+		},
+		{
+			MethodName: "SearchRaces",
+			Handler:    _Racing_SearchRaces_Handler,
+		},
+		{
+			MethodName: "GetReconciliationReport",
+			Handler:    _Racing_GetReconciliationReport_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchChanges",
+			Handler:       _Racing_WatchChanges_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "IngestRaces",
+			Handler:       _Racing_IngestRaces_Handler,
+			ClientStreams: true,
 		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "racing/racing.proto",
 }
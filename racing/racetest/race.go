@@ -0,0 +1,132 @@
+// Package racetest builds races for tests, with sensible defaults so tests
+// only set the fields they care about.
+package racetest
+
+import (
+	"time"
+
+	"git.neds.sh/matty/entain/racing/proto/racing"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// RaceBuilder builds races. Its setters return the builder, so they can be
+// chained, e.g.
+//
+//	race := racetest.NewRace().Name("Melbourne Cup").Distance(3200).Hidden().Build()
+type RaceBuilder struct {
+	race *racing.Race
+}
+
+// NewRace starts building a visible, open race, number 1 at meeting 1 in
+// Melbourne, over 1200m and starting in an hour.
+func NewRace() *RaceBuilder {
+	return &RaceBuilder{race: &racing.Race{
+		MeetingId:           1,
+		Name:                "Test race",
+		Number:              1,
+		Visible:             true,
+		AdvertisedStartTime: timestamppb.New(time.Now().Add(time.Hour).Truncate(time.Second)),
+		VenueTimeZone:       "Australia/Melbourne",
+		Country:             "AU",
+		Distance:            1200,
+		Status:              racing.Race_OPEN,
+	}}
+}
+
+// ID sets the race's ID.
+func (b *RaceBuilder) ID(id int64) *RaceBuilder {
+	b.race.Id = id
+	return b
+}
+
+// Meeting sets the meeting the race is run at.
+func (b *RaceBuilder) Meeting(meetingID int64) *RaceBuilder {
+	b.race.MeetingId = meetingID
+	return b
+}
+
+// Number sets the race's number within its meeting.
+func (b *RaceBuilder) Number(number int64) *RaceBuilder {
+	b.race.Number = number
+	return b
+}
+
+// Name sets the race's name.
+func (b *RaceBuilder) Name(name string) *RaceBuilder {
+	b.race.Name = name
+	return b
+}
+
+// Visible sets whether the race is visible.
+func (b *RaceBuilder) Visible(visible bool) *RaceBuilder {
+	b.race.Visible = visible
+	return b
+}
+
+// Hidden hides the race.
+func (b *RaceBuilder) Hidden() *RaceBuilder {
+	return b.Visible(false)
+}
+
+// Venue sets the IANA time zone and country code of the race's venue.
+func (b *RaceBuilder) Venue(timeZone, country string) *RaceBuilder {
+	b.race.VenueTimeZone = timeZone
+	b.race.Country = country
+	return b
+}
+
+// Country sets the country code of the race's venue, leaving its time zone.
+func (b *RaceBuilder) Country(country string) *RaceBuilder {
+	b.race.Country = country
+	return b
+}
+
+// Distance sets the race's distance, in metres.
+func (b *RaceBuilder) Distance(distance int64) *RaceBuilder {
+	b.race.Distance = distance
+	return b
+}
+
+// StartsAt sets the race's advertised start time.
+func (b *RaceBuilder) StartsAt(start time.Time) *RaceBuilder {
+	b.race.AdvertisedStartTime = timestamppb.New(start)
+	return b
+}
+
+// StartsIn sets the race to start the given time from now, or ago if
+// negative.
+func (b *RaceBuilder) StartsIn(d time.Duration) *RaceBuilder {
+	return b.StartsAt(time.Now().Add(d).Truncate(time.Second))
+}
+
+// Conditions sets the track condition and weather the race is run in.
+func (b *RaceBuilder) Conditions(trackCondition, weather string) *RaceBuilder {
+	b.race.TrackCondition = trackCondition
+	b.race.Weather = weather
+	return b
+}
+
+// Image sets the path or URL of the race's venue image.
+func (b *RaceBuilder) Image(url string) *RaceBuilder {
+	b.race.VenueImageUrl = url
+	return b
+}
+
+// ExternalRef sets the reference to the race in an external source.
+func (b *RaceBuilder) ExternalRef(source, sourceID string) *RaceBuilder {
+	b.race.ExternalRef = &racing.ExternalRef{Source: source, SourceId: sourceID}
+	return b
+}
+
+// Status sets the race's status.
+func (b *RaceBuilder) Status(status racing.Race_Status) *RaceBuilder {
+	b.race.Status = status
+	return b
+}
+
+// Build returns the race. The builder can go on being used, e.g. to build
+// similar races, without changing races already built.
+func (b *RaceBuilder) Build() *racing.Race {
+	return proto.Clone(b.race).(*racing.Race)
+}
@@ -0,0 +1,83 @@
+package scheduler
+
+import (
+	"context"
+	"expvar"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"git.neds.sh/matty/entain/racing/db"
+)
+
+// leaseName is the name of the lease held by the leader.
+const leaseName = "scheduler"
+
+// isLeader is 1 while this replica is the leader, otherwise 0.
+var isLeader = expvar.NewInt("scheduler_leader")
+
+// LeaseElector elects the replica holding a lease in the shared database as
+// the leader. The leader renews the lease well before it expires. If the
+// leader stops renewing it, another replica takes over once it expires.
+type LeaseElector struct {
+	leases db.LeasesRepo
+	holder string
+	ttl    time.Duration
+	leader int32
+}
+
+// NewLeaseElector creates a new elector, contending for the lease as holder.
+// Holders must be unique across replicas.
+func NewLeaseElector(leases db.LeasesRepo, holder string, ttl time.Duration) *LeaseElector {
+	return &LeaseElector{
+		leases: leases,
+		holder: holder,
+		ttl:    ttl,
+	}
+}
+
+// IsLeader reports whether this replica currently holds the lease.
+func (e *LeaseElector) IsLeader() bool {
+	return atomic.LoadInt32(&e.leader) == 1
+}
+
+// Run contends for the lease until the context is cancelled, renewing it a
+// few times per TTL while it's held.
+func (e *LeaseElector) Run(ctx context.Context) {
+	ticker := time.NewTicker(e.ttl / 3)
+	defer ticker.Stop()
+
+	for {
+		e.contend()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// contend tries to take or renew the lease, updating whether this replica is
+// the leader.
+func (e *LeaseElector) contend() {
+	acquired, err := e.leases.Acquire(leaseName, e.holder, time.Now().Add(e.ttl))
+	if err != nil {
+		log.Printf("failed acquiring leader lease: %s\n", err)
+	}
+
+	var leader int32
+	if acquired {
+		leader = 1
+	}
+
+	if atomic.SwapInt32(&e.leader, leader) != leader {
+		if acquired {
+			log.Printf("%s became the leader\n", e.holder)
+		} else {
+			log.Printf("%s is no longer the leader\n", e.holder)
+		}
+	}
+
+	isLeader.Set(int64(leader))
+}
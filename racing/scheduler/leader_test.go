@@ -0,0 +1,120 @@
+package scheduler
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"git.neds.sh/matty/entain/racing/db"
+)
+
+// newTestLeasesRepo creates a leases repository on an empty in-memory
+// database, returning the database too so leases can be expired.
+func newTestLeasesRepo(t *testing.T) (db.LeasesRepo, *sql.DB) {
+	t.Helper()
+
+	testDB, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("opening test database: %s", err)
+	}
+
+	// Every connection to :memory: gets its own database, so share one.
+	testDB.SetMaxOpenConns(1)
+
+	t.Cleanup(func() { testDB.Close() })
+
+	repo := db.NewLeasesRepo(testDB)
+	if err := repo.Init(); err != nil {
+		t.Fatalf("initialising leases repo: %s", err)
+	}
+
+	return repo, testDB
+}
+
+func TestLeaseElectorTakeover(t *testing.T) {
+	leases, testDB := newTestLeasesRepo(t)
+
+	electors := map[string]*LeaseElector{
+		"a": NewLeaseElector(leases, "a", time.Minute),
+		"b": NewLeaseElector(leases, "b", time.Minute),
+	}
+
+	steps := []struct {
+		name string
+		// expire expires the lease before the step.
+		expire     bool
+		contender  string
+		wantLeader bool
+	}{
+		{"first takes the free lease", false, "a", true},
+		{"second can't take a held lease", false, "b", false},
+		{"holder renews its lease", false, "a", true},
+		{"second still can't take it", false, "b", false},
+		{"second takes over an expired lease", true, "b", true},
+		{"first has lost the lease", false, "a", false},
+		{"new holder renews its lease", false, "b", true},
+		{"first takes back an expired lease", true, "a", true},
+	}
+
+	for _, step := range steps {
+		if step.expire {
+			if _, err := testDB.Exec(`UPDATE leases SET expires_at = ?`, time.Now().Add(-time.Second).UTC().Format(time.RFC3339)); err != nil {
+				t.Fatalf("%s: expiring lease: %s", step.name, err)
+			}
+		}
+
+		elector := electors[step.contender]
+		elector.contend()
+
+		if got := elector.IsLeader(); got != step.wantLeader {
+			t.Errorf("%s: %s IsLeader() = %t, want %t", step.name, step.contender, got, step.wantLeader)
+		}
+	}
+}
+
+// stubLeases answers every attempt to acquire a lease with acquired and err.
+type stubLeases struct {
+	db.LeasesRepo
+	t        *testing.T
+	acquired bool
+	err      error
+}
+
+func (l *stubLeases) Acquire(name, holder string, until time.Time) (bool, error) {
+	if name != leaseName || holder != "a" {
+		l.t.Errorf("Acquire(%s, %s), want %s for a", name, holder, leaseName)
+	}
+
+	if ttl := time.Until(until); ttl <= 0 || ttl > time.Minute {
+		l.t.Errorf("Acquire() until %s from now, want within %s", ttl, time.Minute)
+	}
+
+	return l.acquired, l.err
+}
+
+func TestLeaseElectorStepsDownOnError(t *testing.T) {
+	leases := &stubLeases{t: t}
+	elector := NewLeaseElector(leases, "a", time.Minute)
+
+	tests := []struct {
+		acquired   bool
+		err        error
+		wantLeader bool
+	}{
+		{true, nil, true},
+		{false, errors.New("database is locked"), false},
+		{true, nil, true},
+		{false, nil, false},
+	}
+
+	for i, tt := range tests {
+		leases.acquired, leases.err = tt.acquired, tt.err
+
+		elector.contend()
+
+		if got := elector.IsLeader(); got != tt.wantLeader {
+			t.Errorf("contend %d: IsLeader() = %t, want %t", i, got, tt.wantLeader)
+		}
+	}
+}
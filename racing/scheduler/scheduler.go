@@ -0,0 +1,197 @@
+package scheduler
+
+import (
+	"context"
+	"expvar"
+	"log"
+	"math/rand"
+	"sync"
+	"time"
+
+	"git.neds.sh/matty/entain/racing/alerts"
+)
+
+// standbyInterval is how soon a replica that isn't the leader checks again
+// whether it should run a job, so a new leader picks jobs up promptly.
+const standbyInterval = 5 * time.Second
+
+var (
+	// jobRuns counts the runs of each job.
+	jobRuns = expvar.NewMap("scheduler_job_runs_total")
+	// jobFailures counts the failed runs of each job.
+	jobFailures = expvar.NewMap("scheduler_job_failures_total")
+	// jobSkips counts the runs of each job skipped by replicas that aren't the
+	// leader.
+	jobSkips = expvar.NewMap("scheduler_job_skips_total")
+	// jobDuration is how long, in seconds, the last run of each job took.
+	jobDuration = expvar.NewMap("scheduler_job_duration_seconds")
+	// jobLastSuccess is when, in unix seconds, each job last succeeded.
+	jobLastSuccess = expvar.NewMap("scheduler_job_last_success_timestamp")
+)
+
+// Job is a task run periodically by the scheduler.
+type Job struct {
+	// Name identifies the job in logs and metrics.
+	Name string
+	// Interval is how long to wait between runs.
+	Interval time.Duration
+	// Jitter is the most each run is randomly delayed by, so that jobs
+	// started together don't keep running together.
+	Jitter time.Duration
+	// AlertAfter is how many runs in a row must fail before the scheduler's
+	// alert hooks are fired, and fired again to resolve the alert once the
+	// job succeeds. Zero never alerts.
+	AlertAfter int
+	// Run runs the job once.
+	Run func(ctx context.Context) error
+}
+
+// jobState tracks a job's run of failures.
+type jobState struct {
+	failures int
+	since    time.Time
+	alerted  bool
+}
+
+// Elector decides whether this replica is the leader.
+type Elector interface {
+	// IsLeader reports whether this replica is currently the leader.
+	IsLeader() bool
+}
+
+// Scheduler runs jobs periodically, on the leader replica only, so that
+// replicas don't duplicate each other's work.
+type Scheduler struct {
+	elector Elector
+	hooks   []alerts.Hook
+	jobs    []Job
+}
+
+// New creates a new scheduler, running jobs while the elector says this
+// replica is the leader, and firing the given hooks when they keep failing.
+func New(elector Elector, hooks ...alerts.Hook) *Scheduler {
+	return &Scheduler{elector: elector, hooks: hooks}
+}
+
+// Add adds a job to the scheduler. Jobs must be added before Run is called.
+func (s *Scheduler) Add(job Job) {
+	s.jobs = append(s.jobs, job)
+}
+
+// Run runs every job, each first run straight away, until the context is
+// cancelled.
+func (s *Scheduler) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+
+	for _, job := range s.jobs {
+		wg.Add(1)
+
+		go func(job Job) {
+			defer wg.Done()
+
+			s.loop(ctx, job)
+		}(job)
+	}
+
+	wg.Wait()
+}
+
+// loop runs a job until the context is cancelled.
+func (s *Scheduler) loop(ctx context.Context, job Job) {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	var state jobState
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+
+		interval := job.Interval + jitter(job.Jitter)
+		if !s.run(ctx, job, &state) && standbyInterval < interval {
+			interval = standbyInterval
+		}
+
+		timer.Reset(interval)
+	}
+}
+
+// run runs a job once if this replica is the leader, recording its metrics
+// and alerting on its failures, and reports whether it ran.
+func (s *Scheduler) run(ctx context.Context, job Job, state *jobState) bool {
+	if !s.elector.IsLeader() {
+		jobSkips.Add(job.Name, 1)
+		return false
+	}
+
+	jobRuns.Add(job.Name, 1)
+
+	start := time.Now()
+	err := job.Run(ctx)
+
+	duration := new(expvar.Float)
+	duration.Set(time.Since(start).Seconds())
+	jobDuration.Set(job.Name, duration)
+
+	if err != nil {
+		jobFailures.Add(job.Name, 1)
+		log.Printf("failed running job %s: %s\n", job.Name, err)
+
+		if state.failures == 0 {
+			state.since = start
+		}
+		state.failures++
+
+		if job.AlertAfter > 0 && state.failures >= job.AlertAfter && !state.alerted {
+			state.alerted = true
+
+			s.alert(ctx, alerts.Alert{
+				Job:       job.Name,
+				Failures:  state.failures,
+				Since:     state.since,
+				LastError: err.Error(),
+			})
+		}
+
+		return true
+	}
+
+	if state.alerted {
+		s.alert(ctx, alerts.Alert{
+			Job:      job.Name,
+			Failures: state.failures,
+			Since:    state.since,
+			Resolved: true,
+		})
+	}
+
+	*state = jobState{}
+
+	lastSuccess := new(expvar.Int)
+	lastSuccess.Set(start.Unix())
+	jobLastSuccess.Set(job.Name, lastSuccess)
+
+	return true
+}
+
+// alert fires every hook. A hook that fails is logged, rather than failing
+// the job.
+func (s *Scheduler) alert(ctx context.Context, alert alerts.Alert) {
+	for _, hook := range s.hooks {
+		if err := hook.Fire(ctx, alert); err != nil {
+			log.Printf("failed firing alert for job %s: %s\n", alert.Job, err)
+		}
+	}
+}
+
+// jitter returns a random delay of up to max.
+func jitter(max time.Duration) time.Duration {
+	if max <= 0 {
+		return 0
+	}
+
+	return time.Duration(rand.Int63n(int64(max)))
+}
@@ -0,0 +1,217 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"git.neds.sh/matty/entain/racing/alerts"
+)
+
+// fixedElector is an elector that's always, or never, the leader.
+type fixedElector bool
+
+func (e fixedElector) IsLeader() bool {
+	return bool(e)
+}
+
+// recordingHook records the alerts fired at it, failing if fail is set.
+type recordingHook struct {
+	fired []alerts.Alert
+	fail  bool
+}
+
+func (h *recordingHook) Fire(_ context.Context, alert alerts.Alert) error {
+	h.fired = append(h.fired, alert)
+
+	if h.fail {
+		return errors.New("hook unavailable")
+	}
+
+	return nil
+}
+
+// summary summarises the alerts fired at a hook, e.g. "fail:3 resolve:3".
+func (h *recordingHook) summary() []string {
+	var summary []string
+
+	for _, alert := range h.fired {
+		if alert.Resolved {
+			summary = append(summary, fmt.Sprintf("resolve:%d", alert.Failures))
+		} else {
+			summary = append(summary, fmt.Sprintf("fail:%d", alert.Failures))
+		}
+	}
+
+	return summary
+}
+
+func TestSchedulerAlertsOnRepeatedFailures(t *testing.T) {
+	tests := []struct {
+		name       string
+		alertAfter int
+		// runs are whether each run succeeds.
+		runs        []bool
+		wantAlerts  []string
+		wantFailing int
+	}{
+		{"never alerts", 0, []bool{false, false, false, false}, nil, 4},
+		{"alerts after failures", 3, []bool{false, false, false}, []string{"fail:3"}, 3},
+		{"alerts once", 2, []bool{false, false, false, false}, []string{"fail:2"}, 4},
+		{"resolves when it succeeds", 2, []bool{false, false, false, true}, []string{"fail:2", "resolve:3"}, 0},
+		{"success resets failures", 3, []bool{false, false, true, false, false}, nil, 2},
+		{"no resolve without an alert", 3, []bool{false, true}, nil, 0},
+		{"alerts again after resolving", 1, []bool{false, true, false}, []string{"fail:1", "resolve:1", "fail:1"}, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hook := &recordingHook{}
+			s := New(fixedElector(true), hook)
+
+			var (
+				calls int
+				state jobState
+			)
+
+			job := Job{
+				Name:       "test",
+				AlertAfter: tt.alertAfter,
+				Run: func(context.Context) error {
+					succeeded := tt.runs[calls]
+					calls++
+
+					if !succeeded {
+						return fmt.Errorf("run %d failed", calls)
+					}
+
+					return nil
+				},
+			}
+
+			for range tt.runs {
+				if !s.run(context.Background(), job, &state) {
+					t.Fatalf("run() = false, want the leader to run the job")
+				}
+			}
+
+			if got := hook.summary(); !reflect.DeepEqual(got, tt.wantAlerts) {
+				t.Errorf("alerts fired = %v, want %v", got, tt.wantAlerts)
+			}
+
+			if state.failures != tt.wantFailing {
+				t.Errorf("failures = %d, want %d", state.failures, tt.wantFailing)
+			}
+		})
+	}
+}
+
+func TestSchedulerAlertDetails(t *testing.T) {
+	hook := &recordingHook{}
+	failing := &recordingHook{fail: true}
+	s := New(fixedElector(true), failing, hook)
+
+	var state jobState
+
+	errs := []error{errors.New("first"), errors.New("second"), nil}
+	job := Job{
+		Name:       "ingest",
+		AlertAfter: 2,
+		Run: func(context.Context) error {
+			err := errs[0]
+			errs = errs[1:]
+			return err
+		},
+	}
+
+	before := time.Now()
+	for range errs {
+		s.run(context.Background(), job, &state)
+	}
+
+	if len(hook.fired) != 2 || len(failing.fired) != 2 {
+		t.Fatalf("alerts fired = %d and %d, want 2 to each hook despite one failing", len(hook.fired), len(failing.fired))
+	}
+
+	fired, resolved := hook.fired[0], hook.fired[1]
+
+	if fired.Job != "ingest" || fired.LastError != "second" || fired.Since.Before(before) || fired.Resolved {
+		t.Errorf("fired alert = %+v, want ingest failing with second since the first failure", fired)
+	}
+
+	if !resolved.Resolved || resolved.Failures != 2 || !resolved.Since.Equal(fired.Since) || resolved.LastError != "" {
+		t.Errorf("resolved alert = %+v, want ingest resolved after 2 failures since %s", resolved, fired.Since)
+	}
+}
+
+func TestSchedulerSkipsJobsUnlessLeader(t *testing.T) {
+	tests := []struct {
+		leader  bool
+		wantRan bool
+	}{
+		{true, true},
+		{false, false},
+	}
+
+	for _, tt := range tests {
+		s := New(fixedElector(tt.leader))
+
+		var ran bool
+
+		job := Job{Name: "test", Run: func(context.Context) error {
+			ran = true
+			return nil
+		}}
+
+		if got := s.run(context.Background(), job, &jobState{}); got != tt.wantRan || ran != tt.wantRan {
+			t.Errorf("leader %t: run() = %t and ran the job %t, want %t", tt.leader, got, ran, tt.wantRan)
+		}
+	}
+}
+
+func TestSchedulerRunStopsWhenCancelled(t *testing.T) {
+	s := New(fixedElector(true))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ran := make(chan struct{}, 1)
+
+	s.Add(Job{Name: "test", Interval: time.Hour, Run: func(context.Context) error {
+		ran <- struct{}{}
+		return nil
+	}})
+
+	done := make(chan struct{})
+	go func() {
+		s.Run(ctx)
+		close(done)
+	}()
+
+	select {
+	case <-ran:
+	case <-time.After(time.Second):
+		t.Fatal("job wasn't run straight away")
+	}
+
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Run() didn't return once cancelled")
+	}
+}
+
+func TestJitter(t *testing.T) {
+	tests := []time.Duration{-time.Second, 0, time.Nanosecond, time.Second}
+
+	for _, max := range tests {
+		for i := 0; i < 100; i++ {
+			if got := jitter(max); got < 0 || (max > 0 && got >= max) || (max <= 0 && got != 0) {
+				t.Fatalf("jitter(%s) = %s, want within [0, %s)", max, got, max)
+			}
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package search
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/mapping"
+	"github.com/blevesearch/bleve/v2/search/query"
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+
+	"git.neds.sh/matty/entain/racing/proto/racing"
+)
+
+// fuzziness is the edit distance within which search terms match, so small
+// typos still find races.
+const fuzziness = 1
+
+// cursorKey is where the ID of the last race change applied to the index is
+// kept, alongside the races it covers.
+var cursorKey = []byte("cursor")
+
+// versionKey is where the version of the index's contents is kept.
+var versionKey = []byte("version")
+
+// version is the version of what's indexed for each race. Indexes of another
+// version are refilled, rather than synced, as they'd otherwise only be
+// brought up to date as races change.
+const version = "2"
+
+// Index is a full text index of races, for searching them by relevance with
+// fuzzy matching.
+type Index struct {
+	index bleve.Index
+}
+
+// Hit is a race matching a search, and how well it matched.
+type Hit struct {
+	RaceID int64
+	Score  float64
+}
+
+// Open opens the index at the given path, creating it if it doesn't exist.
+func Open(path string) (*Index, error) {
+	index, err := bleve.Open(path)
+	if errors.Is(err, bleve.ErrorIndexPathDoesNotExist) {
+		index, err = bleve.New(path, newMapping())
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &Index{index: index}, nil
+}
+
+// newMapping maps the race fields that are searched, leaving the rest out of
+// the index.
+func newMapping() mapping.IndexMapping {
+	race := bleve.NewDocumentStaticMapping()
+
+	for _, field := range []string{"name", "country", "track_condition", "weather"} {
+		race.AddFieldMappingsAt(field, bleve.NewTextFieldMapping())
+	}
+
+	m := bleve.NewIndexMapping()
+	m.DefaultMapping = race
+
+	return m
+}
+
+// Close closes the index.
+func (i *Index) Close() error {
+	return i.index.Close()
+}
+
+// Search returns up to limit races matching the text, best matches first.
+// Race names count for more than their other fields, and a word being typed
+// matches the words it starts.
+func (i *Index) Search(text string, limit int) ([]Hit, error) {
+	text = fold(text)
+
+	name := bleve.NewMatchQuery(text)
+	name.SetField("name")
+	name.SetFuzziness(fuzziness)
+	name.SetBoost(3)
+
+	queries := []query.Query{name}
+
+	if words := strings.Fields(strings.ToLower(text)); len(words) > 0 {
+		prefix := bleve.NewPrefixQuery(words[len(words)-1])
+		prefix.SetField("name")
+
+		queries = append(queries, prefix)
+	}
+
+	for _, field := range []string{"country", "track_condition", "weather"} {
+		q := bleve.NewMatchQuery(text)
+		q.SetField(field)
+
+		queries = append(queries, q)
+	}
+
+	result, err := i.index.Search(bleve.NewSearchRequestOptions(bleve.NewDisjunctionQuery(queries...), limit, 0, false))
+	if err != nil {
+		return nil, err
+	}
+
+	hits := make([]Hit, 0, len(result.Hits))
+
+	for _, match := range result.Hits {
+		raceID, err := strconv.ParseInt(match.ID, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+
+		hits = append(hits, Hit{RaceID: raceID, Score: match.Score})
+	}
+
+	return hits, nil
+}
+
+// cursor returns the ID of the last race change applied, and whether any has
+// been to an index of the current version.
+func (i *Index) cursor() (int64, bool, error) {
+	indexed, err := i.index.GetInternal(versionKey)
+	if err != nil || string(indexed) != version {
+		return 0, false, err
+	}
+
+	value, err := i.index.GetInternal(cursorKey)
+	if err != nil || value == nil {
+		return 0, false, err
+	}
+
+	cursor, err := strconv.ParseInt(string(value), 10, 64)
+
+	return cursor, true, err
+}
+
+// batch collects writes to apply to the index together.
+type batch struct {
+	*bleve.Batch
+}
+
+// newBatch starts a batch of writes.
+func (i *Index) newBatch() batch {
+	return batch{i.index.NewBatch()}
+}
+
+// put adds or replaces a race in the batch.
+func (b batch) put(race *racing.Race) error {
+	return b.Index(strconv.FormatInt(race.Id, 10), map[string]interface{}{
+		"name":            fold(race.Name),
+		"country":         race.Country,
+		"track_condition": fold(race.TrackCondition),
+		"weather":         fold(race.Weather),
+	})
+}
+
+// remove deletes a race in the batch.
+func (b batch) remove(raceID int64) {
+	b.Delete(strconv.FormatInt(raceID, 10))
+}
+
+// setCursor records the ID of the last race change applied by the batch.
+func (b batch) setCursor(cursor int64) {
+	b.SetInternal(cursorKey, []byte(strconv.FormatInt(cursor, 10)))
+	b.SetInternal(versionKey, []byte(version))
+}
+
+// apply writes a batch to the index.
+func (i *Index) apply(b batch) error {
+	return i.index.Batch(b.Batch)
+}
+
+// fold strips the accents and other diacritics from text, decomposing it
+// first (NFKD), so races at international meetings are found whether or not
+// they're typed, e.g. "Sao Paulo" finds "São Paulo". Both what's indexed and
+// what's searched for are folded.
+func fold(text string) string {
+	folded, _, err := transform.String(transform.Chain(norm.NFKD, runes.Remove(runes.In(unicode.Mn)), norm.NFC), text)
+	if err != nil {
+		return text
+	}
+
+	return folded
+}
@@ -0,0 +1,74 @@
+package search
+
+import (
+	"path/filepath"
+	"testing"
+
+	"git.neds.sh/matty/entain/racing/db"
+	"git.neds.sh/matty/entain/racing/racetest"
+)
+
+func TestFold(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"Flemington", "Flemington"},
+		{"São Paulo", "Sao Paulo"},
+		{"Hipódromo de Palermo", "Hipodromo de Palermo"},
+		{"Zürich Großer Preis", "Zurich Großer Preis"},
+		{"Ｃａｕｌｆｉｅｌｄ", "Caulfield"},
+		{"沙田", "沙田"},
+	}
+
+	for _, tt := range tests {
+		if got := fold(tt.text); got != tt.want {
+			t.Errorf("fold(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestSearchIgnoresDiacritics(t *testing.T) {
+	repo := db.NewInMemoryRacesRepo()
+
+	races := []struct {
+		name string
+		race string
+	}{
+		{"São Paulo", "R1"},
+		{"Sao Paulo Cup", "R2"},
+		{"Flemington", "R3"},
+	}
+
+	for i, r := range races {
+		if _, err := repo.Upsert(racetest.NewRace().Number(int64(i+1)).Name(r.name).ExternalRef("feed", r.race).Build()); err != nil {
+			t.Fatalf("Upsert() error = %s", err)
+		}
+	}
+
+	index, err := Open(filepath.Join(t.TempDir(), "races.bleve"))
+	if err != nil {
+		t.Fatalf("Open() error = %s", err)
+	}
+	defer index.Close()
+
+	if err := NewIndexer(repo, index).Sync(); err != nil {
+		t.Fatalf("Sync() error = %s", err)
+	}
+
+	for _, text := range []string{"Sao Paulo", "São Paulo", "SÃO PAULO"} {
+		hits, err := index.Search(text, 10)
+		if err != nil {
+			t.Fatalf("Search(%q) error = %s", text, err)
+		}
+
+		found := make(map[int64]bool)
+		for _, hit := range hits {
+			found[hit.RaceID] = true
+		}
+
+		if len(found) != 2 || !found[1] || !found[2] {
+			t.Errorf("Search(%q) = %v, want races 1 and 2", text, hits)
+		}
+	}
+}
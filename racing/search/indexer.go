@@ -0,0 +1,127 @@
+package search
+
+import (
+	"context"
+	"expvar"
+	"log"
+	"strconv"
+	"time"
+
+	"git.neds.sh/matty/entain/racing/db"
+	"git.neds.sh/matty/entain/racing/proto/racing"
+)
+
+// batchSize is how many race changes are applied to the index at a time.
+const batchSize = 100
+
+// racesIndexed counts the race changes applied to the index.
+var racesIndexed = expvar.NewInt("search_index_changes_total")
+
+// Indexer mirrors races into a search index, by following the race change
+// log.
+type Indexer struct {
+	racesRepo db.RacesRepo
+	index     *Index
+}
+
+// NewIndexer creates a new indexer.
+func NewIndexer(racesRepo db.RacesRepo, index *Index) *Indexer {
+	return &Indexer{racesRepo: racesRepo, index: index}
+}
+
+// Run syncs the index every interval, until the context is cancelled. Indexes
+// are local to each replica, so every replica runs its own indexer, rather
+// than only the leader.
+func (i *Indexer) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := i.Sync(); err != nil {
+			log.Printf("failed syncing search index: %s\n", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Sync applies the race changes made since it last ran to the index. An
+// index that has never been synced is first filled with every race, as the
+// change log may not go back to when they were created.
+func (i *Indexer) Sync() error {
+	after, synced, err := i.index.cursor()
+	if err != nil {
+		return err
+	}
+
+	if !synced {
+		if err := i.fill(); err != nil {
+			return err
+		}
+	}
+
+	for {
+		changes, err := i.racesRepo.Changes(after, batchSize)
+		if err != nil {
+			return err
+		}
+
+		if len(changes) == 0 {
+			return nil
+		}
+
+		b := i.index.newBatch()
+
+		for _, change := range changes {
+			// Changes carry races as they are now, so a race deleted since it
+			// was upserted has no race.
+			if change.Operation == racing.RaceChange_UPSERTED && change.Race != nil {
+				if err := b.put(change.Race); err != nil {
+					return err
+				}
+			} else {
+				b.remove(change.RaceId)
+			}
+		}
+
+		if after, err = strconv.ParseInt(changes[len(changes)-1].Token, 10, 64); err != nil {
+			return err
+		}
+
+		b.setCursor(after)
+
+		if err := i.index.apply(b); err != nil {
+			return err
+		}
+
+		racesIndexed.Add(int64(len(changes)))
+
+		if len(changes) < batchSize {
+			return nil
+		}
+	}
+}
+
+// fill indexes every race, starting the index at the start of the change log.
+func (i *Indexer) fill() error {
+	races, err := i.racesRepo.List(nil)
+	if err != nil {
+		return err
+	}
+
+	b := i.index.newBatch()
+
+	for _, race := range races {
+		if err := b.put(race); err != nil {
+			return err
+		}
+	}
+
+	b.setCursor(0)
+
+	return i.index.apply(b)
+}
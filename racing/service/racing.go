@@ -1,31 +1,1572 @@
 package service
 
 import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
 	"git.neds.sh/matty/entain/racing/db"
+	"git.neds.sh/matty/entain/racing/features"
 	"git.neds.sh/matty/entain/racing/proto/racing"
+	"git.neds.sh/matty/entain/racing/search"
 	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 type Racing interface {
 	// ListRaces will return a collection of races.
 	ListRaces(ctx context.Context, in *racing.ListRacesRequest) (*racing.ListRacesResponse, error)
+
+	// ListArchivedRaces will return a collection of archived races.
+	ListArchivedRaces(ctx context.Context, in *racing.ListArchivedRacesRequest) (*racing.ListArchivedRacesResponse, error)
+
+	// GetRaceByExternalRef will return the race an external source knows by
+	// the given ID.
+	GetRaceByExternalRef(ctx context.Context, in *racing.GetRaceByExternalRefRequest) (*racing.Race, error)
+
+	// ListMarkets will return a collection of markets.
+	ListMarkets(ctx context.Context, in *racing.ListMarketsRequest) (*racing.ListMarketsResponse, error)
+
+	// GetMarket will return a single market.
+	GetMarket(ctx context.Context, in *racing.GetMarketRequest) (*racing.Market, error)
+
+	// ListSelections will return a collection of market selections.
+	ListSelections(ctx context.Context, in *racing.ListSelectionsRequest) (*racing.ListSelectionsResponse, error)
+
+	// GetSelection will return a single market selection.
+	GetSelection(ctx context.Context, in *racing.GetSelectionRequest) (*racing.Selection, error)
+
+	// WatchChanges will stream the changes made to races since the given
+	// token, then the changes made from then on.
+	WatchChanges(in *racing.WatchChangesRequest, stream racing.Racing_WatchChangesServer) error
+
+	// UpdateRaceStatus will move a race to a new status in its lifecycle.
+	UpdateRaceStatus(ctx context.Context, in *racing.UpdateRaceStatusRequest) (*racing.Race, error)
+
+	// ListStatusHistory will return a race's status transitions.
+	ListStatusHistory(ctx context.Context, in *racing.ListStatusHistoryRequest) (*racing.ListStatusHistoryResponse, error)
+
+	// SetRaceFeatured will feature a race, or stop featuring it.
+	SetRaceFeatured(ctx context.Context, in *racing.SetRaceFeaturedRequest) (*racing.SetRaceFeaturedResponse, error)
+
+	// ListFeaturedRaces will return the visible featured races.
+	ListFeaturedRaces(ctx context.Context, in *racing.ListFeaturedRacesRequest) (*racing.ListFeaturedRacesResponse, error)
+
+	// SetRaceRestrictions will replace the jurisdictions a race is restricted
+	// in.
+	SetRaceRestrictions(ctx context.Context, in *racing.SetRaceRestrictionsRequest) (*racing.SetRaceRestrictionsResponse, error)
+
+	// SetBrandVisibility will show or hide a race for a brand.
+	SetBrandVisibility(ctx context.Context, in *racing.SetBrandVisibilityRequest) (*racing.SetBrandVisibilityResponse, error)
+
+	// RecordRaceViews will count views of a race seen outside the service.
+	RecordRaceViews(ctx context.Context, in *racing.RecordRaceViewsRequest) (*racing.RecordRaceViewsResponse, error)
+
+	// ListPopularRaces will return the visible races viewed most recently.
+	ListPopularRaces(ctx context.Context, in *racing.ListPopularRacesRequest) (*racing.ListPopularRacesResponse, error)
+
+	// GetRaceStats will count races, grouped by meeting, country, status and
+	// day.
+	GetRaceStats(ctx context.Context, in *racing.GetRaceStatsRequest) (*racing.RaceStats, error)
+
+	// ListRacesByDay will return races grouped by the calendar day they start
+	// on at their venue.
+	ListRacesByDay(ctx context.Context, in *racing.ListRacesByDayRequest) (*racing.ListRacesByDayResponse, error)
+
+	// ListMeetings will return meetings, each with their races.
+	ListMeetings(ctx context.Context, in *racing.ListMeetingsRequest) (*racing.ListMeetingsResponse, error)
+
+	// ListRelatedRaces will return other upcoming races at the same meeting
+	// as a race, or starting around the same time.
+	ListRelatedRaces(ctx context.Context, in *racing.ListRelatedRacesRequest) (*racing.ListRelatedRacesResponse, error)
+
+	// CreateSubscription will register a callback URL to be notified of race
+	// changes.
+	CreateSubscription(ctx context.Context, in *racing.CreateSubscriptionRequest) (*racing.Subscription, error)
+
+	// DeleteSubscription will stop notifying a subscription.
+	DeleteSubscription(ctx context.Context, in *racing.DeleteSubscriptionRequest) (*racing.DeleteSubscriptionResponse, error)
+
+	// ListDeliveries will return the notifications sent to a subscription.
+	ListDeliveries(ctx context.Context, in *racing.ListDeliveriesRequest) (*racing.ListDeliveriesResponse, error)
+
+	// RegisterDevice will register a device for push notifications.
+	RegisterDevice(ctx context.Context, in *racing.RegisterDeviceRequest) (*racing.Device, error)
+
+	// UnregisterDevice will stop sending push notifications to a device.
+	UnregisterDevice(ctx context.Context, in *racing.UnregisterDeviceRequest) (*racing.UnregisterDeviceResponse, error)
+
+	// WatchRace will add a race to a user's watchlist.
+	WatchRace(ctx context.Context, in *racing.WatchRaceRequest) (*racing.WatchedRace, error)
+
+	// UnwatchRace will remove a race from a user's watchlist.
+	UnwatchRace(ctx context.Context, in *racing.UnwatchRaceRequest) (*racing.UnwatchRaceResponse, error)
+
+	// ListWatchlist will return the races on a user's watchlist.
+	ListWatchlist(ctx context.Context, in *racing.ListWatchlistRequest) (*racing.ListWatchlistResponse, error)
+
+	// IngestRaces will create or update the streamed races, writing them in
+	// batches.
+	IngestRaces(stream racing.Racing_IngestRacesServer) error
+
+	// SearchRaces will return the races best matching a free text query.
+	SearchRaces(ctx context.Context, in *racing.SearchRacesRequest) (*racing.SearchRacesResponse, error)
+
+	// GetReconciliationReport will return the report of the last
+	// reconciliation of races against the feed.
+	GetReconciliationReport(ctx context.Context, in *racing.GetReconciliationReportRequest) (*racing.ReconciliationReport, error)
 }
 
+const (
+	// watchBatchSize is how many changes are read from the change log at a
+	// time when watching changes.
+	watchBatchSize = 100
+	// watchPollInterval is how often the change log is checked for new changes
+	// once a watcher has caught up.
+	watchPollInterval = time.Second
+	// defaultSearchLimit is how many races a search returns when the request
+	// doesn't say.
+	defaultSearchLimit = 20
+	// ingestBatchSize is how many streamed races are written per transaction
+	// when ingesting races.
+	ingestBatchSize = 500
+	// maxIngestFailures caps how many failed races are described in an
+	// ingest response.
+	maxIngestFailures = 100
+	// defaultPageSize is how many races a page lists when the request doesn't
+	// say.
+	defaultPageSize = 50
+	// defaultPopularLimit is how many popular races are listed when the
+	// request doesn't say.
+	defaultPopularLimit = 10
+	// defaultPopularWindowHours is how many hours of views, before the current
+	// hour, races are ranked by when the request doesn't say.
+	defaultPopularWindowHours = 1
+	// maxPageSize caps how many races a page lists, however many are asked
+	// for.
+	maxPageSize = 1000
+	// defaultRelatedLimit is how many related races are listed when the
+	// request doesn't say.
+	defaultRelatedLimit = 10
+	// relatedWindow is how close to a race other races must start to be
+	// related to it, when they're at another meeting.
+	relatedWindow = time.Hour
+	// maxMeetingRaces caps how many of a meeting's races are read at once,
+	// many more than a meeting holds.
+	maxMeetingRaces = 100
+	// dateLayout is the layout of calendar dates, e.g. 2021-03-01.
+	dateLayout = "2006-01-02"
+	// maxUTCOffset and minUTCOffset are the furthest ahead of and behind UTC
+	// a venue's time zone can be, bounding when its calendar days start.
+	maxUTCOffset = 14 * time.Hour
+	minUTCOffset = -12 * time.Hour
+)
+
 // racingService implements the Racing interface.
 type racingService struct {
-	racesRepo db.RacesRepo
+	racesRepo          db.RacesRepo
+	marketsRepo        db.MarketsRepo
+	subscriptionsRepo  db.SubscriptionsRepo
+	notificationsRepo  db.NotificationsRepo
+	reconciliationRepo db.ReconciliationRepo
+	imageBaseURL       string
+	searchIndex        *search.Index
+	clock              db.Clock
+}
+
+// RacingServiceOption configures optional behaviour of the racing service.
+type RacingServiceOption func(*racingService)
+
+// WithImageBaseURL sets the base URL (e.g. a CDN) that image paths are
+// resolved against.
+func WithImageBaseURL(baseURL string) RacingServiceOption {
+	return func(s *racingService) {
+		s.imageBaseURL = strings.TrimRight(baseURL, "/")
+	}
+}
+
+// WithSearchIndex enables searching races, using the given index.
+func WithSearchIndex(index *search.Index) RacingServiceOption {
+	return func(s *racingService) {
+		s.searchIndex = index
+	}
+}
+
+// WithClock sets the clock races count down to their start from, and that
+// lists say they were generated at, rather than the system clock.
+func WithClock(clock db.Clock) RacingServiceOption {
+	return func(s *racingService) {
+		s.clock = clock
+	}
 }
 
 // NewRacingService instantiates and returns a new racingService.
-func NewRacingService(racesRepo db.RacesRepo) Racing {
-	return &racingService{racesRepo}
+func NewRacingService(racesRepo db.RacesRepo, marketsRepo db.MarketsRepo, subscriptionsRepo db.SubscriptionsRepo, notificationsRepo db.NotificationsRepo, reconciliationRepo db.ReconciliationRepo, opts ...RacingServiceOption) Racing {
+	s := &racingService{
+		racesRepo:          racesRepo,
+		marketsRepo:        marketsRepo,
+		subscriptionsRepo:  subscriptionsRepo,
+		notificationsRepo:  notificationsRepo,
+		reconciliationRepo: reconciliationRepo,
+		clock:              db.SystemClock,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
 }
 
 func (s *racingService) ListRaces(ctx context.Context, in *racing.ListRacesRequest) (*racing.ListRacesResponse, error) {
-	races, err := s.racesRepo.List(in.Filter)
+	var (
+		races []*racing.Race
+		err   error
+	)
+
+	if in.Filter.GetMissingStartTime() && !features.Enabled(ctx, features.MissingStartTimeFilter) {
+		return nil, status.Error(codes.FailedPrecondition, "the missing_start_time filter is turned off")
+	}
+
+	pageSize := clampPageSize(in.PageSize)
+
+	// The page token has already been validated by the request interceptor.
+	var after int64
+	if in.PageToken != "" {
+		if after, err = strconv.ParseInt(in.PageToken, 10, 64); err != nil {
+			return nil, err
+		}
+	}
+
+	// Races as they were aren't read for the caller's audience, so are
+	// filtered for it once read.
+	forCaller := in.AsOf == nil
+
+	// Read a race past the page, to tell whether there's another page.
+	switch {
+	case in.OrderBy == racing.ListRacesRequest_POPULARITY:
+		races, err = s.listByPopularity(ctx, in.Filter, after, pageSize+1)
+	case in.AsOf != nil:
+		races, err = s.racesRepo.ListAt(brandFilter(ctx, in.Filter), in.AsOf.AsTime())
+		races = pageRaces(races, after, pageSize+1)
+	default:
+		races, err = s.racesRepo.ListPage(in.Filter, audience(ctx), after, pageSize+1)
+	}
+	if errors.Is(err, db.ErrEventLogDisabled) {
+		return nil, status.Error(codes.FailedPrecondition, "as_of requires the event log to be enabled")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var nextPageToken string
+	if len(races) > pageSize {
+		races = races[:pageSize]
+		nextPageToken = strconv.FormatInt(races[pageSize-1].Id, 10)
+
+		// Races ranked by popularity are paged by position instead.
+		if in.OrderBy == racing.ListRacesRequest_POPULARITY {
+			nextPageToken = strconv.FormatInt(after+int64(pageSize), 10)
+		}
+	}
+
+	// Those restricted races, and races not visible as asked for the
+	// caller's brand, are dropped once the page is cut. Pages can be short,
+	// but don't skip races.
+	if !forCaller {
+		if races, err = s.withoutRestricted(ctx, races); err != nil {
+			return nil, err
+		}
+
+		if races, err = s.forBrand(ctx, races, in.Filter.GetVisibility()); err != nil {
+			return nil, err
+		}
+	}
+
+	s.resolveImageURLs(races)
+
+	// Races as of a time already count down from it.
+	now := s.now(ctx)
+	if in.AsOf == nil {
+		countDown(races, now)
+	} else {
+		now = in.AsOf.AsTime()
+	}
+
+	if err := localiseStartTimes(races, in.TimeZone); err != nil {
+		return nil, err
+	}
+
+	if err := s.localiseNames(races, in.Locale); err != nil {
+		return nil, err
+	}
+
+	return &racing.ListRacesResponse{
+		Races:         races,
+		NextPageToken: nextPageToken,
+		PageSize:      int32(pageSize),
+		GeneratedAt:   timestamppb.New(now),
+	}, nil
+}
+
+func (s *racingService) ListArchivedRaces(ctx context.Context, in *racing.ListArchivedRacesRequest) (*racing.ListArchivedRacesResponse, error) {
+	if in.Filter.GetMissingStartTime() && !features.Enabled(ctx, features.MissingStartTimeFilter) {
+		return nil, status.Error(codes.FailedPrecondition, "the missing_start_time filter is turned off")
+	}
+
+	pageSize := clampPageSize(in.PageSize)
+
+	// The page token and date range have already been validated by the
+	// request interceptor.
+	var (
+		after    int64
+		from, to time.Time
+		err      error
+	)
+
+	if in.PageToken != "" {
+		if after, err = strconv.ParseInt(in.PageToken, 10, 64); err != nil {
+			return nil, err
+		}
+	}
+
+	if in.DateRange != nil {
+		if from, err = time.Parse(dateLayout, in.DateRange.StartDate); err != nil {
+			return nil, err
+		}
+
+		to = from
+		if in.DateRange.EndDate != "" {
+			if to, err = time.Parse(dateLayout, in.DateRange.EndDate); err != nil {
+				return nil, err
+			}
+		}
+
+		// The range includes its last day.
+		to = to.AddDate(0, 0, 1)
+	}
+
+	// Read a race past the page, to tell whether there's another page.
+	races, err := s.racesRepo.ListArchived(in.Filter, audience(ctx), from, to, after, pageSize+1)
+	if err != nil {
+		return nil, err
+	}
+
+	var nextPageToken string
+	if len(races) > pageSize {
+		races = races[:pageSize]
+		nextPageToken = strconv.FormatInt(races[pageSize-1].Id, 10)
+	}
+
+	s.resolveImageURLs(races)
+
+	now := s.now(ctx)
+	countDown(races, now)
+
+	if err := localiseStartTimes(races, in.TimeZone); err != nil {
+		return nil, err
+	}
+
+	if err := s.localiseNames(races, in.Locale); err != nil {
+		return nil, err
+	}
+
+	return &racing.ListArchivedRacesResponse{
+		Races:         races,
+		GeneratedAt:   timestamppb.New(now),
+		NextPageToken: nextPageToken,
+		PageSize:      int32(pageSize),
+	}, nil
+}
+
+func (s *racingService) GetRaceByExternalRef(ctx context.Context, in *racing.GetRaceByExternalRefRequest) (*racing.Race, error) {
+	race, err := s.racesRepo.GetByExternalRef(in.ExternalRef.Source, in.ExternalRef.SourceId)
+	if errors.Is(err, db.ErrNotFound) {
+		return nil, status.Errorf(codes.NotFound, "race %s/%s not found", in.ExternalRef.Source, in.ExternalRef.SourceId)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	races, err := s.withoutRestricted(ctx, []*racing.Race{race})
+	if err != nil {
+		return nil, err
+	}
+
+	// Races restricted in the caller's jurisdiction look like they don't
+	// exist.
+	if len(races) == 0 {
+		return nil, status.Errorf(codes.NotFound, "race %s/%s not found", in.ExternalRef.Source, in.ExternalRef.SourceId)
+	}
+
+	if races, err = s.forBrand(ctx, races, racing.ListRacesRequestFilter_ALL); err != nil {
+		return nil, err
+	}
+
+	s.recordView(race.Id)
+
+	s.resolveImageURLs(races)
+
+	countDown(races, s.now(ctx))
+
+	if err := localiseStartTimes(races, ""); err != nil {
+		return nil, err
+	}
+
+	return race, nil
+}
+
+func (s *racingService) ListMarkets(ctx context.Context, in *racing.ListMarketsRequest) (*racing.ListMarketsResponse, error) {
+	markets, err := s.marketsRepo.List(in.Filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return &racing.ListMarketsResponse{Markets: markets}, nil
+}
+
+func (s *racingService) GetMarket(ctx context.Context, in *racing.GetMarketRequest) (*racing.Market, error) {
+	market, err := s.marketsRepo.Get(in.Id)
+	if errors.Is(err, db.ErrNotFound) {
+		return nil, status.Errorf(codes.NotFound, "market %d not found", in.Id)
+	}
+
+	return market, err
+}
+
+func (s *racingService) ListSelections(ctx context.Context, in *racing.ListSelectionsRequest) (*racing.ListSelectionsResponse, error) {
+	selections, err := s.marketsRepo.ListSelections(in.Filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return &racing.ListSelectionsResponse{Selections: selections}, nil
+}
+
+func (s *racingService) GetSelection(ctx context.Context, in *racing.GetSelectionRequest) (*racing.Selection, error) {
+	selection, err := s.marketsRepo.GetSelection(in.Id)
+	if errors.Is(err, db.ErrNotFound) {
+		return nil, status.Errorf(codes.NotFound, "selection %d not found", in.Id)
+	}
+
+	return selection, err
+}
+
+func (s *racingService) WatchChanges(in *racing.WatchChangesRequest, stream racing.Racing_WatchChangesServer) error {
+	var after int64
+
+	if in.SinceToken != "" {
+		// The token has already been validated by the request interceptor.
+		var err error
+		if after, err = strconv.ParseInt(in.SinceToken, 10, 64); err != nil {
+			return err
+		}
+	}
+
+	ticker := time.NewTicker(watchPollInterval)
+	defer ticker.Stop()
+
+	for {
+		changes, err := s.racesRepo.Changes(after, watchBatchSize)
+		if err != nil {
+			return err
+		}
+
+		for _, change := range changes {
+			if change.Race != nil {
+				races := []*racing.Race{change.Race}
+
+				s.resolveImageURLs(races)
+
+				if err := localiseStartTimes(races, ""); err != nil {
+					return err
+				}
+			}
+
+			if err := stream.Send(change); err != nil {
+				return err
+			}
+
+			if after, err = strconv.ParseInt(change.Token, 10, 64); err != nil {
+				return err
+			}
+		}
+
+		// Keep reading while there's a backlog, before waiting on new changes.
+		if len(changes) == watchBatchSize {
+			continue
+		}
+
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *racingService) UpdateRaceStatus(ctx context.Context, in *racing.UpdateRaceStatusRequest) (*racing.Race, error) {
+	// Changes are recorded against who made them, so they must be made by an
+	// authenticated user.
+	actor, ok := db.UserFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "status changes need an authenticated user")
+	}
+
+	race, err := s.racesRepo.UpdateStatus(in.RaceId, in.Status, actor)
+	switch {
+	case errors.Is(err, db.ErrNotFound):
+		return nil, status.Errorf(codes.NotFound, "race %d not found", in.RaceId)
+	case errors.Is(err, db.ErrInvalidTransition):
+		return nil, status.Errorf(codes.FailedPrecondition, "race %d: %s", in.RaceId, err)
+	case err != nil:
+		return nil, err
+	}
+
+	races := []*racing.Race{race}
+
+	s.resolveImageURLs(races)
+
+	countDown(races, s.now(ctx))
+
+	if err := localiseStartTimes(races, ""); err != nil {
+		return nil, err
+	}
+
+	return race, nil
+}
+
+func (s *racingService) ListStatusHistory(ctx context.Context, in *racing.ListStatusHistoryRequest) (*racing.ListStatusHistoryResponse, error) {
+	transitions, err := s.racesRepo.StatusHistory(in.RaceId)
+	if errors.Is(err, db.ErrNotFound) {
+		return nil, status.Errorf(codes.NotFound, "race %d not found", in.RaceId)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &racing.ListStatusHistoryResponse{Transitions: transitions}, nil
+}
+
+func (s *racingService) SetRaceFeatured(ctx context.Context, in *racing.SetRaceFeaturedRequest) (*racing.SetRaceFeaturedResponse, error) {
+	err := s.racesRepo.SetFeatured(in.RaceId, in.Featured)
+	if errors.Is(err, db.ErrNotFound) {
+		return nil, status.Errorf(codes.NotFound, "race %d not found", in.RaceId)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &racing.SetRaceFeaturedResponse{}, nil
+}
+
+func (s *racingService) ListFeaturedRaces(ctx context.Context, in *racing.ListFeaturedRacesRequest) (*racing.ListFeaturedRacesResponse, error) {
+	races, err := s.racesRepo.ListFeatured()
+	if err != nil {
+		return nil, err
+	}
+
+	if races, err = s.withoutRestricted(ctx, races); err != nil {
+		return nil, err
+	}
+
+	if races, err = s.forBrand(ctx, races, racing.ListRacesRequestFilter_VISIBLE_ONLY); err != nil {
+		return nil, err
+	}
+
+	s.resolveImageURLs(races)
+
+	now := s.now(ctx)
+	countDown(races, now)
+
+	if err := localiseStartTimes(races, in.TimeZone); err != nil {
+		return nil, err
+	}
+
+	if err := s.localiseNames(races, in.Locale); err != nil {
+		return nil, err
+	}
+
+	return &racing.ListFeaturedRacesResponse{Races: races, GeneratedAt: timestamppb.New(now)}, nil
+}
+
+func (s *racingService) SetRaceRestrictions(ctx context.Context, in *racing.SetRaceRestrictionsRequest) (*racing.SetRaceRestrictionsResponse, error) {
+	err := s.racesRepo.SetRestrictions(in.RaceId, in.Jurisdictions)
+	if errors.Is(err, db.ErrNotFound) {
+		return nil, status.Errorf(codes.NotFound, "race %d not found", in.RaceId)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &racing.SetRaceRestrictionsResponse{}, nil
+}
+
+func (s *racingService) SetBrandVisibility(ctx context.Context, in *racing.SetBrandVisibilityRequest) (*racing.SetBrandVisibilityResponse, error) {
+	var visible *bool
+
+	switch in.Visibility {
+	case racing.SetBrandVisibilityRequest_VISIBLE:
+		visible = proto.Bool(true)
+	case racing.SetBrandVisibilityRequest_HIDDEN:
+		visible = proto.Bool(false)
+	}
+
+	err := s.racesRepo.SetBrandVisibility(in.RaceId, in.Brand, visible)
+	if errors.Is(err, db.ErrNotFound) {
+		return nil, status.Errorf(codes.NotFound, "race %d not found", in.RaceId)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &racing.SetBrandVisibilityResponse{}, nil
+}
+
+func (s *racingService) RecordRaceViews(ctx context.Context, in *racing.RecordRaceViewsRequest) (*racing.RecordRaceViewsResponse, error) {
+	err := s.racesRepo.RecordViews(in.RaceId, in.Views, s.clock.Now())
+	if errors.Is(err, db.ErrNotFound) {
+		return nil, status.Errorf(codes.NotFound, "race %d not found", in.RaceId)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &racing.RecordRaceViewsResponse{}, nil
+}
+
+func (s *racingService) ListPopularRaces(ctx context.Context, in *racing.ListPopularRacesRequest) (*racing.ListPopularRacesResponse, error) {
+	limit := int(in.Limit)
+	if limit == 0 {
+		limit = defaultPopularLimit
+	}
+
+	windowHours := int(in.WindowHours)
+	if windowHours == 0 {
+		windowHours = defaultPopularWindowHours
+	}
+
+	visibleOnly := &racing.ListRacesRequestFilter{Visibility: racing.ListRacesRequestFilter_VISIBLE_ONLY}
+
+	now := s.now(ctx)
+
+	ranked, err := s.racesRepo.ListPopular(visibleOnly, audience(ctx), now.Add(-time.Duration(windowHours)*time.Hour), 0, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	// Races that haven't been viewed aren't popular.
+	popular := make([]*racing.PopularRace, 0, limit)
+	races := make([]*racing.Race, 0, limit)
+
+	for _, race := range ranked {
+		if race.Views == 0 {
+			break
+		}
+
+		popular = append(popular, race)
+		races = append(races, race.Race)
+	}
+
+	s.resolveImageURLs(races)
+	countDown(races, now)
+
+	if err := localiseStartTimes(races, in.TimeZone); err != nil {
+		return nil, err
+	}
+
+	if err := s.localiseNames(races, in.Locale); err != nil {
+		return nil, err
+	}
+
+	return &racing.ListPopularRacesResponse{Races: popular, GeneratedAt: timestamppb.New(now)}, nil
+}
+
+func (s *racingService) GetRaceStats(ctx context.Context, in *racing.GetRaceStatsRequest) (*racing.RaceStats, error) {
+	if in.Filter.GetMissingStartTime() && !features.Enabled(ctx, features.MissingStartTimeFilter) {
+		return nil, status.Error(codes.FailedPrecondition, "the missing_start_time filter is turned off")
+	}
+
+	stats, err := s.racesRepo.Stats(in.Filter)
 	if err != nil {
 		return nil, err
 	}
 
-	return &racing.ListRacesResponse{Races: races}, nil
+	stats.GeneratedAt = timestamppb.New(s.now(ctx))
+
+	return stats, nil
+}
+
+func (s *racingService) ListRacesByDay(ctx context.Context, in *racing.ListRacesByDayRequest) (*racing.ListRacesByDayResponse, error) {
+	if in.Filter.GetMissingStartTime() && !features.Enabled(ctx, features.MissingStartTimeFilter) {
+		return nil, status.Error(codes.FailedPrecondition, "the missing_start_time filter is turned off")
+	}
+
+	// The date range has already been validated by the request interceptor.
+	start, err := time.Parse(dateLayout, in.DateRange.StartDate)
+	if err != nil {
+		return nil, err
+	}
+
+	end := start
+	if in.DateRange.EndDate != "" {
+		if end, err = time.Parse(dateLayout, in.DateRange.EndDate); err != nil {
+			return nil, err
+		}
+	}
+
+	// A venue's day starts up to maxUTCOffset before the UTC day does, and
+	// ends up to -minUTCOffset after it, so read every race that could start
+	// on one of the days somewhere.
+	races, err := s.racesRepo.ListBetween(in.Filter, audience(ctx), start.Add(-maxUTCOffset), end.AddDate(0, 0, 1).Add(-minUTCOffset))
+	if err != nil {
+		return nil, err
+	}
+
+	s.resolveImageURLs(races)
+
+	now := s.now(ctx)
+	countDown(races, now)
+
+	// Races are grouped by their venue's day, so start times are local to it.
+	if err := localiseStartTimes(races, ""); err != nil {
+		return nil, err
+	}
+
+	if err := s.localiseNames(races, in.Locale); err != nil {
+		return nil, err
+	}
+
+	var days []*racing.RaceDay
+	byDate := make(map[string]*racing.RaceDay)
+
+	for day := start; !day.After(end); day = day.AddDate(0, 0, 1) {
+		raceDay := &racing.RaceDay{Date: day.Format(dateLayout)}
+
+		days = append(days, raceDay)
+		byDate[raceDay.Date] = raceDay
+	}
+
+	// Races that start on a day outside the range at their venue are dropped.
+	for _, race := range races {
+		if raceDay, ok := byDate[venueDate(race)]; ok {
+			raceDay.Races = append(raceDay.Races, race)
+		}
+	}
+
+	return &racing.ListRacesByDayResponse{Days: days, GeneratedAt: timestamppb.New(now)}, nil
+}
+
+func (s *racingService) ListMeetings(ctx context.Context, in *racing.ListMeetingsRequest) (*racing.ListMeetingsResponse, error) {
+	if in.Filter.GetMissingStartTime() && !features.Enabled(ctx, features.MissingStartTimeFilter) {
+		return nil, status.Error(codes.FailedPrecondition, "the missing_start_time filter is turned off")
+	}
+
+	pageSize := clampPageSize(in.PageSize)
+
+	// The page token has already been validated by the request interceptor.
+	var after int64
+	if in.PageToken != "" {
+		var err error
+		if after, err = strconv.ParseInt(in.PageToken, 10, 64); err != nil {
+			return nil, err
+		}
+	}
+
+	// Read a meeting past the page, to tell whether there's another page.
+	races, err := s.racesRepo.ListMeetingPage(in.Filter, audience(ctx), after, pageSize+1, maxMeetingRaces)
+	if err != nil {
+		return nil, err
+	}
+
+	s.resolveImageURLs(races)
+
+	now := s.now(ctx)
+	countDown(races, now)
+
+	if err := localiseStartTimes(races, in.TimeZone); err != nil {
+		return nil, err
+	}
+
+	if err := s.localiseNames(races, in.Locale); err != nil {
+		return nil, err
+	}
+
+	meetings := byMeeting(races)
+
+	var nextPageToken string
+	if len(meetings) > pageSize {
+		meetings = meetings[:pageSize]
+		nextPageToken = strconv.FormatInt(meetings[pageSize-1].Id, 10)
+	}
+
+	return &racing.ListMeetingsResponse{
+		Meetings:      meetings,
+		GeneratedAt:   timestamppb.New(now),
+		NextPageToken: nextPageToken,
+		PageSize:      int32(pageSize),
+	}, nil
+}
+
+func (s *racingService) ListRelatedRaces(ctx context.Context, in *racing.ListRelatedRacesRequest) (*racing.ListRelatedRacesResponse, error) {
+	limit := int(in.Limit)
+	if limit == 0 {
+		limit = defaultRelatedLimit
+	}
+
+	races, err := s.racesRepo.ListByIDs([]int64{in.RaceId})
+	if err != nil {
+		return nil, err
+	}
+
+	if races, err = s.withoutRestricted(ctx, races); err != nil {
+		return nil, err
+	}
+
+	if races, err = s.forBrand(ctx, races, racing.ListRacesRequestFilter_ALL); err != nil {
+		return nil, err
+	}
+	if len(races) == 0 {
+		return nil, status.Errorf(codes.NotFound, "race %d not found", in.RaceId)
+	}
+
+	race := races[0]
+
+	candidates, err := s.racesRepo.ListPage(&racing.ListRacesRequestFilter{
+		MeetingIds: []int64{race.MeetingId},
+		Visibility: racing.ListRacesRequestFilter_VISIBLE_ONLY,
+	}, audience(ctx), 0, maxMeetingRaces)
+	if err != nil {
+		return nil, err
+	}
+
+	if race.AdvertisedStartTime != nil {
+		start := race.AdvertisedStartTime.AsTime()
+
+		nearby, err := s.racesRepo.ListBetween(&racing.ListRacesRequestFilter{
+			Visibility: racing.ListRacesRequestFilter_VISIBLE_ONLY,
+		}, audience(ctx), start.Add(-relatedWindow), start.Add(relatedWindow))
+		if err != nil {
+			return nil, err
+		}
+
+		candidates = append(candidates, nearby...)
+	}
+
+	now := s.now(ctx)
+
+	// Races at the meeting can start near the race too, so are skipped once
+	// seen.
+	seen := map[int64]bool{race.Id: true}
+	related := make([]*racing.Race, 0, len(candidates))
+
+	for _, candidate := range candidates {
+		if seen[candidate.Id] || candidate.Status != racing.Race_OPEN || candidate.AdvertisedStartTime == nil || !candidate.AdvertisedStartTime.AsTime().After(now) {
+			continue
+		}
+
+		seen[candidate.Id] = true
+		related = append(related, candidate)
+	}
+
+	byRelevance(race, related)
+
+	if len(related) > limit {
+		related = related[:limit]
+	}
+
+	s.resolveImageURLs(related)
+	countDown(related, now)
+
+	if err := localiseStartTimes(related, in.TimeZone); err != nil {
+		return nil, err
+	}
+
+	if err := s.localiseNames(related, in.Locale); err != nil {
+		return nil, err
+	}
+
+	return &racing.ListRelatedRacesResponse{Races: related, GeneratedAt: timestamppb.New(now)}, nil
+}
+
+func (s *racingService) CreateSubscription(ctx context.Context, in *racing.CreateSubscriptionRequest) (*racing.Subscription, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+
+	sub := &racing.Subscription{
+		CallbackUrl: in.CallbackUrl,
+		Filter:      in.Filter,
+		Secret:      hex.EncodeToString(secret),
+	}
+
+	if err := s.subscriptionsRepo.Create(sub); err != nil {
+		return nil, err
+	}
+
+	return sub, nil
+}
+
+func (s *racingService) DeleteSubscription(ctx context.Context, in *racing.DeleteSubscriptionRequest) (*racing.DeleteSubscriptionResponse, error) {
+	err := s.subscriptionsRepo.Delete(in.Id)
+	if errors.Is(err, db.ErrNotFound) {
+		return nil, status.Errorf(codes.NotFound, "subscription %d not found", in.Id)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &racing.DeleteSubscriptionResponse{}, nil
+}
+
+func (s *racingService) ListDeliveries(ctx context.Context, in *racing.ListDeliveriesRequest) (*racing.ListDeliveriesResponse, error) {
+	deliveries, err := s.subscriptionsRepo.ListDeliveries(in.SubscriptionId)
+	if errors.Is(err, db.ErrNotFound) {
+		return nil, status.Errorf(codes.NotFound, "subscription %d not found", in.SubscriptionId)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &racing.ListDeliveriesResponse{Deliveries: deliveries}, nil
+}
+
+func (s *racingService) RegisterDevice(ctx context.Context, in *racing.RegisterDeviceRequest) (*racing.Device, error) {
+	device := &racing.Device{
+		Channel: in.Channel,
+		Token:   in.Token,
+		Filter: &racing.DeviceFilter{
+			RaceIds:    in.GetFilter().GetRaceIds(),
+			MeetingIds: in.GetFilter().GetMeetingIds(),
+		},
+	}
+
+	if in.FollowWatchlist {
+		userID, err := watchlistUser(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		device.Filter.UserId = userID
+	}
+
+	// Devices are only sent races as they're listed for the caller, so
+	// aren't sent races hidden from them.
+	if err := s.notificationsRepo.RegisterDevice(device, audience(ctx)); err != nil {
+		return nil, err
+	}
+
+	return device, nil
+}
+
+func (s *racingService) UnregisterDevice(ctx context.Context, in *racing.UnregisterDeviceRequest) (*racing.UnregisterDeviceResponse, error) {
+	err := s.notificationsRepo.UnregisterDevice(in.Channel, in.Token)
+	if errors.Is(err, db.ErrNotFound) {
+		return nil, status.Errorf(codes.NotFound, "device not registered on %s", in.Channel)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &racing.UnregisterDeviceResponse{}, nil
+}
+
+func (s *racingService) WatchRace(ctx context.Context, in *racing.WatchRaceRequest) (*racing.WatchedRace, error) {
+	userID, err := watchlistUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	races, err := s.racesRepo.ListByIDs([]int64{in.RaceId})
+	if err != nil {
+		return nil, err
+	}
+
+	if races, err = s.withoutRestricted(ctx, races); err != nil {
+		return nil, err
+	}
+
+	if races, err = s.forBrand(ctx, races, racing.ListRacesRequestFilter_ALL); err != nil {
+		return nil, err
+	}
+	if len(races) == 0 {
+		return nil, status.Errorf(codes.NotFound, "race %d not found", in.RaceId)
+	}
+
+	s.recordView(in.RaceId)
+
+	watched, err := s.notificationsRepo.Watch(userID, in.RaceId, s.clock.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	s.resolveImageURLs(races)
+	countDown(races, s.now(ctx))
+
+	watched.Race = races[0]
+
+	return watched, nil
+}
+
+func (s *racingService) UnwatchRace(ctx context.Context, in *racing.UnwatchRaceRequest) (*racing.UnwatchRaceResponse, error) {
+	userID, err := watchlistUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.notificationsRepo.Unwatch(userID, in.RaceId)
+	if errors.Is(err, db.ErrNotFound) {
+		return nil, status.Errorf(codes.NotFound, "race %d not on the watchlist", in.RaceId)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &racing.UnwatchRaceResponse{}, nil
+}
+
+func (s *racingService) ListWatchlist(ctx context.Context, in *racing.ListWatchlistRequest) (*racing.ListWatchlistResponse, error) {
+	userID, err := watchlistUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	watchlist, err := s.notificationsRepo.Watchlist(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	raceIDs := make([]int64, 0, len(watchlist))
+	for _, watched := range watchlist {
+		raceIDs = append(raceIDs, watched.RaceId)
+	}
+
+	// Races archived or purged since they were watched, or restricted in the
+	// caller's jurisdiction, are skipped, so are left unset.
+	races, err := s.racesRepo.ListByIDs(raceIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	if races, err = s.withoutRestricted(ctx, races); err != nil {
+		return nil, err
+	}
+
+	if races, err = s.forBrand(ctx, races, racing.ListRacesRequestFilter_ALL); err != nil {
+		return nil, err
+	}
+
+	s.resolveImageURLs(races)
+
+	now := s.now(ctx)
+	countDown(races, now)
+
+	if err := localiseStartTimes(races, in.TimeZone); err != nil {
+		return nil, err
+	}
+
+	if err := s.localiseNames(races, in.Locale); err != nil {
+		return nil, err
+	}
+
+	byID := make(map[int64]*racing.Race, len(races))
+	for _, race := range races {
+		byID[race.Id] = race
+	}
+
+	for _, watched := range watchlist {
+		watched.Race = byID[watched.RaceId]
+	}
+
+	return &racing.ListWatchlistResponse{Races: watchlist, GeneratedAt: timestamppb.New(now)}, nil
+}
+
+func (s *racingService) IngestRaces(stream racing.Racing_IngestRacesServer) error {
+	var (
+		resp  racing.IngestRacesResponse
+		batch []*racing.Race
+		index int32
+	)
+
+	flush := func() error {
+		outcomes, err := s.racesRepo.UpsertBatch(batch)
+		if err != nil {
+			return err
+		}
+
+		for i, outcome := range outcomes {
+			switch {
+			case outcome.Err != nil:
+				resp.Failed++
+
+				if len(resp.Failures) < maxIngestFailures {
+					resp.Failures = append(resp.Failures, &racing.IngestFailure{
+						Index:       index - int32(len(batch)) + int32(i),
+						ExternalRef: batch[i].ExternalRef,
+						Reason:      outcome.Err.Error(),
+					})
+				}
+			case outcome.Result == db.UpsertCreated:
+				resp.Created++
+			case outcome.Result == db.UpsertUpdated:
+				resp.Updated++
+			default:
+				resp.Unchanged++
+			}
+		}
+
+		batch = batch[:0]
+
+		return nil
+	}
+
+	for {
+		race, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		batch = append(batch, race)
+		index++
+
+		if len(batch) == ingestBatchSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+
+	if len(batch) > 0 {
+		if err := flush(); err != nil {
+			return err
+		}
+	}
+
+	return stream.SendAndClose(&resp)
+}
+
+func (s *racingService) SearchRaces(ctx context.Context, in *racing.SearchRacesRequest) (*racing.SearchRacesResponse, error) {
+	if s.searchIndex == nil {
+		return nil, status.Error(codes.FailedPrecondition, "search requires the search index to be enabled")
+	}
+
+	limit := int(in.Limit)
+	if limit == 0 {
+		limit = defaultSearchLimit
+	}
+
+	hits, err := s.searchIndex.Search(in.Query, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	raceIDs := make([]int64, 0, len(hits))
+	for _, hit := range hits {
+		raceIDs = append(raceIDs, hit.RaceID)
+	}
+
+	// The index can lag behind races being deleted, so races are looked up
+	// rather than trusted to still exist.
+	races, err := s.racesRepo.ListByIDs(raceIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	if races, err = s.withoutRestricted(ctx, races); err != nil {
+		return nil, err
+	}
+
+	if races, err = s.forBrand(ctx, races, racing.ListRacesRequestFilter_ALL); err != nil {
+		return nil, err
+	}
+
+	s.resolveImageURLs(races)
+
+	now := s.now(ctx)
+	countDown(races, now)
+
+	if err := localiseStartTimes(races, ""); err != nil {
+		return nil, err
+	}
+
+	scores := make(map[int64]float64, len(hits))
+	for _, hit := range hits {
+		scores[hit.RaceID] = hit.Score
+	}
+
+	results := make([]*racing.SearchHit, 0, len(races))
+	for _, race := range races {
+		results = append(results, &racing.SearchHit{Race: race, Score: scores[race.Id]})
+	}
+
+	return &racing.SearchRacesResponse{Hits: results, GeneratedAt: timestamppb.New(now)}, nil
+}
+
+func (s *racingService) GetReconciliationReport(ctx context.Context, in *racing.GetReconciliationReportRequest) (*racing.ReconciliationReport, error) {
+	report, err := s.reconciliationRepo.LatestReport()
+	if errors.Is(err, db.ErrNotFound) {
+		return nil, status.Error(codes.NotFound, "no reconciliation has run")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+// resolveImageURLs turns the image paths stored against races into absolute
+// URLs on the configured image host.
+func (s *racingService) resolveImageURLs(races []*racing.Race) {
+	if s.imageBaseURL == "" {
+		return
+	}
+
+	for _, race := range races {
+		if race.VenueImageUrl != "" {
+			race.VenueImageUrl = s.imageBaseURL + "/" + strings.TrimLeft(race.VenueImageUrl, "/")
+		}
+	}
+}
+
+// clampPageSize returns the page size to use for the one requested, applying
+// the default and cap.
+func clampPageSize(requested int32) int {
+	switch {
+	case requested <= 0:
+		return defaultPageSize
+	case requested > maxPageSize:
+		return maxPageSize
+	default:
+		return int(requested)
+	}
+}
+
+// pageRaces returns up to limit of the races, in ID order, starting after the
+// race with the given ID.
+func pageRaces(races []*racing.Race, after int64, limit int) []*racing.Race {
+	sort.Slice(races, func(i, j int) bool {
+		return races[i].Id < races[j].Id
+	})
+
+	start := sort.Search(len(races), func(i int) bool {
+		return races[i].Id > after
+	})
+
+	races = races[start:]
+	if len(races) > limit {
+		races = races[:limit]
+	}
+
+	return races
+}
+
+// now returns the time it is for a request: the time it pretends to be, if
+// it does, or else the time on the service's clock.
+func (s *racingService) now(ctx context.Context) time.Time {
+	if now, ok := db.NowFromContext(ctx); ok {
+		return now
+	}
+
+	return s.clock.Now()
+}
+
+// countDown counts races down to their start from the given time, so every
+// race in a response counts down from the same time.
+func countDown(races []*racing.Race, now time.Time) {
+	for _, race := range races {
+		if race.AdvertisedStartTime == nil {
+			continue
+		}
+
+		race.SecondsToStart = int64(race.AdvertisedStartTime.AsTime().Sub(now).Seconds())
+	}
+}
+
+// localiseNames replaces race names with their name in the given locale, where
+// one exists.
+func (s *racingService) localiseNames(races []*racing.Race, locale string) error {
+	if locale == "" || len(races) == 0 {
+		return nil
+	}
+
+	raceIDs := make([]int64, 0, len(races))
+	for _, race := range races {
+		raceIDs = append(raceIDs, race.Id)
+	}
+
+	names, err := s.racesRepo.LocalisedNames(raceIDs, locale)
+	if err != nil {
+		return err
+	}
+
+	for _, race := range races {
+		if name, ok := names[race.Id]; ok {
+			race.Name = name
+		}
+	}
+
+	return nil
+}
+
+// watchlistUser returns the user the caller is authenticated as, whose
+// watchlist is used, as callers can't use another user's.
+func watchlistUser(ctx context.Context) (string, error) {
+	userID, ok := db.UserFromContext(ctx)
+	if !ok {
+		return "", status.Error(codes.Unauthenticated, "watchlists need an authenticated user")
+	}
+
+	return userID, nil
+}
+
+// audience returns who the request lists races for, for the repository to
+// leave out races they mustn't be shown, and show races as they are for
+// their brand, as it reads them.
+func audience(ctx context.Context) db.Audience {
+	jurisdiction, _ := db.JurisdictionFromContext(ctx)
+	brand, _ := db.BrandFromContext(ctx)
+
+	return db.Audience{Jurisdiction: jurisdiction, Brand: brand}
+}
+
+// withoutRestricted returns the races that aren't restricted in the
+// jurisdiction the request was made from, in order. Every race is returned
+// to requests that don't say where they're from. Lists of races are read for
+// the request's audience instead, as this only suits a handful of races.
+func (s *racingService) withoutRestricted(ctx context.Context, races []*racing.Race) ([]*racing.Race, error) {
+	jurisdiction, ok := db.JurisdictionFromContext(ctx)
+	if !ok || len(races) == 0 {
+		return races, nil
+	}
+
+	raceIDs := make([]int64, 0, len(races))
+	for _, race := range races {
+		raceIDs = append(raceIDs, race.Id)
+	}
+
+	restricted, err := s.racesRepo.Restricted(raceIDs, jurisdiction)
+	if err != nil {
+		return nil, err
+	}
+
+	allowed := races[:0]
+	for _, race := range races {
+		if !restricted[race.Id] {
+			allowed = append(allowed, race)
+		}
+	}
+
+	return allowed, nil
+}
+
+// listByPopularity lists races by how many times they were viewed since the
+// start of the previous hour, most viewed first, skipping the first offset
+// races.
+func (s *racingService) listByPopularity(ctx context.Context, filter *racing.ListRacesRequestFilter, offset int64, limit int) ([]*racing.Race, error) {
+	ranked, err := s.racesRepo.ListPopular(filter, audience(ctx), s.now(ctx).Add(-defaultPopularWindowHours*time.Hour), offset, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	races := make([]*racing.Race, 0, len(ranked))
+	for _, race := range ranked {
+		races = append(races, race.Race)
+	}
+
+	return races, nil
+}
+
+// recordView counts a race being looked up as a view of it, for ranking
+// popular races. Failing to count it doesn't fail the lookup.
+func (s *racingService) recordView(raceID int64) {
+	err := s.racesRepo.RecordViews(raceID, 1, s.clock.Now())
+
+	// Archived races can still be looked up, but aren't ranked.
+	if err != nil && !errors.Is(err, db.ErrNotFound) {
+		log.Printf("failed recording view of race %d: %s\n", raceID, err)
+	}
+}
+
+// brandFilter returns the filter to read races for the brand the request was
+// made through with, where they aren't read for the request's audience.
+// Races can be shown or hidden per brand, so their visibility is filtered by
+// forBrand rather than the repository.
+func brandFilter(ctx context.Context, filter *racing.ListRacesRequestFilter) *racing.ListRacesRequestFilter {
+	if _, ok := db.BrandFromContext(ctx); !ok || filter.GetVisibility() == racing.ListRacesRequestFilter_ALL {
+		return filter
+	}
+
+	filter = proto.Clone(filter).(*racing.ListRacesRequestFilter)
+	filter.Visibility = racing.ListRacesRequestFilter_ALL
+
+	return filter
+}
+
+// forBrand shows or hides races as they are for the brand the request was
+// made through, returning those with the given visibility, in order. Races
+// are shown as they are for every brand to requests that don't say which
+// they're made through. Like withoutRestricted, it only suits a handful of
+// races.
+func (s *racingService) forBrand(ctx context.Context, races []*racing.Race, visibility racing.ListRacesRequestFilter_Visibility) ([]*racing.Race, error) {
+	brand, ok := db.BrandFromContext(ctx)
+	if !ok || len(races) == 0 {
+		return races, nil
+	}
+
+	raceIDs := make([]int64, 0, len(races))
+	for _, race := range races {
+		raceIDs = append(raceIDs, race.Id)
+	}
+
+	overrides, err := s.racesRepo.BrandVisibility(raceIDs, brand)
+	if err != nil {
+		return nil, err
+	}
+
+	shown := races[:0]
+	for _, race := range races {
+		if visible, ok := overrides[race.Id]; ok {
+			race.Visible = visible
+		}
+
+		switch {
+		case visibility == racing.ListRacesRequestFilter_VISIBLE_ONLY && !race.Visible:
+		case visibility == racing.ListRacesRequestFilter_HIDDEN_ONLY && race.Visible:
+		default:
+			shown = append(shown, race)
+		}
+	}
+
+	return shown, nil
+}
+
+// byRelevance orders races related to a race by how related they are: those
+// at its meeting first, then by how close they start to it, then by ID.
+func byRelevance(race *racing.Race, related []*racing.Race) {
+	gap := func(other *racing.Race) time.Duration {
+		if race.AdvertisedStartTime == nil {
+			return 0
+		}
+
+		gap := other.AdvertisedStartTime.AsTime().Sub(race.AdvertisedStartTime.AsTime())
+		if gap < 0 {
+			return -gap
+		}
+
+		return gap
+	}
+
+	sort.Slice(related, func(i, j int) bool {
+		iAtMeeting, jAtMeeting := related[i].MeetingId == race.MeetingId, related[j].MeetingId == race.MeetingId
+		if iAtMeeting != jAtMeeting {
+			return iAtMeeting
+		}
+
+		if iGap, jGap := gap(related[i]), gap(related[j]); iGap != jGap {
+			return iGap < jGap
+		}
+
+		return related[i].Id < related[j].Id
+	})
+}
+
+// byMeeting groups races by their meeting, in meeting ID order, with each
+// meeting's races in number order.
+func byMeeting(races []*racing.Race) []*racing.Meeting {
+	var meetings []*racing.Meeting
+	byID := make(map[int64]*racing.Meeting)
+
+	for _, race := range races {
+		meeting, ok := byID[race.MeetingId]
+		if !ok {
+			// A meeting's races are all held at its venue.
+			meeting = &racing.Meeting{Id: race.MeetingId, Country: race.Country, VenueTimeZone: race.VenueTimeZone}
+
+			meetings = append(meetings, meeting)
+			byID[race.MeetingId] = meeting
+		}
+
+		meeting.Races = append(meeting.Races, race)
+	}
+
+	sort.Slice(meetings, func(i, j int) bool {
+		return meetings[i].Id < meetings[j].Id
+	})
+
+	for _, meeting := range meetings {
+		races := meeting.Races
+
+		sort.Slice(races, func(i, j int) bool {
+			return races[i].Number < races[j].Number
+		})
+	}
+
+	return meetings
+}
+
+// venueDate returns the calendar day a race starts on at its venue, from its
+// local start time, or its UTC day when its venue time zone isn't known.
+func venueDate(race *racing.Race) string {
+	if len(race.LocalAdvertisedStartTime) >= len(dateLayout) {
+		return race.LocalAdvertisedStartTime[:len(dateLayout)]
+	}
+
+	return race.AdvertisedStartTime.AsTime().UTC().Format(dateLayout)
+}
+
+// localiseStartTimes formats each race's advertised start time in the given
+// time zone, or in the race's venue time zone when none is given.
+func localiseStartTimes(races []*racing.Race, timeZone string) error {
+	var requested *time.Location
+
+	if timeZone != "" {
+		// The time zone has already been validated by the request interceptor.
+		loc, err := time.LoadLocation(timeZone)
+		if err != nil {
+			return err
+		}
+
+		requested = loc
+	}
+
+	venues := make(map[string]*time.Location)
+
+	for _, race := range races {
+		if race.AdvertisedStartTime == nil {
+			continue
+		}
+
+		loc := requested
+		if loc == nil {
+			venue, ok := venues[race.VenueTimeZone]
+			if !ok {
+				var err error
+				if venue, err = time.LoadLocation(race.VenueTimeZone); err != nil {
+					// Leave the local start time unset, rather than failing the
+					// whole list over one race with a bad venue time zone.
+					continue
+				}
+
+				venues[race.VenueTimeZone] = venue
+			}
+
+			loc = venue
+		}
+
+		race.LocalAdvertisedStartTime = race.AdvertisedStartTime.AsTime().In(loc).Format(time.RFC3339)
+	}
+
+	return nil
 }
@@ -0,0 +1,861 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"git.neds.sh/matty/entain/racing/db"
+	"git.neds.sh/matty/entain/racing/features"
+	"git.neds.sh/matty/entain/racing/mocks"
+	"git.neds.sh/matty/entain/racing/proto/racing"
+	"git.neds.sh/matty/entain/racing/racetest"
+	"github.com/golang/mock/gomock"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// testService wires a racing service to mock repositories.
+type testService struct {
+	Racing
+	races          *mocks.MockRacesRepo
+	markets        *mocks.MockMarketsRepo
+	notifications  *mocks.MockNotificationsRepo
+	reconciliation *mocks.MockReconciliationRepo
+}
+
+func newTestService(t *testing.T, opts ...RacingServiceOption) *testService {
+	ctrl := gomock.NewController(t)
+	t.Cleanup(ctrl.Finish)
+
+	s := &testService{
+		races:          mocks.NewMockRacesRepo(ctrl),
+		markets:        mocks.NewMockMarketsRepo(ctrl),
+		notifications:  mocks.NewMockNotificationsRepo(ctrl),
+		reconciliation: mocks.NewMockReconciliationRepo(ctrl),
+	}
+
+	s.Racing = NewRacingService(
+		s.races,
+		s.markets,
+		mocks.NewMockSubscriptionsRepo(ctrl),
+		s.notifications,
+		s.reconciliation,
+		opts...,
+	)
+
+	return s
+}
+
+// raceIDs returns the IDs of races, in order.
+func raceIDs(races []*racing.Race) []int64 {
+	ids := make([]int64, 0, len(races))
+	for _, race := range races {
+		ids = append(ids, race.Id)
+	}
+
+	return ids
+}
+
+func TestListRacesResolvesImageURLs(t *testing.T) {
+	s := newTestService(t, WithImageBaseURL("https://cdn.example.com/"))
+
+	filter := &racing.ListRacesRequestFilter{MeetingIds: []int64{1}}
+
+	s.races.EXPECT().ListPage(filter, db.Audience{}, int64(0), defaultPageSize+1).Return([]*racing.Race{
+		racetest.NewRace().ID(1).Image("/venues/1.jpg").Build(),
+	}, nil)
+
+	resp, err := s.ListRaces(context.Background(), &racing.ListRacesRequest{Filter: filter})
+	if err != nil {
+		t.Fatalf("ListRaces() error = %s", err)
+	}
+
+	if got, want := resp.Races[0].VenueImageUrl, "https://cdn.example.com/venues/1.jpg"; got != want {
+		t.Errorf("venue image url = %q, want %q", got, want)
+	}
+}
+
+func TestListRacesCountsDownFromFakeNow(t *testing.T) {
+	s := newTestService(t)
+
+	now := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	s.races.EXPECT().ListPage(gomock.Nil(), db.Audience{}, int64(0), defaultPageSize+1).Return([]*racing.Race{
+		racetest.NewRace().ID(1).StartsAt(now.Add(-30 * time.Second)).Build(),
+		racetest.NewRace().ID(2).StartsAt(now.Add(5 * time.Minute)).Build(),
+	}, nil)
+
+	resp, err := s.ListRaces(db.ContextWithNow(context.Background(), now), &racing.ListRacesRequest{})
+	if err != nil {
+		t.Fatalf("ListRaces() error = %s", err)
+	}
+
+	for i, want := range []int64{-30, 300} {
+		if got := resp.Races[i].SecondsToStart; got != want {
+			t.Errorf("race %d SecondsToStart = %d, want %d", resp.Races[i].Id, got, want)
+		}
+	}
+}
+
+func TestListRacesCountsDownFromGeneratedAt(t *testing.T) {
+	now := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	s := newTestService(t, WithClock(db.FixedClock(now)))
+
+	s.races.EXPECT().ListPage(gomock.Nil(), db.Audience{}, int64(0), defaultPageSize+1).Return([]*racing.Race{
+		racetest.NewRace().ID(1).StartsAt(now.Add(90 * time.Second)).Build(),
+	}, nil)
+
+	resp, err := s.ListRaces(context.Background(), &racing.ListRacesRequest{})
+	if err != nil {
+		t.Fatalf("ListRaces() error = %s", err)
+	}
+
+	if got := resp.GeneratedAt.AsTime(); !got.Equal(now) {
+		t.Errorf("GeneratedAt = %s, want %s", got, now)
+	}
+
+	if got := resp.Races[0].SecondsToStart; got != 90 {
+		t.Errorf("SecondsToStart = %d, want 90", got)
+	}
+}
+
+func TestListRacesAsOfGeneratedAt(t *testing.T) {
+	s := newTestService(t, WithClock(db.FixedClock(time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC))))
+
+	asOf := time.Date(2021, 2, 1, 9, 0, 0, 0, time.UTC)
+
+	s.races.EXPECT().ListAt(gomock.Nil(), asOf).Return(nil, nil)
+
+	resp, err := s.ListRaces(context.Background(), &racing.ListRacesRequest{AsOf: timestamppb.New(asOf)})
+	if err != nil {
+		t.Fatalf("ListRaces() error = %s", err)
+	}
+
+	if got := resp.GeneratedAt.AsTime(); !got.Equal(asOf) {
+		t.Errorf("GeneratedAt = %s, want as_of %s", got, asOf)
+	}
+}
+
+func TestListRacesPages(t *testing.T) {
+	tests := []struct {
+		name          string
+		req           *racing.ListRacesRequest
+		wantAfter     int64
+		wantPageSize  int
+		listed        int
+		wantRaces     int
+		wantNextToken string
+	}{
+		{"default page size", &racing.ListRacesRequest{}, 0, defaultPageSize, 20, 20, ""},
+		{"last page", &racing.ListRacesRequest{PageSize: 5, PageToken: "10"}, 10, 5, 5, 5, ""},
+		{"more pages", &racing.ListRacesRequest{PageSize: 5, PageToken: "10"}, 10, 5, 6, 5, "15"},
+		{"capped page size", &racing.ListRacesRequest{PageSize: 5000}, 0, maxPageSize, 3, 3, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := newTestService(t)
+
+			var listed []*racing.Race
+			for i := 1; i <= test.listed; i++ {
+				listed = append(listed, racetest.NewRace().ID(test.wantAfter+int64(i)).Number(int64(i)).Build())
+			}
+
+			s.races.EXPECT().ListPage(gomock.Nil(), db.Audience{}, test.wantAfter, test.wantPageSize+1).Return(listed, nil)
+
+			resp, err := s.ListRaces(context.Background(), test.req)
+			if err != nil {
+				t.Fatalf("ListRaces() error = %s", err)
+			}
+
+			if len(resp.Races) != test.wantRaces {
+				t.Errorf("ListRaces() returned %d races, want %d", len(resp.Races), test.wantRaces)
+			}
+
+			if resp.NextPageToken != test.wantNextToken {
+				t.Errorf("next page token = %q, want %q", resp.NextPageToken, test.wantNextToken)
+			}
+
+			if resp.PageSize != int32(test.wantPageSize) {
+				t.Errorf("page size = %d, want %d", resp.PageSize, test.wantPageSize)
+			}
+		})
+	}
+}
+
+func TestListRacesHidesRestrictedRaces(t *testing.T) {
+	s := newTestService(t)
+
+	// Races restricted in the caller's jurisdiction are left out as races are
+	// read, so pages are full.
+	s.races.EXPECT().ListPage(gomock.Nil(), db.Audience{Jurisdiction: "SA"}, int64(0), 3).Return([]*racing.Race{
+		racetest.NewRace().ID(1).Build(),
+		racetest.NewRace().ID(3).Build(),
+		racetest.NewRace().ID(4).Build(),
+	}, nil)
+
+	ctx := db.ContextWithJurisdiction(context.Background(), "SA")
+
+	resp, err := s.ListRaces(ctx, &racing.ListRacesRequest{PageSize: 2})
+	if err != nil {
+		t.Fatalf("ListRaces() error = %s", err)
+	}
+
+	if got, want := raceIDs(resp.Races), []int64{1, 3}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ListRaces() = races %v, want %v", got, want)
+	}
+
+	if resp.NextPageToken != "3" {
+		t.Errorf("next page token = %q, want %q", resp.NextPageToken, "3")
+	}
+}
+
+func TestListRacesAsOfHidesRestrictedRaces(t *testing.T) {
+	s := newTestService(t)
+
+	asOf := time.Date(2021, 2, 1, 9, 0, 0, 0, time.UTC)
+
+	s.races.EXPECT().ListAt(gomock.Nil(), asOf).Return([]*racing.Race{
+		racetest.NewRace().ID(1).Build(),
+		racetest.NewRace().ID(2).Build(),
+		racetest.NewRace().ID(3).Build(),
+	}, nil)
+	s.races.EXPECT().Restricted([]int64{1, 2}, "SA").Return(map[int64]bool{2: true}, nil)
+
+	ctx := db.ContextWithJurisdiction(context.Background(), "SA")
+
+	resp, err := s.ListRaces(ctx, &racing.ListRacesRequest{PageSize: 2, AsOf: timestamppb.New(asOf)})
+	if err != nil {
+		t.Fatalf("ListRaces() error = %s", err)
+	}
+
+	if got := raceIDs(resp.Races); len(got) != 1 || got[0] != 1 {
+		t.Errorf("ListRaces() = races %v, want [1]", got)
+	}
+
+	// Races as they were are restricted once the page is cut, so the next
+	// page starts after the restricted race, rather than listing it.
+	if resp.NextPageToken != "2" {
+		t.Errorf("next page token = %q, want %q", resp.NextPageToken, "2")
+	}
+}
+
+func TestListRacesShowsRacesAsForBrand(t *testing.T) {
+	s := newTestService(t)
+
+	filter := &racing.ListRacesRequestFilter{MeetingIds: []int64{1}, Visibility: racing.ListRacesRequestFilter_VISIBLE_ONLY}
+
+	// Races are shown or hidden for the caller's brand as they're read.
+	s.races.EXPECT().ListPage(filter, db.Audience{Brand: "neds"}, int64(0), defaultPageSize+1).Return([]*racing.Race{
+		racetest.NewRace().ID(1).Build(),
+		racetest.NewRace().ID(2).Build(),
+	}, nil)
+
+	ctx := db.ContextWithBrand(context.Background(), "neds")
+
+	resp, err := s.ListRaces(ctx, &racing.ListRacesRequest{Filter: filter})
+	if err != nil {
+		t.Fatalf("ListRaces() error = %s", err)
+	}
+
+	if got := raceIDs(resp.Races); !reflect.DeepEqual(got, []int64{1, 2}) {
+		t.Errorf("ListRaces() = races %v, want [1 2]", got)
+	}
+}
+
+func TestListRacesAsOfShowsRacesAsForBrand(t *testing.T) {
+	s := newTestService(t)
+
+	asOf := time.Date(2021, 2, 1, 9, 0, 0, 0, time.UTC)
+
+	// Races hidden for every brand can be shown for this one, so visibility
+	// is filtered once the brand's overrides are applied.
+	s.races.EXPECT().ListAt(&racing.ListRacesRequestFilter{MeetingIds: []int64{1}}, asOf).Return([]*racing.Race{
+		racetest.NewRace().ID(1).Build(),
+		racetest.NewRace().ID(2).Hidden().Build(),
+		racetest.NewRace().ID(3).Build(),
+		racetest.NewRace().ID(4).Hidden().Build(),
+	}, nil)
+	s.races.EXPECT().BrandVisibility([]int64{1, 2, 3, 4}, "neds").Return(map[int64]bool{2: true, 3: false}, nil)
+
+	ctx := db.ContextWithBrand(context.Background(), "neds")
+
+	resp, err := s.ListRaces(ctx, &racing.ListRacesRequest{
+		Filter: &racing.ListRacesRequestFilter{MeetingIds: []int64{1}, Visibility: racing.ListRacesRequestFilter_VISIBLE_ONLY},
+		AsOf:   timestamppb.New(asOf),
+	})
+	if err != nil {
+		t.Fatalf("ListRaces() error = %s", err)
+	}
+
+	if got := raceIDs(resp.Races); !reflect.DeepEqual(got, []int64{1, 2}) {
+		t.Errorf("ListRaces() = races %v, want [1 2]", got)
+	}
+
+	for _, race := range resp.Races {
+		if !race.Visible {
+			t.Errorf("race %d isn't visible, want it shown for the brand", race.Id)
+		}
+	}
+}
+
+func TestListRacesByPopularityPages(t *testing.T) {
+	now := time.Date(2021, 3, 1, 12, 30, 0, 0, time.UTC)
+	s := newTestService(t, WithClock(db.FixedClock(now)))
+
+	ranked := []*racing.PopularRace{
+		{Race: racetest.NewRace().ID(3).Build(), Views: 9},
+		{Race: racetest.NewRace().ID(2).Build(), Views: 3},
+		{Race: racetest.NewRace().ID(4).Build(), Views: 3},
+		{Race: racetest.NewRace().ID(1).Build()},
+	}
+
+	// Each page is read a race past its end, from where the last page ended.
+	s.races.EXPECT().ListPopular(gomock.Nil(), db.Audience{}, now.Add(-time.Hour), int64(0), 4).Return(ranked, nil)
+	s.races.EXPECT().ListPopular(gomock.Nil(), db.Audience{}, now.Add(-time.Hour), int64(3), 4).Return(ranked[3:], nil)
+
+	var (
+		got       []int64
+		pageToken string
+	)
+
+	for page := 0; page < 2; page++ {
+		resp, err := s.ListRaces(context.Background(), &racing.ListRacesRequest{
+			OrderBy:   racing.ListRacesRequest_POPULARITY,
+			PageSize:  3,
+			PageToken: pageToken,
+		})
+		if err != nil {
+			t.Fatalf("ListRaces() page %d error = %s", page, err)
+		}
+
+		got = append(got, raceIDs(resp.Races)...)
+		pageToken = resp.NextPageToken
+	}
+
+	if want := []int64{3, 2, 4, 1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ListRaces() = races %v, want %v", got, want)
+	}
+
+	if pageToken != "" {
+		t.Errorf("last page token = %q, want none", pageToken)
+	}
+}
+
+func TestListRacesByPopularityForAudience(t *testing.T) {
+	s := newTestService(t)
+
+	ctx := db.ContextWithBrand(db.ContextWithJurisdiction(context.Background(), "VIC"), "neds")
+
+	// Restricted races and brand visibility are left to the repository, so
+	// pages aren't cut short once read.
+	s.races.EXPECT().ListPopular(gomock.Nil(), db.Audience{Jurisdiction: "VIC", Brand: "neds"}, gomock.Any(), int64(0), gomock.Any()).Return([]*racing.PopularRace{
+		{Race: racetest.NewRace().ID(2).Build(), Views: 1},
+	}, nil)
+
+	resp, err := s.ListRaces(ctx, &racing.ListRacesRequest{OrderBy: racing.ListRacesRequest_POPULARITY})
+	if err != nil {
+		t.Fatalf("ListRaces() error = %s", err)
+	}
+
+	if got := raceIDs(resp.Races); !reflect.DeepEqual(got, []int64{2}) {
+		t.Errorf("ListRaces() = races %v, want [2]", got)
+	}
+}
+
+func TestListPopularRaces(t *testing.T) {
+	now := time.Date(2021, 3, 1, 12, 30, 0, 0, time.UTC)
+	s := newTestService(t, WithClock(db.FixedClock(now)))
+
+	visibleOnly := &racing.ListRacesRequestFilter{Visibility: racing.ListRacesRequestFilter_VISIBLE_ONLY}
+
+	s.races.EXPECT().ListPopular(visibleOnly, db.Audience{}, now.Add(-3*time.Hour), int64(0), 2).Return([]*racing.PopularRace{
+		{Race: racetest.NewRace().ID(2).Build(), Views: 7},
+		{Race: racetest.NewRace().ID(4).Build(), Views: 5},
+	}, nil)
+
+	resp, err := s.ListPopularRaces(context.Background(), &racing.ListPopularRacesRequest{Limit: 2, WindowHours: 3})
+	if err != nil {
+		t.Fatalf("ListPopularRaces() error = %s", err)
+	}
+
+	var got []string
+	for _, race := range resp.Races {
+		got = append(got, fmt.Sprintf("%d:%d", race.Race.Id, race.Views))
+	}
+
+	if want := []string{"2:7", "4:5"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ListPopularRaces() = %v, want %v", got, want)
+	}
+}
+
+func TestListPopularRacesLeavesOutUnviewedRaces(t *testing.T) {
+	s := newTestService(t)
+
+	s.races.EXPECT().ListPopular(gomock.Any(), gomock.Any(), gomock.Any(), int64(0), gomock.Any()).Return([]*racing.PopularRace{
+		{Race: racetest.NewRace().ID(2).Build(), Views: 1},
+		{Race: racetest.NewRace().ID(1).Build()},
+	}, nil)
+
+	resp, err := s.ListPopularRaces(context.Background(), &racing.ListPopularRacesRequest{})
+	if err != nil {
+		t.Fatalf("ListPopularRaces() error = %s", err)
+	}
+
+	if len(resp.Races) != 1 || resp.Races[0].Race.Id != 2 {
+		t.Errorf("ListPopularRaces() = %v, want only race 2", resp.Races)
+	}
+}
+
+func TestGetRaceStats(t *testing.T) {
+	now := time.Date(2021, 3, 1, 12, 30, 0, 0, time.UTC)
+	s := newTestService(t, WithClock(db.FixedClock(now)))
+
+	filter := &racing.ListRacesRequestFilter{MeetingIds: []int64{2}}
+	s.races.EXPECT().Stats(filter).Return(&racing.RaceStats{Total: 3}, nil)
+
+	stats, err := s.GetRaceStats(context.Background(), &racing.GetRaceStatsRequest{Filter: filter})
+	if err != nil {
+		t.Fatalf("GetRaceStats() error = %s", err)
+	}
+
+	if stats.Total != 3 {
+		t.Errorf("GetRaceStats() total = %d, want 3", stats.Total)
+	}
+
+	if got := stats.GeneratedAt.AsTime(); !got.Equal(now) {
+		t.Errorf("GetRaceStats() generated at %s, want %s", got, now)
+	}
+}
+
+func TestGetRaceStatsMissingStartTimeTurnedOff(t *testing.T) {
+	s := newTestService(t)
+
+	ctx := features.NewContext(context.Background(), features.Set{features.MissingStartTimeFilter: false})
+
+	_, err := s.GetRaceStats(ctx, &racing.GetRaceStatsRequest{
+		Filter: &racing.ListRacesRequestFilter{MissingStartTime: true},
+	})
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("GetRaceStats() error = %v, want FailedPrecondition", err)
+	}
+}
+
+func TestListRacesByDay(t *testing.T) {
+	now := time.Date(2021, 3, 1, 9, 30, 0, 0, time.UTC)
+	s := newTestService(t, WithClock(db.FixedClock(now)))
+
+	s.races.EXPECT().ListBetween(nil, db.Audience{}, time.Date(2021, 2, 28, 10, 0, 0, 0, time.UTC), time.Date(2021, 3, 4, 12, 0, 0, 0, time.UTC)).Return([]*racing.Race{
+		// 1am on 1 March in Melbourne.
+		racetest.NewRace().ID(1).Venue("Australia/Melbourne", "AU").StartsAt(time.Date(2021, 2, 28, 14, 0, 0, 0, time.UTC)).Build(),
+		// 10pm on 28 February in Los Angeles.
+		racetest.NewRace().ID(2).Venue("America/Los_Angeles", "US").StartsAt(time.Date(2021, 3, 1, 6, 0, 0, 0, time.UTC)).Build(),
+		// Races at venues in unknown time zones start on their UTC day.
+		racetest.NewRace().ID(3).Venue("Nowhere/Special", "AU").StartsAt(time.Date(2021, 3, 3, 23, 0, 0, 0, time.UTC)).Build(),
+	}, nil)
+
+	resp, err := s.ListRacesByDay(context.Background(), &racing.ListRacesByDayRequest{
+		DateRange: &racing.DateRange{StartDate: "2021-03-01", EndDate: "2021-03-03"},
+	})
+	if err != nil {
+		t.Fatalf("ListRacesByDay() error = %s", err)
+	}
+
+	var got []string
+	for _, day := range resp.Days {
+		got = append(got, fmt.Sprintf("%s:%v", day.Date, raceIDs(day.Races)))
+	}
+
+	if want := []string{"2021-03-01:[1]", "2021-03-02:[]", "2021-03-03:[3]"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ListRacesByDay() = %v, want %v", got, want)
+	}
+
+	if got := resp.Days[0].Races[0].LocalAdvertisedStartTime; got != "2021-03-01T01:00:00+11:00" {
+		t.Errorf("ListRacesByDay() local start time = %q, want it in the venue time zone", got)
+	}
+}
+
+func TestListMeetings(t *testing.T) {
+	s := newTestService(t)
+
+	filter := &racing.ListRacesRequestFilter{Countries: []string{"AU", "NZ"}}
+
+	s.races.EXPECT().ListMeetingPage(filter, db.Audience{}, int64(0), defaultPageSize+1, maxMeetingRaces).Return([]*racing.Race{
+		racetest.NewRace().ID(1).Meeting(7).Number(2).Venue("Pacific/Auckland", "NZ").Build(),
+		racetest.NewRace().ID(2).Meeting(3).Number(1).Venue("Australia/Perth", "AU").Build(),
+		racetest.NewRace().ID(3).Meeting(7).Number(1).Venue("Pacific/Auckland", "NZ").Build(),
+	}, nil)
+
+	resp, err := s.ListMeetings(context.Background(), &racing.ListMeetingsRequest{Filter: filter})
+	if err != nil {
+		t.Fatalf("ListMeetings() error = %s", err)
+	}
+
+	var got []string
+	for _, meeting := range resp.Meetings {
+		got = append(got, fmt.Sprintf("%d:%s:%s:%v", meeting.Id, meeting.Country, meeting.VenueTimeZone, raceIDs(meeting.Races)))
+	}
+
+	// Meetings are in ID order, and their races in number order.
+	if want := []string{"3:AU:Australia/Perth:[2]", "7:NZ:Pacific/Auckland:[3 1]"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ListMeetings() = %v, want %v", got, want)
+	}
+}
+
+func TestListMeetingsPages(t *testing.T) {
+	s := newTestService(t)
+
+	ctx := db.ContextWithJurisdiction(context.Background(), "SA")
+
+	// Each page is read a meeting past its end, from where the last page
+	// ended.
+	s.races.EXPECT().ListMeetingPage(gomock.Nil(), db.Audience{Jurisdiction: "SA"}, int64(0), 3, maxMeetingRaces).Return([]*racing.Race{
+		racetest.NewRace().ID(1).Meeting(2).Build(),
+		racetest.NewRace().ID(2).Meeting(4).Build(),
+		racetest.NewRace().ID(3).Meeting(4).Number(2).Build(),
+		racetest.NewRace().ID(4).Meeting(9).Build(),
+	}, nil)
+	s.races.EXPECT().ListMeetingPage(gomock.Nil(), db.Audience{Jurisdiction: "SA"}, int64(4), 3, maxMeetingRaces).Return([]*racing.Race{
+		racetest.NewRace().ID(4).Meeting(9).Build(),
+	}, nil)
+
+	var (
+		got       []int64
+		pageToken string
+	)
+
+	for page := 0; page < 2; page++ {
+		resp, err := s.ListMeetings(ctx, &racing.ListMeetingsRequest{PageSize: 2, PageToken: pageToken})
+		if err != nil {
+			t.Fatalf("ListMeetings() page %d error = %s", page, err)
+		}
+
+		for _, meeting := range resp.Meetings {
+			got = append(got, meeting.Id)
+		}
+		pageToken = resp.NextPageToken
+	}
+
+	if want := []int64{2, 4, 9}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ListMeetings() = meetings %v, want %v", got, want)
+	}
+
+	if pageToken != "" {
+		t.Errorf("last page token = %q, want none", pageToken)
+	}
+}
+
+func TestListRelatedRaces(t *testing.T) {
+	now := time.Date(2021, 3, 1, 9, 30, 0, 0, time.UTC)
+	s := newTestService(t, WithClock(db.FixedClock(now)))
+
+	at := func(hour, min int) time.Time {
+		return time.Date(2021, 3, 1, hour, min, 0, 0, time.UTC)
+	}
+
+	race := racetest.NewRace().ID(1).Meeting(1).StartsAt(at(10, 0)).Build()
+
+	s.races.EXPECT().ListByIDs([]int64{1}).Return([]*racing.Race{race}, nil)
+	s.races.EXPECT().ListPage(&racing.ListRacesRequestFilter{
+		MeetingIds: []int64{1},
+		Visibility: racing.ListRacesRequestFilter_VISIBLE_ONLY,
+	}, db.Audience{}, int64(0), maxMeetingRaces).Return([]*racing.Race{
+		race,
+		racetest.NewRace().ID(2).Meeting(1).StartsAt(at(12, 0)).Build(),
+		// Races that have started, or aren't open, aren't related.
+		racetest.NewRace().ID(3).Meeting(1).StartsAt(at(9, 0)).Build(),
+		racetest.NewRace().ID(4).Meeting(1).StartsAt(at(11, 0)).Status(racing.Race_CLOSED).Build(),
+	}, nil)
+	s.races.EXPECT().ListBetween(&racing.ListRacesRequestFilter{
+		Visibility: racing.ListRacesRequestFilter_VISIBLE_ONLY,
+	}, db.Audience{}, at(9, 0), at(11, 0)).Return([]*racing.Race{
+		race,
+		racetest.NewRace().ID(5).Meeting(2).StartsAt(at(10, 15)).Build(),
+		racetest.NewRace().ID(6).Meeting(3).StartsAt(at(9, 50)).Build(),
+		racetest.NewRace().ID(7).Meeting(3).StartsAt(at(10, 10)).Build(),
+	}, nil)
+
+	resp, err := s.ListRelatedRaces(context.Background(), &racing.ListRelatedRacesRequest{RaceId: 1, Limit: 3})
+	if err != nil {
+		t.Fatalf("ListRelatedRaces() error = %s", err)
+	}
+
+	// Races at the meeting come first, then the closest to the race's start.
+	if got, want := raceIDs(resp.Races), []int64{2, 6, 7}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ListRelatedRaces() = %v, want %v", got, want)
+	}
+}
+
+func TestListRelatedRacesMissingRace(t *testing.T) {
+	s := newTestService(t)
+
+	s.races.EXPECT().ListByIDs([]int64{99}).Return(nil, nil)
+
+	_, err := s.ListRelatedRaces(context.Background(), &racing.ListRelatedRacesRequest{RaceId: 99})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("ListRelatedRaces() error = %v, want NotFound", err)
+	}
+}
+
+func TestListArchivedRacesPages(t *testing.T) {
+	s := newTestService(t)
+
+	from := time.Date(2021, 2, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	// A race past the page is read, to tell there's another page.
+	s.races.EXPECT().ListArchived(nil, db.Audience{}, from, to, int64(4), 3).Return([]*racing.Race{
+		racetest.NewRace().ID(5).Build(),
+		racetest.NewRace().ID(7).Build(),
+		racetest.NewRace().ID(8).Build(),
+	}, nil)
+
+	resp, err := s.ListArchivedRaces(context.Background(), &racing.ListArchivedRacesRequest{
+		// The range includes its last day.
+		DateRange: &racing.DateRange{StartDate: "2021-02-01", EndDate: "2021-02-28"},
+		PageSize:  2,
+		PageToken: "4",
+	})
+	if err != nil {
+		t.Fatalf("ListArchivedRaces() error = %s", err)
+	}
+
+	if got, want := raceIDs(resp.Races), []int64{5, 7}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ListArchivedRaces() = %v, want %v", got, want)
+	}
+
+	if resp.NextPageToken != "7" {
+		t.Errorf("ListArchivedRaces() next page token = %q, want 7", resp.NextPageToken)
+	}
+}
+
+func TestListRacesMissingStartTimeTurnedOff(t *testing.T) {
+	s := newTestService(t)
+
+	ctx := features.NewContext(context.Background(), features.Set{features.MissingStartTimeFilter: false})
+
+	_, err := s.ListRaces(ctx, &racing.ListRacesRequest{
+		Filter: &racing.ListRacesRequestFilter{MissingStartTime: true},
+	})
+	if code := status.Code(err); code != codes.FailedPrecondition {
+		t.Errorf("ListRaces() code = %s, want %s", code, codes.FailedPrecondition)
+	}
+}
+
+func TestListRacesAsOfPages(t *testing.T) {
+	s := newTestService(t)
+
+	asOf := time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	s.races.EXPECT().ListAt(gomock.Nil(), asOf).Return([]*racing.Race{
+		racetest.NewRace().ID(3).Build(),
+		racetest.NewRace().ID(1).Build(),
+		racetest.NewRace().ID(4).Build(),
+		racetest.NewRace().ID(2).Build(),
+	}, nil)
+
+	resp, err := s.ListRaces(context.Background(), &racing.ListRacesRequest{AsOf: timestamppb.New(asOf), PageSize: 2, PageToken: "1"})
+	if err != nil {
+		t.Fatalf("ListRaces() error = %s", err)
+	}
+
+	if got := raceIDs(resp.Races); len(got) != 2 || got[0] != 2 || got[1] != 3 {
+		t.Errorf("ListRaces() = races %v, want [2 3]", got)
+	}
+
+	if resp.NextPageToken != "3" {
+		t.Errorf("next page token = %q, want %q", resp.NextPageToken, "3")
+	}
+}
+
+func TestListRacesAsOfWithoutEventLog(t *testing.T) {
+	s := newTestService(t)
+
+	asOf := time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	s.races.EXPECT().ListAt(gomock.Nil(), asOf).Return(nil, db.ErrEventLogDisabled)
+
+	_, err := s.ListRaces(context.Background(), &racing.ListRacesRequest{AsOf: timestamppb.New(asOf)})
+	if got := status.Code(err); got != codes.FailedPrecondition {
+		t.Errorf("ListRaces() code = %s, want %s", got, codes.FailedPrecondition)
+	}
+}
+
+func TestListWatchlistWithArchivedRace(t *testing.T) {
+	s := newTestService(t)
+
+	watchedAt := timestamppb.New(time.Date(2021, 3, 1, 9, 0, 0, 0, time.UTC))
+
+	s.notifications.EXPECT().Watchlist("punter").Return([]*racing.WatchedRace{
+		{RaceId: 2, WatchedAt: watchedAt},
+		{RaceId: 1, WatchedAt: watchedAt},
+	}, nil)
+	s.races.EXPECT().ListByIDs([]int64{2, 1}).Return([]*racing.Race{
+		racetest.NewRace().ID(1).Build(),
+	}, nil)
+
+	resp, err := s.ListWatchlist(db.ContextWithUser(context.Background(), "punter"), &racing.ListWatchlistRequest{})
+	if err != nil {
+		t.Fatalf("ListWatchlist() error = %s", err)
+	}
+
+	if len(resp.Races) != 2 {
+		t.Fatalf("ListWatchlist() = %d races, want 2", len(resp.Races))
+	}
+
+	if resp.Races[0].Race != nil {
+		t.Errorf("archived race 2 = %v, want it unset", resp.Races[0].Race)
+	}
+
+	if got := resp.Races[1].Race.GetId(); got != 1 {
+		t.Errorf("race 1 = race %d, want race 1", got)
+	}
+}
+
+func TestWatchMissingRace(t *testing.T) {
+	s := newTestService(t)
+
+	s.races.EXPECT().ListByIDs([]int64{9}).Return(nil, nil)
+
+	_, err := s.WatchRace(db.ContextWithUser(context.Background(), "punter"), &racing.WatchRaceRequest{RaceId: 9})
+	if got := status.Code(err); got != codes.NotFound {
+		t.Errorf("WatchRace() code = %s, want %s", got, codes.NotFound)
+	}
+}
+
+func TestWatchlistNeedsAuthenticatedUser(t *testing.T) {
+	s := newTestService(t)
+
+	// Nothing is read or written for callers that aren't authenticated.
+	tests := []struct {
+		name string
+		call func(ctx context.Context) error
+	}{
+		{"WatchRace", func(ctx context.Context) error {
+			_, err := s.WatchRace(ctx, &racing.WatchRaceRequest{RaceId: 1})
+			return err
+		}},
+		{"UnwatchRace", func(ctx context.Context) error {
+			_, err := s.UnwatchRace(ctx, &racing.UnwatchRaceRequest{RaceId: 1})
+			return err
+		}},
+		{"ListWatchlist", func(ctx context.Context) error {
+			_, err := s.ListWatchlist(ctx, &racing.ListWatchlistRequest{})
+			return err
+		}},
+	}
+
+	for _, tt := range tests {
+		if got := status.Code(tt.call(context.Background())); got != codes.Unauthenticated {
+			t.Errorf("%s() code = %s, want %s", tt.name, got, codes.Unauthenticated)
+		}
+	}
+}
+
+func TestUnwatchRaceForUser(t *testing.T) {
+	s := newTestService(t)
+
+	s.notifications.EXPECT().Unwatch("punter", int64(3)).Return(db.ErrNotFound)
+
+	_, err := s.UnwatchRace(db.ContextWithUser(context.Background(), "punter"), &racing.UnwatchRaceRequest{RaceId: 3})
+	if got := status.Code(err); got != codes.NotFound {
+		t.Errorf("UnwatchRace() code = %s, want %s", got, codes.NotFound)
+	}
+}
+
+func TestUpdateRaceStatusAsUser(t *testing.T) {
+	s := newTestService(t)
+
+	s.races.EXPECT().UpdateStatus(int64(1), racing.Race_CLOSED, "steward").Return(
+		racetest.NewRace().ID(1).Status(racing.Race_CLOSED).Build(), nil,
+	)
+
+	race, err := s.UpdateRaceStatus(db.ContextWithUser(context.Background(), "steward"), &racing.UpdateRaceStatusRequest{RaceId: 1, Status: racing.Race_CLOSED})
+	if err != nil {
+		t.Fatalf("UpdateRaceStatus() error = %s", err)
+	}
+
+	if race.Status != racing.Race_CLOSED {
+		t.Errorf("UpdateRaceStatus() status = %s, want %s", race.Status, racing.Race_CLOSED)
+	}
+}
+
+func TestUpdateRaceStatusNeedsAuthenticatedUser(t *testing.T) {
+	s := newTestService(t)
+
+	// Nothing is changed for callers that aren't authenticated.
+	_, err := s.UpdateRaceStatus(context.Background(), &racing.UpdateRaceStatusRequest{RaceId: 1, Status: racing.Race_CLOSED})
+	if got := status.Code(err); got != codes.Unauthenticated {
+		t.Errorf("UpdateRaceStatus() code = %s, want %s", got, codes.Unauthenticated)
+	}
+}
+
+func TestRegisterDeviceFollowsUsersWatchlist(t *testing.T) {
+	s := newTestService(t)
+
+	s.notifications.EXPECT().RegisterDevice(&racing.Device{
+		Channel: "push",
+		Token:   "device",
+		Filter:  &racing.DeviceFilter{RaceIds: []int64{3}, UserId: "punter"},
+	}, db.Audience{Jurisdiction: "SA", Brand: "neds"}).Return(nil)
+
+	// The device is sent races as they're listed for the user registering it.
+	ctx := db.ContextWithUser(context.Background(), "punter")
+	ctx = db.ContextWithJurisdiction(ctx, "SA")
+	ctx = db.ContextWithBrand(ctx, "neds")
+
+	device, err := s.RegisterDevice(ctx, &racing.RegisterDeviceRequest{Channel: "push", Token: "device", Filter: &racing.DeviceFilter{RaceIds: []int64{3}}, FollowWatchlist: true})
+	if err != nil {
+		t.Fatalf("RegisterDevice() error = %s", err)
+	}
+
+	if device.Filter.UserId != "punter" {
+		t.Errorf("RegisterDevice() user = %q, want %q", device.Filter.UserId, "punter")
+	}
+}
+
+func TestRegisterDeviceFollowingWatchlistNeedsAuthenticatedUser(t *testing.T) {
+	s := newTestService(t)
+
+	// Nothing is registered for callers that aren't authenticated.
+	_, err := s.RegisterDevice(context.Background(), &racing.RegisterDeviceRequest{Channel: "push", Token: "device", FollowWatchlist: true})
+	if got := status.Code(err); got != codes.Unauthenticated {
+		t.Errorf("RegisterDevice() code = %s, want %s", got, codes.Unauthenticated)
+	}
+}
+
+func TestGetMarketNotFound(t *testing.T) {
+	s := newTestService(t)
+
+	s.markets.EXPECT().Get(int64(9)).Return(nil, db.ErrNotFound)
+
+	_, err := s.GetMarket(context.Background(), &racing.GetMarketRequest{Id: 9})
+	if got := status.Code(err); got != codes.NotFound {
+		t.Errorf("GetMarket() code = %s, want %s", got, codes.NotFound)
+	}
+}
+
+func TestSearchRacesWithoutIndex(t *testing.T) {
+	s := newTestService(t)
+
+	_, err := s.SearchRaces(context.Background(), &racing.SearchRacesRequest{Query: "flemington"})
+	if got := status.Code(err); got != codes.FailedPrecondition {
+		t.Errorf("SearchRaces() code = %s, want %s", got, codes.FailedPrecondition)
+	}
+}
+
+func TestGetReconciliationReportBeforeAnyRun(t *testing.T) {
+	s := newTestService(t)
+
+	s.reconciliation.EXPECT().LatestReport().Return(nil, db.ErrNotFound)
+
+	_, err := s.GetReconciliationReport(context.Background(), &racing.GetReconciliationReportRequest{})
+	if got := status.Code(err); got != codes.NotFound {
+		t.Errorf("GetReconciliationReport() code = %s, want %s", got, codes.NotFound)
+	}
+}
@@ -0,0 +1,98 @@
+package webhooks
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+// ErrNonPublicAddress is returned connecting to a callback URL at an address
+// that isn't public, e.g. one on the notifier's own network.
+var ErrNonPublicAddress = errors.New("callback address isn't public")
+
+// nonPublicNetworks are the networks callbacks can't be delivered to:
+// unspecified, private, shared, loopback, link-local, multicast and reserved
+// addresses.
+var nonPublicNetworks = parseCIDRs(
+	"0.0.0.0/8",
+	"10.0.0.0/8",
+	"100.64.0.0/10",
+	"127.0.0.0/8",
+	"169.254.0.0/16",
+	"172.16.0.0/12",
+	"192.168.0.0/16",
+	"224.0.0.0/4",
+	"240.0.0.0/4",
+	"::/128",
+	"::1/128",
+	"fc00::/7",
+	"fe80::/10",
+	"ff00::/8",
+)
+
+// NewClient creates an HTTP client to deliver to callback URLs with. Anyone
+// can subscribe a callback URL, so the client refuses to connect to
+// addresses that aren't public. Addresses are checked as they're dialed,
+// after the callback's host is resolved, so a host can't pass the check then
+// resolve to another address, and redirects are checked too.
+func NewClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout:   10 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+
+			if ip := net.ParseIP(host); ip == nil || !isPublic(ip) {
+				return fmt.Errorf("connecting to %s: %w", address, ErrNonPublicAddress)
+			}
+
+			return nil
+		},
+	}
+
+	return &http.Client{
+		// There's no proxy, which would connect to callbacks unchecked.
+		Transport: &http.Transport{
+			DialContext:           dialer.DialContext,
+			ForceAttemptHTTP2:     true,
+			MaxIdleConns:          100,
+			IdleConnTimeout:       90 * time.Second,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ExpectContinueTimeout: time.Second,
+		},
+	}
+}
+
+// isPublic reports whether an IP address is on none of the non-public
+// networks. IPv4 addresses mapped to IPv6 are checked as IPv4.
+func isPublic(ip net.IP) bool {
+	for _, network := range nonPublicNetworks {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// parseCIDRs parses networks in CIDR notation, panicking if one is invalid.
+func parseCIDRs(cidrs ...string) []*net.IPNet {
+	networks := make([]*net.IPNet, len(cidrs))
+
+	for i, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+
+		networks[i] = network
+	}
+
+	return networks
+}
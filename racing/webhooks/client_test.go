@@ -0,0 +1,57 @@
+package webhooks
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestIsPublic(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1:248:1893:25c8:1946", true},
+		{"0.0.0.0", false},
+		{"127.0.0.1", false},
+		{"10.1.2.3", false},
+		{"172.20.0.1", false},
+		{"192.168.1.1", false},
+		{"100.64.0.1", false},
+		{"169.254.169.254", false},
+		{"224.0.0.1", false},
+		{"::", false},
+		{"::1", false},
+		{"fd00::1", false},
+		{"fe80::1", false},
+		{"::ffff:127.0.0.1", false},
+		{"::ffff:169.254.169.254", false},
+	}
+
+	for _, tt := range tests {
+		if got := isPublic(net.ParseIP(tt.ip)); got != tt.want {
+			t.Errorf("isPublic(%s) = %t, want %t", tt.ip, got, tt.want)
+		}
+	}
+}
+
+func TestNewClientRefusesLoopback(t *testing.T) {
+	var requested bool
+
+	server := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		requested = true
+	}))
+	t.Cleanup(server.Close)
+
+	_, err := NewClient().Post(server.URL, "application/json", nil)
+	if !errors.Is(err, ErrNonPublicAddress) {
+		t.Errorf("Post() error = %v, want %v", err, ErrNonPublicAddress)
+	}
+
+	if requested {
+		t.Error("Post() reached the loopback server")
+	}
+}
@@ -0,0 +1,277 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"expvar"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"git.neds.sh/matty/entain/racing/db"
+	"git.neds.sh/matty/entain/racing/proto/racing"
+	"github.com/golang/protobuf/ptypes"
+)
+
+const (
+	// batchSize is how many changes or deliveries are handled at a time.
+	batchSize = 100
+	// maxAttempts is how many times a delivery is attempted before it fails.
+	maxAttempts = 8
+	// initialBackoff is how long to wait before retrying a delivery the first
+	// time, doubling with each attempt after.
+	initialBackoff = 10 * time.Second
+	// attemptTimeout is how long a callback has to accept a delivery before
+	// the attempt fails.
+	attemptTimeout = 10 * time.Second
+	// deliveryWorkers is how many subscriptions are delivered to at once.
+	deliveryWorkers = 8
+
+	// SignatureHeader is the header holding the hex HMAC-SHA256 of the body,
+	// keyed by the subscription's secret.
+	SignatureHeader = "X-Racing-Signature"
+	// DeliveryHeader is the header holding the delivery's ID, for receivers
+	// to dedupe retried deliveries by.
+	DeliveryHeader = "X-Racing-Delivery"
+)
+
+// deliveryAttempts counts delivery attempts by their outcome.
+var deliveryAttempts = expvar.NewMap("webhook_delivery_attempts_total")
+
+// Notifier matches race changes against subscriptions, and delivers them to
+// their callback URLs.
+type Notifier struct {
+	racesRepo         db.RacesRepo
+	subscriptionsRepo db.SubscriptionsRepo
+	client            *http.Client
+}
+
+// NewNotifier creates a new notifier.
+func NewNotifier(racesRepo db.RacesRepo, subscriptionsRepo db.SubscriptionsRepo, client *http.Client) *Notifier {
+	return &Notifier{
+		racesRepo:         racesRepo,
+		subscriptionsRepo: subscriptionsRepo,
+		client:            client,
+	}
+}
+
+// Notify matches new race changes to subscriptions, then attempts every
+// delivery that's due.
+func (n *Notifier) Notify(ctx context.Context) error {
+	if err := n.Match(); err != nil {
+		return err
+	}
+
+	return n.Deliver(ctx)
+}
+
+// Match queues a delivery to each subscription for every race change it
+// hasn't yet been matched against that passes its filter.
+func (n *Notifier) Match() error {
+	subscribers, err := n.subscriptionsRepo.List()
+	if err != nil {
+		return err
+	}
+
+	for _, subscriber := range subscribers {
+		for {
+			changes, err := n.racesRepo.Changes(subscriber.LastChangeID, batchSize)
+			if err != nil {
+				return err
+			}
+
+			if len(changes) == 0 {
+				break
+			}
+
+			var matched []*racing.RaceChange
+
+			for _, change := range changes {
+				if matches(subscriber.Subscription.Filter, change) {
+					matched = append(matched, change)
+				}
+			}
+
+			lastChangeID, err := strconv.ParseInt(changes[len(changes)-1].Token, 10, 64)
+			if err != nil {
+				return err
+			}
+
+			if err := n.subscriptionsRepo.Enqueue(subscriber.Subscription.Id, matched, lastChangeID); err != nil {
+				return err
+			}
+
+			subscriber.LastChangeID = lastChangeID
+
+			if len(changes) < batchSize {
+				break
+			}
+		}
+	}
+
+	return nil
+}
+
+// Deliver attempts every delivery that's due. Up to deliveryWorkers
+// subscriptions are delivered to at once, each in order, so a slow callback
+// only holds up its own deliveries.
+func (n *Notifier) Deliver(ctx context.Context) error {
+	for {
+		due, err := n.subscriptionsRepo.Due(time.Now(), batchSize)
+		if err != nil {
+			return err
+		}
+
+		if err := n.deliverBatch(ctx, due); err != nil {
+			return err
+		}
+
+		if len(due) < batchSize {
+			return nil
+		}
+	}
+}
+
+// deliverBatch attempts a batch of deliveries, returning the first error
+// recording an attempt.
+func (n *Notifier) deliverBatch(ctx context.Context, due []*db.DueDelivery) error {
+	var subscriptions [][]*db.DueDelivery
+
+	bySubscription := make(map[int64]int)
+	for _, delivery := range due {
+		i, ok := bySubscription[delivery.SubscriptionID]
+		if !ok {
+			i = len(subscriptions)
+			bySubscription[delivery.SubscriptionID] = i
+			subscriptions = append(subscriptions, nil)
+		}
+
+		subscriptions[i] = append(subscriptions[i], delivery)
+	}
+
+	var (
+		wg       sync.WaitGroup
+		errs     = make(chan error, len(subscriptions))
+		inFlight = make(chan struct{}, deliveryWorkers)
+	)
+
+	for _, deliveries := range subscriptions {
+		inFlight <- struct{}{}
+		wg.Add(1)
+
+		go func(deliveries []*db.DueDelivery) {
+			defer wg.Done()
+			defer func() { <-inFlight }()
+
+			for _, delivery := range deliveries {
+				if err := n.attempt(ctx, delivery); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}(deliveries)
+	}
+
+	wg.Wait()
+	close(errs)
+
+	return <-errs
+}
+
+// attempt POSTs a delivery to its subscription's callback URL, recording the
+// outcome. A delivery that fails is retried with exponential backoff, until it
+// has been attempted too many times.
+func (n *Notifier) attempt(ctx context.Context, due *db.DueDelivery) error {
+	now := time.Now()
+
+	lastAttemptAt, err := ptypes.TimestampProto(now)
+	if err != nil {
+		return err
+	}
+
+	delivery := &racing.Delivery{
+		Id:            due.ID,
+		Attempts:      due.Attempts + 1,
+		LastAttemptAt: lastAttemptAt,
+		Status:        racing.Delivery_DELIVERED,
+	}
+
+	code, err := n.post(ctx, due)
+	delivery.LastResponseCode = int32(code)
+
+	if err != nil {
+		delivery.LastError = err.Error()
+		delivery.Status = racing.Delivery_PENDING
+
+		if delivery.Attempts >= maxAttempts {
+			delivery.Status = racing.Delivery_FAILED
+		}
+	}
+
+	deliveryAttempts.Add(delivery.Status.String(), 1)
+
+	backoff := initialBackoff << (delivery.Attempts - 1)
+
+	return n.subscriptionsRepo.RecordAttempt(delivery, now.Add(backoff))
+}
+
+// post sends a delivery, returning the response status code if there was a
+// response, and an error unless it was accepted.
+func (n *Notifier) post(ctx context.Context, due *db.DueDelivery) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, attemptTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, due.CallbackURL, bytes.NewReader(due.Payload))
+	if err != nil {
+		return 0, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(DeliveryHeader, strconv.FormatInt(due.ID, 10))
+	req.Header.Set(SignatureHeader, Sign(due.Secret, due.Payload))
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	// Drain the body, so the connection can be reused.
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected response: %s", resp.Status)
+	}
+
+	return resp.StatusCode, nil
+}
+
+// Sign returns the signature of a payload, as sent in the SignatureHeader.
+func Sign(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// matches reports whether a change passes a subscription's filter.
+func matches(filter *racing.SubscriptionFilter, change *racing.RaceChange) bool {
+	if len(filter.GetMeetingIds()) == 0 {
+		return true
+	}
+
+	for _, meetingID := range filter.MeetingIds {
+		if change.MeetingId == meetingID {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,374 @@
+package webhooks
+
+import (
+	"context"
+	"database/sql"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"git.neds.sh/matty/entain/racing/db"
+	"git.neds.sh/matty/entain/racing/proto/racing"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// testRepos are the repositories a notifier reads, on an empty in-memory
+// database.
+type testRepos struct {
+	races         db.RacesRepo
+	subscriptions db.SubscriptionsRepo
+}
+
+func newTestRepos(t *testing.T) *testRepos {
+	t.Helper()
+
+	testDB, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("opening test database: %s", err)
+	}
+
+	// Every connection to :memory: gets its own database, so share one.
+	testDB.SetMaxOpenConns(1)
+
+	t.Cleanup(func() { testDB.Close() })
+
+	repos := &testRepos{
+		races:         db.NewRacesRepo(testDB, db.WithoutDummyData()),
+		subscriptions: db.NewSubscriptionsRepo(testDB),
+	}
+
+	if err := repos.races.Init(); err != nil {
+		t.Fatalf("initialising races repo: %s", err)
+	}
+
+	if err := repos.subscriptions.Init(); err != nil {
+		t.Fatalf("initialising subscriptions repo: %s", err)
+	}
+
+	return repos
+}
+
+// upsertRace upserts a race numbered within its meeting, returning its ID.
+func (r *testRepos) upsertRace(t *testing.T, meetingID, number int64) int64 {
+	t.Helper()
+
+	race := &racing.Race{
+		MeetingId:           meetingID,
+		Number:              number,
+		Visible:             true,
+		AdvertisedStartTime: timestamppb.Now(),
+		ExternalRef:         &racing.ExternalRef{Source: "test", SourceId: strconv.FormatInt(meetingID*100+number, 10)},
+	}
+
+	if _, err := r.races.Upsert(race); err != nil {
+		t.Fatalf("Upsert() error = %s", err)
+	}
+
+	return race.Id
+}
+
+// deliveredRaceIDs returns the races of a subscription's deliveries, oldest
+// first.
+func (r *testRepos) deliveredRaceIDs(t *testing.T, subscriptionID int64) []int64 {
+	t.Helper()
+
+	deliveries, err := r.subscriptions.ListDeliveries(subscriptionID)
+	if err != nil {
+		t.Fatalf("ListDeliveries(%d) error = %s", subscriptionID, err)
+	}
+
+	var ids []int64
+	for _, delivery := range deliveries {
+		ids = append(ids, delivery.RaceId)
+	}
+
+	return ids
+}
+
+func TestNotifierMatch(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter *racing.SubscriptionFilter
+		// wantRaces are the numbers of the races delivered, out of races 1
+		// and 3 at meeting 5 and race 2 at meeting 6.
+		wantRaces []int64
+	}{
+		{"no filter", nil, []int64{1, 2, 3}},
+		{"empty filter", &racing.SubscriptionFilter{}, []int64{1, 2, 3}},
+		{"meeting", &racing.SubscriptionFilter{MeetingIds: []int64{5}}, []int64{1, 3}},
+		{"meetings", &racing.SubscriptionFilter{MeetingIds: []int64{6, 5}}, []int64{1, 2, 3}},
+		{"other meeting", &racing.SubscriptionFilter{MeetingIds: []int64{7}}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repos := newTestRepos(t)
+
+			// Races changed before subscribing aren't delivered.
+			repos.upsertRace(t, 5, 10)
+
+			sub := &racing.Subscription{CallbackUrl: "https://example.com/hook", Filter: tt.filter, Secret: "secret"}
+			if err := repos.subscriptions.Create(sub); err != nil {
+				t.Fatalf("Create() error = %s", err)
+			}
+
+			ids := map[int64]int64{
+				repos.upsertRace(t, 5, 1): 1,
+				repos.upsertRace(t, 6, 2): 2,
+				repos.upsertRace(t, 5, 3): 3,
+			}
+
+			notifier := NewNotifier(repos.races, repos.subscriptions, http.DefaultClient)
+
+			// Matching again finds nothing new.
+			for i := 0; i < 2; i++ {
+				if err := notifier.Match(); err != nil {
+					t.Fatalf("Match() error = %s", err)
+				}
+			}
+
+			var got []int64
+			for _, id := range repos.deliveredRaceIDs(t, sub.Id) {
+				got = append(got, ids[id])
+			}
+
+			if !reflect.DeepEqual(got, tt.wantRaces) {
+				t.Errorf("delivered races %v, want %v", got, tt.wantRaces)
+			}
+		})
+	}
+}
+
+func TestNotifierMatchPagesThroughChanges(t *testing.T) {
+	repos := newTestRepos(t)
+
+	sub := &racing.Subscription{CallbackUrl: "https://example.com/hook", Secret: "secret"}
+	if err := repos.subscriptions.Create(sub); err != nil {
+		t.Fatalf("Create() error = %s", err)
+	}
+
+	for i := int64(1); i <= batchSize+1; i++ {
+		repos.upsertRace(t, 1, i)
+	}
+
+	if err := NewNotifier(repos.races, repos.subscriptions, http.DefaultClient).Match(); err != nil {
+		t.Fatalf("Match() error = %s", err)
+	}
+
+	if got := len(repos.deliveredRaceIDs(t, sub.Id)); got != batchSize+1 {
+		t.Errorf("queued %d deliveries, want %d", got, batchSize+1)
+	}
+}
+
+func TestNotifierMatchAndDeliver(t *testing.T) {
+	tests := []struct {
+		name         string
+		code         int
+		wantStatus   racing.Delivery_Status
+		wantAttempts int32
+	}{
+		{"accepted", http.StatusNoContent, racing.Delivery_DELIVERED, 1},
+		{"rejected", http.StatusInternalServerError, racing.Delivery_PENDING, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repos := newTestRepos(t)
+
+			var posts int
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				posts++
+
+				body, _ := ioutil.ReadAll(r.Body)
+
+				if got, want := r.Header.Get(SignatureHeader), Sign("secret", body); got != want {
+					t.Errorf("signature = %q, want %q", got, want)
+				}
+
+				if r.Header.Get(DeliveryHeader) == "" {
+					t.Errorf("no %s header", DeliveryHeader)
+				}
+
+				w.WriteHeader(tt.code)
+			}))
+			t.Cleanup(server.Close)
+
+			sub := &racing.Subscription{CallbackUrl: server.URL, Secret: "secret"}
+			if err := repos.subscriptions.Create(sub); err != nil {
+				t.Fatalf("Create() error = %s", err)
+			}
+
+			repos.upsertRace(t, 1, 1)
+
+			notifier := NewNotifier(repos.races, repos.subscriptions, server.Client())
+
+			// A delivery that failed isn't retried until it has backed off.
+			if err := notifier.Match(); err != nil {
+				t.Fatalf("Match() error = %s", err)
+			}
+
+			for i := 0; i < 2; i++ {
+				if err := notifier.Deliver(context.Background()); err != nil {
+					t.Fatalf("Deliver() error = %s", err)
+				}
+			}
+
+			if posts != 1 {
+				t.Errorf("posted %d times, want once", posts)
+			}
+
+			deliveries, err := repos.subscriptions.ListDeliveries(sub.Id)
+			if err != nil {
+				t.Fatalf("ListDeliveries() error = %s", err)
+			}
+
+			if len(deliveries) != 1 {
+				t.Fatalf("ListDeliveries() = %d deliveries, want 1", len(deliveries))
+			}
+
+			if got := deliveries[0]; got.Status != tt.wantStatus || got.Attempts != tt.wantAttempts || got.LastResponseCode != int32(tt.code) {
+				t.Errorf("delivery %s after %d attempts with %d, want %s after %d with %d",
+					got.Status, got.Attempts, got.LastResponseCode, tt.wantStatus, tt.wantAttempts, tt.code)
+			}
+		})
+	}
+}
+
+// attemptRecorder wraps a subscriptions repository, handing out the given
+// due deliveries and recording when each attempt is next due.
+type attemptRecorder struct {
+	db.SubscriptionsRepo
+	mu       sync.Mutex
+	due      []*db.DueDelivery
+	attempts []*racing.Delivery
+	backoffs []time.Duration
+}
+
+func (r *attemptRecorder) Due(time.Time, int) ([]*db.DueDelivery, error) {
+	due := r.due
+	r.due = nil
+
+	return due, nil
+}
+
+func (r *attemptRecorder) RecordAttempt(delivery *racing.Delivery, nextAttemptAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.attempts = append(r.attempts, delivery)
+	r.backoffs = append(r.backoffs, nextAttemptAt.Sub(delivery.LastAttemptAt.AsTime()))
+
+	return nil
+}
+
+func TestNotifierDeliverRetriesWithBackoff(t *testing.T) {
+	tests := []struct {
+		name string
+		code int
+		// attempts is how many times the delivery was attempted before.
+		attempts     int32
+		wantStatus   racing.Delivery_Status
+		wantAttempts int32
+		wantBackoff  time.Duration
+	}{
+		{"accepted", http.StatusOK, 0, racing.Delivery_DELIVERED, 1, initialBackoff},
+		{"first failure", http.StatusInternalServerError, 0, racing.Delivery_PENDING, 1, initialBackoff},
+		{"second failure", http.StatusBadGateway, 1, racing.Delivery_PENDING, 2, 2 * initialBackoff},
+		{"third failure", http.StatusNotFound, 2, racing.Delivery_PENDING, 3, 4 * initialBackoff},
+		{"last failure", http.StatusInternalServerError, maxAttempts - 1, racing.Delivery_FAILED, maxAttempts, initialBackoff << (maxAttempts - 1)},
+		{"accepted on last attempt", http.StatusOK, maxAttempts - 1, racing.Delivery_DELIVERED, maxAttempts, initialBackoff << (maxAttempts - 1)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.code)
+			}))
+			t.Cleanup(server.Close)
+
+			recorder := &attemptRecorder{due: []*db.DueDelivery{
+				{ID: 7, SubscriptionID: 1, Attempts: tt.attempts, Payload: []byte(`{}`), CallbackURL: server.URL, Secret: "secret"},
+			}}
+
+			if err := NewNotifier(nil, recorder, server.Client()).Deliver(context.Background()); err != nil {
+				t.Fatalf("Deliver() error = %s", err)
+			}
+
+			if len(recorder.attempts) != 1 {
+				t.Fatalf("recorded %d attempts, want 1", len(recorder.attempts))
+			}
+
+			delivery := recorder.attempts[0]
+
+			if delivery.Id != 7 || delivery.Status != tt.wantStatus || delivery.Attempts != tt.wantAttempts {
+				t.Errorf("delivery %d %s after %d attempts, want 7 %s after %d",
+					delivery.Id, delivery.Status, delivery.Attempts, tt.wantStatus, tt.wantAttempts)
+			}
+
+			if failed := delivery.LastError != ""; failed == (tt.wantStatus == racing.Delivery_DELIVERED) {
+				t.Errorf("last error = %q, want error %t", delivery.LastError, !failed)
+			}
+
+			if backoff := recorder.backoffs[0]; backoff != tt.wantBackoff {
+				t.Errorf("backoff = %s, want %s", backoff, tt.wantBackoff)
+			}
+		})
+	}
+}
+
+func TestNotifierDeliverUnreachable(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+
+	recorder := &attemptRecorder{due: []*db.DueDelivery{{ID: 1, CallbackURL: server.URL}}}
+
+	if err := NewNotifier(nil, recorder, server.Client()).Deliver(context.Background()); err != nil {
+		t.Fatalf("Deliver() error = %s", err)
+	}
+
+	if delivery := recorder.attempts[0]; delivery.Status != racing.Delivery_PENDING || delivery.LastResponseCode != 0 || delivery.LastError == "" {
+		t.Errorf("delivery %s with code %d and error %q, want PENDING without a response",
+			delivery.Status, delivery.LastResponseCode, delivery.LastError)
+	}
+}
+
+func TestNotifierDeliverToSubscriptionsAtOnce(t *testing.T) {
+	// The slow callback only accepts its delivery once the other callback
+	// has had its own.
+	other := make(chan struct{})
+
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-other:
+		case <-time.After(5 * time.Second):
+			w.WriteHeader(http.StatusGatewayTimeout)
+		}
+	}))
+	t.Cleanup(slow.Close)
+
+	fast := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(other)
+	}))
+	t.Cleanup(fast.Close)
+
+	recorder := &attemptRecorder{due: []*db.DueDelivery{
+		{ID: 1, SubscriptionID: 1, CallbackURL: slow.URL},
+		{ID: 2, SubscriptionID: 2, CallbackURL: fast.URL},
+	}}
+
+	if err := NewNotifier(nil, recorder, http.DefaultClient).Deliver(context.Background()); err != nil {
+		t.Fatalf("Deliver() error = %s", err)
+	}
+
+	for _, delivery := range recorder.attempts {
+		if delivery.Status != racing.Delivery_DELIVERED {
+			t.Errorf("delivery %d %s with %d, want DELIVERED", delivery.Id, delivery.Status, delivery.LastResponseCode)
+		}
+	}
+}